@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/serroba/features/internal/cli"
+)
+
+func main() {
+	if err := cli.New().Execute(); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "Error:", err)
+
+		os.Exit(1)
+	}
+}
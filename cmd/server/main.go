@@ -3,10 +3,15 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/danielgtaylor/huma/v2"
@@ -14,28 +19,62 @@ import (
 	"github.com/danielgtaylor/huma/v2/humacli"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/serroba/features/internal/auth"
 	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/redis"
+	"github.com/serroba/features/internal/flags/sqlite"
+	"github.com/serroba/features/internal/flagsfile"
 	"github.com/serroba/features/internal/handler"
+	"github.com/serroba/features/internal/metrics"
+	"github.com/serroba/features/internal/rbac"
+	"github.com/serroba/features/internal/tracing"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 type Options struct {
-	Port int `default:"8080" help:"Port to listen on"`
+	Port        int           `default:"8080"                   doc:"Port to listen on"`
+	AuditLog    string        `default:""                       doc:"Audit log file (memory if empty)"`
+	Storage     string        `default:"memory"                 doc:"memory, file, sqlite or redis"`
+	DataDir     string        `default:"data"                   doc:"Data directory for file storage"`
+	SQLitePath  string        `default:"features.db"            doc:"Database file for sqlite storage" name:"sqlite-path"`
+	RedisURL    string        `default:"redis://localhost:6379" doc:"Redis URL for redis storage"      name:"redis-url"`
+	CacheSize   int           `default:"10000"                  doc:"Read cache size, 0 disables"`
+	CacheTTL    time.Duration `default:"30s"                    doc:"Read cache TTL, 0 never expires"  name:"cache-ttl"`
+	FlagsFile   string        `default:""                       doc:"Flags file or directory to sync"`
+	FlagsWatch  time.Duration `default:"0s"                     doc:"Re-sync interval, 0 syncs once"`
+	FlagsDryRun bool          `default:"false"                  doc:"Log flags file changes only"`
+	KeysFile    string        `default:""                       doc:"API keys file; enables auth"`
+	AdminKey    string        `default:""                       doc:"Admin key for bootstrapping auth"`
+	Environment string        `default:""                       doc:"Environment name for API keys"`
+	RBACFile    string        `default:""                       doc:"Role bindings file; enables RBAC" name:"rbac-file"`
+	JWKS        string        `default:""                       doc:"JWKS file or URL; enables JWTs"   name:"jwks"`
+	JWTIssuer   string        `default:""                       doc:"Required JWT issuer"              name:"jwt-issuer"`
+	JWTAudience string        `default:""                       doc:"Required JWT audience"            name:"jwt-audience"`
+	JWTUser     string        `default:"sub"                    doc:"JWT claim naming the user"        name:"jwt-user"`
+	JWTRoles    string        `default:"roles"                  doc:"JWT claim listing roles"          name:"jwt-roles"`
+	ProtectAll  bool          `default:"false"                  doc:"Require approval for all flags"   name:"protect-all"`
+	Approvals   int           `default:"1"                      doc:"Approvals per change request"`
+	Schedules   string        `default:""                       doc:"Schedules file (memory if empty)"`
+	Scheduler   time.Duration `default:"10s"                    doc:"Interval to run schedules/ramps"`
+	Ramps       string        `default:""                       doc:"Ramps file (memory if empty)"`
+	Metrics     bool          `default:"true"                   doc:"Serve Prometheus /metrics"`
+	MetricFlags string        `default:""                       doc:"Flag keys labeled in metrics"     name:"metric-flags"`
+	Tracing     string        `default:""                       doc:"Trace exporter: stdout or otlp"`
+	Usage       string        `default:""                       doc:"Usage file (storage if empty)"`
+	UsageFlush  time.Duration `default:"1m"                     doc:"Interval to flush usage"`
 }
 
 func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 
 	cli := humacli.New(func(hooks humacli.Hooks, options *Options) {
-		router := chi.NewRouter()
-		router.Use(middleware.Logger)
-		router.Use(middleware.Recoverer)
-
-		api := humachi.New(router, huma.DefaultConfig("Feature Flags API", "1.0.0"))
-
-		repo := flags.NewMemoryRepository()
-		service := flags.NewService(repo)
-		h := handler.New(service)
-		h.Register(api)
+		router, closers, err := newApp(options, logger)
+		if err != nil {
+			logger.Error("failed to initialize service", slog.Any("error", err))
+			os.Exit(1)
+		}
 
 		var server *http.Server
 
@@ -66,9 +105,601 @@ func main() {
 				}
 			}
 
+			for _, closer := range closers {
+				if err := closer.Close(); err != nil {
+					logger.Error("failed to close storage", slog.Any("error", err))
+				}
+			}
+
 			logger.Info("shutdown complete")
 		})
 	})
 
 	cli.Run()
 }
+
+// newApp wires the API to the flags service. The returned closers must be
+// closed in order on shutdown.
+func newApp(options *Options, logger *slog.Logger) (*chi.Mux, []io.Closer, error) {
+	policy, err := newPolicy(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tel, stopTracing, err := newTelemetry(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	router, api, err := newAPI(options, policy, logger, tel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	service, closers, err := newService(options, policy, logger, tel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Spans are flushed last, after everything that could still record one.
+	closers = append(closers, stopTracing)
+
+	handler.New(service).Register(api)
+	handler.NewScheduleHandler(service).Register(api)
+	handler.NewRampHandler(service).Register(api)
+	handler.NewSimulationHandler(service).Register(api)
+	handler.NewUsageHandler(service).Register(api)
+	handler.NewReportHandler(service).Register(api)
+
+	return router, closers, nil
+}
+
+func newService(
+	options *Options, policy *rbac.Policy, logger *slog.Logger, tel telemetry,
+) (*flags.Service, []io.Closer, error) {
+	repo, storageOpts, repoClosers, err := newRepository(options, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	repo, serviceOpts := instrument(options, tel, repo)
+	serviceOpts = append(serviceOpts, storageOpts...)
+
+	storeOpts, stores, err := fileStores(options)
+	if err != nil {
+		closeAll(repoClosers)
+
+		return nil, nil, err
+	}
+
+	serviceOpts = append(serviceOpts, storeOpts...)
+
+	if policy != nil {
+		serviceOpts = append(serviceOpts, flags.WithAuthorizer(policy))
+	}
+
+	serviceOpts = append(serviceOpts, flags.WithApprovalPolicy(flags.ApprovalPolicy{
+		ProtectAll: options.ProtectAll,
+		Approvals:  options.Approvals,
+	}))
+
+	service := flags.NewService(repo, serviceOpts...)
+
+	stopSync, err := startFlagsSync(service, options, logger)
+	if err != nil {
+		closeAll(append(stores, repoClosers...))
+
+		return nil, nil, fmt.Errorf("sync flags file: %w", err)
+	}
+
+	// The background workers stop first, so their last writes (such as the
+	// final usage flush) reach the stores, which close before the repository.
+	closers := []io.Closer{stopSync, startScheduler(service, options, logger), startUsageFlush(service, options, logger)}
+	closers = append(closers, stores...)
+
+	return service, append(closers, repoClosers...), nil
+}
+
+// fileStores opens the file-backed audit log and stores of scheduled changes,
+// ramps and usage that are configured, and returns the service options using
+// them and their closers.
+func fileStores(options *Options) ([]flags.Option, []io.Closer, error) {
+	var (
+		opts    []flags.Option
+		closers []io.Closer
+	)
+
+	fail := func(err error) ([]flags.Option, []io.Closer, error) {
+		closeAll(closers)
+
+		return nil, nil, err
+	}
+
+	if options.AuditLog != "" {
+		auditStore, err := flags.OpenFileAuditStore(options.AuditLog)
+		if err != nil {
+			return fail(err)
+		}
+
+		opts = append(opts, flags.WithAuditStore(auditStore))
+		closers = append(closers, auditStore)
+	}
+
+	if options.Schedules != "" {
+		scheduleStore, err := flags.OpenFileScheduleStore(options.Schedules)
+		if err != nil {
+			return fail(err)
+		}
+
+		opts = append(opts, flags.WithScheduleStore(scheduleStore))
+		closers = append(closers, scheduleStore)
+	}
+
+	if options.Ramps != "" {
+		rampStore, err := flags.OpenFileRampStore(options.Ramps)
+		if err != nil {
+			return fail(err)
+		}
+
+		opts = append(opts, flags.WithRampStore(rampStore))
+		closers = append(closers, rampStore)
+	}
+
+	if options.Usage != "" {
+		usageStore, err := flags.OpenFileUsageStore(options.Usage)
+		if err != nil {
+			return fail(err)
+		}
+
+		opts = append(opts, flags.WithUsageStore(usageStore))
+		closers = append(closers, usageStore)
+	}
+
+	return opts, closers, nil
+}
+
+func newAPI(
+	options *Options, policy *rbac.Policy, logger *slog.Logger, tel telemetry,
+) (*chi.Mux, huma.API, error) {
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(middleware.Logger)
+	router.Use(middleware.Recoverer)
+	router.Use(handler.RequestContext)
+
+	config := huma.DefaultConfig("Feature Flags API", "1.0.0")
+	config.Components.SecuritySchemes = handler.SecuritySchemes()
+	api := humachi.New(router, config)
+	api.UseMiddleware(handler.Trace(tel.tracer))
+
+	if options.Metrics {
+		router.Method(http.MethodGet, "/metrics", tel.registry)
+		api.UseMiddleware(handler.Instrument(metrics.NewHTTP(tel.registry)))
+	}
+
+	if err := setupAuth(api, options, policy, logger); err != nil {
+		return nil, nil, err
+	}
+
+	return router, api, nil
+}
+
+// setupAuth requires an API key or a JWT on every operation and registers
+// the key endpoints when keys are configured. Without keys or a JWKS auth is
+// disabled. It must run before any other operation is registered.
+func setupAuth(api huma.API, options *Options, policy *rbac.Policy, logger *slog.Logger) error {
+	keysEnabled := options.KeysFile != "" || options.AdminKey != ""
+	if !keysEnabled && options.JWKS == "" {
+		logger.Warn("authentication is disabled; set --keys-file, --admin-key or --jwks to enable it")
+
+		return nil
+	}
+
+	var (
+		authenticators auth.Authenticators
+		keys           *auth.Service
+	)
+
+	if keysEnabled {
+		var err error
+		if keys, err = newKeyService(options, policy); err != nil {
+			return err
+		}
+
+		authenticators.Keys = keys
+	}
+
+	if options.JWKS != "" {
+		verifier, err := newJWTVerifier(options)
+		if err != nil {
+			return err
+		}
+
+		authenticators.JWT = verifier
+	}
+
+	api.UseMiddleware(handler.Authenticate(api, authenticators, options.Environment))
+
+	if keys != nil {
+		handler.NewKeyHandler(keys).Register(api)
+	}
+
+	return nil
+}
+
+// telemetry is where the server reports its metrics and traces.
+type telemetry struct {
+	registry *metrics.Registry
+	tracer   trace.TracerProvider
+}
+
+// newTelemetry sets up tracing to the --tracing exporter, if any. Closing the
+// returned closer flushes the buffered spans.
+func newTelemetry(options *Options) (telemetry, io.Closer, error) {
+	tel := telemetry{registry: metrics.NewRegistry(), tracer: noop.NewTracerProvider()}
+	if options.Tracing == "" {
+		return tel, closerFunc(func() error { return nil }), nil
+	}
+
+	provider, err := tracing.NewProvider(context.Background(), tracing.Config{
+		Exporter:    options.Tracing,
+		Stdout:      os.Stdout,
+		ServiceName: "features",
+	})
+	if err != nil {
+		return telemetry{}, nil, err
+	}
+
+	tel.tracer = provider
+
+	return tel, closerFunc(func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		return provider.Shutdown(ctx)
+	}), nil
+}
+
+// instrument wraps repo to trace its calls and, unless metrics are off, to
+// record its metrics, and returns the service options that do the same for
+// evaluations. Only the flags listed in --metric-flags are labeled by key.
+func instrument(options *Options, tel telemetry, repo flags.Repository) (flags.Repository, []flags.Option) {
+	repo = tracing.NewRepository(tel.tracer, repo)
+	traceOpt := flags.WithTracerProvider(tel.tracer)
+
+	if !options.Metrics {
+		return repo, []flags.Option{traceOpt}
+	}
+
+	var allowed []flags.FlagKey
+
+	for key := range strings.SplitSeq(options.MetricFlags, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			allowed = append(allowed, flags.FlagKey(key))
+		}
+	}
+
+	metrics.RegisterFlagCount(tel.registry, repo)
+
+	return metrics.NewRepository(tel.registry, repo),
+		[]flags.Option{traceOpt, flags.WithEvaluationRecorder(metrics.NewEvaluations(tel.registry, allowed))}
+}
+
+// newKeyService opens the key store. With RBAC, managing keys needs the
+// admin role.
+func newKeyService(options *Options, policy *rbac.Policy) (*auth.Service, error) {
+	var store auth.Store = auth.NewMemoryStore()
+
+	if options.KeysFile != "" {
+		fileStore, err := auth.OpenFileStore(options.KeysFile)
+		if err != nil {
+			return nil, err
+		}
+
+		store = fileStore
+	}
+
+	opts := []auth.Option{auth.WithBootstrapKey(options.AdminKey)}
+
+	if policy != nil {
+		opts = append(opts, auth.WithAuthorizer(func(ctx context.Context) error {
+			return policy.Authorize(ctx, flags.PermissionAdmin, "")
+		}))
+	}
+
+	return auth.NewService(store, opts...), nil
+}
+
+func newJWTVerifier(options *Options) (*auth.JWTVerifier, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return auth.NewJWTVerifier(ctx, options.JWKS, options.JWTIssuer, options.JWTAudience,
+		auth.WithPrincipalClaim(options.JWTUser), auth.WithRolesClaim(options.JWTRoles))
+}
+
+// newPolicy loads the role bindings, or returns nil when RBAC is disabled.
+// The flags file is server configuration, so its syncer is always an editor,
+// and the bootstrap key belongs to the operator, so it is always an admin.
+func newPolicy(options *Options) (*rbac.Policy, error) {
+	if options.RBACFile == "" {
+		return nil, nil //nolint:nilnil // a nil policy disables RBAC
+	}
+
+	config, err := rbac.LoadConfig(options.RBACFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config.Bindings = append(config.Bindings,
+		rbac.Binding{Principal: flagsfile.Actor, Role: rbac.RoleEditor},
+		rbac.Binding{Principal: "key:" + auth.BootstrapKeyID, Role: rbac.RoleAdmin},
+	)
+
+	return rbac.NewPolicy(options.Environment, config.Bindings, config.Projects...)
+}
+
+// newRepository opens the configured storage backend and the revision history
+// kept alongside it. The returned closers must be closed in order on shutdown.
+func newRepository(options *Options, logger *slog.Logger) (flags.Repository, []flags.Option, []io.Closer, error) {
+	switch options.Storage {
+	case "memory":
+		return flags.NewMemoryRepository(), nil, nil, nil
+	case "file":
+		return newFileRepository(options, logger)
+	case "sqlite":
+		repo, err := sqlite.Open(context.Background(), options.SQLitePath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		opts := []flags.Option{flags.WithHistoryStore(repo.History()), flags.WithUsageStore(repo.Usage())}
+
+		if options.CacheSize > 0 {
+			return flags.NewCachedRepository(repo, cacheOptions(options)...), opts, []io.Closer{repo}, nil
+		}
+
+		return repo, opts, []io.Closer{repo}, nil
+	case "redis":
+		return newRedisRepository(options)
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown storage backend %q", options.Storage)
+	}
+}
+
+// newFileRepository recovers the flags in the data directory and opens the
+// history log next to the write-ahead log. Usage is kept in the data
+// directory too, unless --usage names another file.
+func newFileRepository(options *Options, logger *slog.Logger) (flags.Repository, []flags.Option, []io.Closer, error) {
+	repo, err := flags.OpenFileRepository(options.DataDir, flags.WithSnapshotErrorHandler(func(err error) {
+		logger.Error("file storage snapshot failed", slog.Any("error", err))
+	}))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	recovery := repo.Recovery()
+	logger.Info("file storage recovered",
+		slog.String("dataDir", options.DataDir),
+		slog.Uint64("snapshotSeq", recovery.SnapshotSeq),
+		slog.Int("replayed", recovery.Replayed),
+		slog.Int64("discardedBytes", recovery.DiscardedBytes),
+	)
+
+	if recovery.DiscardedBytes > 0 {
+		logger.Warn("discarded torn write-ahead log tail",
+			slog.Int64("bytes", recovery.DiscardedBytes))
+	}
+
+	history, err := flags.OpenFileHistoryStore(filepath.Join(options.DataDir, "history.log"))
+	if err != nil {
+		_ = repo.Close()
+
+		return nil, nil, nil, err
+	}
+
+	opts := []flags.Option{flags.WithHistoryStore(history)}
+	closers := []io.Closer{history, repo}
+
+	if options.Usage == "" {
+		usage, err := flags.OpenFileUsageStore(filepath.Join(options.DataDir, "usage.json"))
+		if err != nil {
+			closeAll(closers)
+
+			return nil, nil, nil, err
+		}
+
+		opts = append(opts, flags.WithUsageStore(usage))
+		closers = append([]io.Closer{usage}, closers...)
+	}
+
+	return repo, opts, closers, nil
+}
+
+func newRedisRepository(options *Options) (flags.Repository, []flags.Option, []io.Closer, error) {
+	redisOpts, err := goredis.ParseURL(options.RedisURL)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("parse redis url: %w", err)
+	}
+
+	client := goredis.NewClient(redisOpts)
+
+	repo, err := redis.New(context.Background(), client, redis.WithCacheOptions(cacheOptions(options)...))
+	if err != nil {
+		_ = client.Close()
+
+		return nil, nil, nil, err
+	}
+
+	opts := []flags.Option{flags.WithHistoryStore(repo.History()), flags.WithUsageStore(repo.Usage())}
+
+	return repo, opts, []io.Closer{repo, client}, nil
+}
+
+// closeAll closes closers in order, ignoring errors. It releases what was
+// opened before a later step of startup failed.
+func closeAll(closers []io.Closer) {
+	for _, closer := range closers {
+		_ = closer.Close()
+	}
+}
+
+// closerFunc adapts a function to io.Closer.
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// startFlagsSync reconciles the service with the flags file, if one is
+// configured, and keeps it in sync in the background when watching is on.
+// Closing the returned closer stops watching and waits for it to return.
+func startFlagsSync(service *flags.Service, options *Options, logger *slog.Logger) (io.Closer, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	stop := closerFunc(func() error {
+		cancel()
+		<-done
+
+		return nil
+	})
+
+	if options.FlagsFile == "" {
+		close(done)
+
+		return stop, nil
+	}
+
+	syncer := flagsfile.NewSyncer(service, options.FlagsFile, flagsfile.WithDryRun(options.FlagsDryRun))
+	report := func(changes []flagsfile.Change, err error) {
+		for _, change := range changes {
+			paths := make([]string, len(change.Diff))
+			for i, fc := range change.Diff {
+				paths[i] = fc.Path
+			}
+
+			logger.Info("flags file change",
+				slog.String("action", string(change.Action)),
+				slog.String("key", string(change.Key)),
+				slog.Any("fields", paths),
+				slog.Bool("dryRun", options.FlagsDryRun),
+			)
+		}
+
+		if err != nil {
+			logger.Error("flags file sync failed", slog.Any("error", err))
+		}
+	}
+
+	changes, err := syncer.Sync(ctx)
+	if err != nil {
+		cancel()
+
+		return nil, err
+	}
+
+	report(changes, nil)
+
+	if options.FlagsWatch <= 0 || options.FlagsDryRun {
+		close(done)
+
+		return stop, nil
+	}
+
+	go func() {
+		defer close(done)
+
+		syncer.Watch(ctx, options.FlagsWatch, report)
+	}()
+
+	return stop, nil
+}
+
+// startScheduler runs scheduled changes and steps rollout ramps in the
+// background, catching up on the changes missed while the server was down.
+// Closing the returned closer stops both and waits for them to return.
+func startScheduler(service *flags.Service, options *Options, logger *slog.Logger) io.Closer {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+
+	wg.Go(func() { watchSchedules(ctx, service, options, logger) })
+	wg.Go(func() { watchRamps(ctx, service, options, logger) })
+
+	return closerFunc(func() error {
+		cancel()
+		wg.Wait()
+
+		return nil
+	})
+}
+
+// watchSchedules runs scheduled changes until ctx is done, logging each one.
+func watchSchedules(ctx context.Context, service *flags.Service, options *Options, logger *slog.Logger) {
+	service.WatchSchedules(ctx, options.Scheduler, func(ran []flags.ScheduledChange, err error) {
+		for _, change := range ran {
+			logger.Info("scheduled change ran",
+				slog.String("id", change.ID),
+				slog.String("key", string(change.FlagKey)),
+				slog.String("action", string(change.Action)),
+				slog.String("status", string(change.Status)),
+				slog.Time("at", change.At),
+				slog.String("error", change.Error),
+			)
+		}
+
+		if err != nil {
+			logger.Error("scheduled changes failed", slog.Any("error", err))
+		}
+	})
+}
+
+// watchRamps steps rollout ramps until ctx is done, logging each step.
+func watchRamps(ctx context.Context, service *flags.Service, options *Options, logger *slog.Logger) {
+	service.WatchRamps(ctx, options.Scheduler, func(moved []flags.Ramp, err error) {
+		for _, ramp := range moved {
+			logger.Info("rollout ramp stepped",
+				slog.String("id", ramp.ID),
+				slog.String("key", string(ramp.FlagKey)),
+				slog.String("rule", ramp.RuleID),
+				slog.Float64("percentage", ramp.Percentage()),
+				slog.String("status", string(ramp.Status)),
+				slog.String("error", ramp.Error),
+			)
+		}
+
+		if err != nil {
+			logger.Error("rollout ramps failed", slog.Any("error", err))
+		}
+	})
+}
+
+// startUsageFlush flushes usage every --usage-flush interval. Closing the
+// returned closer stops it and flushes the evaluations counted since.
+func startUsageFlush(service *flags.Service, options *Options, logger *slog.Logger) io.Closer {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		service.WatchUsage(ctx, options.UsageFlush, func(_ []flags.UsageBucket, err error) {
+			if err != nil {
+				logger.Error("usage flush failed", slog.Any("error", err))
+			}
+		})
+	}()
+
+	return closerFunc(func() error {
+		cancel()
+		<-done
+
+		_, err := service.FlushUsage(context.Background())
+
+		return err
+	})
+}
+
+func cacheOptions(options *Options) []flags.CacheOption {
+	return []flags.CacheOption{flags.WithCacheSize(options.CacheSize), flags.WithCacheTTL(options.CacheTTL)}
+}
@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+var ErrInvalidJWKS = errors.New("invalid JWKS")
+
+// signatureAlgorithms are the asymmetric algorithms identity providers sign
+// with. Symmetric ones are refused because JWKS keys are public.
+var signatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+const (
+	// jwtLeeway tolerates clock skew between the identity provider and us.
+	jwtLeeway = time.Minute
+	// jwksRefreshInterval limits refetches of a JWKS URL triggered by tokens
+	// signed with an unknown key. The first one is immediate.
+	jwksRefreshInterval = time.Minute
+)
+
+// JWTVerifier authenticates users by bearer JWTs signed with a key from a
+// JWKS, issued by issuer for audience.
+type JWTVerifier struct {
+	issuer         string
+	audience       string
+	principalClaim string
+	rolesClaim     string
+	jwks           *jwks
+}
+
+type JWTOption func(*JWTVerifier)
+
+// WithPrincipalClaim names the claim that identifies the user, "sub" by
+// default. Principals are named "user:" followed by its value.
+func WithPrincipalClaim(claim string) JWTOption {
+	return func(v *JWTVerifier) {
+		v.principalClaim = claim
+	}
+}
+
+// WithRolesClaim names the claim listing the user's roles, "roles" by
+// default. Dots select nested claims, as in "realm_access.roles". The claim
+// may be a list or a space-separated string.
+func WithRolesClaim(claim string) JWTOption {
+	return func(v *JWTVerifier) {
+		v.rolesClaim = claim
+	}
+}
+
+// WithJWKSClient replaces http.DefaultClient for fetching a JWKS URL.
+func WithJWKSClient(client *http.Client) JWTOption {
+	return func(v *JWTVerifier) {
+		v.jwks.client = client
+	}
+}
+
+// NewJWTVerifier loads the JWKS at source, a file path or an http(s) URL.
+// Keys from a URL are refetched when a token names a key the set lacks, so
+// the identity provider can rotate keys.
+func NewJWTVerifier(ctx context.Context, source, issuer, audience string, opts ...JWTOption) (*JWTVerifier, error) {
+	if issuer == "" || audience == "" {
+		return nil, errors.New("JWT verification needs an issuer and an audience")
+	}
+
+	v := &JWTVerifier{
+		issuer:         issuer,
+		audience:       audience,
+		principalClaim: "sub",
+		rolesClaim:     "roles",
+		jwks:           &jwks{source: source, client: http.DefaultClient},
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	if err := v.jwks.load(ctx); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// Authenticate verifies token and returns its user. Users get the admin
+// scope; what they may change is up to the flags.Service authorizer.
+func (v *JWTVerifier) Authenticate(ctx context.Context, token string) (Principal, error) {
+	parsed, err := jwt.ParseSigned(token, signatureAlgorithms)
+	if err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+
+	key, err := v.jwks.key(ctx, parsed.Headers[0].KeyID)
+	if err != nil {
+		return Principal{}, err
+	}
+
+	var (
+		claims jwt.Claims
+		all    map[string]any
+	)
+
+	if err := parsed.Claims(key, &claims, &all); err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+
+	if claims.Expiry == nil {
+		return Principal{}, fmt.Errorf("%w: token has no expiry", ErrUnauthenticated)
+	}
+
+	expected := jwt.Expected{Issuer: v.issuer, AnyAudience: jwt.Audience{v.audience}, Time: time.Now()}
+	if err := claims.ValidateWithLeeway(expected, jwtLeeway); err != nil {
+		return Principal{}, fmt.Errorf("%w: %w", ErrUnauthenticated, err)
+	}
+
+	name, _ := lookupClaim(all, v.principalClaim).(string)
+	if name == "" {
+		return Principal{}, fmt.Errorf("%w: token has no %s claim", ErrUnauthenticated, v.principalClaim)
+	}
+
+	return Principal{Name: "user:" + name, Scope: ScopeAdmin, Roles: stringList(lookupClaim(all, v.rolesClaim))}, nil
+}
+
+func lookupClaim(claims map[string]any, path string) any {
+	var value any = claims
+
+	for part := range strings.SplitSeq(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		value = object[part]
+	}
+
+	return value
+}
+
+func stringList(value any) []string {
+	switch value := value.(type) {
+	case string:
+		return strings.Fields(value)
+	case []any:
+		list := make([]string, 0, len(value))
+
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+
+		return list
+	default:
+		return nil
+	}
+}
+
+// jwks holds the keys of a JWKS file or URL.
+type jwks struct {
+	source string
+	client *http.Client
+
+	mu          sync.Mutex
+	keys        jose.JSONWebKeySet
+	refetchedAt time.Time
+}
+
+func (j *jwks) remote() bool {
+	return strings.HasPrefix(j.source, "https://") || strings.HasPrefix(j.source, "http://")
+}
+
+// key returns the public key with id, refetching a remote set at most once
+// per jwksRefreshInterval when the key is missing.
+func (j *jwks) key(ctx context.Context, id string) (any, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	found := j.keys.Key(id)
+	if len(found) == 0 && j.remote() && time.Since(j.refetchedAt) >= jwksRefreshInterval {
+		j.refetchedAt = time.Now()
+
+		if err := j.fetch(ctx); err != nil {
+			return nil, err
+		}
+
+		found = j.keys.Key(id)
+	}
+
+	if len(found) == 0 {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrUnauthenticated, id)
+	}
+
+	return found[0].Public().Key, nil
+}
+
+func (j *jwks) load(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.remote() {
+		return j.fetch(ctx)
+	}
+
+	data, err := os.ReadFile(j.source)
+	if err != nil {
+		return fmt.Errorf("read JWKS: %w", err)
+	}
+
+	return j.parse(data)
+}
+
+func (j *jwks) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.source, nil)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch JWKS: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("fetch JWKS: %w", err)
+	}
+
+	return j.parse(data)
+}
+
+func (j *jwks) parse(data []byte) error {
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidJWKS, err)
+	}
+
+	for _, key := range keys.Keys {
+		if !key.IsPublic() {
+			return fmt.Errorf("%w: key %q is not a public key", ErrInvalidJWKS, key.KeyID)
+		}
+	}
+
+	j.keys = keys
+
+	return nil
+}
@@ -0,0 +1,314 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/serroba/features/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	issuer   = "https://id.example.com"
+	audience = "features"
+)
+
+// signingKey is a private key with the kid it is published under.
+type signingKey struct {
+	id        string
+	algorithm jose.SignatureAlgorithm
+	private   any
+	public    any
+}
+
+func newECKey(t *testing.T, id string) signingKey {
+	t.Helper()
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	return signingKey{id: id, algorithm: jose.ES256, private: private, public: &private.PublicKey}
+}
+
+func newRSAKey(t *testing.T, id string) signingKey {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	return signingKey{id: id, algorithm: jose.RS256, private: private, public: &private.PublicKey}
+}
+
+func (k signingKey) sign(t *testing.T, claims ...any) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: k.algorithm, Key: jose.JSONWebKey{Key: k.private, KeyID: k.id}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	require.NoError(t, err)
+
+	builder := jwt.Signed(signer)
+	for _, c := range claims {
+		builder = builder.Claims(c)
+	}
+
+	token, err := builder.Serialize()
+	require.NoError(t, err)
+
+	return token
+}
+
+func jwksJSON(t *testing.T, keys ...signingKey) []byte {
+	t.Helper()
+
+	var set jose.JSONWebKeySet
+	for _, key := range keys {
+		set.Keys = append(set.Keys, jose.JSONWebKey{
+			Key: key.public, KeyID: key.id, Algorithm: string(key.algorithm), Use: "sig",
+		})
+	}
+
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+
+	return data
+}
+
+// jwksServer serves a JWKS whose keys can be replaced, counting fetches.
+type jwksServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	body    []byte
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, body []byte) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{body: body}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		_, _ = w.Write(s.body)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *jwksServer) publish(body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.body = body
+}
+
+func validClaims(subject string) jwt.Claims {
+	now := time.Now()
+
+	return jwt.Claims{
+		Issuer:   issuer,
+		Audience: jwt.Audience{audience},
+		Subject:  subject,
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+}
+
+func TestJWTVerifier_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	ec := newECKey(t, "ec")
+	rs := newRSAKey(t, "rsa")
+	server := newJWKSServer(t, jwksJSON(t, ec, rs))
+
+	verifier, err := auth.NewJWTVerifier(context.Background(), server.URL, issuer, audience,
+		auth.WithJWKSClient(server.Client()))
+	require.NoError(t, err)
+
+	for _, key := range []signingKey{ec, rs} {
+		token := key.sign(t, validClaims("alice@example.com"), map[string]any{"roles": []string{"editor", "prod:approver"}})
+
+		principal, err := verifier.Authenticate(context.Background(), token)
+		require.NoError(t, err, key.id)
+		assert.Equal(t, auth.Principal{
+			Name:  "user:alice@example.com",
+			Scope: auth.ScopeAdmin,
+			Roles: []string{"editor", "prod:approver"},
+		}, principal)
+	}
+}
+
+func TestJWTVerifier_Rejects(t *testing.T) {
+	t.Parallel()
+
+	key := newECKey(t, "ec")
+	impostor := newECKey(t, "ec")
+	stranger := newECKey(t, "other")
+	server := newJWKSServer(t, jwksJSON(t, key))
+
+	verifier, err := auth.NewJWTVerifier(context.Background(), server.URL, issuer, audience)
+	require.NoError(t, err)
+
+	expired := validClaims("alice")
+	expired.Expiry = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+
+	notYet := validClaims("alice")
+	notYet.NotBefore = jwt.NewNumericDate(time.Now().Add(time.Hour))
+
+	wrongIssuer := validClaims("alice")
+	wrongIssuer.Issuer = "https://evil.example.com"
+
+	wrongAudience := validClaims("alice")
+	wrongAudience.Audience = jwt.Audience{"billing"}
+
+	noExpiry := validClaims("alice")
+	noExpiry.Expiry = nil
+
+	tests := map[string]string{
+		"malformed":      "not.a.jwt",
+		"expired":        key.sign(t, expired),
+		"not yet valid":  key.sign(t, notYet),
+		"wrong issuer":   key.sign(t, wrongIssuer),
+		"wrong audience": key.sign(t, wrongAudience),
+		"no expiry":      key.sign(t, noExpiry),
+		"no subject":     key.sign(t, validClaims("")),
+		"bad signature":  impostor.sign(t, validClaims("alice")),
+		"unknown key":    stranger.sign(t, validClaims("alice")),
+	}
+
+	for name, token := range tests {
+		_, err := verifier.Authenticate(context.Background(), token)
+		assert.ErrorIs(t, err, auth.ErrUnauthenticated, name)
+	}
+}
+
+func TestJWTVerifier_RefetchesRotatedKeys(t *testing.T) {
+	t.Parallel()
+
+	old := newECKey(t, "2025")
+	rotated := newECKey(t, "2026")
+	server := newJWKSServer(t, jwksJSON(t, old))
+
+	verifier, err := auth.NewJWTVerifier(context.Background(), server.URL, issuer, audience)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), server.fetches.Load())
+
+	server.publish(jwksJSON(t, rotated))
+
+	principal, err := verifier.Authenticate(context.Background(), rotated.sign(t, validClaims("alice")))
+	require.NoError(t, err)
+	assert.Equal(t, "user:alice", principal.Name)
+	assert.Equal(t, int32(2), server.fetches.Load())
+
+	// Unknown keys do not refetch again within the refresh interval.
+	_, err = verifier.Authenticate(context.Background(), newECKey(t, "2027").sign(t, validClaims("alice")))
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+	assert.Equal(t, int32(2), server.fetches.Load())
+}
+
+func TestJWTVerifier_RefetchFails(t *testing.T) {
+	t.Parallel()
+
+	key := newECKey(t, "ec")
+	server := newJWKSServer(t, jwksJSON(t, key))
+
+	verifier, err := auth.NewJWTVerifier(context.Background(), server.URL, issuer, audience)
+	require.NoError(t, err)
+
+	server.publish([]byte("{"))
+
+	_, err = verifier.Authenticate(context.Background(), newECKey(t, "new").sign(t, validClaims("alice")))
+	require.ErrorIs(t, err, auth.ErrInvalidJWKS)
+}
+
+func TestJWTVerifier_FileAndCustomClaims(t *testing.T) {
+	t.Parallel()
+
+	key := newRSAKey(t, "rsa")
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwksJSON(t, key), 0o600))
+
+	verifier, err := auth.NewJWTVerifier(context.Background(), path, issuer, audience,
+		auth.WithPrincipalClaim("email"), auth.WithRolesClaim("realm_access.roles"))
+	require.NoError(t, err)
+
+	token := key.sign(t, validClaims("0042"), map[string]any{
+		"email":        "bob@example.com",
+		"realm_access": map[string]any{"roles": "viewer staging:editor"},
+	})
+
+	principal, err := verifier.Authenticate(context.Background(), token)
+	require.NoError(t, err)
+	assert.Equal(t, "user:bob@example.com", principal.Name)
+	assert.Equal(t, []string{"viewer", "staging:editor"}, principal.Roles)
+
+	token = key.sign(t, validClaims("0042"), map[string]any{"email": "bob@example.com", "realm_access": "admin"})
+
+	principal, err = verifier.Authenticate(context.Background(), token)
+	require.NoError(t, err)
+	assert.Empty(t, principal.Roles, "roles must be found at the claim path")
+}
+
+func TestNewJWTVerifier_Errors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+
+		return path
+	}
+
+	key := newECKey(t, "ec")
+	valid := write("valid.json", jwksJSON(t, key))
+
+	_, err := auth.NewJWTVerifier(ctx, valid, "", audience)
+	require.Error(t, err)
+
+	_, err = auth.NewJWTVerifier(ctx, valid, issuer, "")
+	require.Error(t, err)
+
+	_, err = auth.NewJWTVerifier(ctx, filepath.Join(dir, "missing.json"), issuer, audience)
+	require.Error(t, err)
+
+	_, err = auth.NewJWTVerifier(ctx, write("invalid.json", []byte("[")), issuer, audience)
+	require.ErrorIs(t, err, auth.ErrInvalidJWKS)
+
+	private, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: key.private, KeyID: "ec"}}})
+	require.NoError(t, err)
+
+	_, err = auth.NewJWTVerifier(ctx, write("private.json", private), issuer, audience)
+	require.ErrorIs(t, err, auth.ErrInvalidJWKS)
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(down.Close)
+
+	_, err = auth.NewJWTVerifier(ctx, down.URL, issuer, audience)
+	require.Error(t, err)
+
+	_, err = auth.NewJWTVerifier(ctx, "http://127.0.0.1:0/jwks", issuer, audience)
+	require.Error(t, err)
+}
@@ -0,0 +1,60 @@
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/serroba/features/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScope_Includes(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, auth.ScopeAdmin.Includes(auth.ScopeEvaluate))
+	assert.True(t, auth.ScopeAdmin.Includes(auth.ScopeRead))
+	assert.True(t, auth.ScopeRead.Includes(auth.ScopeRead))
+	assert.False(t, auth.ScopeRead.Includes(auth.ScopeAdmin))
+	assert.False(t, auth.ScopeEvaluate.Includes(auth.ScopeRead))
+	assert.False(t, auth.Scope("owner").Includes(auth.ScopeEvaluate))
+	assert.False(t, auth.ScopeAdmin.Includes("owner"))
+}
+
+func TestPrincipal_Authorize(t *testing.T) {
+	t.Parallel()
+
+	principal := auth.Principal{Name: "key:deploy", Scope: auth.ScopeRead, Environment: "prod"}
+
+	require.NoError(t, principal.Authorize(auth.ScopeEvaluate, "prod"))
+
+	err := principal.Authorize(auth.ScopeAdmin, "prod")
+	require.ErrorIs(t, err, auth.ErrForbidden)
+	require.EqualError(t, err, "forbidden: requires the admin scope")
+
+	err = principal.Authorize(auth.ScopeRead, "staging")
+	require.ErrorIs(t, err, auth.ErrForbidden)
+	require.EqualError(t, err, "forbidden: key is restricted to environment prod")
+
+	unrestricted := auth.Principal{Scope: auth.ScopeRead}
+	assert.NoError(t, unrestricted.Authorize(auth.ScopeRead, "staging"))
+}
+
+func TestPrincipal_AuthorizeTenant(t *testing.T) {
+	t.Parallel()
+
+	principal := auth.Principal{Scope: auth.ScopeEvaluate, Tenant: "acme"}
+
+	require.NoError(t, principal.AuthorizeTenant("acme"))
+	require.ErrorIs(t, principal.AuthorizeTenant("globex"), auth.ErrForbidden)
+	require.ErrorIs(t, principal.AuthorizeTenant(""), auth.ErrForbidden)
+	assert.NoError(t, auth.Principal{Scope: auth.ScopeEvaluate}.AuthorizeTenant("globex"))
+}
+
+func TestKey_Principal(t *testing.T) {
+	t.Parallel()
+
+	key := auth.Key{ID: "k1", Name: "deploy", Scope: auth.ScopeEvaluate, Environment: "prod", Tenant: "acme"}
+
+	assert.Equal(t, auth.Principal{Name: "key:deploy", Scope: auth.ScopeEvaluate, Environment: "prod", Tenant: "acme"},
+		key.Principal())
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Principal is who made a request: an API key or a user signed in through
+// the identity provider.
+type Principal struct {
+	// Name is recorded as the actor, for example "key:deploy-bot" or
+	// "user:alice@example.com".
+	Name  string
+	Scope Scope
+	// Environment and Tenant restrict API keys; see Key.
+	Environment string
+	Tenant      string
+	// Roles are the roles claimed by a user's token, for rbac.
+	Roles []string
+}
+
+// Authorize reports whether the principal may perform an operation
+// requiring scope on a server running in environment.
+func (p Principal) Authorize(scope Scope, environment string) error {
+	if !p.Scope.Includes(scope) {
+		return fmt.Errorf("%w: requires the %s scope", ErrForbidden, scope)
+	}
+
+	if p.Environment != "" && p.Environment != environment {
+		return fmt.Errorf("%w: key is restricted to environment %s", ErrForbidden, p.Environment)
+	}
+
+	return nil
+}
+
+// AuthorizeTenant reports whether the principal may evaluate flags for
+// tenant.
+func (p Principal) AuthorizeTenant(tenant string) error {
+	if p.Tenant != "" && p.Tenant != tenant {
+		return fmt.Errorf("%w: key is restricted to tenant %s", ErrForbidden, p.Tenant)
+	}
+
+	return nil
+}
+
+// Authenticator turns a bearer token into a principal. It returns an error
+// wrapping ErrUnauthenticated for tokens it does not accept.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (Principal, error)
+}
+
+// Authenticators sends JWTs, which have three dot-separated segments, to JWT
+// and any other token to Keys. Either may be nil to reject those tokens.
+type Authenticators struct {
+	Keys Authenticator
+	JWT  Authenticator
+}
+
+func (a Authenticators) Authenticate(ctx context.Context, token string) (Principal, error) {
+	next := a.Keys
+	if a.JWT != nil && strings.Count(token, ".") == 2 {
+		next = a.JWT
+	}
+
+	if next == nil {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	return next.Authenticate(ctx, token)
+}
+
+type contextKey struct{}
+
+// WithPrincipal stores the authenticated principal in ctx.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal. ok is
+// false when the request was not authenticated, for example because auth is
+// disabled.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(contextKey{}).(Principal)
+
+	return principal, ok
+}
@@ -0,0 +1,56 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/serroba/features/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// staticAuthenticator accepts any token as principal.
+type staticAuthenticator auth.Principal
+
+func (a staticAuthenticator) Authenticate(context.Context, string) (auth.Principal, error) {
+	return auth.Principal(a), nil
+}
+
+func TestAuthenticators(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	both := auth.Authenticators{
+		Keys: staticAuthenticator{Name: "key:backend"},
+		JWT:  staticAuthenticator{Name: "user:alice"},
+	}
+
+	principal, err := both.Authenticate(ctx, "ffk_id_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "key:backend", principal.Name)
+
+	principal, err = both.Authenticate(ctx, "header.payload.signature")
+	require.NoError(t, err)
+	assert.Equal(t, "user:alice", principal.Name)
+
+	keysOnly := auth.Authenticators{Keys: staticAuthenticator{Name: "key:bootstrap"}}
+
+	principal, err = keysOnly.Authenticate(ctx, "looks.like.jwt")
+	require.NoError(t, err)
+	assert.Equal(t, "key:bootstrap", principal.Name, "without a verifier every token is a key")
+
+	_, err = auth.Authenticators{}.Authenticate(ctx, "ffk_id_secret")
+	assert.ErrorIs(t, err, auth.ErrUnauthenticated)
+}
+
+func TestPrincipalFromContext(t *testing.T) {
+	t.Parallel()
+
+	_, ok := auth.PrincipalFromContext(context.Background())
+	assert.False(t, ok)
+
+	principal := auth.Principal{Name: "user:alice", Scope: auth.ScopeAdmin, Roles: []string{"editor"}}
+	got, ok := auth.PrincipalFromContext(auth.WithPrincipal(context.Background(), principal))
+	assert.True(t, ok)
+	assert.Equal(t, principal, got)
+}
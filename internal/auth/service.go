@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidScope = errors.New("invalid scope")
+	ErrTenantScope  = errors.New("only evaluate keys can be restricted to a tenant")
+	ErrKeyRevoked   = errors.New("key is revoked")
+	ErrNameTaken    = errors.New("key name is taken")
+)
+
+// BootstrapKeyID identifies the key configured with WithBootstrapKey.
+const BootstrapKeyID = "bootstrap"
+
+type Service struct {
+	store     Store
+	bootstrap string
+	authorize func(ctx context.Context) error
+
+	// creates serializes Create so key names stay unique.
+	creates sync.Mutex
+}
+
+type Option func(*Service)
+
+// WithBootstrapKey accepts token as an admin key that is not stored, so the
+// first keys can be created. Any non-empty string works as a token.
+func WithBootstrapKey(token string) Option {
+	return func(s *Service) {
+		s.bootstrap = token
+	}
+}
+
+// WithAuthorizer checks every call that manages keys with authorize, for
+// example to require a role. authorize returns an error to deny.
+func WithAuthorizer(authorize func(ctx context.Context) error) Option {
+	return func(s *Service) {
+		s.authorize = authorize
+	}
+}
+
+func NewService(store Store, opts ...Option) *Service {
+	s := &Service{store: store, authorize: func(context.Context) error { return nil }}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// NewKey describes a key to create.
+type NewKey struct {
+	Name        string
+	Scope       Scope
+	Environment string
+	Tenant      string
+}
+
+// Create stores a new key and returns it with its token, which cannot be
+// recovered later. Names are unique, revoked keys included, because the
+// name identifies the key's principal.
+func (s *Service) Create(ctx context.Context, spec NewKey) (Key, string, error) {
+	if err := s.authorize(ctx); err != nil {
+		return Key{}, "", err
+	}
+
+	if !spec.Scope.Valid() {
+		return Key{}, "", fmt.Errorf("%w: %q", ErrInvalidScope, spec.Scope)
+	}
+
+	if spec.Tenant != "" && spec.Scope != ScopeEvaluate {
+		return Key{}, "", ErrTenantScope
+	}
+
+	s.creates.Lock()
+	defer s.creates.Unlock()
+
+	if err := s.checkName(ctx, spec.Name); err != nil {
+		return Key{}, "", err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	token, hash, err := newSecret(id)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	key := Key{
+		ID:          id,
+		Name:        spec.Name,
+		Scope:       spec.Scope,
+		Environment: spec.Environment,
+		Tenant:      spec.Tenant,
+		Hash:        hash,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	if err := s.store.Create(ctx, key); err != nil {
+		return Key{}, "", err
+	}
+
+	return key, token, nil
+}
+
+func (s *Service) checkName(ctx context.Context, name string) error {
+	existing, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	if name == BootstrapKeyID || slices.ContainsFunc(existing, func(key Key) bool { return key.Name == name }) {
+		return fmt.Errorf("%w: %s", ErrNameTaken, name)
+	}
+
+	return nil
+}
+
+// List returns every key, revoked ones included.
+func (s *Service) List(ctx context.Context) ([]Key, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	return s.store.List(ctx)
+}
+
+// Rotate replaces the key's secret. The previous token stops working at once.
+func (s *Service) Rotate(ctx context.Context, id string) (Key, string, error) {
+	if err := s.authorize(ctx); err != nil {
+		return Key{}, "", err
+	}
+
+	key, err := s.store.Get(ctx, id)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	if key.Revoked() {
+		return Key{}, "", ErrKeyRevoked
+	}
+
+	token, hash, err := newSecret(id)
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	key.Hash = hash
+	key.RotatedAt = time.Now().UTC()
+
+	if err := s.store.Update(ctx, key); err != nil {
+		return Key{}, "", err
+	}
+
+	return key, token, nil
+}
+
+// Revoke disables the key for good. Revoking a revoked key is a no-op.
+func (s *Service) Revoke(ctx context.Context, id string) (Key, error) {
+	if err := s.authorize(ctx); err != nil {
+		return Key{}, err
+	}
+
+	key, err := s.store.Get(ctx, id)
+	if err != nil {
+		return Key{}, err
+	}
+
+	if key.Revoked() {
+		return key, nil
+	}
+
+	key.RevokedAt = time.Now().UTC()
+
+	if err := s.store.Update(ctx, key); err != nil {
+		return Key{}, err
+	}
+
+	return key, nil
+}
+
+// Authenticate returns the principal of the active key for token, or
+// ErrUnauthenticated.
+func (s *Service) Authenticate(ctx context.Context, token string) (Principal, error) {
+	if s.bootstrap != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.bootstrap)) == 1 {
+		return Key{ID: BootstrapKeyID, Name: BootstrapKeyID, Scope: ScopeAdmin}.Principal(), nil
+	}
+
+	id, secret, ok := parseToken(token)
+	if !ok {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	key, err := s.store.Get(ctx, id)
+	if errors.Is(err, ErrKeyNotFound) {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if key.Revoked() || !key.matches(secret) {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	return key.Principal(), nil
+}
@@ -0,0 +1,236 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/serroba/features/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingStore fails writes and lookups of keys missing from MemoryStore.
+type failingStore struct {
+	*auth.MemoryStore
+
+	err error
+}
+
+func (s *failingStore) Get(ctx context.Context, id string) (auth.Key, error) {
+	key, err := s.MemoryStore.Get(ctx, id)
+	if err != nil {
+		return auth.Key{}, s.err
+	}
+
+	return key, nil
+}
+
+func (s *failingStore) Create(context.Context, auth.Key) error {
+	return s.err
+}
+
+func (s *failingStore) Update(context.Context, auth.Key) error {
+	return s.err
+}
+
+func TestService_CreateAndAuthenticate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := auth.NewService(auth.NewMemoryStore())
+
+	key, token, err := svc.Create(ctx, auth.NewKey{Name: "backend", Scope: auth.ScopeEvaluate, Tenant: "acme"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, key.ID)
+	assert.Equal(t, auth.ScopeEvaluate, key.Scope)
+	assert.Equal(t, "acme", key.Tenant)
+	assert.False(t, key.CreatedAt.IsZero())
+	assert.True(t, strings.HasPrefix(token, "ffk_"+key.ID+"_"))
+	assert.NotContains(t, key.Hash, strings.TrimPrefix(token, "ffk_"+key.ID+"_"), "only the hash is stored")
+
+	got, err := svc.Authenticate(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, key.Principal(), got)
+
+	listed, err := svc.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []auth.Key{key}, listed)
+}
+
+func TestService_Authenticate_Invalid(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := auth.NewService(auth.NewMemoryStore())
+
+	key, token, err := svc.Create(ctx, auth.NewKey{Name: "backend", Scope: auth.ScopeRead})
+	require.NoError(t, err)
+
+	for _, bad := range []string{
+		"",
+		"not-a-key",
+		"ffk_",
+		"ffk_" + key.ID,
+		"ffk_" + key.ID + "_",
+		"ffk_unknown_secret",
+		"ffk_" + key.ID + "_wrong",
+		token + "x",
+	} {
+		_, err := svc.Authenticate(ctx, bad)
+		require.ErrorIs(t, err, auth.ErrUnauthenticated, bad)
+	}
+}
+
+func TestService_Create_Invalid(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := auth.NewService(auth.NewMemoryStore())
+
+	_, _, err := svc.Create(ctx, auth.NewKey{Name: "x", Scope: "owner"})
+	require.ErrorIs(t, err, auth.ErrInvalidScope)
+
+	_, _, err = svc.Create(ctx, auth.NewKey{Name: "x", Scope: auth.ScopeAdmin, Tenant: "acme"})
+	require.ErrorIs(t, err, auth.ErrTenantScope)
+
+	keys, err := svc.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestService_Rotate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := auth.NewService(auth.NewMemoryStore())
+
+	key, oldToken, err := svc.Create(ctx, auth.NewKey{Name: "backend", Scope: auth.ScopeRead})
+	require.NoError(t, err)
+
+	rotated, newToken, err := svc.Rotate(ctx, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, rotated.ID)
+	assert.NotEqual(t, oldToken, newToken)
+	assert.False(t, rotated.RotatedAt.IsZero())
+
+	_, err = svc.Authenticate(ctx, oldToken)
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+
+	_, err = svc.Authenticate(ctx, newToken)
+	require.NoError(t, err)
+
+	_, _, err = svc.Rotate(ctx, "missing")
+	assert.ErrorIs(t, err, auth.ErrKeyNotFound)
+}
+
+func TestService_Revoke(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := auth.NewService(auth.NewMemoryStore())
+
+	key, token, err := svc.Create(ctx, auth.NewKey{Name: "backend", Scope: auth.ScopeRead})
+	require.NoError(t, err)
+
+	revoked, err := svc.Revoke(ctx, key.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked.Revoked())
+
+	_, err = svc.Authenticate(ctx, token)
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+
+	again, err := svc.Revoke(ctx, key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, revoked.RevokedAt, again.RevokedAt, "revoking twice keeps the first time")
+
+	_, _, err = svc.Rotate(ctx, key.ID)
+	require.ErrorIs(t, err, auth.ErrKeyRevoked)
+
+	_, err = svc.Revoke(ctx, "missing")
+	require.ErrorIs(t, err, auth.ErrKeyNotFound)
+
+	listed, err := svc.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, listed, 1, "revoked keys stay listed")
+}
+
+func TestService_BootstrapKey(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := auth.NewService(auth.NewMemoryStore(), auth.WithBootstrapKey("let-me-in"))
+
+	principal, err := svc.Authenticate(ctx, "let-me-in")
+	require.NoError(t, err)
+	assert.Equal(t, "key:"+auth.BootstrapKeyID, principal.Name)
+	assert.Equal(t, auth.ScopeAdmin, principal.Scope)
+
+	_, err = svc.Authenticate(ctx, "let-me-out")
+	require.ErrorIs(t, err, auth.ErrUnauthenticated)
+
+	keys, err := svc.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, keys, "the bootstrap key is not stored")
+
+	_, err = auth.NewService(auth.NewMemoryStore()).Authenticate(ctx, "")
+	assert.ErrorIs(t, err, auth.ErrUnauthenticated, "no bootstrap key accepts nothing")
+}
+
+func TestService_StoreErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	memory := auth.NewMemoryStore()
+
+	key, token, err := auth.NewService(memory).Create(ctx, auth.NewKey{Name: "backend", Scope: auth.ScopeRead})
+	require.NoError(t, err)
+
+	down := errors.New("down")
+	svc := auth.NewService(&failingStore{MemoryStore: memory, err: down})
+
+	_, _, err = svc.Create(ctx, auth.NewKey{Name: "x", Scope: auth.ScopeRead})
+	require.ErrorIs(t, err, down)
+
+	_, _, err = svc.Rotate(ctx, key.ID)
+	require.ErrorIs(t, err, down)
+
+	_, err = svc.Revoke(ctx, key.ID)
+	require.ErrorIs(t, err, down)
+
+	_, err = svc.Authenticate(ctx, "ffk_unknown_secret")
+	require.ErrorIs(t, err, down, "a store failure is not an authentication failure")
+
+	_, err = svc.Authenticate(ctx, token)
+	assert.NoError(t, err)
+}
+
+func TestService_Authorizer(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	memory := auth.NewMemoryStore()
+
+	key, _, err := auth.NewService(memory).Create(ctx, auth.NewKey{Name: "backend", Scope: auth.ScopeRead})
+	require.NoError(t, err)
+
+	denied := errors.New("denied")
+	svc := auth.NewService(memory, auth.WithAuthorizer(func(context.Context) error { return denied }))
+
+	_, _, err = svc.Create(ctx, auth.NewKey{Name: "other", Scope: auth.ScopeRead})
+	require.ErrorIs(t, err, denied)
+
+	_, err = svc.List(ctx)
+	require.ErrorIs(t, err, denied)
+
+	_, _, err = svc.Rotate(ctx, key.ID)
+	require.ErrorIs(t, err, denied)
+
+	_, err = svc.Revoke(ctx, key.ID)
+	require.ErrorIs(t, err, denied)
+
+	keys, err := memory.List(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []auth.Key{key}, keys)
+}
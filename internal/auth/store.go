@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrKeyNotFound = errors.New("key not found")
+	ErrKeyExists   = errors.New("key already exists")
+)
+
+// Store persists API keys.
+type Store interface {
+	Create(ctx context.Context, key Key) error
+	Get(ctx context.Context, id string) (Key, error)
+	List(ctx context.Context) ([]Key, error)
+	Update(ctx context.Context, key Key) error
+}
+
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]Key)}
+}
+
+func (s *MemoryStore) Create(_ context.Context, key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[key.ID]; ok {
+		return ErrKeyExists
+	}
+
+	s.keys[key.ID] = key
+
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) (Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+// List returns the keys ordered by creation time.
+func (s *MemoryStore) List(_ context.Context) ([]Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.SortedFunc(maps.Values(s.keys), func(a, b Key) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.ID, b.ID)
+	}), nil
+}
+
+func (s *MemoryStore) Update(_ context.Context, key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[key.ID]; !ok {
+		return ErrKeyNotFound
+	}
+
+	s.keys[key.ID] = key
+
+	return nil
+}
+
+// FileStore keeps the keys in memory and rewrites a JSON file on every
+// change. Keys change rarely, so rewriting the whole file is cheap.
+type FileStore struct {
+	mu     sync.Mutex
+	path   string
+	memory *MemoryStore
+}
+
+// OpenFileStore loads the keys in path, which is created on the first change
+// if it does not exist.
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{path: path, memory: NewMemoryStore()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read keys: %w", err)
+	}
+
+	var keys []Key
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("decode keys %s: %w", path, err)
+	}
+
+	for _, key := range keys {
+		s.memory.keys[key.ID] = key
+	}
+
+	return s, nil
+}
+
+func (s *FileStore) Create(ctx context.Context, key Key) error {
+	return s.write(ctx, func() error { return s.memory.Create(ctx, key) })
+}
+
+func (s *FileStore) Get(ctx context.Context, id string) (Key, error) {
+	return s.memory.Get(ctx, id)
+}
+
+func (s *FileStore) List(ctx context.Context) ([]Key, error) {
+	return s.memory.List(ctx)
+}
+
+func (s *FileStore) Update(ctx context.Context, key Key) error {
+	return s.write(ctx, func() error { return s.memory.Update(ctx, key) })
+}
+
+// write applies change in memory and persists the result, undoing the change
+// when the file cannot be written.
+func (s *FileStore) write(ctx context.Context, change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := maps.Clone(s.memory.keys)
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	keys, _ := s.memory.List(ctx)
+
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(s.path, data)
+	}
+
+	if err != nil {
+		s.memory.mu.Lock()
+		s.memory.keys = before
+		s.memory.mu.Unlock()
+
+		return fmt.Errorf("write keys: %w", err)
+	}
+
+	return nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
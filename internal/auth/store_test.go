@@ -0,0 +1,110 @@
+package auth_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sampleKey(id string, created time.Time) auth.Key {
+	return auth.Key{ID: id, Name: id, Scope: auth.ScopeRead, Hash: "hash-" + id, CreatedAt: created}
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := auth.NewMemoryStore()
+	now := time.Now().UTC()
+
+	require.NoError(t, store.Create(ctx, sampleKey("b", now)))
+	require.NoError(t, store.Create(ctx, sampleKey("a", now)))
+	require.NoError(t, store.Create(ctx, sampleKey("c", now.Add(-time.Hour))))
+	require.ErrorIs(t, store.Create(ctx, sampleKey("a", now)), auth.ErrKeyExists)
+
+	keys, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	assert.Equal(t, []string{"c", "a", "b"}, []string{keys[0].ID, keys[1].ID, keys[2].ID})
+
+	updated := sampleKey("a", now)
+	updated.RevokedAt = now
+	require.NoError(t, store.Update(ctx, updated))
+
+	got, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, got.Revoked())
+
+	require.ErrorIs(t, store.Update(ctx, sampleKey("missing", now)), auth.ErrKeyNotFound)
+
+	_, err = store.Get(ctx, "missing")
+	assert.ErrorIs(t, err, auth.ErrKeyNotFound)
+}
+
+func TestFileStore_PersistsAcrossReopen(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	now := time.Now().UTC().Truncate(time.Second)
+
+	store, err := auth.OpenFileStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Create(ctx, sampleKey("a", now)))
+
+	revoked := sampleKey("a", now)
+	revoked.RevokedAt = now
+	require.NoError(t, store.Update(ctx, revoked))
+
+	reopened, err := auth.OpenFileStore(path)
+	require.NoError(t, err)
+
+	got, err := reopened.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, revoked, got)
+
+	keys, err := reopened.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestFileStore_WriteFailureIsUndone(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "missing", "keys.json")
+
+	store, err := auth.OpenFileStore(path)
+	require.NoError(t, err)
+
+	require.ErrorContains(t, store.Create(ctx, sampleKey("a", time.Now())), "write keys")
+
+	_, err = store.Get(ctx, "a")
+	require.ErrorIs(t, err, auth.ErrKeyNotFound)
+
+	require.ErrorIs(t, store.Update(ctx, sampleKey("a", time.Now())), auth.ErrKeyNotFound)
+}
+
+func TestOpenFileStore_Invalid(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "keys.json")
+	require.NoError(t, os.WriteFile(corrupt, []byte("{"), 0o600))
+
+	_, err := auth.OpenFileStore(corrupt)
+	require.ErrorContains(t, err, "decode keys")
+
+	_, err = auth.OpenFileStore(dir)
+	assert.ErrorContains(t, err, "read keys")
+}
@@ -0,0 +1,77 @@
+// Package cli implements featurectl, a command-line client for the flags API
+// meant to replace hand-written curl commands in runbooks.
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/serroba/features/internal/client"
+	"github.com/spf13/cobra"
+)
+
+// ServerEnv overrides the default server address.
+const ServerEnv = "FEATURECTL_SERVER"
+
+// APIKeyEnv holds the API key sent to the server. It is only read from the
+// environment so it stays out of shell history.
+const APIKeyEnv = "FEATURECTL_API_KEY" //nolint:gosec // an environment variable name, not a credential
+
+const defaultServer = "http://localhost:8080"
+
+// app holds the state shared by every command once flags are parsed.
+type app struct {
+	server string
+	output string
+	client *client.Client
+	out    io.Writer
+}
+
+// New returns the featurectl root command.
+func New() *cobra.Command {
+	a := &app{}
+
+	root := &cobra.Command{
+		Use:           "featurectl",
+		Short:         "Manage feature flags through the flags API",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			switch a.output {
+			case outputTable, outputJSON, outputYAML:
+			default:
+				return fmt.Errorf("unknown output format %q", a.output)
+			}
+
+			a.client = client.New(a.server, client.WithAPIKey(os.Getenv(APIKeyEnv)))
+			a.out = cmd.OutOrStdout()
+
+			return nil
+		},
+	}
+
+	server := defaultServer
+	if env := os.Getenv(ServerEnv); env != "" {
+		server = env
+	}
+
+	root.PersistentFlags().StringVarP(&a.server, "server", "s", server, "Server URL (or $"+ServerEnv+")")
+	root.PersistentFlags().StringVarP(&a.output, "output", "o", outputTable, "Output format: table, json or yaml")
+
+	root.AddCommand(
+		a.listCommand(),
+		a.getCommand(),
+		a.createCommand(),
+		a.updateCommand(),
+		a.deleteCommand(),
+		a.setEnabledCommand("enable", true),
+		a.setEnabledCommand("disable", false),
+		a.evalCommand(),
+		a.exportCommand(),
+		a.importCommand(),
+		a.diffCommand(),
+	)
+
+	return root
+}
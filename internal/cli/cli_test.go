@@ -0,0 +1,391 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/serroba/features/internal/cli"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const darkModeYAML = `
+flags:
+  - key: dark-mode
+    type: bool
+    enabled: true
+    defaultValue: {kind: bool, bool: false}
+    rules:
+      - id: beta
+        conditions:
+          - {attr: plan, op: in, value: [pro]}
+        value: {kind: bool, bool: true}
+`
+
+type testServer struct {
+	url     string
+	service *flags.Service
+}
+
+func newServer(t *testing.T) *testServer {
+	t.Helper()
+
+	service := flags.NewService(flags.NewMemoryRepository())
+	router := chi.NewRouter()
+	api := humachi.New(router, huma.DefaultConfig("Feature Flags API", "1.0.0"))
+	handler.New(service).Register(api)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &testServer{url: server.URL, service: service}
+}
+
+func (s *testServer) run(args ...string) (string, error) {
+	var out bytes.Buffer
+
+	cmd := cli.New()
+	cmd.SetArgs(append([]string{"--server", s.url}, args...))
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+
+	err := cmd.Execute()
+
+	return out.String(), err
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "flags.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestCLI_CreateGetList(t *testing.T) {
+	t.Parallel()
+
+	s := newServer(t)
+	path := writeFile(t, darkModeYAML)
+
+	out, err := s.run("create", "-f", path)
+	require.NoError(t, err)
+	assert.Contains(t, out, "dark-mode  bool  true     false    1      1        api")
+
+	out, err = s.run("get", "dark-mode", "-o", "json")
+	require.NoError(t, err)
+
+	var body handler.FlagBody
+	require.NoError(t, json.Unmarshal([]byte(out), &body))
+	assert.Equal(t, flags.FlagKey("dark-mode"), body.Key)
+	assert.Len(t, body.Rules, 1)
+
+	out, err = s.run("list", "-o", "yaml")
+	require.NoError(t, err)
+	assert.Contains(t, out, "- key: dark-mode\n  type: bool\n  enabled: true\n")
+
+	_, err = s.run("create", "-f", path)
+	require.ErrorContains(t, err, "create dark-mode: 409 Conflict")
+}
+
+func TestCLI_ListValueTypes(t *testing.T) {
+	t.Parallel()
+
+	s := newServer(t)
+
+	out, err := s.run("list")
+	require.NoError(t, err)
+	assert.Equal(t, "KEY  TYPE  ENABLED  DEFAULT  RULES  VERSION  MANAGED BY\n", out)
+
+	_, err = s.run("create", "-f", writeFile(t, `
+flags:
+  - {key: checkout, type: string, enabled: true, defaultValue: {kind: string, string: v2}}
+  - {key: limit, type: number, enabled: true, defaultValue: {kind: number, number: 2.5}}
+`))
+	require.NoError(t, err)
+
+	out, err = s.run("list")
+	require.NoError(t, err)
+	assert.Contains(t, out, `checkout  string  true     "v2"`)
+	assert.Contains(t, out, "limit     number  true     2.5")
+}
+
+func TestCLI_Update(t *testing.T) {
+	t.Parallel()
+
+	s := newServer(t)
+
+	_, err := s.run("create", "-f", writeFile(t, darkModeYAML))
+	require.NoError(t, err)
+
+	out, err := s.run("update", "-f",
+		writeFile(t, "flags:\n  - {key: dark-mode, type: bool, enabled: false, defaultValue: {kind: bool, bool: true}}"))
+	require.NoError(t, err)
+	assert.Contains(t, out, "dark-mode  bool  false    true     0      2")
+
+	_, err = s.run("update", "-f",
+		writeFile(t, "flags:\n  - {key: missing, type: bool, enabled: false, defaultValue: {kind: bool}}"))
+	require.ErrorContains(t, err, "update missing: 404 Not Found")
+}
+
+func TestCLI_EnableDisable(t *testing.T) {
+	t.Parallel()
+
+	s := newServer(t)
+
+	_, err := s.run("create", "-f", writeFile(t, darkModeYAML))
+	require.NoError(t, err)
+
+	out, err := s.run("disable", "dark-mode")
+	require.NoError(t, err)
+	assert.Contains(t, out, "dark-mode  bool  false")
+
+	out, err = s.run("enable", "dark-mode")
+	require.NoError(t, err)
+	assert.Contains(t, out, "dark-mode  bool  true")
+
+	flag, err := s.service.Get(context.Background(), "dark-mode")
+	require.NoError(t, err)
+	assert.Equal(t, 3, flag.Version)
+	assert.Len(t, flag.Rules, 1, "enable must not drop rules")
+
+	_, err = s.run("enable", "missing")
+	require.ErrorContains(t, err, "404 Not Found")
+}
+
+func TestCLI_EnableReadOnly(t *testing.T) {
+	t.Parallel()
+
+	s := newServer(t)
+	ctx := flags.WithManager(context.Background(), flags.ManagedByFile)
+
+	_, err := s.service.Create(ctx, flags.Flag{Key: "managed", Type: flags.FlagBool, DefaultValue: flags.BoolValue(false)})
+	require.NoError(t, err)
+
+	out, err := s.run("list")
+	require.NoError(t, err)
+	assert.Regexp(t, `managed +bool +false +false +0 +1 +file`, out)
+
+	_, err = s.run("enable", "managed")
+	require.ErrorContains(t, err, "read-only")
+}
+
+func TestCLI_Delete(t *testing.T) {
+	t.Parallel()
+
+	s := newServer(t)
+
+	_, err := s.run("create", "-f", writeFile(t, darkModeYAML))
+	require.NoError(t, err)
+
+	out, err := s.run("delete", "dark-mode")
+	require.NoError(t, err)
+	assert.Equal(t, "deleted dark-mode\n", out)
+
+	out, err = s.run("list", "-o", "json")
+	require.NoError(t, err)
+	assert.JSONEq(t, "[]", out)
+
+	_, err = s.run("delete", "dark-mode")
+	require.ErrorContains(t, err, "delete dark-mode: 404 Not Found")
+}
+
+func TestCLI_Eval(t *testing.T) {
+	t.Parallel()
+
+	s := newServer(t)
+
+	_, err := s.run("create", "-f", writeFile(t, darkModeYAML))
+	require.NoError(t, err)
+
+	out, err := s.run("eval", "dark-mode", "--user", "u1", "--tenant", "t1", "--attr", "plan=pro", "--attr", "seats=3")
+	require.NoError(t, err)
+	assert.Contains(t, out, "dark-mode  true   rule_match  beta")
+
+	out, err = s.run("eval", "dark-mode", "--attr", "plan=free", "-o", "json")
+	require.NoError(t, err)
+
+	var result handler.EvalResultBody
+	require.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, "default", result.Reason)
+
+	out, err = s.run("eval", "dark-mode")
+	require.NoError(t, err)
+	assert.Contains(t, out, "dark-mode  false  default  -")
+
+	_, err = s.run("eval", "dark-mode", "--attr", "plan")
+	require.ErrorContains(t, err, `invalid attribute "plan"`)
+
+	_, err = s.run("eval", "missing")
+	require.ErrorContains(t, err, "404 Not Found")
+}
+
+func TestCLI_ExportImport(t *testing.T) {
+	t.Parallel()
+
+	source := newServer(t)
+
+	_, err := source.run("create", "-f", writeFile(t, darkModeYAML))
+	require.NoError(t, err)
+
+	exported, err := source.run("export")
+	require.NoError(t, err)
+	assert.Contains(t, exported, "formatVersion: 1\n")
+	assert.Contains(t, exported, "flags:\n  - key: dark-mode\n")
+
+	path := filepath.Join(t.TempDir(), "export.json")
+	_, err = source.run("export", "-o", "json", "-f", path)
+	require.NoError(t, err)
+
+	target := newServer(t)
+
+	out, err := target.run("import", "-f", path)
+	require.NoError(t, err)
+	assert.Contains(t, out, "create  dark-mode  enabled")
+
+	got, err := target.service.Get(context.Background(), "dark-mode")
+	require.NoError(t, err)
+
+	want, err := source.service.Get(context.Background(), "dark-mode")
+	require.NoError(t, err)
+	assert.Empty(t, flags.Diff(&want, &got))
+
+	out, err = target.run("import", "-f", path)
+	require.NoError(t, err)
+	assert.Equal(t, "no changes\n", out)
+}
+
+func TestCLI_DiffAndImport(t *testing.T) {
+	t.Parallel()
+
+	s := newServer(t)
+
+	_, err := s.run("create", "-f", writeFile(t, darkModeYAML))
+	require.NoError(t, err)
+
+	path := writeFile(t, `
+formatVersion: 1
+flags:
+  - {key: checkout, type: string, enabled: true, defaultValue: {kind: string, string: v2}}
+  - {key: dark-mode, type: bool, enabled: false, defaultValue: {kind: bool, bool: false}}
+`)
+
+	out, err := s.run("diff", "-f", path)
+	require.NoError(t, err)
+	assert.Contains(t, out, "dry run, nothing was changed")
+	assert.Contains(t, out, "create  checkout   defaultValue  -")
+	assert.Regexp(t, `skip +dark-mode +enabled +true +false +flag exists`, out)
+
+	out, err = s.run("diff", "-f", path, "--mode", "merge")
+	require.NoError(t, err)
+	assert.Regexp(t, `update +dark-mode +enabled +true +false`, out)
+	assert.Contains(t, out, "update  dark-mode  rules[beta]")
+
+	out, err = s.run("diff", "-f", path, "--mode", "merge", "-o", "yaml")
+	require.NoError(t, err)
+	assert.Contains(t, out, "dryRun: true\nchanges:\n  - action: create\n    key: checkout\n")
+
+	_, err = s.service.Get(context.Background(), "checkout")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound, "diff must not change anything")
+
+	_, err = s.run("import", "-f", path, "--mode", "overwrite", "--dry-run")
+	require.NoError(t, err)
+
+	_, err = s.service.Get(context.Background(), "checkout")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound, "a dry run must not change anything")
+
+	_, err = s.run("import", "-f", path, "--mode", "merge")
+	require.NoError(t, err)
+
+	flag, err := s.service.Get(context.Background(), "dark-mode")
+	require.NoError(t, err)
+	assert.False(t, flag.Enabled)
+	assert.Empty(t, flag.Rules)
+
+	out, err = s.run("diff", "-f", path, "-o", "json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"dryRun": true, "changes": []}`, out)
+}
+
+func TestCLI_ImportSkipsManagedFlags(t *testing.T) {
+	t.Parallel()
+
+	s := newServer(t)
+	ctx := flags.WithManager(context.Background(), flags.ManagedByFile)
+
+	_, err := s.service.Create(ctx, flags.Flag{Key: "managed", Type: flags.FlagBool, DefaultValue: flags.BoolValue(false)})
+	require.NoError(t, err)
+
+	out, err := s.run("import", "--mode", "overwrite", "-f", writeFile(t, `
+formatVersion: 1
+flags:
+  - {key: a-flag, type: bool, enabled: true, defaultValue: {kind: bool, bool: false}}
+`))
+	require.NoError(t, err)
+	assert.Contains(t, out, "create  a-flag")
+	assert.Regexp(t, `skip +managed .* flag is read-only: managed by file`, out)
+
+	_, err = s.service.Get(context.Background(), "managed")
+	require.NoError(t, err)
+}
+
+func TestCLI_Errors(t *testing.T) {
+	t.Parallel()
+
+	s := newServer(t)
+	missing := filepath.Join(t.TempDir(), "missing.yaml")
+	invalid := writeFile(t, "flags: [{key: Bad}]")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "output", args: []string{"list", "-o", "xml"}, want: `unknown output format "xml"`},
+		{name: "args", args: []string{"get"}, want: "accepts 1 arg"},
+		{name: "file required", args: []string{"create"}, want: `required flag(s) "file" not set`},
+		{name: "create missing file", args: []string{"create", "-f", missing}, want: "no such file"},
+		{name: "update invalid file", args: []string{"update", "-f", invalid}, want: "invalid flags file"},
+		{name: "import missing file", args: []string{"import", "-f", missing}, want: "no such file"},
+		{name: "import invalid yaml", args: []string{"import", "-f", writeFile(t, "flags: [")}, want: "read archive"},
+		{name: "import wrong shape", args: []string{"import", "-f", writeFile(t, "flags: x")}, want: "read archive"},
+		{name: "import flags file", args: []string{"import", "-f", invalid}, want: "422 Unprocessable Entity"},
+		{name: "diff mode", args: []string{"diff", "-f", invalid, "--mode", "replace"}, want: "422 Unprocessable Entity"},
+		{name: "export to missing dir", args: []string{"export", "-f", filepath.Join(missing, "x")}, want: "no such file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := s.run(tt.args...)
+			require.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestCLI_ServerUnreachable(t *testing.T) {
+	t.Parallel()
+
+	s := newServer(t)
+	s.url = "http://127.0.0.1:1"
+	path := writeFile(t, darkModeYAML)
+
+	for _, args := range [][]string{
+		{"list"}, {"get", "dark-mode"}, {"create", "-f", path}, {"export"}, {"import", "-f", path}, {"diff", "-f", path},
+	} {
+		_, err := s.run(args...)
+		require.ErrorContains(t, err, "connection refused", args)
+	}
+}
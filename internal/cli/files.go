@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/serroba/features/internal/handler"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func (a *app) exportCommand() *cobra.Command {
+	var path string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write every flag to an archive (YAML unless -o json)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			archive, err := a.client.Export(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			format := outputYAML
+			if a.output == outputJSON {
+				format = outputJSON
+			}
+
+			var buf bytes.Buffer
+			if err := render(&buf, format, archive, nil); err != nil {
+				return err
+			}
+
+			if path == "" {
+				_, err = a.out.Write(buf.Bytes())
+
+				return err
+			}
+
+			return os.WriteFile(path, buf.Bytes(), 0o600)
+		},
+	}
+
+	cmd.Flags().StringVarP(&path, "file", "f", "", "Write to this file instead of standard output")
+
+	return cmd
+}
+
+func (a *app) importCommand() *cobra.Command {
+	var (
+		path   string
+		mode   string
+		dryRun bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import -f FILE",
+		Short: "Import an archive written by export, all or nothing",
+		Long: "Import an archive written by export. With --mode create-only missing flags\n" +
+			"are created; merge also updates flags that differ; overwrite also deletes\n" +
+			"flags missing from the archive. If any change fails, none is kept.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return a.runImport(cmd, path, mode, dryRun)
+		},
+	}
+
+	fileFlag(cmd, &path, "Archive file (YAML or JSON)")
+	modeFlag(cmd, &mode)
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show the changes without applying them")
+
+	return cmd
+}
+
+func (a *app) diffCommand() *cobra.Command {
+	var (
+		path string
+		mode string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "diff -f FILE",
+		Short: "Show what importing an archive would change",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return a.runImport(cmd, path, mode, true)
+		},
+	}
+
+	fileFlag(cmd, &path, "Archive file (YAML or JSON)")
+	modeFlag(cmd, &mode)
+
+	return cmd
+}
+
+func (a *app) runImport(cmd *cobra.Command, path, mode string, dryRun bool) error {
+	archive, err := readArchive(path)
+	if err != nil {
+		return err
+	}
+
+	result, err := a.client.Import(cmd.Context(), archive, mode, dryRun)
+	if err != nil {
+		return err
+	}
+
+	return render(a.out, a.output, result, func(w io.Writer) { writeChangeTable(w, result) })
+}
+
+func modeFlag(cmd *cobra.Command, mode *string) {
+	cmd.Flags().StringVar(mode, "mode", "create-only", "Import mode: create-only, merge or overwrite")
+}
+
+// readArchive reads a YAML or JSON archive. The server validates it.
+func readArchive(path string) (handler.ArchiveBody, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return handler.ArchiveBody{}, fmt.Errorf("read archive: %w", err)
+	}
+
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return handler.ArchiveBody{}, fmt.Errorf("read archive %s: %w", path, err)
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return handler.ArchiveBody{}, fmt.Errorf("read archive %s: %w", path, err)
+	}
+
+	var archive handler.ArchiveBody
+	if err := json.Unmarshal(normalized, &archive); err != nil {
+		return handler.ArchiveBody{}, fmt.Errorf("read archive %s: %w", path, err)
+	}
+
+	return archive, nil
+}
+
+func writeChangeTable(w io.Writer, result handler.ImportResponseBody) {
+	switch {
+	case len(result.Changes) == 0:
+		_, _ = fmt.Fprintln(w, "no changes")
+
+		return
+	case result.DryRun:
+		_, _ = fmt.Fprintln(w, "dry run, nothing was changed")
+	}
+
+	_, _ = fmt.Fprintln(w, "ACTION\tKEY\tFIELD\tBEFORE\tAFTER\tREASON")
+
+	for _, change := range result.Changes {
+		for _, fc := range change.Changes {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				change.Action, change.Key, fc.Path, formatAny(fc.Before), formatAny(fc.After), change.Reason)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flagsfile"
+	"github.com/serroba/features/internal/handler"
+	"github.com/spf13/cobra"
+)
+
+func (a *app) listCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List flags",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			bodies, err := a.client.List(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			return a.renderFlags(bodies...)
+		},
+	}
+}
+
+func (a *app) getCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get KEY",
+		Short: "Show a flag",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			body, err := a.client.Get(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			return a.renderFlag(body)
+		},
+	}
+}
+
+func (a *app) createCommand() *cobra.Command {
+	var path string
+
+	cmd := &cobra.Command{
+		Use:   "create -f FILE",
+		Short: "Create the flags declared in a flags file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			declared, err := loadFlags(path)
+			if err != nil {
+				return err
+			}
+
+			created := make([]handler.FlagBody, 0, len(declared))
+
+			for _, flag := range declared {
+				if _, err := a.client.Create(cmd.Context(), handler.ToCreateFlagBody(flag)); err != nil {
+					return fmt.Errorf("create %s: %w", flag.Key, err)
+				}
+
+				body, err := a.client.Get(cmd.Context(), string(flag.Key))
+				if err != nil {
+					return err
+				}
+
+				created = append(created, body)
+			}
+
+			return a.renderFlags(created...)
+		},
+	}
+
+	fileFlag(cmd, &path, "Flags file or directory, in the --flags-file format")
+
+	return cmd
+}
+
+func (a *app) updateCommand() *cobra.Command {
+	var path string
+
+	cmd := &cobra.Command{
+		Use:   "update -f FILE",
+		Short: "Replace the flags declared in a flags file",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			declared, err := loadFlags(path)
+			if err != nil {
+				return err
+			}
+
+			updated := make([]handler.FlagBody, 0, len(declared))
+
+			for _, flag := range declared {
+				body, err := a.client.Update(cmd.Context(), string(flag.Key), toUpdateBody(handler.ToCreateFlagBody(flag), 0))
+				if err != nil {
+					return fmt.Errorf("update %s: %w", flag.Key, err)
+				}
+
+				updated = append(updated, body)
+			}
+
+			return a.renderFlags(updated...)
+		},
+	}
+
+	fileFlag(cmd, &path, "Flags file or directory, in the --flags-file format")
+
+	return cmd
+}
+
+func (a *app) deleteCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete KEY...",
+		Short: "Delete flags and their revisions",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, key := range args {
+				if err := a.client.Delete(cmd.Context(), key); err != nil {
+					return fmt.Errorf("delete %s: %w", key, err)
+				}
+
+				_, _ = fmt.Fprintf(a.out, "deleted %s\n", key)
+			}
+
+			return nil
+		},
+	}
+}
+
+// setEnabledCommand returns the enable or disable command. The update is
+// conditional on the version read, so a concurrent change is not overwritten.
+func (a *app) setEnabledCommand(name string, enabled bool) *cobra.Command {
+	return &cobra.Command{
+		Use:   name + " KEY",
+		Short: strings.ToUpper(name[:1]) + name[1:] + " a flag",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			current, err := a.client.Get(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+
+			next := toUpdateBody(toCreateBody(current), current.Version)
+			next.Enabled = enabled
+
+			body, err := a.client.Update(cmd.Context(), args[0], next)
+			if err != nil {
+				return err
+			}
+
+			return a.renderFlag(body)
+		},
+	}
+}
+
+func (a *app) evalCommand() *cobra.Command {
+	var (
+		evalCtx handler.EvaluateFlagBody
+		attrs   []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "eval KEY",
+		Short: "Evaluate a flag for a user, tenant and attributes",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			parsed, err := parseAttrs(attrs)
+			if err != nil {
+				return err
+			}
+
+			evalCtx.Attrs = parsed
+
+			result, err := a.client.Evaluate(cmd.Context(), args[0], evalCtx)
+			if err != nil {
+				return err
+			}
+
+			return render(a.out, a.output, result, func(w io.Writer) {
+				ruleID := result.RuleID
+				if ruleID == "" {
+					ruleID = "-"
+				}
+
+				_, _ = fmt.Fprintln(w, "FLAG\tVALUE\tREASON\tRULE")
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", result.FlagKey, formatValue(result.Value), result.Reason, ruleID)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&evalCtx.UserID, "user", "", "User ID")
+	cmd.Flags().StringVar(&evalCtx.TenantID, "tenant", "", "Tenant ID")
+	cmd.Flags().StringArrayVar(&attrs, "attr", nil, "Attribute as key=value; JSON values keep their type (repeatable)")
+
+	return cmd
+}
+
+// parseAttrs parses key=value pairs. Values that are valid JSON (numbers,
+// booleans, lists) are decoded; anything else is taken as a string.
+func parseAttrs(pairs []string) (map[string]any, error) {
+	attrs := make(map[string]any, len(pairs))
+
+	for _, pair := range pairs {
+		key, raw, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid attribute %q, want key=value", pair)
+		}
+
+		var value any
+		if err := json.Unmarshal([]byte(raw), &value); err != nil {
+			value = raw
+		}
+
+		attrs[key] = value
+	}
+
+	return attrs, nil
+}
+
+func (a *app) renderFlag(body handler.FlagBody) error {
+	return render(a.out, a.output, body, func(w io.Writer) { writeFlagTable(w, body) })
+}
+
+func (a *app) renderFlags(bodies ...handler.FlagBody) error {
+	if bodies == nil {
+		bodies = []handler.FlagBody{}
+	}
+
+	return render(a.out, a.output, bodies, func(w io.Writer) { writeFlagTable(w, bodies...) })
+}
+
+func fileFlag(cmd *cobra.Command, path *string, usage string) {
+	cmd.Flags().StringVarP(path, "file", "f", "", usage)
+	_ = cmd.MarkFlagRequired("file")
+}
+
+// loadFlags reads and validates a flags file. The flags are written through
+// the API, so they are not marked as managed by files.
+func loadFlags(path string) ([]flags.Flag, error) {
+	declared, err := flagsfile.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range declared {
+		declared[i].ManagedBy = ""
+	}
+
+	return declared, nil
+}
+
+func toCreateBody(body handler.FlagBody) handler.CreateFlagBody {
+	return handler.CreateFlagBody{
+		Key:          string(body.Key),
+		MetadataBody: body.MetadataBody,
+		Type:         body.Type,
+		Enabled:      body.Enabled,
+		Protected:    body.Protected,
+		DefaultValue: body.DefaultValue,
+		Rules:        body.Rules,
+		Tests:        body.Tests,
+	}
+}
+
+// toUpdateBody returns the update that sets a flag to body; a non-zero version
+// makes it conditional.
+func toUpdateBody(body handler.CreateFlagBody, version int) handler.UpdateFlagBody {
+	return handler.UpdateFlagBody{
+		MetadataBody: body.MetadataBody,
+		Type:         body.Type,
+		Enabled:      body.Enabled,
+		Protected:    body.Protected,
+		DefaultValue: body.DefaultValue,
+		Rules:        body.Rules,
+		Tests:        body.Tests,
+		Version:      version,
+	}
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/serroba/features/internal/handler"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// render writes v as JSON or YAML, or calls table with a tab-aligned writer.
+func render(w io.Writer, format string, v any, table func(io.Writer)) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(v)
+	case outputYAML:
+		return writeYAML(w, v)
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		table(tw)
+
+		return tw.Flush()
+	}
+}
+
+// writeYAML encodes v through its JSON form so field names and order match the
+// API rather than Go's.
+func writeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+
+	resetStyle(&node)
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// resetStyle drops the flow style yaml.v3 keeps from the JSON input, so the
+// output uses block style.
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+func writeFlagTable(w io.Writer, bodies ...handler.FlagBody) {
+	_, _ = fmt.Fprintln(w, "KEY\tTYPE\tENABLED\tDEFAULT\tRULES\tVERSION\tMANAGED BY")
+
+	for _, body := range bodies {
+		managedBy := body.ManagedBy
+		if managedBy == "" {
+			managedBy = "api"
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%d\t%d\t%s\n",
+			body.Key, body.Type, body.Enabled, formatValue(body.DefaultValue), len(body.Rules), body.Version, managedBy)
+	}
+}
+
+func formatValue(value handler.ValueBody) string {
+	switch {
+	case value.Bool != nil:
+		return strconv.FormatBool(*value.Bool)
+	case value.String != nil:
+		return strconv.Quote(*value.String)
+	case value.Number != nil:
+		return strconv.FormatFloat(*value.Number, 'g', -1, 64)
+	default:
+		return "-"
+	}
+}
+
+// formatAny renders a diff value compactly for tables.
+func formatAny(value any) string {
+	if value == nil {
+		return "-"
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case handler.ValueBody:
+		return formatValue(v)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,213 @@
+// Package client is a typed HTTP client for the flags API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/serroba/features/internal/handler"
+)
+
+// APIError is returned for non-2xx responses. It carries the problem details
+// the server sent, so callers can match on StatusCode.
+type APIError struct {
+	StatusCode int    `json:"status"`
+	Title      string `json:"title"`
+	Detail     string `json:"detail"`
+}
+
+func (e *APIError) Error() string {
+	if e.Detail == "" {
+		return fmt.Sprintf("%d %s", e.StatusCode, e.Title)
+	}
+
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Title, e.Detail)
+}
+
+// PendingChangeError is returned when the server opened a change request
+// instead of changing a protected flag.
+type PendingChangeError struct {
+	Change handler.ChangeRequestBody
+}
+
+func (e *PendingChangeError) Error() string {
+	return fmt.Sprintf("flag %s is protected: change request %s awaits approval", e.Change.FlagKey, e.Change.ID)
+}
+
+type Client struct {
+	baseURL string
+	apiKey  string
+	http    *http.Client
+}
+
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.http = httpClient
+	}
+}
+
+// WithAPIKey sends apiKey as a bearer token with every request.
+func WithAPIKey(apiKey string) Option {
+	return func(c *Client) {
+		c.apiKey = apiKey
+	}
+}
+
+// New returns a client for the server at baseURL, e.g. http://localhost:8080.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{baseURL: strings.TrimRight(baseURL, "/"), http: http.DefaultClient}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *Client) List(ctx context.Context) ([]handler.FlagBody, error) {
+	var body handler.ListFlagsResponseBody
+	if err := c.do(ctx, http.MethodGet, "/flags", nil, &body); err != nil {
+		return nil, err
+	}
+
+	return body.Flags, nil
+}
+
+func (c *Client) Get(ctx context.Context, key string) (handler.FlagBody, error) {
+	var body handler.FlagBody
+
+	err := c.do(ctx, http.MethodGet, flagPath(key), nil, &body)
+
+	return body, err
+}
+
+func (c *Client) Create(ctx context.Context, flag handler.CreateFlagBody) (handler.CreateFlagResponseBody, error) {
+	var body handler.CreateFlagResponseBody
+
+	err := c.do(ctx, http.MethodPost, "/flags", flag, &body)
+
+	return body, err
+}
+
+func (c *Client) Update(ctx context.Context, key string, flag handler.UpdateFlagBody) (handler.FlagBody, error) {
+	var body handler.FlagBody
+
+	err := c.do(ctx, http.MethodPut, flagPath(key), flag, &body)
+
+	return body, err
+}
+
+func (c *Client) Delete(ctx context.Context, key string) error {
+	return c.do(ctx, http.MethodDelete, flagPath(key), nil, nil)
+}
+
+func (c *Client) Evaluate(
+	ctx context.Context, key string, evalCtx handler.EvaluateFlagBody,
+) (handler.EvalResultBody, error) {
+	var body handler.EvalResultBody
+
+	err := c.do(ctx, http.MethodPost, flagPath(key)+"/evaluate", evalCtx, &body)
+
+	return body, err
+}
+
+func (c *Client) Export(ctx context.Context) (handler.ArchiveBody, error) {
+	var body handler.ArchiveBody
+
+	err := c.do(ctx, http.MethodGet, "/export", nil, &body)
+
+	return body, err
+}
+
+// Import sends archive to POST /import with the given mode; see flags.ImportMode.
+func (c *Client) Import(
+	ctx context.Context, archive handler.ArchiveBody, mode string, dryRun bool,
+) (handler.ImportResponseBody, error) {
+	query := url.Values{"mode": {mode}, "dryRun": {strconv.FormatBool(dryRun)}}
+
+	var body handler.ImportResponseBody
+
+	err := c.do(ctx, http.MethodPost, "/import?"+query.Encode(), archive, &body)
+
+	return body, err
+}
+
+func flagPath(key string) string {
+	return "/flags/" + url.PathEscape(key)
+}
+
+// do sends in as the JSON request body, when not nil, and decodes the response
+// into out, when not nil.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var reqBody io.Reader
+
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	return decode(resp, out)
+}
+
+// decode turns error responses into *APIError and 202 Accepted into
+// *PendingChangeError, and otherwise decodes the body into out, when not nil.
+func decode(resp *http.Response, out any) error {
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+		_ = json.NewDecoder(resp.Body).Decode(apiErr)
+
+		return apiErr
+	}
+
+	if resp.StatusCode == http.StatusAccepted {
+		pending := &PendingChangeError{}
+		if err := json.NewDecoder(resp.Body).Decode(&pending.Change); err != nil {
+			return fmt.Errorf("decode response: %w", err)
+		}
+
+		return pending
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+
+	return nil
+}
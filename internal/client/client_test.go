@@ -0,0 +1,239 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/adapters/humachi"
+	"github.com/go-chi/chi/v5"
+	"github.com/serroba/features/internal/client"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClient(t *testing.T) *client.Client {
+	t.Helper()
+
+	router := chi.NewRouter()
+	api := humachi.New(router, huma.DefaultConfig("Feature Flags API", "1.0.0"))
+	handler.New(flags.NewService(flags.NewMemoryRepository())).Register(api)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return client.New(server.URL+"/", client.WithHTTPClient(server.Client()))
+}
+
+func boolValue(b bool) handler.ValueBody {
+	return handler.ValueBody{Kind: "bool", Bool: &b}
+}
+
+func TestClient_Lifecycle(t *testing.T) {
+	t.Parallel()
+
+	c := newClient(t)
+	ctx := context.Background()
+
+	created, err := c.Create(ctx, handler.CreateFlagBody{
+		Key:          "dark-mode",
+		Type:         "bool",
+		DefaultValue: boolValue(false),
+		Rules: []handler.RuleBody{{
+			ID:         "beta",
+			Conditions: []handler.ConditionBody{{Attr: "plan", Op: "eq", Value: "pro"}},
+			Value:      boolValue(true),
+		}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, flags.FlagKey("dark-mode"), created.Key)
+
+	updated, err := c.Update(ctx, "dark-mode", handler.UpdateFlagBody{
+		Type:         "bool",
+		Enabled:      true,
+		DefaultValue: boolValue(false),
+		Rules: []handler.RuleBody{{
+			ID:         "beta",
+			Conditions: []handler.ConditionBody{{Attr: "plan", Op: "eq", Value: "pro"}},
+			Value:      boolValue(true),
+		}},
+		Version: 1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	got, err := c.Get(ctx, "dark-mode")
+	require.NoError(t, err)
+	assert.True(t, got.Enabled)
+
+	result, err := c.Evaluate(ctx, "dark-mode", handler.EvaluateFlagBody{Attrs: map[string]any{"plan": "pro"}})
+	require.NoError(t, err)
+	assert.Equal(t, "rule_match", result.Reason)
+	assert.Equal(t, "beta", result.RuleID)
+
+	all, err := c.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+
+	require.NoError(t, c.Delete(ctx, "dark-mode"))
+
+	all, err = c.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestClient_APIError(t *testing.T) {
+	t.Parallel()
+
+	c := newClient(t)
+
+	_, err := c.Get(context.Background(), "missing")
+
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "404 Not Found: flag not found", err.Error())
+}
+
+func TestClient_APIErrorWithoutDetail(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(server.Close)
+
+	err := client.New(server.URL).Delete(context.Background(), "dark-mode")
+	assert.EqualError(t, err, "502 Bad Gateway")
+}
+
+func TestClient_InvalidResponse(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("<html>"))
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := client.New(server.URL).List(context.Background())
+	assert.ErrorContains(t, err, "decode response")
+}
+
+func TestClient_Unreachable(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	_, err := client.New(server.URL).List(context.Background())
+	assert.ErrorContains(t, err, "GET /flags")
+}
+
+func TestClient_InvalidRequest(t *testing.T) {
+	t.Parallel()
+
+	_, err := client.New("http://localhost").Evaluate(context.Background(), "dark-mode",
+		handler.EvaluateFlagBody{Attrs: map[string]any{"bad": make(chan int)}})
+	require.ErrorContains(t, err, "encode request")
+
+	_, err = client.New("http://[::1").List(context.Background())
+	assert.ErrorContains(t, err, "build request")
+}
+
+func TestClient_ExportImport(t *testing.T) {
+	t.Parallel()
+
+	source := newClient(t)
+	ctx := context.Background()
+
+	_, err := source.Create(ctx, handler.CreateFlagBody{Key: "dark-mode", Type: "bool", DefaultValue: boolValue(false)})
+	require.NoError(t, err)
+
+	archive, err := source.Export(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, archive.FormatVersion)
+	require.Len(t, archive.Flags, 1)
+
+	target := newClient(t)
+
+	result, err := target.Import(ctx, archive, "merge", true)
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	require.Len(t, result.Changes, 1)
+	assert.Equal(t, "create", result.Changes[0].Action)
+
+	all, err := target.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+
+	_, err = target.Import(ctx, archive, "merge", false)
+	require.NoError(t, err)
+
+	all, err = target.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+}
+
+func TestClient_WithAPIKey(t *testing.T) {
+	t.Parallel()
+
+	var authorization string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"flags":[]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := client.New(server.URL, client.WithAPIKey("ffk_id_secret")).List(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer ffk_id_secret", authorization)
+
+	_, err = client.New(server.URL).List(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, authorization)
+}
+
+func TestClient_PendingChange(t *testing.T) {
+	t.Parallel()
+
+	c := newClient(t)
+	ctx := context.Background()
+
+	_, err := c.Create(ctx, handler.CreateFlagBody{
+		Key:          "kill-switch",
+		Type:         "bool",
+		Enabled:      true,
+		Protected:    true,
+		DefaultValue: boolValue(true),
+	})
+	require.NoError(t, err)
+
+	err = c.Delete(ctx, "kill-switch")
+
+	var pending *client.PendingChangeError
+	require.ErrorAs(t, err, &pending)
+	assert.Equal(t, "delete", pending.Change.Action)
+	assert.Equal(t, "pending", pending.Change.Status)
+	require.ErrorContains(t, err, "flag kill-switch is protected: change request "+pending.Change.ID)
+
+	flag, err := c.Get(ctx, "kill-switch")
+	require.NoError(t, err)
+	assert.True(t, flag.Protected)
+}
+
+func TestClient_InvalidPendingChange(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+
+	err := client.New(server.URL).Delete(context.Background(), "dark-mode")
+	assert.ErrorContains(t, err, "decode response")
+}
@@ -0,0 +1,412 @@
+package flags
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrApprovalRequired = errors.New("change requires approval")
+	ErrSelfApproval     = errors.New("requesters cannot approve their own change")
+	ErrChangeClosed     = errors.New("change request is closed")
+	ErrChangeNotReady   = errors.New("change request is not approved")
+	ErrChangeConflict   = errors.New("change conflicts with the current flag")
+)
+
+// PendingChangeError is returned by mutations that need approval. The
+// mutation was not applied; Change was opened instead.
+type PendingChangeError struct {
+	Change ChangeRequest
+}
+
+func (e *PendingChangeError) Error() string {
+	return fmt.Sprintf("%s: opened change request %s", ErrApprovalRequired, e.Change.ID)
+}
+
+func (e *PendingChangeError) Unwrap() error {
+	return ErrApprovalRequired
+}
+
+// ApprovalPolicy decides which mutations need a change request. Mutations of
+// protected flags always do, unless they are emergencies or come from a
+// manager such as a flags file, whose changes are reviewed where the file
+// lives.
+type ApprovalPolicy struct {
+	// ProtectAll treats every flag as protected, creations included, as
+	// suits a production environment.
+	ProtectAll bool
+	// Approvals is how many people other than the requester must approve a
+	// change. Values below 1 mean 1.
+	Approvals int
+}
+
+// WithApprovalPolicy replaces the default policy, which protects flags
+// marked as protected with one approval.
+func WithApprovalPolicy(policy ApprovalPolicy) Option {
+	return func(s *Service) {
+		s.approvals = policy
+	}
+}
+
+func (p ApprovalPolicy) required() int {
+	return max(p.Approvals, 1)
+}
+
+// needsApproval reports whether changing current, or creating a flag when
+// current is nil, must go through a change request.
+func (s *Service) needsApproval(ctx context.Context, current *Flag) bool {
+	if _, approved := approvalFromContext(ctx); approved || EmergencyFromContext(ctx) || ManagerFromContext(ctx) != "" {
+		return false
+	}
+
+	return s.approvals.ProtectAll || (current != nil && current.Protected)
+}
+
+// propose opens a change request turning base into proposed and returns it
+// as a *PendingChangeError.
+func (s *Service) propose(ctx context.Context, action AuditAction, base, proposed *Flag) error {
+	var key FlagKey
+
+	if base != nil {
+		if err := checkManager(ctx, *base); err != nil {
+			return err
+		}
+
+		key = base.Key
+	} else {
+		if err := s.checkMissing(ctx, proposed.Key); err != nil {
+			return err
+		}
+
+		key = proposed.Key
+	}
+
+	if proposed != nil {
+		next := proposed.Clone()
+		next.Key = key
+		next.Version = 0
+		next.UpdatedAt = time.Time{}
+		next.ManagedBy = ""
+		proposed = &next
+
+		if err := s.check(next); err != nil {
+			return err
+		}
+	}
+
+	id, err := newID()
+	if err != nil {
+		return err
+	}
+
+	now := s.clock.Now()
+	change := ChangeRequest{
+		ID:                id,
+		FlagKey:           key,
+		Action:            action,
+		Base:              base,
+		Proposed:          proposed,
+		Diff:              Diff(base, proposed),
+		Requester:         ActorFromContext(ctx),
+		RequiredApprovals: s.approvals.required(),
+		Status:            ChangePending,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	if err := s.changes.Create(ctx, change); err != nil {
+		return fmt.Errorf("open change request: %w", err)
+	}
+
+	return &PendingChangeError{Change: change}
+}
+
+// checkMissing returns ErrFlagExists when key is taken, so creations that
+// cannot be applied are not proposed.
+func (s *Service) checkMissing(ctx context.Context, key FlagKey) error {
+	_, err := s.repo.Get(ctx, key)
+
+	switch {
+	case err == nil:
+		return ErrFlagExists
+	case errors.Is(err, ErrFlagNotFound):
+		return nil
+	default:
+		return err
+	}
+}
+
+func (s *Service) ChangeRequests(ctx context.Context, filter ChangeFilter) ([]ChangeRequest, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionRead, filter.FlagKey); err != nil {
+		return nil, err
+	}
+
+	return s.changes.List(ctx, filter)
+}
+
+func (s *Service) ChangeRequest(ctx context.Context, id string) (ChangeRequest, error) {
+	change, err := s.changes.Get(ctx, id)
+	if err != nil {
+		return ChangeRequest{}, err
+	}
+
+	if err := s.authorizer.Authorize(ctx, PermissionRead, change.FlagKey); err != nil {
+		return ChangeRequest{}, err
+	}
+
+	return change, nil
+}
+
+// Approve records the caller's approval, with an optional comment. The change
+// becomes approved once it has its required approvals. Approving twice is a
+// no-op.
+func (s *Service) Approve(ctx context.Context, id, comment string) (ChangeRequest, error) {
+	return s.review(ctx, id, comment, func(change *ChangeRequest, actor string) error {
+		if err := s.authorizer.Authorize(ctx, PermissionApprove, change.FlagKey); err != nil {
+			return err
+		}
+
+		if change.Closed() {
+			return ErrChangeClosed
+		}
+
+		if actor == change.Requester {
+			return ErrSelfApproval
+		}
+
+		if !slices.Contains(change.Approvers, actor) {
+			change.Approvers = append(change.Approvers, actor)
+		}
+
+		if len(change.Approvers) >= change.RequiredApprovals {
+			change.Status = ChangeApproved
+		}
+
+		return nil
+	})
+}
+
+// Reject closes the change without applying it. Requesters may withdraw
+// their own changes; anyone else needs the approve permission.
+func (s *Service) Reject(ctx context.Context, id, comment string) (ChangeRequest, error) {
+	return s.review(ctx, id, comment, func(change *ChangeRequest, actor string) error {
+		if err := s.authorizer.Authorize(ctx, PermissionRead, change.FlagKey); err != nil {
+			return err
+		}
+
+		if change.Closed() {
+			return ErrChangeClosed
+		}
+
+		if actor != change.Requester {
+			if err := s.authorizer.Authorize(ctx, PermissionApprove, change.FlagKey); err != nil {
+				return err
+			}
+		}
+
+		change.Status = ChangeRejected
+		change.ClosedBy = actor
+		change.ClosedAt = change.UpdatedAt
+
+		return nil
+	})
+}
+
+// Comment adds a comment to the change, open or closed.
+func (s *Service) Comment(ctx context.Context, id, text string) (ChangeRequest, error) {
+	return s.review(ctx, id, text, func(change *ChangeRequest, _ string) error {
+		return s.authorizer.Authorize(ctx, PermissionRead, change.FlagKey)
+	})
+}
+
+// review applies update to the stored change, adds comment when it is not
+// empty and stores the result. Reviews of all changes are serialized.
+func (s *Service) review(
+	ctx context.Context, id, comment string, update func(change *ChangeRequest, actor string) error,
+) (ChangeRequest, error) {
+	s.reviews.Lock()
+	defer s.reviews.Unlock()
+
+	change, err := s.changes.Get(ctx, id)
+	if err != nil {
+		return ChangeRequest{}, err
+	}
+
+	actor := ActorFromContext(ctx)
+	change.UpdatedAt = s.clock.Now()
+
+	if err := update(&change, actor); err != nil {
+		return ChangeRequest{}, err
+	}
+
+	if comment != "" {
+		change.Comments = append(change.Comments, Comment{Author: actor, Text: comment, Time: change.UpdatedAt})
+	}
+
+	if err := s.changes.Update(ctx, change); err != nil {
+		return ChangeRequest{}, err
+	}
+
+	return change, nil
+}
+
+// Apply makes an approved change. An update or rollback is rebased on the
+// current flag when the fields it changes were not changed since it was
+// requested; otherwise, and for deletions of a changed flag, ErrChangeConflict
+// is returned and the change stays approved.
+func (s *Service) Apply(ctx context.Context, id string) (ChangeRequest, error) {
+	s.reviews.Lock()
+	defer s.reviews.Unlock()
+
+	change, err := s.changes.Get(ctx, id)
+	if err != nil {
+		return ChangeRequest{}, err
+	}
+
+	if err := s.authorizer.Authorize(ctx, PermissionWrite, change.FlagKey); err != nil {
+		return ChangeRequest{}, err
+	}
+
+	switch {
+	case change.Closed():
+		return ChangeRequest{}, ErrChangeClosed
+	case change.Status != ChangeApproved:
+		return ChangeRequest{}, ErrChangeNotReady
+	}
+
+	flag, err := s.applyChange(withApproval(ctx, id), change)
+	if err != nil {
+		return ChangeRequest{}, err
+	}
+
+	now := s.clock.Now()
+	change.Status = ChangeApplied
+	change.ClosedBy = ActorFromContext(ctx)
+	change.ClosedAt = now
+	change.UpdatedAt = now
+	change.AppliedVersion = flag.Version
+
+	if err := s.changes.Update(ctx, change); err != nil {
+		return ChangeRequest{}, fmt.Errorf("close change request: %w", err)
+	}
+
+	return change, nil
+}
+
+func (s *Service) applyChange(ctx context.Context, change ChangeRequest) (Flag, error) {
+	if change.Action == AuditCreate {
+		return s.create(ctx, *change.Proposed)
+	}
+
+	current, err := s.repo.Get(ctx, change.FlagKey)
+	if err != nil {
+		return Flag{}, err
+	}
+
+	if change.Action == AuditDelete {
+		if current.Version != change.Base.Version {
+			return Flag{}, fmt.Errorf("%w: flag is at version %d, not %d", ErrChangeConflict,
+				current.Version, change.Base.Version)
+		}
+
+		return Flag{}, s.remove(ctx, current)
+	}
+
+	next, err := rebase(*change.Base, current, *change.Proposed)
+	if err != nil {
+		return Flag{}, err
+	}
+
+	return s.replace(ctx, change.Action, current, next)
+}
+
+// rebase returns proposed, which was based on base, applied on top of
+// current: the fields proposed changes are taken from it and the rest from
+// current. Rules count as a single field.
+func rebase(base, current, proposed Flag) (Flag, error) {
+	if current.Version == base.Version {
+		return proposed, nil
+	}
+
+	theirs := changedFields(Diff(&base, &current))
+	ours := changedFields(Diff(&base, &proposed))
+
+	next := current.Clone()
+
+	for _, field := range ours {
+		if slices.Contains(theirs, field) {
+			return Flag{}, fmt.Errorf("%w: %s changed since version %d", ErrChangeConflict, field, base.Version)
+		}
+
+		rebaseField(&next, proposed, field)
+	}
+
+	return next, nil
+}
+
+// rebaseField copies field from proposed onto next.
+func rebaseField(next *Flag, proposed Flag, field string) {
+	switch field {
+	case pathType:
+		next.Type = proposed.Type
+	case pathEnabled:
+		next.Enabled = proposed.Enabled
+	case pathDefaultValue:
+		next.DefaultValue = proposed.DefaultValue.clone()
+	case pathProtected:
+		next.Protected = proposed.Protected
+	case pathRules:
+		next.Rules = proposed.Clone().Rules
+	case pathTests:
+		next.Tests = proposed.Clone().Tests
+	default:
+		rebaseMetadata(next, proposed, field)
+	}
+}
+
+func rebaseMetadata(next *Flag, proposed Flag, field string) {
+	switch field {
+	case pathDescription:
+		next.Description = proposed.Description
+	case pathOwner:
+		next.Owner = proposed.Owner
+	case pathTags:
+		next.Tags = slices.Clone(proposed.Tags)
+	case pathKind:
+		next.Kind = proposed.Kind
+	case pathExpiresAt:
+		next.ExpiresAt = proposed.ExpiresAt
+	}
+}
+
+func changedFields(changes []FieldChange) []string {
+	var fields []string
+
+	for _, change := range changes {
+		field := change.Path
+		if strings.HasPrefix(field, pathRules) {
+			field = pathRules
+		}
+
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,489 @@
+package flags_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func as(actor string) context.Context {
+	return flags.WithActor(context.Background(), actor)
+}
+
+// newProtectedFlag creates an enabled, protected kill switch.
+func newProtectedFlag(t *testing.T, svc *flags.Service) flags.Flag {
+	t.Helper()
+
+	flag := boolFlag("kill-switch", true)
+	flag.Protected = true
+
+	created, err := svc.Create(as("alice"), flag)
+	require.NoError(t, err)
+
+	return created
+}
+
+// pending asserts that err opened a change request and returns it.
+func pending(t *testing.T, err error) flags.ChangeRequest {
+	t.Helper()
+
+	var opened *flags.PendingChangeError
+	require.ErrorAs(t, err, &opened)
+	require.ErrorIs(t, err, flags.ErrApprovalRequired)
+	assert.Contains(t, err.Error(), opened.Change.ID)
+
+	return opened.Change
+}
+
+func TestService_ProtectedUpdate(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newProtectedFlag(t, svc)
+
+	next := created
+	next.Enabled = false
+
+	_, err := svc.Update(as("alice"), next)
+	change := pending(t, err)
+	assert.Equal(t, flags.ChangePending, change.Status)
+	assert.Equal(t, flags.AuditUpdate, change.Action)
+	assert.Equal(t, "alice", change.Requester)
+	assert.Equal(t, 1, change.RequiredApprovals)
+	assert.Equal(t, []flags.FieldChange{
+		{Path: "enabled", Kind: flags.ChangeModified, Before: true, After: false},
+	}, change.Diff)
+
+	current, err := svc.Get(context.Background(), created.Key)
+	require.NoError(t, err)
+	assert.True(t, current.Enabled, "nothing changes before approval")
+
+	_, err = svc.Apply(as("alice"), change.ID)
+	require.ErrorIs(t, err, flags.ErrChangeNotReady)
+
+	_, err = svc.Approve(as("alice"), change.ID, "")
+	require.ErrorIs(t, err, flags.ErrSelfApproval)
+
+	approved, err := svc.Approve(as("bob"), change.ID, "looks good")
+	require.NoError(t, err)
+	assert.Equal(t, flags.ChangeApproved, approved.Status)
+	assert.Equal(t, []string{"bob"}, approved.Approvers)
+	require.Len(t, approved.Comments, 1)
+	assert.Equal(t, "bob", approved.Comments[0].Author)
+
+	applied, err := svc.Apply(as("alice"), change.ID)
+	require.NoError(t, err)
+	assert.Equal(t, flags.ChangeApplied, applied.Status)
+	assert.Equal(t, "alice", applied.ClosedBy)
+	assert.Equal(t, 2, applied.AppliedVersion)
+
+	current, err = svc.Get(context.Background(), created.Key)
+	require.NoError(t, err)
+	assert.False(t, current.Enabled)
+	assert.True(t, current.Protected)
+
+	page, err := svc.AuditLog(context.Background(), flags.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 2)
+	assert.Equal(t, change.ID, page.Entries[1].ChangeID)
+	assert.Equal(t, "alice", page.Entries[1].Actor)
+
+	_, err = svc.Apply(as("alice"), change.ID)
+	require.ErrorIs(t, err, flags.ErrChangeClosed)
+
+	_, err = svc.Approve(as("carol"), change.ID, "")
+	require.ErrorIs(t, err, flags.ErrChangeClosed)
+}
+
+func TestService_ProtectedDeleteAndRollback(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newProtectedFlag(t, svc)
+
+	next := created
+	next.Enabled = false
+
+	_, err := svc.Update(flags.WithEmergency(as("alice")), next)
+	require.NoError(t, err)
+
+	_, err = svc.Rollback(as("alice"), created.Key, 1)
+	rollback := pending(t, err)
+	assert.Equal(t, flags.AuditRollback, rollback.Action)
+
+	err = svc.Delete(as("alice"), created.Key)
+	deletion := pending(t, err)
+	assert.Equal(t, flags.AuditDelete, deletion.Action)
+	assert.Nil(t, deletion.Proposed)
+
+	for _, id := range []string{rollback.ID, deletion.ID} {
+		_, err = svc.Approve(as("bob"), id, "")
+		require.NoError(t, err)
+	}
+
+	rolledBack, err := svc.Apply(as("alice"), rollback.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, rolledBack.AppliedVersion)
+
+	_, err = svc.Apply(as("alice"), deletion.ID)
+	require.ErrorIs(t, err, flags.ErrChangeConflict, "the flag changed since the deletion was requested")
+
+	err = svc.Delete(as("alice"), created.Key)
+	deletion = pending(t, err)
+
+	_, err = svc.Approve(as("bob"), deletion.ID, "")
+	require.NoError(t, err)
+
+	deleted, err := svc.Apply(as("alice"), deletion.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted.AppliedVersion)
+
+	_, err = svc.Get(context.Background(), created.Key)
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+}
+
+func TestService_ApplyRebases(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newProtectedFlag(t, svc)
+
+	disable := created
+	disable.Enabled = false
+
+	_, err := svc.Update(as("alice"), disable)
+	disableChange := pending(t, err)
+
+	newDefault := created
+	newDefault.DefaultValue = flags.BoolValue(true)
+
+	_, err = svc.Update(as("carol"), newDefault)
+	defaultChange := pending(t, err)
+
+	// An emergency changes the default value in the meantime.
+	_, err = svc.Update(flags.WithEmergency(as("dave")), newDefault)
+	require.NoError(t, err)
+
+	for _, id := range []string{disableChange.ID, defaultChange.ID} {
+		_, err = svc.Approve(as("bob"), id, "")
+		require.NoError(t, err)
+	}
+
+	_, err = svc.Apply(as("alice"), disableChange.ID)
+	require.NoError(t, err, "enabled was not changed since")
+
+	current, err := svc.Get(context.Background(), created.Key)
+	require.NoError(t, err)
+	assert.False(t, current.Enabled)
+	assert.Equal(t, flags.BoolValue(true), current.DefaultValue, "the emergency change is kept")
+	assert.Equal(t, 3, current.Version)
+
+	_, err = svc.Apply(as("carol"), defaultChange.ID)
+	require.ErrorIs(t, err, flags.ErrChangeConflict)
+
+	stillApproved, err := svc.ChangeRequest(context.Background(), defaultChange.ID)
+	require.NoError(t, err)
+	assert.Equal(t, flags.ChangeApproved, stillApproved.Status)
+}
+
+func TestService_ApplyRebasesRules(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newProtectedFlag(t, svc)
+
+	withRule := created
+	withRule.Rules = []flags.Rule{{
+		ID:         "beta",
+		Conditions: []flags.Condition{{Attr: "plan", Op: flags.OpEquals, Value: "beta"}},
+		Value:      flags.BoolValue(true),
+	}}
+
+	_, err := svc.Update(as("alice"), withRule)
+	change := pending(t, err)
+
+	unprotected := created
+	unprotected.Protected = false
+	_, err = svc.Update(flags.WithEmergency(as("dave")), unprotected)
+	require.NoError(t, err)
+
+	_, err = svc.Approve(as("bob"), change.ID, "")
+	require.NoError(t, err)
+
+	_, err = svc.Apply(as("alice"), change.ID)
+	require.NoError(t, err)
+
+	current, err := svc.Get(context.Background(), created.Key)
+	require.NoError(t, err)
+	assert.False(t, current.Protected)
+	assert.Equal(t, withRule.Rules, current.Rules)
+}
+
+func TestService_ApplyRebasesMetadata(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newProtectedFlag(t, svc)
+
+	described := created
+	described.Description = "New checkout"
+	described.Owner = "payments"
+	described.Tags = []string{"checkout"}
+	described.Kind = flags.KindRelease
+	described.ExpiresAt = time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC)
+
+	_, err := svc.Update(as("alice"), described)
+	change := pending(t, err)
+
+	disabled := created
+	disabled.Enabled = false
+	_, err = svc.Update(flags.WithEmergency(as("dave")), disabled)
+	require.NoError(t, err)
+
+	_, err = svc.Approve(as("bob"), change.ID, "")
+	require.NoError(t, err)
+
+	_, err = svc.Apply(as("alice"), change.ID)
+	require.NoError(t, err)
+
+	current, err := svc.Get(context.Background(), created.Key)
+	require.NoError(t, err)
+	assert.False(t, current.Enabled, "the emergency change is kept")
+	assert.Equal(t, "New checkout", current.Description)
+	assert.Equal(t, "payments", current.Owner)
+	assert.Equal(t, []string{"checkout"}, current.Tags)
+	assert.Equal(t, flags.KindRelease, current.Kind)
+	assert.Equal(t, described.ExpiresAt, current.ExpiresAt)
+}
+
+func TestService_ApplyRebasesTests(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newProtectedFlag(t, svc)
+
+	tested := created
+	tested.Tests = []flags.FlagTest{{Name: "off for everyone", Expect: flags.BoolValue(false)}}
+
+	_, err := svc.Update(as("alice"), tested)
+	change := pending(t, err)
+
+	disabled := created
+	disabled.Enabled = false
+	_, err = svc.Update(flags.WithEmergency(as("dave")), disabled)
+	require.NoError(t, err)
+
+	_, err = svc.Approve(as("bob"), change.ID, "")
+	require.NoError(t, err)
+
+	_, err = svc.Apply(as("alice"), change.ID)
+	require.NoError(t, err)
+
+	current, err := svc.Get(context.Background(), created.Key)
+	require.NoError(t, err)
+	assert.False(t, current.Enabled, "the emergency change is kept")
+	assert.Equal(t, tested.Tests, current.Tests)
+}
+
+func TestService_ProtectAll(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository(),
+		flags.WithApprovalPolicy(flags.ApprovalPolicy{ProtectAll: true, Approvals: 2}))
+
+	_, err := svc.Create(as("alice"), boolFlag("checkout", true))
+	change := pending(t, err)
+	assert.Equal(t, flags.AuditCreate, change.Action)
+	assert.Nil(t, change.Base)
+	assert.Equal(t, 2, change.RequiredApprovals)
+
+	for _, approver := range []string{"bob", "bob"} {
+		approved, err := svc.Approve(as(approver), change.ID, "")
+		require.NoError(t, err)
+		assert.Equal(t, flags.ChangePending, approved.Status, "one approver counts once")
+	}
+
+	approved, err := svc.Approve(as("carol"), change.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, flags.ChangeApproved, approved.Status)
+	assert.Equal(t, []string{"bob", "carol"}, approved.Approvers)
+
+	applied, err := svc.Apply(as("alice"), change.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied.AppliedVersion)
+
+	_, err = svc.Create(as("alice"), boolFlag("checkout", false))
+	require.ErrorIs(t, err, flags.ErrFlagExists, "creations of taken keys are not proposed")
+
+	_, err = svc.Create(flags.WithManager(context.Background(), flags.ManagedByFile), boolFlag("from-file", true))
+	require.NoError(t, err, "managers do not need approval")
+
+	_, err = svc.Create(flags.WithEmergency(as("alice")), boolFlag("hotfix", true))
+	require.NoError(t, err)
+
+	page, err := svc.AuditLog(context.Background(), flags.AuditFilter{FlagKey: "hotfix"})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 1)
+	assert.True(t, page.Entries[0].Emergency)
+}
+
+func TestService_ApplyCreateOfTakenKey(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository(),
+		flags.WithApprovalPolicy(flags.ApprovalPolicy{ProtectAll: true}))
+
+	_, err := svc.Create(as("alice"), boolFlag("checkout", true))
+	change := pending(t, err)
+
+	_, err = svc.Create(flags.WithEmergency(as("dave")), boolFlag("checkout", false))
+	require.NoError(t, err)
+
+	_, err = svc.Approve(as("bob"), change.ID, "")
+	require.NoError(t, err)
+
+	_, err = svc.Apply(as("alice"), change.ID)
+	require.ErrorIs(t, err, flags.ErrFlagExists)
+}
+
+func TestService_RejectAndComment(t *testing.T) {
+	t.Parallel()
+
+	repo := flags.NewMemoryRepository()
+	changes := flags.NewMemoryChangeStore()
+	svc := flags.NewService(repo, flags.WithChangeStore(changes))
+	created := newProtectedFlag(t, svc)
+
+	_, err := svc.Update(as("alice"), boolFlag(created.Key, false))
+	first := pending(t, err)
+
+	_, err = svc.Update(as("alice"), boolFlag(created.Key, false))
+	second := pending(t, err)
+
+	commented, err := svc.Comment(as("bob"), first.ID, "why now?")
+	require.NoError(t, err)
+	assert.Equal(t, "why now?", commented.Comments[0].Text)
+
+	viewers := flags.NewService(repo, flags.WithChangeStore(changes), flags.WithAuthorizer(grant{flags.PermissionRead}))
+
+	_, err = viewers.Reject(as("mallory"), first.ID, "")
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	_, err = viewers.Approve(as("mallory"), first.ID, "")
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	withdrawn, err := viewers.Reject(as("alice"), first.ID, "not needed")
+	require.NoError(t, err, "requesters may withdraw their changes")
+	assert.Equal(t, flags.ChangeRejected, withdrawn.Status)
+	assert.Equal(t, "alice", withdrawn.ClosedBy)
+
+	rejected, err := svc.Reject(as("bob"), second.ID, "")
+	require.NoError(t, err)
+	assert.Equal(t, flags.ChangeRejected, rejected.Status)
+
+	_, err = svc.Reject(as("bob"), second.ID, "")
+	require.ErrorIs(t, err, flags.ErrChangeClosed)
+
+	_, err = svc.Apply(as("alice"), second.ID)
+	require.ErrorIs(t, err, flags.ErrChangeClosed)
+
+	_, err = svc.Comment(as("carol"), second.ID, "closed, but noted")
+	require.NoError(t, err)
+
+	all, err := svc.ChangeRequests(context.Background(), flags.ChangeFilter{FlagKey: created.Key})
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	open, err := svc.ChangeRequests(context.Background(), flags.ChangeFilter{Status: flags.ChangePending})
+	require.NoError(t, err)
+	assert.Empty(t, open)
+
+	_, err = svc.ChangeRequest(context.Background(), "missing")
+	require.ErrorIs(t, err, flags.ErrChangeNotFound)
+
+	_, err = svc.Approve(as("bob"), "missing", "")
+	require.ErrorIs(t, err, flags.ErrChangeNotFound)
+
+	_, err = svc.Apply(as("bob"), "missing")
+	require.ErrorIs(t, err, flags.ErrChangeNotFound)
+}
+
+func TestService_ChangeRequests_Denied(t *testing.T) {
+	t.Parallel()
+
+	repo := flags.NewMemoryRepository()
+	changes := flags.NewMemoryChangeStore()
+	created := newProtectedFlag(t, flags.NewService(repo, flags.WithChangeStore(changes)))
+
+	_, err := flags.NewService(repo, flags.WithChangeStore(changes)).Update(as("alice"), boolFlag(created.Key, false))
+	change := pending(t, err)
+
+	svc := flags.NewService(repo, flags.WithChangeStore(changes), flags.WithAuthorizer(grant{}))
+	ctx := context.Background()
+
+	_, err = svc.ChangeRequests(ctx, flags.ChangeFilter{})
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	_, err = svc.ChangeRequest(ctx, change.ID)
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	_, err = svc.Comment(ctx, change.ID, "hi")
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	_, err = svc.Reject(ctx, change.ID, "")
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	_, err = svc.Apply(ctx, change.ID)
+	require.ErrorIs(t, err, flags.ErrForbidden)
+}
+
+func TestService_Import_SkipsProtectedFlags(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newProtectedFlag(t, svc)
+	_, err := svc.Create(context.Background(), boolFlag("plain", true))
+	require.NoError(t, err)
+
+	changes, err := svc.Import(context.Background(), archiveOf(boolFlag(created.Key, false)), flags.ImportOverwrite, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"delete plain", "skip kill-switch"}, summarize(changes))
+
+	current, err := svc.Get(context.Background(), created.Key)
+	require.NoError(t, err)
+	assert.True(t, current.Enabled)
+
+	all := flags.NewService(flags.NewMemoryRepository(),
+		flags.WithApprovalPolicy(flags.ApprovalPolicy{ProtectAll: true}))
+
+	changes, err = all.Import(context.Background(), archiveOf(boolFlag("new", true)), flags.ImportMerge, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"skip new"}, summarize(changes))
+}
+
+func TestMemoryChangeStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := flags.NewMemoryChangeStore()
+	base := boolFlag("checkout", true)
+	change := flags.ChangeRequest{ID: "c1", FlagKey: base.Key, Base: &base, Approvers: []string{"bob"}}
+
+	require.NoError(t, store.Create(ctx, change))
+	require.ErrorIs(t, store.Create(ctx, change), flags.ErrChangeExists)
+	require.ErrorIs(t, store.Update(ctx, flags.ChangeRequest{ID: "missing"}), flags.ErrChangeNotFound)
+
+	got, err := store.Get(ctx, "c1")
+	require.NoError(t, err)
+
+	got.Base.Enabled = false
+	got.Approvers[0] = "mallory"
+
+	again, err := store.Get(ctx, "c1")
+	require.NoError(t, err)
+	assert.Equal(t, change, again, "stored changes do not alias returned ones")
+}
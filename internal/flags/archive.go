@@ -0,0 +1,300 @@
+package flags
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnsupportedArchive = errors.New("unsupported archive format version")
+	ErrInvalidArchive     = errors.New("invalid archive")
+	ErrInvalidImportMode  = errors.New("invalid import mode")
+)
+
+// ArchiveFormatVersion is the archive format written by Export. Import
+// rejects archives in any other format.
+const ArchiveFormatVersion = 1
+
+// Archive is the complete flag configuration of an instance. Versions,
+// timestamps and managers belong to the instance and are not carried over.
+type Archive struct {
+	FormatVersion int
+	ExportedAt    time.Time
+	Flags         []Flag
+}
+
+type ImportMode string
+
+const (
+	// ImportCreateOnly creates missing flags and leaves existing ones alone.
+	ImportCreateOnly ImportMode = "create-only"
+	// ImportMerge also updates existing flags that differ from the archive.
+	ImportMerge ImportMode = "merge"
+	// ImportOverwrite also deletes flags missing from the archive, so the
+	// instance ends up matching it.
+	ImportOverwrite ImportMode = "overwrite"
+)
+
+type ImportAction string
+
+const (
+	ImportCreate ImportAction = "create"
+	ImportUpdate ImportAction = "update"
+	ImportDelete ImportAction = "delete"
+	ImportSkip   ImportAction = "skip"
+)
+
+// ImportChange is one planned or applied change. Reason explains a skip.
+type ImportChange struct {
+	Action ImportAction
+	Key    FlagKey
+	Diff   []FieldChange
+	Reason string
+
+	before, after *Flag
+}
+
+// Export returns every flag as an archive.
+func (s *Service) Export(ctx context.Context) (Archive, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionRead, ""); err != nil {
+		return Archive{}, err
+	}
+
+	all, err := s.repo.List(ctx)
+	if err != nil {
+		return Archive{}, err
+	}
+
+	for i := range all {
+		all[i].Version = 0
+		all[i].UpdatedAt = time.Time{}
+		all[i].ManagedBy = ""
+	}
+
+	return Archive{FormatVersion: ArchiveFormatVersion, ExportedAt: s.clock.Now().UTC(), Flags: all}, nil
+}
+
+// Import brings the flags in line with archive according to mode and returns
+// the changes, creations first and skips last. Flags managed outside the
+// caller's manager, and flags whose changes need approval, are skipped. With
+// dryRun nothing is written. Otherwise the import is all or nothing: when a
+// change fails, the changes already applied are reverted and the error is
+// returned.
+func (s *Service) Import(ctx context.Context, archive Archive, mode ImportMode, dryRun bool) ([]ImportChange, error) {
+	if archive.FormatVersion != ArchiveFormatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedArchive, archive.FormatVersion)
+	}
+
+	switch mode {
+	case ImportCreateOnly, ImportMerge, ImportOverwrite:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidImportMode, mode)
+	}
+
+	permission := PermissionWrite
+	if dryRun {
+		permission = PermissionRead
+	}
+
+	if err := s.authorizer.Authorize(ctx, permission, ""); err != nil {
+		return nil, err
+	}
+
+	s.imports.Lock()
+	defer s.imports.Unlock()
+
+	current, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := planImport(ctx, current, archive.Flags, mode, s.needsApproval)
+	if err != nil || dryRun {
+		return changes, err
+	}
+
+	if err := s.applyImport(withApproval(ctx, ""), changes); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// approvalCheck reports whether changing current, or creating a flag when
+// current is nil, needs a change request.
+type approvalCheck func(ctx context.Context, current *Flag) bool
+
+// protectedReason explains skips of flags that need a change request.
+const protectedReason = "protected; needs a change request"
+
+func planImport(
+	ctx context.Context, current, archived []Flag, mode ImportMode, needsApproval approvalCheck,
+) ([]ImportChange, error) {
+	existing := make(map[FlagKey]Flag, len(current))
+	for _, flag := range current {
+		existing[flag.Key] = flag
+	}
+
+	declared := make(map[FlagKey]bool, len(archived))
+
+	var changes []ImportChange
+
+	for _, flag := range archived {
+		if declared[flag.Key] {
+			return nil, fmt.Errorf("%w: flag %s appears more than once", ErrInvalidArchive, flag.Key)
+		}
+
+		declared[flag.Key] = true
+
+		if change, ok := planFlag(ctx, existing, flag, mode, needsApproval); ok {
+			changes = append(changes, change)
+		}
+	}
+
+	if mode == ImportOverwrite {
+		for _, flag := range current {
+			if !declared[flag.Key] {
+				changes = append(changes, planDeletion(ctx, flag, needsApproval))
+			}
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b ImportChange) int {
+		return cmp.Or(
+			cmp.Compare(importOrder(a.Action), importOrder(b.Action)),
+			strings.Compare(string(a.Key), string(b.Key)),
+		)
+	})
+
+	return changes, nil
+}
+
+// planFlag returns the change that brings an archived flag in, if any.
+func planFlag(
+	ctx context.Context, existing map[FlagKey]Flag, flag Flag, mode ImportMode, needsApproval approvalCheck,
+) (ImportChange, bool) {
+	flag.Version = 0
+	flag.UpdatedAt = time.Time{}
+	flag.ManagedBy = ManagerFromContext(ctx)
+
+	prev, ok := existing[flag.Key]
+	if !ok {
+		diff := Diff(nil, &flag)
+
+		if needsApproval(ctx, nil) {
+			return ImportChange{Action: ImportSkip, Key: flag.Key, Diff: diff, Reason: protectedReason}, true
+		}
+
+		return ImportChange{Action: ImportCreate, Key: flag.Key, Diff: diff, after: &flag}, true
+	}
+
+	diff := Diff(&prev, &flag)
+	if len(diff) == 0 {
+		return ImportChange{}, false
+	}
+
+	if mode == ImportCreateOnly {
+		return ImportChange{Action: ImportSkip, Key: flag.Key, Diff: diff, Reason: "flag exists"}, true
+	}
+
+	if err := checkManager(ctx, prev); err != nil {
+		return ImportChange{Action: ImportSkip, Key: flag.Key, Diff: diff, Reason: err.Error()}, true
+	}
+
+	if needsApproval(ctx, &prev) {
+		return ImportChange{Action: ImportSkip, Key: flag.Key, Diff: diff, Reason: protectedReason}, true
+	}
+
+	return ImportChange{Action: ImportUpdate, Key: flag.Key, Diff: diff, before: &prev, after: &flag}, true
+}
+
+func planDeletion(ctx context.Context, flag Flag, needsApproval approvalCheck) ImportChange {
+	diff := Diff(&flag, nil)
+
+	if err := checkManager(ctx, flag); err != nil {
+		return ImportChange{Action: ImportSkip, Key: flag.Key, Diff: diff, Reason: err.Error()}
+	}
+
+	if needsApproval(ctx, &flag) {
+		return ImportChange{Action: ImportSkip, Key: flag.Key, Diff: diff, Reason: protectedReason}
+	}
+
+	return ImportChange{Action: ImportDelete, Key: flag.Key, Diff: diff, before: &flag}
+}
+
+// importOrder applies deletions last, so a failed import rarely has to
+// recreate a deleted flag, whose revision history is gone.
+func importOrder(action ImportAction) int {
+	return slices.Index([]ImportAction{ImportCreate, ImportUpdate, ImportDelete, ImportSkip}, action)
+}
+
+func (s *Service) applyImport(ctx context.Context, changes []ImportChange) error {
+	for i, change := range changes {
+		if err := s.applyImportChange(ctx, change); err != nil {
+			err = fmt.Errorf("%s %s: %w", change.Action, change.Key, err)
+
+			if revertErr := s.revertImport(ctx, changes[:i]); revertErr != nil {
+				return errors.Join(err, fmt.Errorf("revert import: %w", revertErr))
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) applyImportChange(ctx context.Context, change ImportChange) error {
+	var err error
+
+	switch change.Action {
+	case ImportCreate:
+		_, err = s.create(ctx, *change.after)
+	case ImportUpdate:
+		// Conditional on the planned version, so a concurrent API write
+		// fails the import instead of being overwritten.
+		next := *change.after
+		next.Version = change.before.Version
+		_, err = s.update(ctx, next)
+	case ImportDelete:
+		err = s.delete(ctx, change.Key)
+	case ImportSkip:
+	}
+
+	return err
+}
+
+// revertImport undoes applied changes in reverse order. Reverting a deletion
+// recreates the flag with a new history starting at version 1.
+func (s *Service) revertImport(ctx context.Context, applied []ImportChange) error {
+	var errs []error
+
+	for i := len(applied) - 1; i >= 0; i-- {
+		change := applied[i]
+
+		var err error
+
+		switch change.Action {
+		case ImportCreate:
+			err = s.delete(ctx, change.Key)
+		case ImportUpdate:
+			prev := *change.before
+			prev.Version = 0
+			_, err = s.update(ctx, prev)
+		case ImportDelete:
+			_, err = s.create(ctx, *change.before)
+		case ImportSkip:
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", change.Action, change.Key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
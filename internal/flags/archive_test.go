@@ -0,0 +1,258 @@
+package flags_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingRepository fails Delete for the keys in failDelete.
+type failingRepository struct {
+	*flags.MemoryRepository
+
+	failDelete map[flags.FlagKey]bool
+	listErr    error
+}
+
+func (r *failingRepository) Delete(ctx context.Context, key flags.FlagKey) error {
+	if r.failDelete[key] {
+		return errors.New("disk full")
+	}
+
+	return r.MemoryRepository.Delete(ctx, key)
+}
+
+func (r *failingRepository) List(ctx context.Context) ([]flags.Flag, error) {
+	if r.listErr != nil {
+		return nil, r.listErr
+	}
+
+	return r.MemoryRepository.List(ctx)
+}
+
+func boolFlag(key flags.FlagKey, enabled bool) flags.Flag {
+	return flags.Flag{Key: key, Type: flags.FlagBool, Enabled: enabled, DefaultValue: flags.BoolValue(false)}
+}
+
+func archiveOf(all ...flags.Flag) flags.Archive {
+	return flags.Archive{FormatVersion: flags.ArchiveFormatVersion, Flags: all}
+}
+
+func summarize(changes []flags.ImportChange) []string {
+	summary := make([]string, len(changes))
+	for i, change := range changes {
+		summary[i] = string(change.Action) + " " + string(change.Key)
+	}
+
+	return summary
+}
+
+func TestService_ExportImport(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := newFakeClock()
+	source := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+	created := newVersionedFlag(t, source)
+
+	_, err := source.Update(ctx, created)
+	require.NoError(t, err)
+
+	archive, err := source.Export(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, flags.ArchiveFormatVersion, archive.FormatVersion)
+	assert.Equal(t, clock.Now(), archive.ExportedAt)
+	require.Len(t, archive.Flags, 1)
+	assert.Zero(t, archive.Flags[0].Version)
+	assert.True(t, archive.Flags[0].UpdatedAt.IsZero())
+
+	target := flags.NewService(flags.NewMemoryRepository())
+
+	changes, err := target.Import(ctx, archive, flags.ImportCreateOnly, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"create versioned"}, summarize(changes))
+
+	imported, err := target.Get(ctx, "versioned")
+	require.NoError(t, err)
+	assert.Equal(t, 1, imported.Version)
+	assert.Empty(t, flags.Diff(&created, &imported))
+
+	// Importing the same archive again changes nothing.
+	changes, err = target.Import(ctx, archive, flags.ImportOverwrite, false)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestService_Import_Modes(t *testing.T) {
+	t.Parallel()
+
+	archive := archiveOf(boolFlag("same", true), boolFlag("changed", false), boolFlag("added", true),
+		boolFlag("managed", false))
+
+	tests := []struct {
+		mode flags.ImportMode
+		want []string
+	}{
+		{
+			mode: flags.ImportCreateOnly,
+			want: []string{"create added", "skip changed", "skip managed"},
+		},
+		{
+			mode: flags.ImportMerge,
+			want: []string{"create added", "update changed", "skip managed"},
+		},
+		{
+			mode: flags.ImportOverwrite,
+			want: []string{"create added", "update changed", "delete extra", "skip managed", "skip managed-extra"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			fileCtx := flags.WithManager(ctx, flags.ManagedByFile)
+			svc := flags.NewService(flags.NewMemoryRepository())
+
+			for _, flag := range []flags.Flag{boolFlag("same", true), boolFlag("changed", true), boolFlag("extra", true)} {
+				_, err := svc.Create(ctx, flag)
+				require.NoError(t, err)
+			}
+
+			for _, flag := range []flags.Flag{boolFlag("managed", true), boolFlag("managed-extra", true)} {
+				_, err := svc.Create(fileCtx, flag)
+				require.NoError(t, err)
+			}
+
+			planned, err := svc.Import(ctx, archive, tt.mode, true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, summarize(planned))
+
+			all, err := svc.List(ctx, flags.FlagFilter{})
+			require.NoError(t, err)
+			assert.Len(t, all, 5, "a dry run must not write")
+
+			applied, err := svc.Import(ctx, archive, tt.mode, false)
+			require.NoError(t, err)
+			assert.Equal(t, planned, applied)
+
+			managed, err := svc.Get(ctx, "managed")
+			require.NoError(t, err)
+			assert.True(t, managed.Enabled, "flags managed elsewhere are left alone")
+
+			for _, change := range applied {
+				if change.Action == flags.ImportSkip {
+					assert.NotEmpty(t, change.Reason)
+				}
+			}
+		})
+	}
+}
+
+func TestService_Import_UpdateDiff(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := flags.NewService(flags.NewMemoryRepository())
+
+	_, err := svc.Create(ctx, boolFlag("changed", true))
+	require.NoError(t, err)
+
+	changes, err := svc.Import(ctx, archiveOf(boolFlag("changed", false)), flags.ImportMerge, false)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, []flags.FieldChange{
+		{Path: "enabled", Kind: flags.ChangeModified, Before: true, After: false},
+	}, changes[0].Diff)
+
+	flag, err := svc.Get(ctx, "changed")
+	require.NoError(t, err)
+	assert.Equal(t, 2, flag.Version)
+	assert.False(t, flag.Enabled)
+}
+
+func TestService_Import_Invalid(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	ctx := context.Background()
+
+	_, err := svc.Import(ctx, flags.Archive{FormatVersion: 2}, flags.ImportMerge, false)
+	require.ErrorIs(t, err, flags.ErrUnsupportedArchive)
+
+	_, err = svc.Import(ctx, archiveOf(), "replace", false)
+	require.ErrorIs(t, err, flags.ErrInvalidImportMode)
+
+	_, err = svc.Import(ctx, archiveOf(boolFlag("dup", true), boolFlag("dup", false)), flags.ImportMerge, false)
+	require.ErrorIs(t, err, flags.ErrInvalidArchive)
+
+	all, err := svc.List(ctx, flags.FlagFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestService_Import_ListError(t *testing.T) {
+	t.Parallel()
+
+	repo := &failingRepository{MemoryRepository: flags.NewMemoryRepository(), listErr: errors.New("down")}
+	svc := flags.NewService(repo)
+
+	_, err := svc.Import(context.Background(), archiveOf(), flags.ImportMerge, false)
+	require.ErrorIs(t, err, repo.listErr)
+
+	_, err = svc.Export(context.Background())
+	assert.ErrorIs(t, err, repo.listErr)
+}
+
+func TestService_Import_IsAtomic(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo := &failingRepository{
+		MemoryRepository: flags.NewMemoryRepository(),
+		failDelete:       map[flags.FlagKey]bool{"z-undeletable": true},
+	}
+	svc := flags.NewService(repo)
+
+	for _, key := range []flags.FlagKey{"changed", "removed", "z-undeletable"} {
+		_, err := svc.Create(ctx, boolFlag(key, true))
+		require.NoError(t, err)
+	}
+
+	before, err := svc.List(ctx, flags.FlagFilter{})
+	require.NoError(t, err)
+
+	_, err = svc.Import(ctx, archiveOf(boolFlag("added", true), boolFlag("changed", false)), flags.ImportOverwrite, false)
+	require.ErrorContains(t, err, "delete z-undeletable: disk full")
+
+	after, err := svc.List(ctx, flags.FlagFilter{})
+	require.NoError(t, err)
+	require.Len(t, after, len(before))
+
+	for i := range before {
+		assert.Empty(t, flags.Diff(&before[i], &after[i]), before[i].Key)
+	}
+}
+
+func TestService_Import_RevertFailure(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo := &failingRepository{
+		MemoryRepository: flags.NewMemoryRepository(),
+		failDelete:       map[flags.FlagKey]bool{"added": true, "removed": true},
+	}
+	svc := flags.NewService(repo)
+
+	_, err := svc.Create(ctx, boolFlag("removed", true))
+	require.NoError(t, err)
+
+	_, err = svc.Import(ctx, archiveOf(boolFlag("added", true)), flags.ImportOverwrite, false)
+	require.ErrorContains(t, err, "delete removed: disk full")
+	assert.ErrorContains(t, err, "revert import: create added: disk full")
+}
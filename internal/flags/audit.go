@@ -0,0 +1,184 @@
+package flags
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const defaultAuditLimit = 100
+
+type AuditAction string
+
+const (
+	AuditCreate   AuditAction = "create"
+	AuditUpdate   AuditAction = "update"
+	AuditRollback AuditAction = "rollback"
+	AuditDelete   AuditAction = "delete"
+)
+
+// AuditEntry is an immutable record of a single flag mutation. Before is nil
+// for creations and After is nil for deletions.
+type AuditEntry struct {
+	Seq       uint64
+	Time      time.Time
+	Actor     string
+	Action    AuditAction
+	FlagKey   FlagKey
+	Before    *Flag
+	After     *Flag
+	Diff      []FieldChange
+	RequestID string
+	// ChangeID is the change request the mutation applied, if any.
+	ChangeID string
+	// Emergency marks mutations that skipped approval; see WithEmergency.
+	Emergency bool
+	// ScheduleID is the scheduled change the mutation ran, if any.
+	ScheduleID string
+	// RampID is the rollout ramp the mutation stepped, if any.
+	RampID string
+}
+
+type AuditFilter struct {
+	FlagKey FlagKey
+	Actor   string
+	Since   time.Time // inclusive, zero means unbounded
+	Until   time.Time // exclusive, zero means unbounded
+	Cursor  string
+	Limit   int
+}
+
+type AuditPage struct {
+	Entries    []AuditEntry
+	NextCursor string // empty when there are no more entries
+}
+
+// AuditStore is an append-only log of flag mutations. Implementations assign
+// Seq on Append and list entries in ascending Seq order.
+type AuditStore interface {
+	Append(ctx context.Context, entry AuditEntry) (AuditEntry, error)
+	List(ctx context.Context, filter AuditFilter) (AuditPage, error)
+}
+
+type MemoryAuditStore struct {
+	mu      sync.RWMutex
+	entries []AuditEntry
+}
+
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{}
+}
+
+func (s *MemoryAuditStore) Append(_ context.Context, entry AuditEntry) (AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.Seq = uint64(len(s.entries)) + 1
+	entry = entry.clone()
+	s.entries = append(s.entries, entry)
+
+	return entry.clone(), nil
+}
+
+func (s *MemoryAuditStore) List(_ context.Context, filter AuditFilter) (AuditPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return listAuditEntries(s.entries, filter)
+}
+
+func (e AuditEntry) clone() AuditEntry {
+	if e.Before != nil {
+		before := e.Before.Clone()
+		e.Before = &before
+	}
+
+	if e.After != nil {
+		after := e.After.Clone()
+		e.After = &after
+	}
+
+	if e.Diff != nil {
+		e.Diff = append([]FieldChange(nil), e.Diff...)
+	}
+
+	return e
+}
+
+func (f AuditFilter) matches(entry AuditEntry) bool {
+	if f.FlagKey != "" && entry.FlagKey != f.FlagKey {
+		return false
+	}
+
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+
+	if !f.Until.IsZero() && !entry.Time.Before(f.Until) {
+		return false
+	}
+
+	return true
+}
+
+// listAuditEntries applies filter to entries, which must be sorted by Seq.
+func listAuditEntries(entries []AuditEntry, filter AuditFilter) (AuditPage, error) {
+	after, err := decodeCursor(filter.Cursor)
+	if err != nil {
+		return AuditPage{}, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+
+	var page AuditPage
+
+	for _, entry := range entries {
+		if entry.Seq <= after || !filter.matches(entry) {
+			continue
+		}
+
+		if len(page.Entries) == limit {
+			page.NextCursor = encodeCursor(page.Entries[limit-1].Seq)
+
+			break
+		}
+
+		page.Entries = append(page.Entries, entry.clone())
+	}
+
+	return page, nil
+}
+
+func encodeCursor(seq uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(seq, 10)))
+}
+
+func decodeCursor(cursor string) (uint64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	seq, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	return seq, nil
+}
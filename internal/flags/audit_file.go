@@ -0,0 +1,197 @@
+package flags
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrCorruptAuditLog = errors.New("corrupt audit log")
+
+// FileAuditStore appends entries as JSON lines to a single file and fsyncs
+// after every write. The diff is not persisted; it is recomputed from the
+// snapshots when the log is loaded.
+type FileAuditStore struct {
+	mu      sync.RWMutex
+	file    logFile
+	size    int64 // bytes of whole records in file
+	entries []AuditEntry
+}
+
+type auditRecord struct {
+	Seq        uint64      `json:"seq"`
+	Time       time.Time   `json:"time"`
+	Actor      string      `json:"actor"`
+	Action     AuditAction `json:"action"`
+	FlagKey    FlagKey     `json:"flagKey"`
+	Before     *Flag       `json:"before,omitempty"`
+	After      *Flag       `json:"after,omitempty"`
+	RequestID  string      `json:"requestId,omitempty"`
+	ChangeID   string      `json:"changeId,omitempty"`
+	Emergency  bool        `json:"emergency,omitempty"`
+	ScheduleID string      `json:"scheduleId,omitempty"`
+	RampID     string      `json:"rampId,omitempty"`
+}
+
+// OpenFileAuditStore loads the log at path, creating it if needed. A torn
+// final line left behind by a crash is truncated; corruption anywhere else is
+// reported as ErrCorruptAuditLog.
+func OpenFileAuditStore(path string) (*FileAuditStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+
+	entries, size, err := readAuditRecords(file)
+	if err == nil {
+		err = truncateTo(file, size)
+	}
+
+	if err != nil {
+		_ = file.Close()
+
+		return nil, err
+	}
+
+	return &FileAuditStore{file: file, size: size, entries: entries}, nil
+}
+
+func (s *FileAuditStore) Append(_ context.Context, entry AuditEntry) (AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.Seq = uint64(len(s.entries)) + 1
+
+	line, err := json.Marshal(auditRecord{
+		Seq:        entry.Seq,
+		Time:       entry.Time,
+		Actor:      entry.Actor,
+		Action:     entry.Action,
+		FlagKey:    entry.FlagKey,
+		Before:     entry.Before,
+		After:      entry.After,
+		RequestID:  entry.RequestID,
+		ChangeID:   entry.ChangeID,
+		Emergency:  entry.Emergency,
+		ScheduleID: entry.ScheduleID,
+		RampID:     entry.RampID,
+	})
+	if err != nil {
+		return AuditEntry{}, fmt.Errorf("encode audit entry: %w", err)
+	}
+
+	line = append(line, '\n')
+
+	if err := s.write(line); err != nil {
+		// Drop whatever part of the line made it to disk so the next append
+		// does not land after a torn record.
+		_ = truncateTo(s.file, s.size)
+
+		return AuditEntry{}, err
+	}
+
+	s.size += int64(len(line))
+	entry = entry.clone()
+	s.entries = append(s.entries, entry)
+
+	return entry.clone(), nil
+}
+
+func (s *FileAuditStore) write(line []byte) error {
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("write audit entry: %w", err)
+	}
+
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("sync audit log: %w", err)
+	}
+
+	return nil
+}
+
+func (s *FileAuditStore) List(_ context.Context, filter AuditFilter) (AuditPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return listAuditEntries(s.entries, filter)
+}
+
+func (s *FileAuditStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+// readAuditRecords returns the decoded entries and the byte offset just past
+// the last valid record.
+func readAuditRecords(file *os.File) ([]AuditEntry, int64, error) {
+	reader := bufio.NewReader(file)
+
+	var (
+		entries []AuditEntry
+		offset  int64
+	)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// An unterminated final line is a write that never completed.
+			return entries, offset, nil
+		}
+
+		if err != nil {
+			return nil, 0, fmt.Errorf("read audit log: %w", err)
+		}
+
+		var record auditRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+			return nil, 0, fmt.Errorf("%w: record at offset %d: %w", ErrCorruptAuditLog, offset, err)
+		}
+
+		entries = append(entries, AuditEntry{
+			Seq:        record.Seq,
+			Time:       record.Time,
+			Actor:      record.Actor,
+			Action:     record.Action,
+			FlagKey:    record.FlagKey,
+			Before:     record.Before,
+			After:      record.After,
+			Diff:       Diff(record.Before, record.After),
+			RequestID:  record.RequestID,
+			ChangeID:   record.ChangeID,
+			Emergency:  record.Emergency,
+			ScheduleID: record.ScheduleID,
+			RampID:     record.RampID,
+		})
+		offset += int64(len(line))
+	}
+}
+
+// logFile is the part of *os.File that the append-only logs use.
+type logFile interface {
+	io.WriteSeeker
+	io.Closer
+	Name() string
+	Sync() error
+	Truncate(size int64) error
+}
+
+func truncateTo(file logFile, size int64) error {
+	if err := file.Truncate(size); err != nil {
+		return fmt.Errorf("truncate %s: %w", file.Name(), err)
+	}
+
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		return fmt.Errorf("seek %s: %w", file.Name(), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package flags_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileAuditStore_PersistsAcrossReopen(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	ctx := context.Background()
+
+	store, err := flags.OpenFileAuditStore(path)
+	require.NoError(t, err)
+
+	after := flags.Flag{
+		Key:          "flag-a",
+		Type:         flags.FlagBool,
+		Enabled:      true,
+		DefaultValue: flags.BoolValue(false),
+	}
+	_, err = store.Append(ctx, flags.AuditEntry{
+		Time:       time.Now(),
+		Actor:      "alice",
+		Action:     flags.AuditCreate,
+		FlagKey:    "flag-a",
+		After:      &after,
+		Diff:       flags.Diff(nil, &after),
+		RequestID:  "req-1",
+		ChangeID:   "change-1",
+		Emergency:  true,
+		ScheduleID: "schedule-1",
+		RampID:     "ramp-1",
+	})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	reopened, err := flags.OpenFileAuditStore(path)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = reopened.Close() })
+
+	page, err := reopened.List(ctx, flags.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 1)
+
+	entry := page.Entries[0]
+	assert.Equal(t, uint64(1), entry.Seq)
+	assert.Equal(t, "alice", entry.Actor)
+	assert.Equal(t, "req-1", entry.RequestID)
+	assert.Equal(t, "change-1", entry.ChangeID)
+	assert.True(t, entry.Emergency)
+	assert.Equal(t, "schedule-1", entry.ScheduleID)
+	assert.Equal(t, "ramp-1", entry.RampID)
+	assert.Nil(t, entry.Before)
+	require.NotNil(t, entry.After)
+	assert.False(t, *entry.After.DefaultValue.Bool)
+	assert.Equal(t, flags.Diff(nil, &after), entry.Diff)
+
+	next, err := reopened.Append(ctx, flags.AuditEntry{FlagKey: "flag-b"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), next.Seq)
+}
+
+func TestFileAuditStore_TruncatesTornTail(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	ctx := context.Background()
+
+	store, err := flags.OpenFileAuditStore(path)
+	require.NoError(t, err)
+
+	_, err = store.Append(ctx, flags.AuditEntry{FlagKey: "flag-a"})
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"seq":2,"flagKey":"fla`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reopened, err := flags.OpenFileAuditStore(path)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = reopened.Close() })
+
+	entry, err := reopened.Append(ctx, flags.AuditEntry{FlagKey: "flag-b"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), entry.Seq)
+
+	page, err := reopened.List(ctx, flags.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 2)
+	assert.Equal(t, flags.FlagKey("flag-b"), page.Entries[1].FlagKey)
+}
+
+// failingFile writes half of a line and fails, or fails to sync, while its
+// flag is set.
+type failingFile struct {
+	flags.LogFile
+
+	failWrite, failSync bool
+}
+
+func (f *failingFile) Write(p []byte) (int, error) {
+	if !f.failWrite {
+		return f.LogFile.Write(p)
+	}
+
+	n, _ := f.LogFile.Write(p[:len(p)/2])
+
+	return n, errors.New("disk full")
+}
+
+func (f *failingFile) Sync() error {
+	if f.failSync {
+		return errors.New("sync failed")
+	}
+
+	return f.LogFile.Sync()
+}
+
+func TestFileAuditStore_FailedAppendLeavesNoRecord(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	ctx := context.Background()
+
+	store, err := flags.OpenFileAuditStore(path)
+	require.NoError(t, err)
+
+	file := &failingFile{}
+
+	flags.WrapAuditFile(store, func(inner flags.LogFile) flags.LogFile {
+		file.LogFile = inner
+
+		return file
+	})
+
+	_, err = store.Append(ctx, flags.AuditEntry{FlagKey: "flag-a"})
+	require.NoError(t, err)
+
+	file.failWrite = true
+	_, err = store.Append(ctx, flags.AuditEntry{FlagKey: "torn"})
+	require.Error(t, err)
+
+	file.failWrite, file.failSync = false, true
+	_, err = store.Append(ctx, flags.AuditEntry{FlagKey: "unsynced"})
+	require.Error(t, err)
+
+	file.failSync = false
+	next, err := store.Append(ctx, flags.AuditEntry{FlagKey: "flag-b"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), next.Seq)
+	require.NoError(t, store.Close())
+
+	reopened, err := flags.OpenFileAuditStore(path)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = reopened.Close() })
+
+	page, err := reopened.List(ctx, flags.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 2)
+	assert.Equal(t, flags.FlagKey("flag-a"), page.Entries[0].FlagKey)
+	assert.Equal(t, flags.FlagKey("flag-b"), page.Entries[1].FlagKey)
+}
+
+func TestFileAuditStore_RejectsCorruptRecord(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, os.WriteFile(path, []byte("garbage\n{\"seq\":2}\n"), 0o600))
+
+	_, err := flags.OpenFileAuditStore(path)
+	assert.ErrorIs(t, err, flags.ErrCorruptAuditLog)
+}
+
+func TestFileAuditStore_OpenError(t *testing.T) {
+	t.Parallel()
+
+	_, err := flags.OpenFileAuditStore(filepath.Join(t.TempDir(), "missing", "audit.log"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,143 @@
+package flags_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendAuditEntries(t *testing.T, store flags.AuditStore, base time.Time) {
+	t.Helper()
+
+	ctx := context.Background()
+	entries := []flags.AuditEntry{
+		{Time: base, Actor: "alice", Action: flags.AuditCreate, FlagKey: "flag-a"},
+		{Time: base.Add(time.Minute), Actor: "bob", Action: flags.AuditCreate, FlagKey: "flag-b"},
+		{Time: base.Add(2 * time.Minute), Actor: "alice", Action: flags.AuditCreate, FlagKey: "flag-c"},
+	}
+
+	for _, entry := range entries {
+		_, err := store.Append(ctx, entry)
+		require.NoError(t, err)
+	}
+}
+
+func TestMemoryAuditStore_AppendAssignsSeq(t *testing.T) {
+	t.Parallel()
+
+	store := flags.NewMemoryAuditStore()
+	ctx := context.Background()
+
+	first, err := store.Append(ctx, flags.AuditEntry{FlagKey: "flag-a"})
+	require.NoError(t, err)
+
+	second, err := store.Append(ctx, flags.AuditEntry{FlagKey: "flag-b"})
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), first.Seq)
+	assert.Equal(t, uint64(2), second.Seq)
+}
+
+func TestMemoryAuditStore_ListFilters(t *testing.T) {
+	t.Parallel()
+
+	store := flags.NewMemoryAuditStore()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	appendAuditEntries(t, store, base)
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		filter flags.AuditFilter
+		want   []flags.FlagKey
+	}{
+		{name: "no filter", filter: flags.AuditFilter{}, want: []flags.FlagKey{"flag-a", "flag-b", "flag-c"}},
+		{name: "by flag key", filter: flags.AuditFilter{FlagKey: "flag-b"}, want: []flags.FlagKey{"flag-b"}},
+		{name: "by actor", filter: flags.AuditFilter{Actor: "alice"}, want: []flags.FlagKey{"flag-a", "flag-c"}},
+		{
+			name:   "by time range",
+			filter: flags.AuditFilter{Since: base.Add(time.Minute), Until: base.Add(2 * time.Minute)},
+			want:   []flags.FlagKey{"flag-b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			page, err := store.List(ctx, tt.filter)
+			require.NoError(t, err)
+
+			keys := make([]flags.FlagKey, len(page.Entries))
+			for i, entry := range page.Entries {
+				keys[i] = entry.FlagKey
+			}
+
+			assert.Equal(t, tt.want, keys)
+			assert.Empty(t, page.NextCursor)
+		})
+	}
+}
+
+func TestMemoryAuditStore_ListPaginates(t *testing.T) {
+	t.Parallel()
+
+	store := flags.NewMemoryAuditStore()
+	appendAuditEntries(t, store, time.Now())
+
+	ctx := context.Background()
+
+	first, err := store.List(ctx, flags.AuditFilter{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, first.Entries, 2)
+	require.NotEmpty(t, first.NextCursor)
+
+	second, err := store.List(ctx, flags.AuditFilter{Limit: 2, Cursor: first.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, second.Entries, 1)
+	assert.Equal(t, flags.FlagKey("flag-c"), second.Entries[0].FlagKey)
+	assert.Empty(t, second.NextCursor)
+}
+
+func TestMemoryAuditStore_ListInvalidCursor(t *testing.T) {
+	t.Parallel()
+
+	store := flags.NewMemoryAuditStore()
+
+	_, err := store.List(context.Background(), flags.AuditFilter{Cursor: "not a cursor!"})
+	require.ErrorIs(t, err, flags.ErrInvalidCursor)
+
+	_, err = store.List(context.Background(), flags.AuditFilter{Cursor: "YWJj"})
+	assert.ErrorIs(t, err, flags.ErrInvalidCursor)
+}
+
+func TestMemoryAuditStore_EntriesAreImmutable(t *testing.T) {
+	t.Parallel()
+
+	store := flags.NewMemoryAuditStore()
+	ctx := context.Background()
+
+	after := flags.Flag{
+		Key:   "flag-a",
+		Rules: []flags.Rule{{ID: "rule-1", Value: flags.BoolValue(true)}},
+	}
+	_, err := store.Append(ctx, flags.AuditEntry{FlagKey: "flag-a", After: &after})
+	require.NoError(t, err)
+
+	after.Rules[0].ID = "mutated"
+
+	page, err := store.List(ctx, flags.AuditFilter{})
+	require.NoError(t, err)
+
+	page.Entries[0].After.Rules[0].Value = flags.BoolValue(false)
+
+	page, err = store.List(ctx, flags.AuditFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, "rule-1", page.Entries[0].After.Rules[0].ID)
+	assert.True(t, *page.Entries[0].After.Rules[0].Value.Bool)
+}
@@ -0,0 +1,47 @@
+package flags
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+// Permission is what the caller needs for a Service operation.
+type Permission string
+
+const (
+	// PermissionRead covers reading flags, revisions, the audit log and
+	// exports. Evaluating flags needs no permission.
+	PermissionRead Permission = "read"
+	// PermissionWrite covers creating, updating, deleting, rolling back and
+	// importing flags.
+	PermissionWrite Permission = "write"
+	// PermissionApprove covers approving changes proposed by others.
+	PermissionApprove Permission = "approve"
+	// PermissionAdmin covers managing API keys. Service never checks it; it
+	// is here so one Authorizer can guard the whole server.
+	PermissionAdmin Permission = "admin"
+)
+
+// Authorizer decides whether the caller, identified by ActorFromContext, has
+// permission on the flag key, or on the flags as a whole when key is empty,
+// as for listing, exporting and importing flags or reading the audit log. It
+// returns an error wrapping ErrForbidden to deny.
+type Authorizer interface {
+	Authorize(ctx context.Context, permission Permission, key FlagKey) error
+}
+
+// WithAuthorizer checks every operation except Evaluate with authorizer
+// before it reaches the repository. Without one everything is allowed.
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(s *Service) {
+		s.authorizer = authorizer
+	}
+}
+
+type allowAll struct{}
+
+func (allowAll) Authorize(context.Context, Permission, FlagKey) error {
+	return nil
+}
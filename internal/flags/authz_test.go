@@ -0,0 +1,185 @@
+package flags_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// grant allows only the listed permissions.
+type grant []flags.Permission
+
+func (g grant) Authorize(ctx context.Context, permission flags.Permission, _ flags.FlagKey) error {
+	if slices.Contains(g, permission) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s lacks %s", flags.ErrForbidden, flags.ActorFromContext(ctx), permission)
+}
+
+// scoped grants every permission on the listed flag keys only.
+type scoped []flags.FlagKey
+
+func (s scoped) Authorize(_ context.Context, permission flags.Permission, key flags.FlagKey) error {
+	if slices.Contains(s, key) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: lacks %s on %q", flags.ErrForbidden, permission, key)
+}
+
+func TestService_Authorizer_Keys(t *testing.T) {
+	t.Parallel()
+
+	repo := flags.NewMemoryRepository()
+	changes := flags.NewMemoryChangeStore()
+	open := flags.NewService(repo, flags.WithChangeStore(changes))
+	created := newProtectedFlag(t, open)
+
+	_, err := open.Create(context.Background(), boolFlag("other", true))
+	require.NoError(t, err)
+
+	_, err = open.Update(as("alice"), boolFlag(created.Key, false))
+	change := pending(t, err)
+
+	svc := flags.NewService(repo, flags.WithChangeStore(changes), flags.WithAuthorizer(scoped{created.Key}))
+
+	_, err = svc.Get(context.Background(), created.Key)
+	require.NoError(t, err)
+
+	_, err = svc.Get(context.Background(), "other")
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	_, err = svc.List(context.Background(), flags.FlagFilter{})
+	require.ErrorIs(t, err, flags.ErrForbidden, "listing covers every flag")
+
+	_, err = svc.ChangeRequests(context.Background(), flags.ChangeFilter{FlagKey: created.Key})
+	require.NoError(t, err)
+
+	_, err = svc.Approve(as("bob"), change.ID, "")
+	require.NoError(t, err, "reviews are authorized on the flag of the change")
+
+	_, err = svc.Apply(as("alice"), change.ID)
+	require.NoError(t, err)
+}
+
+func TestService_Authorizer_ReadOnly(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	repo := flags.NewMemoryRepository()
+	created := newVersionedFlag(t, flags.NewService(repo))
+	svc := flags.NewService(repo, flags.WithAuthorizer(grant{flags.PermissionRead}))
+
+	_, err := svc.Get(ctx, created.Key)
+	require.NoError(t, err)
+
+	_, err = svc.List(ctx, flags.FlagFilter{})
+	require.NoError(t, err)
+
+	_, err = svc.Export(ctx)
+	require.NoError(t, err)
+
+	_, err = svc.Import(ctx, archiveOf(boolFlag("added", true)), flags.ImportMerge, true)
+	require.NoError(t, err, "a dry run only reads")
+
+	writes := map[string]func() error{
+		"create": func() error {
+			_, err := svc.Create(ctx, boolFlag("other", true))
+
+			return err
+		},
+		"update": func() error {
+			_, err := svc.Update(ctx, boolFlag(created.Key, false))
+
+			return err
+		},
+		"delete": func() error { return svc.Delete(ctx, created.Key) },
+		"rollback": func() error {
+			_, err := svc.Rollback(ctx, created.Key, 1)
+
+			return err
+		},
+		"import": func() error {
+			_, err := svc.Import(ctx, archiveOf(boolFlag("added", true)), flags.ImportMerge, false)
+
+			return err
+		},
+	}
+
+	for name, write := range writes {
+		require.ErrorIs(t, write(), flags.ErrForbidden, name)
+	}
+
+	all, err := repo.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, created, all[0], "denied writes never reach the repository")
+}
+
+func TestService_Authorizer_DenyAll(t *testing.T) {
+	t.Parallel()
+
+	ctx := flags.WithActor(context.Background(), "key:mallory")
+	repo := flags.NewMemoryRepository()
+	created := newVersionedFlag(t, flags.NewService(repo))
+	svc := flags.NewService(repo, flags.WithAuthorizer(grant{}))
+
+	reads := map[string]func() error{
+		"get": func() error {
+			_, err := svc.Get(ctx, created.Key)
+
+			return err
+		},
+		"list": func() error {
+			_, err := svc.List(ctx, flags.FlagFilter{})
+
+			return err
+		},
+		"versions": func() error {
+			_, err := svc.Versions(ctx, created.Key)
+
+			return err
+		},
+		"version": func() error {
+			_, err := svc.Version(ctx, created.Key, 1)
+
+			return err
+		},
+		"diff": func() error {
+			_, err := svc.DiffVersions(ctx, created.Key, 1, 1)
+
+			return err
+		},
+		"audit": func() error {
+			_, err := svc.AuditLog(ctx, flags.AuditFilter{})
+
+			return err
+		},
+		"export": func() error {
+			_, err := svc.Export(ctx)
+
+			return err
+		},
+		"dry run": func() error {
+			_, err := svc.Import(ctx, archiveOf(), flags.ImportMerge, true)
+
+			return err
+		},
+	}
+
+	for name, read := range reads {
+		err := read()
+		require.ErrorIs(t, err, flags.ErrForbidden, name)
+		require.ErrorContains(t, err, "key:mallory lacks read", name)
+	}
+
+	result, err := svc.Evaluate(ctx, created.Key, flags.EvalContext{})
+	require.NoError(t, err, "evaluation needs no permission")
+	assert.Equal(t, flags.ReasonDefault, result.Reason)
+}
@@ -0,0 +1,241 @@
+package flags
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultCacheSize        = 10000
+	defaultCacheTTL         = 30 * time.Second
+	defaultNegativeCacheTTL = 5 * time.Second
+)
+
+// CachedRepository is a read-through cache in front of another Repository.
+// Get results, including ErrFlagNotFound, are kept in a size-bounded LRU for
+// a limited time, and concurrent misses for the same key share one backend
+// read. Writes made through the cache invalidate the key they touch; changes
+// made elsewhere must be reported with Invalidate or InvalidateAll. List is
+// not cached.
+type CachedRepository struct {
+	repo        Repository
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	clock       Clock
+	loads       singleflight.Group
+
+	mu      sync.Mutex
+	entries map[FlagKey]*list.Element
+	lru     *list.List // of *cacheEntry, most recently used first
+	gen     uint64     // bumped on every invalidation so in-flight loads are not cached
+}
+
+type cacheEntry struct {
+	key     FlagKey
+	flag    Flag
+	found   bool
+	expires time.Time // zero means never
+}
+
+type CacheOption func(*CachedRepository)
+
+// WithCacheSize bounds the number of cached keys. Zero disables caching.
+func WithCacheSize(size int) CacheOption {
+	return func(c *CachedRepository) {
+		c.size = size
+	}
+}
+
+// WithCacheTTL sets how long a flag stays cached. Zero keeps flags until they
+// are evicted or invalidated.
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(c *CachedRepository) {
+		c.ttl = ttl
+	}
+}
+
+// WithNegativeCacheTTL sets how long a missing key stays cached. Zero
+// disables negative caching.
+func WithNegativeCacheTTL(ttl time.Duration) CacheOption {
+	return func(c *CachedRepository) {
+		c.negativeTTL = ttl
+	}
+}
+
+// WithCacheClock replaces the system clock used to expire entries.
+func WithCacheClock(clock Clock) CacheOption {
+	return func(c *CachedRepository) {
+		c.clock = clock
+	}
+}
+
+func NewCachedRepository(repo Repository, opts ...CacheOption) *CachedRepository {
+	c := &CachedRepository{
+		repo:        repo,
+		size:        defaultCacheSize,
+		ttl:         defaultCacheTTL,
+		negativeTTL: defaultNegativeCacheTTL,
+		clock:       systemClock{},
+		entries:     make(map[FlagKey]*list.Element),
+		lru:         list.New(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *CachedRepository) Get(ctx context.Context, key FlagKey) (Flag, error) {
+	entry, gen, ok := c.lookup(key)
+	if ok {
+		if !entry.found {
+			return Flag{}, ErrFlagNotFound
+		}
+
+		return entry.flag.Clone(), nil
+	}
+
+	// The generation is part of the group key so callers arriving after an
+	// invalidation never join a load that may have read the old value. The
+	// load is shared, so it ignores the cancellation of whichever caller
+	// started it; each caller stops waiting when its own ctx is done.
+	results := c.loads.DoChan(strconv.FormatUint(gen, 10)+"/"+string(key), func() (any, error) {
+		flag, err := c.repo.Get(context.WithoutCancel(ctx), key)
+
+		switch {
+		case err == nil:
+			c.store(gen, cacheEntry{key: key, flag: flag.Clone(), found: true})
+		case errors.Is(err, ErrFlagNotFound):
+			c.store(gen, cacheEntry{key: key})
+		}
+
+		return flag, err
+	})
+
+	select {
+	case <-ctx.Done():
+		return Flag{}, ctx.Err()
+	case result := <-results:
+		if result.Err != nil {
+			return Flag{}, result.Err
+		}
+
+		return result.Val.(Flag).Clone(), nil
+	}
+}
+
+func (c *CachedRepository) List(ctx context.Context) ([]Flag, error) {
+	return c.repo.List(ctx)
+}
+
+func (c *CachedRepository) Create(ctx context.Context, flag Flag) error {
+	defer c.Invalidate(flag.Key)
+
+	return c.repo.Create(ctx, flag)
+}
+
+func (c *CachedRepository) Update(ctx context.Context, flag Flag) error {
+	defer c.Invalidate(flag.Key)
+
+	return c.repo.Update(ctx, flag)
+}
+
+func (c *CachedRepository) Delete(ctx context.Context, key FlagKey) error {
+	defer c.Invalidate(key)
+
+	return c.repo.Delete(ctx, key)
+}
+
+// Invalidate drops key from the cache.
+func (c *CachedRepository) Invalidate(key FlagKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+}
+
+// InvalidateAll empties the cache, for example after change notifications may
+// have been missed.
+func (c *CachedRepository) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	clear(c.entries)
+	c.lru.Init()
+}
+
+// lookup returns the live entry for key, if any, and the current generation.
+func (c *CachedRepository) lookup(key FlagKey) (cacheEntry, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return cacheEntry{}, c.gen, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if !entry.expires.IsZero() && !c.clock.Now().Before(entry.expires) {
+		c.remove(elem)
+
+		return cacheEntry{}, c.gen, false
+	}
+
+	c.lru.MoveToFront(elem)
+
+	return *entry, c.gen, true
+}
+
+// store caches entry unless the cache was invalidated since gen was read.
+func (c *CachedRepository) store(gen uint64, entry cacheEntry) {
+	ttl := c.ttl
+	if !entry.found {
+		ttl = c.negativeTTL
+		if ttl <= 0 {
+			return
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gen != gen || c.size <= 0 {
+		return
+	}
+
+	if ttl > 0 {
+		entry.expires = c.clock.Now().Add(ttl)
+	}
+
+	if elem, ok := c.entries[entry.key]; ok {
+		elem.Value = &entry
+		c.lru.MoveToFront(elem)
+
+		return
+	}
+
+	c.entries[entry.key] = c.lru.PushFront(&entry)
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *CachedRepository) remove(elem *list.Element) {
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+	c.lru.Remove(elem)
+}
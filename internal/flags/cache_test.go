@@ -0,0 +1,353 @@
+package flags_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRepository counts backend reads. When release is set, each Get
+// signals started and then waits for release to be closed. Like a real
+// backend, it fails reads whose ctx is done by then.
+type countingRepository struct {
+	*flags.MemoryRepository
+
+	gets    atomic.Int32
+	started chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func newCountingRepository() *countingRepository {
+	return &countingRepository{MemoryRepository: flags.NewMemoryRepository()}
+}
+
+func (r *countingRepository) Get(ctx context.Context, key flags.FlagKey) (flags.Flag, error) {
+	r.gets.Add(1)
+
+	if r.release != nil {
+		r.started <- struct{}{}
+
+		<-r.release
+	}
+
+	if err := ctx.Err(); err != nil {
+		return flags.Flag{}, err
+	}
+
+	if r.err != nil {
+		return flags.Flag{}, r.err
+	}
+
+	return r.MemoryRepository.Get(ctx, key)
+}
+
+func TestCachedRepository_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunRepositorySuite(t, func(*testing.T) flags.Repository {
+		return flags.NewCachedRepository(flags.NewMemoryRepository())
+	})
+}
+
+func TestCachedRepository_GetIsCached(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingRepository()
+	repo := flags.NewCachedRepository(backend)
+	ctx := context.Background()
+
+	require.NoError(t, backend.Create(ctx, flagstest.SampleFlag("sample")))
+
+	for range 3 {
+		got, err := repo.Get(ctx, "sample")
+		require.NoError(t, err)
+		flagstest.AssertFlagEqual(t, flagstest.SampleFlag("sample"), got)
+	}
+
+	assert.Equal(t, int32(1), backend.gets.Load())
+}
+
+func TestCachedRepository_WritesInvalidate(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingRepository()
+	repo := flags.NewCachedRepository(backend)
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, flagstest.SampleFlag("sample")))
+
+	_, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+
+	next := flagstest.SampleFlag("sample")
+	next.Version = 2
+	next.Enabled = false
+	require.NoError(t, repo.Update(ctx, next))
+
+	got, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	assert.False(t, got.Enabled)
+
+	require.NoError(t, repo.Delete(ctx, "sample"))
+
+	_, err = repo.Get(ctx, "sample")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+	assert.Equal(t, int32(3), backend.gets.Load())
+}
+
+func TestCachedRepository_NegativeCaching(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingRepository()
+	repo := flags.NewCachedRepository(backend)
+	ctx := context.Background()
+
+	for range 2 {
+		_, err := repo.Get(ctx, "sample")
+		require.ErrorIs(t, err, flags.ErrFlagNotFound)
+	}
+
+	assert.Equal(t, int32(1), backend.gets.Load())
+
+	// A flag created behind the cache's back stays hidden until invalidated.
+	require.NoError(t, backend.Create(ctx, flagstest.SampleFlag("sample")))
+
+	_, err := repo.Get(ctx, "sample")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	repo.Invalidate("sample")
+
+	_, err = repo.Get(ctx, "sample")
+	require.NoError(t, err)
+}
+
+func TestCachedRepository_NegativeCachingDisabled(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingRepository()
+	repo := flags.NewCachedRepository(backend, flags.WithNegativeCacheTTL(0))
+	ctx := context.Background()
+
+	for range 2 {
+		_, err := repo.Get(ctx, "sample")
+		require.ErrorIs(t, err, flags.ErrFlagNotFound)
+	}
+
+	assert.Equal(t, int32(2), backend.gets.Load())
+}
+
+func TestCachedRepository_ErrorsAreNotCached(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingRepository()
+	backend.err = errors.New("backend down")
+	repo := flags.NewCachedRepository(backend)
+
+	for range 2 {
+		_, err := repo.Get(context.Background(), "sample")
+		require.ErrorIs(t, err, backend.err)
+	}
+
+	assert.Equal(t, int32(2), backend.gets.Load())
+}
+
+func TestCachedRepository_TTL(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingRepository()
+	clock := flagstest.NewClock(time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC))
+	repo := flags.NewCachedRepository(backend, flags.WithCacheTTL(time.Minute), flags.WithCacheClock(clock))
+	ctx := context.Background()
+
+	require.NoError(t, backend.Create(ctx, flagstest.SampleFlag("sample")))
+
+	_, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+
+	clock.Advance(59 * time.Second)
+
+	_, err = repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), backend.gets.Load(), "the entry is still fresh")
+
+	clock.Advance(time.Second)
+
+	_, err = repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), backend.gets.Load())
+}
+
+func TestCachedRepository_EvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingRepository()
+	repo := flags.NewCachedRepository(backend, flags.WithCacheSize(2))
+	ctx := context.Background()
+
+	for _, key := range []flags.FlagKey{"a", "b", "a", "c"} {
+		_, _ = repo.Get(ctx, key)
+	}
+
+	require.Equal(t, int32(3), backend.gets.Load())
+
+	_, _ = repo.Get(ctx, "a")
+
+	assert.Equal(t, int32(3), backend.gets.Load(), "a was used recently and must still be cached")
+
+	_, _ = repo.Get(ctx, "b")
+
+	assert.Equal(t, int32(4), backend.gets.Load(), "b must have been evicted")
+}
+
+func TestCachedRepository_SizeZeroDisablesCaching(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingRepository()
+	repo := flags.NewCachedRepository(backend, flags.WithCacheSize(0))
+
+	for range 2 {
+		_, _ = repo.Get(context.Background(), "sample")
+	}
+
+	assert.Equal(t, int32(2), backend.gets.Load())
+}
+
+func TestCachedRepository_CoalescesConcurrentMisses(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingRepository()
+	backend.started = make(chan struct{}, 1)
+	backend.release = make(chan struct{})
+	repo := flags.NewCachedRepository(backend)
+	ctx := context.Background()
+
+	require.NoError(t, backend.Create(ctx, flagstest.SampleFlag("sample")))
+
+	const callers = 10
+
+	var wg sync.WaitGroup
+
+	results := make(chan error, callers)
+
+	for range callers {
+		wg.Go(func() {
+			_, err := repo.Get(ctx, "sample")
+			results <- err
+		})
+	}
+
+	<-backend.started
+	// Give the other callers time to join the in-flight load.
+	time.Sleep(20 * time.Millisecond)
+	close(backend.release)
+	wg.Wait()
+	close(results)
+
+	for err := range results {
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, int32(1), backend.gets.Load())
+}
+
+func TestCachedRepository_FirstCallerCancels(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingRepository()
+	backend.started = make(chan struct{}, 1)
+	backend.release = make(chan struct{})
+	repo := flags.NewCachedRepository(backend)
+
+	require.NoError(t, backend.Create(context.Background(), flagstest.SampleFlag("sample")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+
+	go func() {
+		_, err := repo.Get(ctx, "sample")
+		first <- err
+	}()
+
+	<-backend.started
+
+	second := make(chan error, 1)
+
+	go func() {
+		_, err := repo.Get(context.Background(), "sample")
+		second <- err
+	}()
+
+	// Give the second caller time to join the in-flight load.
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	require.ErrorIs(t, <-first, context.Canceled, "the first caller stops waiting at once")
+
+	close(backend.release)
+
+	require.NoError(t, <-second, "the shared load does not see the first caller's cancellation")
+	assert.Equal(t, int32(1), backend.gets.Load())
+
+	_, err := repo.Get(context.Background(), "sample")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), backend.gets.Load(), "the shared load was cached")
+}
+
+func TestCachedRepository_InvalidationDuringLoad(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingRepository()
+	backend.started = make(chan struct{}, 1)
+	backend.release = make(chan struct{})
+	repo := flags.NewCachedRepository(backend)
+	ctx := context.Background()
+
+	require.NoError(t, backend.Create(ctx, flagstest.SampleFlag("sample")))
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		_, _ = repo.Get(ctx, "sample")
+	}()
+
+	<-backend.started
+	repo.Invalidate("sample")
+	close(backend.release)
+	<-done
+
+	// The load that raced with the invalidation must not have been cached.
+	backend.release = nil
+
+	_, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), backend.gets.Load())
+}
+
+func TestCachedRepository_InvalidateAll(t *testing.T) {
+	t.Parallel()
+
+	backend := newCountingRepository()
+	repo := flags.NewCachedRepository(backend)
+	ctx := context.Background()
+
+	_, _ = repo.Get(ctx, "a")
+	_, _ = repo.Get(ctx, "b")
+
+	repo.InvalidateAll()
+
+	_, _ = repo.Get(ctx, "a")
+	_, _ = repo.Get(ctx, "b")
+
+	assert.Equal(t, int32(4), backend.gets.Load())
+}
@@ -0,0 +1,172 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrChangeNotFound = errors.New("change request not found")
+	ErrChangeExists   = errors.New("change request already exists")
+)
+
+type ChangeStatus string
+
+const (
+	ChangePending  ChangeStatus = "pending"
+	ChangeApproved ChangeStatus = "approved"
+	ChangeRejected ChangeStatus = "rejected"
+	ChangeApplied  ChangeStatus = "applied"
+)
+
+type Comment struct {
+	Author string
+	Text   string
+	Time   time.Time
+}
+
+// ChangeRequest is a proposed mutation of a flag that needs approval. Base
+// is the flag as it was when the change was requested and Proposed the flag
+// as the requester wants it; Base is nil for creations and Proposed is nil
+// for deletions.
+type ChangeRequest struct {
+	ID        string
+	FlagKey   FlagKey
+	Action    AuditAction
+	Base      *Flag
+	Proposed  *Flag
+	Diff      []FieldChange
+	Requester string
+	// RequiredApprovals is how many people other than the requester must
+	// approve before the change can be applied.
+	RequiredApprovals int
+	Approvers         []string
+	Comments          []Comment
+	Status            ChangeStatus
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	// ClosedBy rejected or applied the change.
+	ClosedBy string
+	ClosedAt time.Time
+	// AppliedVersion is the flag version the change produced; 0 for
+	// deletions and changes not applied.
+	AppliedVersion int
+}
+
+// Closed reports whether the change was rejected or applied.
+func (c ChangeRequest) Closed() bool {
+	return c.Status == ChangeRejected || c.Status == ChangeApplied
+}
+
+type ChangeFilter struct {
+	FlagKey FlagKey
+	Status  ChangeStatus
+}
+
+func (f ChangeFilter) matches(change ChangeRequest) bool {
+	return (f.FlagKey == "" || change.FlagKey == f.FlagKey) && (f.Status == "" || change.Status == f.Status)
+}
+
+// ChangeStore keeps change requests. List returns them oldest first.
+type ChangeStore interface {
+	Create(ctx context.Context, change ChangeRequest) error
+	Get(ctx context.Context, id string) (ChangeRequest, error)
+	List(ctx context.Context, filter ChangeFilter) ([]ChangeRequest, error)
+	Update(ctx context.Context, change ChangeRequest) error
+}
+
+// WithChangeStore replaces the default in-memory change request store.
+func WithChangeStore(store ChangeStore) Option {
+	return func(s *Service) {
+		s.changes = store
+	}
+}
+
+type MemoryChangeStore struct {
+	mu      sync.RWMutex
+	changes []ChangeRequest
+}
+
+func NewMemoryChangeStore() *MemoryChangeStore {
+	return &MemoryChangeStore{}
+}
+
+func (s *MemoryChangeStore) Create(_ context.Context, change ChangeRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index(change.ID) >= 0 {
+		return fmt.Errorf("%w: %s", ErrChangeExists, change.ID)
+	}
+
+	s.changes = append(s.changes, change.clone())
+
+	return nil
+}
+
+func (s *MemoryChangeStore) Get(_ context.Context, id string) (ChangeRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return ChangeRequest{}, ErrChangeNotFound
+	}
+
+	return s.changes[i].clone(), nil
+}
+
+func (s *MemoryChangeStore) List(_ context.Context, filter ChangeFilter) ([]ChangeRequest, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []ChangeRequest
+
+	for _, change := range s.changes {
+		if filter.matches(change) {
+			result = append(result, change.clone())
+		}
+	}
+
+	return result, nil
+}
+
+func (s *MemoryChangeStore) Update(_ context.Context, change ChangeRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(change.ID)
+	if i < 0 {
+		return ErrChangeNotFound
+	}
+
+	s.changes[i] = change.clone()
+
+	return nil
+}
+
+func (s *MemoryChangeStore) index(id string) int {
+	return slices.IndexFunc(s.changes, func(change ChangeRequest) bool { return change.ID == id })
+}
+
+func (c ChangeRequest) clone() ChangeRequest {
+	if c.Base != nil {
+		base := c.Base.Clone()
+		c.Base = &base
+	}
+
+	if c.Proposed != nil {
+		proposed := c.Proposed.Clone()
+		c.Proposed = &proposed
+	}
+
+	c.Diff = slices.Clone(c.Diff)
+	c.Approvers = slices.Clone(c.Approvers)
+	c.Comments = slices.Clone(c.Comments)
+
+	return c
+}
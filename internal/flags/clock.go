@@ -0,0 +1,23 @@
+package flags
+
+import "time"
+
+// Clock tells the service the time. Tests replace it to control time-based
+// behaviour, such as scheduled changes, timestamps and cache expiry, without
+// sleeping; flagstest.Clock is one they can move by hand.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithClock replaces the system clock.
+func WithClock(clock Clock) Option {
+	return func(s *Service) {
+		s.clock = clock
+	}
+}
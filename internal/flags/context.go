@@ -0,0 +1,105 @@
+package flags
+
+import "context"
+
+const AnonymousActor = "anonymous"
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	requestIDKey
+	managerKey
+	emergencyKey
+	approvalKey
+	scheduleKey
+	rampKey
+)
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx, or AnonymousActor.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+
+	return AnonymousActor
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+
+	return requestID
+}
+
+// WithManager marks writes made with ctx as coming from manager (for example
+// ManagedByFile). Flags record the manager that last wrote them, and a flag
+// with a manager can only be changed by that same manager.
+func WithManager(ctx context.Context, manager string) context.Context {
+	return context.WithValue(ctx, managerKey, manager)
+}
+
+// ManagerFromContext returns the manager stored in ctx, or "" for the API.
+func ManagerFromContext(ctx context.Context) string {
+	manager, _ := ctx.Value(managerKey).(string)
+
+	return manager
+}
+
+// WithEmergency marks writes made with ctx as emergency changes, which are
+// applied at once even to protected flags and flagged in the audit log.
+func WithEmergency(ctx context.Context) context.Context {
+	return context.WithValue(ctx, emergencyKey, true)
+}
+
+func EmergencyFromContext(ctx context.Context) bool {
+	emergency, _ := ctx.Value(emergencyKey).(bool)
+
+	return emergency
+}
+
+// approval marks writes that were already approved: those applying change
+// request changeID, or imports, which skip flags needing approval when they
+// are planned.
+type approval struct {
+	changeID string
+}
+
+func withApproval(ctx context.Context, changeID string) context.Context {
+	return context.WithValue(ctx, approvalKey, approval{changeID: changeID})
+}
+
+func approvalFromContext(ctx context.Context) (approval, bool) {
+	a, ok := ctx.Value(approvalKey).(approval)
+
+	return a, ok
+}
+
+// withSchedule marks writes made by scheduled change id.
+func withSchedule(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, scheduleKey, id)
+}
+
+func scheduleFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(scheduleKey).(string)
+
+	return id
+}
+
+// withRamp marks writes made by rollout ramp id.
+func withRamp(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, rampKey, id)
+}
+
+func rampFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(rampKey).(string)
+
+	return id
+}
@@ -0,0 +1,196 @@
+package flags
+
+import (
+	"reflect"
+	"slices"
+)
+
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// Paths of the fields in a FieldChange that change requests rebase.
+const (
+	pathType         = "type"
+	pathEnabled      = "enabled"
+	pathDefaultValue = "defaultValue"
+	pathProtected    = "protected"
+	pathTests        = "tests"
+	pathRules        = "rules"
+)
+
+// Paths of the metadata fields in a FieldChange.
+const (
+	pathDescription = "description"
+	pathOwner       = "owner"
+	pathTags        = "tags"
+	pathKind        = "kind"
+	pathExpiresAt   = "expiresAt"
+)
+
+// FieldChange describes one difference between two flag snapshots. Path is a
+// top-level field name ("enabled", "defaultValue", ...), "rules[<id>]" for a
+// single rule, or "rules" when only the rule order changed. Before and After
+// hold the domain value (bool, string, FlagType, FlagKind, time.Time, Value,
+// Rule, []FlagTest or []string).
+type FieldChange struct {
+	Path   string
+	Kind   ChangeKind
+	Before any
+	After  any
+}
+
+// Diff compares two snapshots of the same flag. A nil snapshot stands for a
+// flag that does not exist, so Diff(nil, f) lists everything f sets.
+func Diff(before, after *Flag) []FieldChange {
+	var from, to Flag
+
+	if before != nil {
+		from = *before
+	}
+
+	if after != nil {
+		to = *after
+	}
+
+	var changes []FieldChange
+
+	if from.Type != to.Type {
+		changes = append(changes, fieldChange(pathType, before, after, from.Type, to.Type))
+	}
+
+	if from.Enabled != to.Enabled || before == nil || after == nil {
+		changes = append(changes, fieldChange(pathEnabled, before, after, from.Enabled, to.Enabled))
+	}
+
+	if !reflect.DeepEqual(from.DefaultValue, to.DefaultValue) {
+		changes = append(changes, fieldChange(pathDefaultValue, before, after, from.DefaultValue, to.DefaultValue))
+	}
+
+	changes = append(changes, diffSettings(before, after, from, to)...)
+	changes = append(changes, diffMetadata(before, after, from, to)...)
+
+	return append(changes, diffRules(from.Rules, to.Rules)...)
+}
+
+// diffSettings compares the fields that do not affect evaluation.
+func diffSettings(before, after *Flag, from, to Flag) []FieldChange {
+	var changes []FieldChange
+
+	if from.Protected != to.Protected {
+		changes = append(changes, fieldChange(pathProtected, before, after, from.Protected, to.Protected))
+	}
+
+	if from.ManagedBy != to.ManagedBy {
+		changes = append(changes, fieldChange("managedBy", before, after, from.ManagedBy, to.ManagedBy))
+	}
+
+	if !reflect.DeepEqual(from.Tests, to.Tests) {
+		changes = append(changes, fieldChange(pathTests, before, after, from.Tests, to.Tests))
+	}
+
+	return changes
+}
+
+// diffMetadata compares the fields that describe the flag to people.
+func diffMetadata(before, after *Flag, from, to Flag) []FieldChange {
+	var changes []FieldChange
+
+	if from.Description != to.Description {
+		changes = append(changes, fieldChange(pathDescription, before, after, from.Description, to.Description))
+	}
+
+	if from.Owner != to.Owner {
+		changes = append(changes, fieldChange(pathOwner, before, after, from.Owner, to.Owner))
+	}
+
+	if !slices.Equal(from.Tags, to.Tags) {
+		changes = append(changes, fieldChange(pathTags, before, after, from.Tags, to.Tags))
+	}
+
+	if from.Kind != to.Kind {
+		changes = append(changes, fieldChange(pathKind, before, after, from.Kind, to.Kind))
+	}
+
+	if !from.ExpiresAt.Equal(to.ExpiresAt) {
+		changes = append(changes, fieldChange(pathExpiresAt, before, after, from.ExpiresAt, to.ExpiresAt))
+	}
+
+	return changes
+}
+
+func fieldChange(path string, before, after *Flag, from, to any) FieldChange {
+	switch {
+	case before == nil:
+		return FieldChange{Path: path, Kind: ChangeAdded, After: to}
+	case after == nil:
+		return FieldChange{Path: path, Kind: ChangeRemoved, Before: from}
+	default:
+		return FieldChange{Path: path, Kind: ChangeModified, Before: from, After: to}
+	}
+}
+
+func diffRules(from, to []Rule) []FieldChange {
+	fromByID := make(map[string]Rule, len(from))
+	for _, rule := range from {
+		fromByID[rule.ID] = rule
+	}
+
+	toByID := make(map[string]Rule, len(to))
+	for _, rule := range to {
+		toByID[rule.ID] = rule
+	}
+
+	var changes []FieldChange
+
+	for _, rule := range from {
+		if _, ok := toByID[rule.ID]; !ok {
+			changes = append(changes, FieldChange{Path: rulePath(rule.ID), Kind: ChangeRemoved, Before: rule})
+		}
+	}
+
+	for _, rule := range to {
+		prev, ok := fromByID[rule.ID]
+
+		switch {
+		case !ok:
+			changes = append(changes, FieldChange{Path: rulePath(rule.ID), Kind: ChangeAdded, After: rule})
+		case !reflect.DeepEqual(prev, rule):
+			changes = append(changes, FieldChange{
+				Path:   rulePath(rule.ID),
+				Kind:   ChangeModified,
+				Before: prev,
+				After:  rule,
+			})
+		}
+	}
+
+	fromOrder := commonRuleIDs(from, toByID)
+	toOrder := commonRuleIDs(to, fromByID)
+
+	if !slices.Equal(fromOrder, toOrder) {
+		changes = append(changes, FieldChange{Path: pathRules, Kind: ChangeModified, Before: fromOrder, After: toOrder})
+	}
+
+	return changes
+}
+
+func commonRuleIDs(rules []Rule, other map[string]Rule) []string {
+	var ids []string
+
+	for _, rule := range rules {
+		if _, ok := other[rule.ID]; ok {
+			ids = append(ids, rule.ID)
+		}
+	}
+
+	return ids
+}
+
+func rulePath(id string) string {
+	return pathRules + "[" + id + "]"
+}
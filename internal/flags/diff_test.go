@@ -0,0 +1,131 @@
+package flags_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff_Create(t *testing.T) {
+	t.Parallel()
+
+	after := flags.Flag{
+		Key:          "flag-a",
+		Type:         flags.FlagBool,
+		Enabled:      true,
+		DefaultValue: flags.BoolValue(false),
+		Rules:        []flags.Rule{{ID: "rule-1", Value: flags.BoolValue(true)}},
+	}
+
+	changes := flags.Diff(nil, &after)
+
+	assert.Equal(t, []flags.FieldChange{
+		{Path: "type", Kind: flags.ChangeAdded, After: flags.FlagBool},
+		{Path: "enabled", Kind: flags.ChangeAdded, After: true},
+		{Path: "defaultValue", Kind: flags.ChangeAdded, After: flags.BoolValue(false)},
+		{Path: "rules[rule-1]", Kind: flags.ChangeAdded, After: after.Rules[0]},
+	}, changes)
+}
+
+func TestDiff_Delete(t *testing.T) {
+	t.Parallel()
+
+	before := flags.Flag{Key: "flag-a", Type: flags.FlagString, Enabled: false}
+
+	changes := flags.Diff(&before, nil)
+
+	assert.Equal(t, []flags.FieldChange{
+		{Path: "type", Kind: flags.ChangeRemoved, Before: flags.FlagString},
+		{Path: "enabled", Kind: flags.ChangeRemoved, Before: false},
+	}, changes)
+}
+
+func TestDiff_Update(t *testing.T) {
+	t.Parallel()
+
+	ruleA := flags.Rule{ID: "a", Value: flags.BoolValue(true)}
+	ruleB := flags.Rule{ID: "b", Value: flags.BoolValue(true)}
+	ruleC := flags.Rule{ID: "c", Value: flags.BoolValue(true)}
+	changedB := flags.Rule{ID: "b", Value: flags.BoolValue(false)}
+	ruleD := flags.Rule{ID: "d", Value: flags.BoolValue(true)}
+
+	before := flags.Flag{
+		Key:          "flag-a",
+		Type:         flags.FlagBool,
+		Enabled:      true,
+		DefaultValue: flags.BoolValue(false),
+		Rules:        []flags.Rule{ruleA, ruleB, ruleC},
+	}
+	after := flags.Flag{
+		Key:          "flag-a",
+		Type:         flags.FlagBool,
+		Enabled:      false,
+		DefaultValue: flags.BoolValue(false),
+		Rules:        []flags.Rule{changedB, ruleD, ruleA},
+	}
+
+	changes := flags.Diff(&before, &after)
+
+	assert.Equal(t, []flags.FieldChange{
+		{Path: "enabled", Kind: flags.ChangeModified, Before: true, After: false},
+		{Path: "rules[c]", Kind: flags.ChangeRemoved, Before: ruleC},
+		{Path: "rules[b]", Kind: flags.ChangeModified, Before: ruleB, After: changedB},
+		{Path: "rules[d]", Kind: flags.ChangeAdded, After: ruleD},
+		{Path: "rules", Kind: flags.ChangeModified, Before: []string{"a", "b"}, After: []string{"b", "a"}},
+	}, changes)
+}
+
+func TestDiff_NoChanges(t *testing.T) {
+	t.Parallel()
+
+	flag := flags.Flag{Key: "flag-a", Type: flags.FlagBool, DefaultValue: flags.BoolValue(true)}
+	other := flag
+	other.DefaultValue = flags.BoolValue(true)
+
+	assert.Empty(t, flags.Diff(&flag, &other))
+}
+
+func TestDiff_ManagedBy(t *testing.T) {
+	t.Parallel()
+
+	before := flags.Flag{Key: "flag-a", Type: flags.FlagBool}
+	after := flags.Flag{Key: "flag-a", Type: flags.FlagBool, ManagedBy: flags.ManagedByFile}
+
+	assert.Equal(t, []flags.FieldChange{
+		{Path: "managedBy", Kind: flags.ChangeModified, Before: "", After: flags.ManagedByFile},
+	}, flags.Diff(&before, &after))
+}
+
+func TestDiff_Tests(t *testing.T) {
+	t.Parallel()
+
+	before := flags.Flag{Key: "flag-a", Type: flags.FlagBool}
+	after := before
+	after.Tests = []flags.FlagTest{{Name: "off by default", Expect: flags.BoolValue(false)}}
+
+	assert.Equal(t, []flags.FieldChange{
+		{Path: "tests", Kind: flags.ChangeModified, Before: []flags.FlagTest(nil), After: after.Tests},
+	}, flags.Diff(&before, &after))
+}
+
+func TestDiff_Metadata(t *testing.T) {
+	t.Parallel()
+
+	before := flags.Flag{Key: "flag-a", Type: flags.FlagBool, Tags: []string{"checkout"}}
+	after := before
+	after.Description = "New checkout"
+	after.Owner = "payments"
+	after.Tags = []string{"checkout", "q3"}
+	after.Kind = flags.KindRelease
+	after.ExpiresAt = time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, []flags.FieldChange{
+		{Path: "description", Kind: flags.ChangeModified, Before: "", After: "New checkout"},
+		{Path: "owner", Kind: flags.ChangeModified, Before: "", After: "payments"},
+		{Path: "tags", Kind: flags.ChangeModified, Before: []string{"checkout"}, After: []string{"checkout", "q3"}},
+		{Path: "kind", Kind: flags.ChangeModified, Before: flags.FlagKind(""), After: flags.KindRelease},
+		{Path: "expiresAt", Kind: flags.ChangeModified, Before: time.Time{}, After: after.ExpiresAt},
+	}, flags.Diff(&before, &after))
+}
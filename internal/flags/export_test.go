@@ -0,0 +1,12 @@
+package flags
+
+// LogFile lets tests wrap the file an append-only log writes through.
+type LogFile = logFile
+
+// WrapAuditFile makes s write through wrap(file) instead of its file.
+func WrapAuditFile(s *FileAuditStore, wrap func(LogFile) LogFile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.file = wrap(s.file)
+}
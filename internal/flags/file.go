@@ -0,0 +1,555 @@
+package flags
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+const (
+	walFileName      = "flags.wal"
+	snapshotFileName = "flags.snapshot"
+
+	walHeaderSize        = 8
+	maxWALRecordSize     = 16 << 20
+	defaultSnapshotEvery = 1000
+)
+
+var (
+	ErrCorruptSnapshot = errors.New("corrupt snapshot")
+	ErrCorruptWAL      = errors.New("corrupt write-ahead log")
+	ErrStoreClosed     = errors.New("store closed")
+	crcTable           = crc32.MakeTable(crc32.Castagnoli)
+
+	// errTornWALRecord marks a final frame cut short by a crash mid-append.
+	errTornWALRecord = errors.New("torn record")
+)
+
+// FileRepository keeps every flag in memory and persists mutations to a
+// write-ahead log in a data directory. Each WAL record is framed as a 4-byte
+// big-endian payload length, a 4-byte CRC-32C of the payload and the JSON
+// payload, and is fsynced before the mutation becomes visible. Every
+// snapshotEvery records the full state is written to a snapshot and the log is
+// truncated.
+//
+// Writers are serialized by writeMu, which they hold while the record is
+// appended and fsynced; mu is only taken to publish the change, so reads never
+// wait for the disk.
+type FileRepository struct {
+	writeMu       sync.Mutex
+	mu            sync.RWMutex
+	dir           string
+	flags         map[FlagKey]Flag
+	wal           *os.File
+	walSize       int64
+	walRecords    int
+	seq           uint64
+	snapshotEvery int
+	snapshotError func(error)
+	recovery      RecoveryReport
+}
+
+// RecoveryReport describes what OpenFileRepository found on disk.
+type RecoveryReport struct {
+	SnapshotSeq    uint64 // sequence number covered by the snapshot, 0 if none
+	Replayed       int    // WAL records applied on top of the snapshot
+	DiscardedBytes int64  // size of the torn WAL tail that was dropped
+}
+
+type FileRepositoryOption func(*FileRepository)
+
+// WithSnapshotEvery sets how many WAL records trigger a compacted snapshot.
+func WithSnapshotEvery(records int) FileRepositoryOption {
+	return func(r *FileRepository) {
+		r.snapshotEvery = records
+	}
+}
+
+// WithSnapshotErrorHandler sets the function told about failed automatic
+// snapshots. The mutation that triggered one is already durable in the WAL,
+// so it still succeeds, and the snapshot is retried on the next write.
+func WithSnapshotErrorHandler(handle func(error)) FileRepositoryOption {
+	return func(r *FileRepository) {
+		r.snapshotError = handle
+	}
+}
+
+type walOp string
+
+const (
+	walPut    walOp = "put"
+	walDelete walOp = "delete"
+)
+
+type walRecord struct {
+	Seq  uint64  `json:"seq"`
+	Op   walOp   `json:"op"`
+	Flag *Flag   `json:"flag,omitempty"` // set for put
+	Key  FlagKey `json:"key,omitempty"`  // set for delete
+}
+
+func (w walRecord) valid() bool {
+	switch w.Op {
+	case walPut:
+		return w.Flag != nil
+	case walDelete:
+		return w.Key != ""
+	default:
+		return false
+	}
+}
+
+func (w walRecord) apply(flags map[FlagKey]Flag) {
+	if w.Op == walDelete {
+		delete(flags, w.Key)
+
+		return
+	}
+
+	flags[w.Flag.Key] = w.Flag.Clone()
+}
+
+type snapshot struct {
+	Seq   uint64 `json:"seq"`
+	Flags []Flag `json:"flags"`
+}
+
+// OpenFileRepository loads the snapshot and replays the WAL found in dir,
+// creating the directory if needed. A final record cut short by a crash
+// mid-append is truncated; see Recovery. Any other bad record, including a
+// final one that fails its checksum, is reported as ErrCorruptWAL.
+func OpenFileRepository(dir string, opts ...FileRepositoryOption) (*FileRepository, error) {
+	r := &FileRepository{
+		dir:           dir,
+		flags:         make(map[FlagKey]Flag),
+		snapshotEvery: defaultSnapshotEvery,
+		snapshotError: func(error) {},
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create data dir: %w", err)
+	}
+
+	if err := r.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	if err := r.openWAL(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *FileRepository) Recovery() RecoveryReport {
+	return r.recovery
+}
+
+func (r *FileRepository) Get(_ context.Context, key FlagKey) (Flag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	flag, ok := r.flags[key]
+	if !ok {
+		return Flag{}, ErrFlagNotFound
+	}
+
+	return flag.Clone(), nil
+}
+
+func (r *FileRepository) List(_ context.Context) ([]Flag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return sortedFlags(r.flags), nil
+}
+
+func (r *FileRepository) Create(_ context.Context, flag Flag) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if _, exists := r.flags[flag.Key]; exists {
+		return ErrFlagExists
+	}
+
+	return r.put(flag)
+}
+
+func (r *FileRepository) Update(_ context.Context, flag Flag) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	current, exists := r.flags[flag.Key]
+	if !exists {
+		return ErrFlagNotFound
+	}
+
+	if current.Version != flag.Version-1 {
+		return ErrVersionConflict
+	}
+
+	return r.put(flag)
+}
+
+func (r *FileRepository) Delete(_ context.Context, key FlagKey) error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	if _, exists := r.flags[key]; !exists {
+		return ErrFlagNotFound
+	}
+
+	return r.apply(walRecord{Op: walDelete, Key: key})
+}
+
+// Snapshot writes the current state to disk and truncates the WAL.
+func (r *FileRepository) Snapshot() error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	return r.snapshot()
+}
+
+func (r *FileRepository) Close() error {
+	r.writeMu.Lock()
+	defer r.writeMu.Unlock()
+
+	return r.wal.Close()
+}
+
+func (r *FileRepository) put(flag Flag) error {
+	return r.apply(walRecord{Op: walPut, Flag: &flag})
+}
+
+// apply logs record and then applies it to the in-memory state. The caller
+// holds writeMu.
+func (r *FileRepository) apply(record walRecord) error {
+	record.Seq = r.seq + 1
+
+	if err := r.appendWAL(record); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	record.apply(r.flags)
+	r.mu.Unlock()
+
+	r.seq = record.Seq
+
+	if r.snapshotEvery > 0 && r.walRecords >= r.snapshotEvery {
+		if err := r.snapshot(); err != nil {
+			r.snapshotError(err)
+		}
+	}
+
+	return nil
+}
+
+func (r *FileRepository) appendWAL(record walRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encode wal record: %w", err)
+	}
+
+	frame := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(payload))) //nolint:gosec // bounded by maxWALRecordSize on read
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload, crcTable))
+	copy(frame[walHeaderSize:], payload)
+
+	_, err = r.wal.Write(frame)
+	if err == nil {
+		err = r.wal.Sync()
+	}
+
+	if err != nil {
+		// Drop whatever part of the frame made it to disk so the next append
+		// does not land after a torn record.
+		_ = truncateTo(r.wal, r.walSize)
+
+		return fmt.Errorf("append wal record: %w", err)
+	}
+
+	r.walSize += int64(len(frame))
+	r.walRecords++
+
+	return nil
+}
+
+// snapshot writes the state and truncates the WAL. The caller holds writeMu,
+// so the flags cannot change underneath it.
+func (r *FileRepository) snapshot() error {
+	state := snapshot{Seq: r.seq, Flags: sortedFlags(r.flags)}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	if err := writeFileAtomic(filepath.Join(r.dir, snapshotFileName), data); err != nil {
+		return err
+	}
+
+	// Records up to seq are now in the snapshot and are skipped on replay, so a
+	// crash before the truncation below is harmless.
+	if err := truncateTo(r.wal, 0); err != nil {
+		return err
+	}
+
+	r.walSize = 0
+	r.walRecords = 0
+
+	return nil
+}
+
+func (r *FileRepository) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(r.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("read snapshot: %w", err)
+	}
+
+	var state snapshot
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("%w: %w", ErrCorruptSnapshot, err)
+	}
+
+	for _, flag := range state.Flags {
+		r.flags[flag.Key] = flag
+	}
+
+	r.seq = state.Seq
+	r.recovery.SnapshotSeq = state.Seq
+
+	return nil
+}
+
+func (r *FileRepository) openWAL() error {
+	wal, err := os.OpenFile(filepath.Join(r.dir, walFileName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("open wal: %w", err)
+	}
+
+	valid, total, err := r.replay(wal)
+	if err == nil {
+		err = truncateTo(wal, valid)
+	}
+
+	if err != nil {
+		_ = wal.Close()
+
+		return err
+	}
+
+	r.wal = wal
+	r.walSize = valid
+	r.recovery.DiscardedBytes = total - valid
+
+	return nil
+}
+
+// replay applies every intact record in wal and returns the offset just past
+// the last one together with the file size. Only a frame cut short by the end
+// of the file, as a crash mid-append leaves it, is tolerated; any other bad
+// record is reported as ErrCorruptWAL.
+func (r *FileRepository) replay(wal *os.File) (int64, int64, error) {
+	info, err := wal.Stat()
+	if err != nil {
+		return 0, 0, fmt.Errorf("stat wal: %w", err)
+	}
+
+	reader := bufio.NewReader(wal)
+
+	var offset int64
+
+	for {
+		record, size, err := readWALRecord(reader)
+		if errors.Is(err, io.EOF) {
+			return offset, info.Size(), nil
+		}
+
+		if errors.Is(err, errTornWALRecord) {
+			return offset, info.Size(), nil
+		}
+
+		if err != nil {
+			return 0, 0, fmt.Errorf("%w: record at offset %d: %w", ErrCorruptWAL, offset, err)
+		}
+
+		offset += size
+		r.walRecords++
+
+		if record.Seq <= r.seq {
+			continue
+		}
+
+		record.apply(r.flags)
+		r.seq = record.Seq
+		r.recovery.Replayed++
+	}
+}
+
+// readWALRecord reads one frame and returns its size. The error is io.EOF at
+// the end of the file and errTornWALRecord for a frame the end of the file
+// cuts short. A short payload that still holds a whole JSON value means the
+// length header is wrong rather than the write torn, so it is not torn.
+func readWALRecord(reader io.Reader) (walRecord, int64, error) {
+	var header [walHeaderSize]byte
+
+	n, err := io.ReadFull(reader, header[:])
+	if n == 0 && errors.Is(err, io.EOF) {
+		return walRecord{}, 0, io.EOF
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return walRecord{}, walHeaderSize, errTornWALRecord
+	}
+
+	if err != nil {
+		return walRecord{}, walHeaderSize, fmt.Errorf("read header: %w", err)
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	size := int64(walHeaderSize) + int64(length)
+
+	if length > maxWALRecordSize {
+		return walRecord{}, size, fmt.Errorf("record of %d bytes exceeds the limit", length)
+	}
+
+	payload := make([]byte, length)
+
+	n, err = io.ReadFull(reader, payload)
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		if startsWithJSONValue(payload[:n]) {
+			return walRecord{}, size, fmt.Errorf("length %d runs past the end of the log", length)
+		}
+
+		return walRecord{}, size, errTornWALRecord
+	}
+
+	if err != nil {
+		return walRecord{}, size, fmt.Errorf("read payload: %w", err)
+	}
+
+	record, err := decodeWALRecord(payload, binary.BigEndian.Uint32(header[4:8]))
+
+	return record, size, err
+}
+
+// decodeWALRecord checks payload against its checksum and decodes it.
+func decodeWALRecord(payload []byte, checksum uint32) (walRecord, error) {
+	if crc32.Checksum(payload, crcTable) != checksum {
+		return walRecord{}, errors.New("checksum mismatch")
+	}
+
+	var record walRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return walRecord{}, fmt.Errorf("decode record: %w", err)
+	}
+
+	if !record.valid() {
+		return walRecord{}, fmt.Errorf("invalid %q record", record.Op)
+	}
+
+	return record, nil
+}
+
+// startsWithJSONValue reports whether data opens with a complete JSON value,
+// which a prefix of a single encoded record never does.
+func startsWithJSONValue(data []byte) bool {
+	var value json.RawMessage
+
+	return json.NewDecoder(bytes.NewReader(data)).Decode(&value) == nil
+}
+
+// rewriteJSON applies change to *items, which mu guards, and rewrites path
+// with the result as JSON, undoing the change when the file cannot be
+// written. Errors from change are returned as they are.
+func rewriteJSON[T any](mu *sync.RWMutex, items *[]T, path, what string, change func() error) error {
+	read := func() []T {
+		mu.RLock()
+		defer mu.RUnlock()
+
+		return slices.Clone(*items)
+	}
+
+	before := read()
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(read(), "", "  ")
+	if err == nil {
+		err = writeFileAtomic(path, data)
+	}
+
+	if err != nil {
+		mu.Lock()
+		*items = before
+		mu.Unlock()
+
+		return fmt.Errorf("write %s: %w", what, err)
+	}
+
+	return nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename %s: %w", filepath.Base(path), err)
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("open dir: %w", err)
+	}
+
+	defer func() { _ = d.Close() }()
+
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("sync dir: %w", err)
+	}
+
+	return nil
+}
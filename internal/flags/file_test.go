@@ -0,0 +1,368 @@
+package flags_test
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openFileRepository(t *testing.T, dir string) *flags.FileRepository {
+	t.Helper()
+
+	repo, err := flags.OpenFileRepository(dir)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = repo.Close() })
+
+	return repo
+}
+
+func TestFileRepository_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunRepositorySuite(t, func(t *testing.T) flags.Repository {
+		t.Helper()
+
+		return openFileRepository(t, t.TempDir())
+	})
+}
+
+func TestFileRepository_CRUD(t *testing.T) {
+	t.Parallel()
+
+	repo := openFileRepository(t, t.TempDir())
+	ctx := context.Background()
+
+	flag := flags.Flag{Key: "test-flag", Type: flags.FlagBool, DefaultValue: flags.BoolValue(true), Version: 1}
+	require.NoError(t, repo.Create(ctx, flag))
+	require.ErrorIs(t, repo.Create(ctx, flag), flags.ErrFlagExists)
+
+	flag.Enabled = true
+	flag.Version = 2
+	require.NoError(t, repo.Update(ctx, flag))
+	require.ErrorIs(t, repo.Update(ctx, flag), flags.ErrVersionConflict)
+	require.ErrorIs(t, repo.Update(ctx, flags.Flag{Key: "nonexistent", Version: 1}), flags.ErrFlagNotFound)
+
+	got, err := repo.Get(ctx, "test-flag")
+	require.NoError(t, err)
+	assert.True(t, got.Enabled)
+	assert.Equal(t, 2, got.Version)
+
+	_, err = repo.Get(ctx, "nonexistent")
+	assert.ErrorIs(t, err, flags.ErrFlagNotFound)
+}
+
+func TestFileRepository_RecoversFromWAL(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := flags.OpenFileRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-a", Type: flags.FlagBool, Version: 1}))
+	require.NoError(t, repo.Update(ctx, flags.Flag{Key: "flag-a", Type: flags.FlagBool, Enabled: true, Version: 2}))
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-b", Type: flags.FlagString, Version: 1}))
+	require.NoError(t, repo.Close())
+
+	reopened := openFileRepository(t, dir)
+
+	assert.Equal(t, flags.RecoveryReport{Replayed: 3}, reopened.Recovery())
+
+	got, err := reopened.Get(ctx, "flag-a")
+	require.NoError(t, err)
+	assert.True(t, got.Enabled)
+	assert.Equal(t, 2, got.Version)
+
+	_, err = reopened.Get(ctx, "flag-b")
+	assert.NoError(t, err)
+}
+
+func TestFileRepository_SnapshotCompactsWAL(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := flags.OpenFileRepository(dir, flags.WithSnapshotEvery(2))
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-a", Version: 1}))
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-b", Version: 1}))
+
+	info, err := os.Stat(filepath.Join(dir, "flags.wal"))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-c", Version: 1}))
+	require.NoError(t, repo.Close())
+
+	reopened := openFileRepository(t, dir)
+
+	assert.Equal(t, flags.RecoveryReport{SnapshotSeq: 2, Replayed: 1}, reopened.Recovery())
+
+	for _, key := range []flags.FlagKey{"flag-a", "flag-b", "flag-c"} {
+		_, err := reopened.Get(ctx, key)
+		require.NoError(t, err, key)
+	}
+}
+
+func TestFileRepository_ExplicitSnapshot(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := flags.OpenFileRepository(dir, flags.WithSnapshotEvery(0))
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-a", Version: 1}))
+	require.NoError(t, repo.Snapshot())
+	require.NoError(t, repo.Close())
+
+	reopened := openFileRepository(t, dir)
+
+	assert.Equal(t, flags.RecoveryReport{SnapshotSeq: 1}, reopened.Recovery())
+
+	_, err = reopened.Get(ctx, "flag-a")
+	assert.NoError(t, err)
+}
+
+func TestFileRepository_DiscardsTornTail(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := flags.OpenFileRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-a", Version: 1}))
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-b", Version: 1}))
+	require.NoError(t, repo.Close())
+
+	walPath := filepath.Join(dir, "flags.wal")
+	info, err := os.Stat(walPath)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(walPath, info.Size()-3))
+
+	reopened := openFileRepository(t, dir)
+
+	report := reopened.Recovery()
+	assert.Equal(t, 1, report.Replayed)
+	assert.Positive(t, report.DiscardedBytes)
+
+	_, err = reopened.Get(ctx, "flag-b")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	// The torn record is gone, so new writes are readable after another restart.
+	require.NoError(t, reopened.Create(ctx, flags.Flag{Key: "flag-c", Version: 1}))
+	require.NoError(t, reopened.Close())
+
+	again := openFileRepository(t, dir)
+
+	assert.Equal(t, flags.RecoveryReport{Replayed: 2}, again.Recovery())
+}
+
+func TestFileRepository_RejectsCorruptTail(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := flags.OpenFileRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-a", Version: 1}))
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-b", Version: 1}))
+	require.NoError(t, repo.Close())
+
+	walPath := filepath.Join(dir, "flags.wal")
+	data, err := os.ReadFile(walPath)
+	require.NoError(t, err)
+
+	// A whole final record that fails its checksum was not torn by a crash.
+	data[len(data)-2] ^= 0xff
+	require.NoError(t, os.WriteFile(walPath, data, 0o600)) //nolint:gosec // test-owned temp dir
+
+	_, err = flags.OpenFileRepository(dir)
+	require.ErrorIs(t, err, flags.ErrCorruptWAL)
+}
+
+func TestFileRepository_RejectsCorruptRecordMidLog(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := flags.OpenFileRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-a", Version: 1}))
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-b", Version: 1}))
+	require.NoError(t, repo.Close())
+
+	walPath := filepath.Join(dir, "flags.wal")
+	data, err := os.ReadFile(walPath)
+	require.NoError(t, err)
+
+	// Flip a byte inside the first record's payload.
+	data[10] ^= 0xff
+	require.NoError(t, os.WriteFile(walPath, data, 0o600)) //nolint:gosec // test-owned temp dir
+
+	_, err = flags.OpenFileRepository(dir)
+	require.ErrorIs(t, err, flags.ErrCorruptWAL)
+
+	after, err := os.ReadFile(walPath)
+	require.NoError(t, err)
+	assert.Equal(t, data, after, "a corrupt log is left for inspection")
+}
+
+func TestFileRepository_RejectsCorruptLengthMidLog(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := flags.OpenFileRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-a", Version: 1}))
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-b", Version: 1}))
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-c", Version: 1}))
+	require.NoError(t, repo.Close())
+
+	walPath := filepath.Join(dir, "flags.wal")
+	data, err := os.ReadFile(walPath)
+	require.NoError(t, err)
+
+	// Grow the second record's length so that it points past the end of the log.
+	first := binary.BigEndian.Uint32(data[0:4])
+	data[8+first+1] ^= 0x10
+	require.NoError(t, os.WriteFile(walPath, data, 0o600)) //nolint:gosec // test-owned temp dir
+
+	_, err = flags.OpenFileRepository(dir)
+	require.ErrorIs(t, err, flags.ErrCorruptWAL)
+
+	after, err := os.ReadFile(walPath)
+	require.NoError(t, err)
+	assert.Equal(t, data, after, "a corrupt log is left for inspection")
+}
+
+func TestFileRepository_SnapshotFailureKeepsWrite(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	var reported []error
+
+	repo, err := flags.OpenFileRepository(dir, flags.WithSnapshotEvery(1),
+		flags.WithSnapshotErrorHandler(func(err error) { reported = append(reported, err) }))
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = repo.Close() })
+
+	// A directory where the snapshot belongs makes writing it fail.
+	snapshotPath := filepath.Join(dir, "flags.snapshot")
+	require.NoError(t, os.Mkdir(snapshotPath, 0o750))
+
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-a", Version: 1}))
+	require.Len(t, reported, 1)
+
+	_, err = repo.Get(ctx, "flag-a")
+	require.NoError(t, err)
+
+	// The next write retries the snapshot.
+	require.NoError(t, os.Remove(snapshotPath))
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-b", Version: 1}))
+	assert.Len(t, reported, 1)
+	require.NoError(t, repo.Close())
+
+	reopened := openFileRepository(t, dir)
+
+	assert.Equal(t, flags.RecoveryReport{SnapshotSeq: 2}, reopened.Recovery())
+}
+
+func TestFileRepository_CorruptSnapshot(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "flags.snapshot"), []byte("{not json"), 0o600))
+
+	_, err := flags.OpenFileRepository(dir)
+	assert.ErrorIs(t, err, flags.ErrCorruptSnapshot)
+}
+
+func TestFileRepository_OpenError(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "not-a-dir")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	_, err := flags.OpenFileRepository(path)
+	assert.Error(t, err)
+}
+
+func TestFileRepository_GetReturnsCopy(t *testing.T) {
+	t.Parallel()
+
+	repo := openFileRepository(t, t.TempDir())
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-a", Version: 1, Rules: []flags.Rule{{ID: "rule-1"}}}))
+
+	got, err := repo.Get(ctx, "flag-a")
+	require.NoError(t, err)
+
+	got.Rules[0].ID = "mutated"
+
+	again, err := repo.Get(ctx, "flag-a")
+	require.NoError(t, err)
+	assert.Equal(t, "rule-1", again.Rules[0].ID)
+}
+
+func TestFileRepository_DiscardsTornHeader(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "flags.wal"), []byte{0, 0, 0}, 0o600))
+
+	repo := openFileRepository(t, dir)
+
+	assert.Equal(t, flags.RecoveryReport{DiscardedBytes: 3}, repo.Recovery())
+}
+
+func TestFileRepository_RejectsOversizedRecord(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "flags.wal"), []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 1}, 0o600))
+
+	_, err := flags.OpenFileRepository(dir)
+	assert.ErrorIs(t, err, flags.ErrCorruptWAL)
+}
+
+func TestFileRepository_RecoversDeletes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := flags.OpenFileRepository(dir)
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-a", Version: 1}))
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "flag-b", Version: 1}))
+	require.NoError(t, repo.Delete(ctx, "flag-a"))
+	require.NoError(t, repo.Close())
+
+	reopened := openFileRepository(t, dir)
+
+	assert.Equal(t, flags.RecoveryReport{Replayed: 3}, reopened.Recovery())
+
+	list, err := reopened.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, flags.FlagKey("flag-b"), list[0].Key)
+}
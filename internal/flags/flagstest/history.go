@@ -0,0 +1,173 @@
+package flagstest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// HistoryStoreFactory returns a new, empty history store. It is called once
+// per subtest and should register any cleanup with t.Cleanup.
+type HistoryStoreFactory func(t *testing.T) flags.HistoryStore
+
+// RunHistoryStoreSuite checks that a flags.HistoryStore implementation
+// behaves exactly like flags.MemoryHistoryStore.
+func RunHistoryStoreSuite(t *testing.T, newStore HistoryStoreFactory) {
+	t.Helper()
+
+	tests := map[string]func(t *testing.T, store flags.HistoryStore){
+		"AppendListAndGet":     testHistoryAppendListAndGet,
+		"NotFound":             testHistoryNotFound,
+		"DoesNotAlias":         testHistoryDoesNotAlias,
+		"DeleteOnlyForgetsKey": testHistoryDeleteOnlyForgetsKey,
+		"DeleteThenRecreate":   testHistoryDeleteThenRecreate,
+		"KeepsKeysApart":       testHistoryKeepsKeysApart,
+		"RoundTrip":            testHistoryRoundTrip,
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			test(t, newStore(t))
+		})
+	}
+}
+
+// Revision returns SampleFlag(key) at version.
+func Revision(key flags.FlagKey, version int) flags.Flag {
+	flag := SampleFlag(key)
+	flag.Version = version
+	flag.Enabled = version%2 == 1
+
+	return flag
+}
+
+func testHistoryAppendListAndGet(t *testing.T, store flags.HistoryStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, store.Append(ctx, Revision("sample", 1)))
+	require.NoError(t, store.Append(ctx, Revision("sample", 2)))
+
+	revisions, err := store.List(ctx, "sample")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 1, revisions[0].Version)
+	assert.Equal(t, 2, revisions[1].Version)
+
+	got, err := store.Get(ctx, "sample", 2)
+	require.NoError(t, err)
+	AssertFlagEqual(t, Revision("sample", 2), got)
+}
+
+func testHistoryNotFound(t *testing.T, store flags.HistoryStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	_, err := store.List(ctx, "missing")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	_, err = store.Get(ctx, "missing", 1)
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	require.NoError(t, store.Append(ctx, Revision("sample", 1)))
+
+	_, err = store.Get(ctx, "sample", 2)
+	assert.ErrorIs(t, err, flags.ErrRevisionNotFound)
+}
+
+func testHistoryDoesNotAlias(t *testing.T, store flags.HistoryStore) {
+	t.Helper()
+
+	ctx := context.Background()
+	revision := Revision("sample", 1)
+
+	require.NoError(t, store.Append(ctx, revision))
+
+	mutate(&revision)
+
+	listed, err := store.List(ctx, "sample")
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+
+	mutate(&listed[0])
+
+	got, err := store.Get(ctx, "sample", 1)
+	require.NoError(t, err)
+	AssertFlagEqual(t, Revision("sample", 1), got)
+}
+
+func testHistoryDeleteOnlyForgetsKey(t *testing.T, store flags.HistoryStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, store.Append(ctx, Revision("flag-a", 1)))
+	require.NoError(t, store.Append(ctx, Revision("flag-b", 1)))
+	require.NoError(t, store.Delete(ctx, "flag-a"))
+	require.NoError(t, store.Delete(ctx, "missing"))
+
+	_, err := store.List(ctx, "flag-a")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	revisions, err := store.List(ctx, "flag-b")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 1)
+}
+
+func testHistoryDeleteThenRecreate(t *testing.T, store flags.HistoryStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, store.Append(ctx, Revision("sample", 1)))
+	require.NoError(t, store.Append(ctx, Revision("sample", 2)))
+	require.NoError(t, store.Delete(ctx, "sample"))
+	require.NoError(t, store.Append(ctx, Revision("sample", 1)))
+
+	revisions, err := store.List(ctx, "sample")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	assert.Equal(t, 1, revisions[0].Version)
+}
+
+func testHistoryKeepsKeysApart(t *testing.T, store flags.HistoryStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	for version := 1; version <= 3; version++ {
+		require.NoError(t, store.Append(ctx, Revision("flag-a", version)))
+		require.NoError(t, store.Append(ctx, Revision("flag-b", version)))
+	}
+
+	for _, key := range []flags.FlagKey{"flag-a", "flag-b"} {
+		revisions, err := store.List(ctx, key)
+		require.NoError(t, err)
+		require.Len(t, revisions, 3)
+
+		for i, revision := range revisions {
+			assert.Equal(t, key, revision.Key)
+			assert.Equal(t, i+1, revision.Version)
+		}
+	}
+}
+
+func testHistoryRoundTrip(t *testing.T, store flags.HistoryStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, store.Append(ctx, Revision("sample", 1)))
+
+	revisions, err := store.List(ctx, "sample")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	AssertFlagEqual(t, Revision("sample", 1), revisions[0])
+}
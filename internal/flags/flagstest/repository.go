@@ -0,0 +1,419 @@
+// Package flagstest provides helpers for testing code built on package flags.
+package flagstest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RepositoryFactory returns a new, empty repository. It is called once per
+// subtest and should register any cleanup with t.Cleanup.
+type RepositoryFactory func(t *testing.T) flags.Repository
+
+// RunRepositorySuite checks that a flags.Repository implementation behaves
+// exactly like flags.MemoryRepository.
+func RunRepositorySuite(t *testing.T, newRepo RepositoryFactory) {
+	t.Helper()
+
+	tests := map[string]func(t *testing.T, repo flags.Repository){
+		"CreateAndGet":             testCreateAndGet,
+		"CreateDuplicate":          testCreateDuplicate,
+		"GetNotFound":              testGetNotFound,
+		"Update":                   testUpdate,
+		"UpdateVersionConflict":    testUpdateVersionConflict,
+		"UpdateNotFound":           testUpdateNotFound,
+		"Delete":                   testDelete,
+		"DeleteNotFound":           testDeleteNotFound,
+		"DeleteThenRecreate":       testDeleteThenRecreate,
+		"ListEmpty":                testListEmpty,
+		"ListOrderedByKey":         testListOrderedByKey,
+		"CreateDoesNotAliasInput":  testCreateDoesNotAliasInput,
+		"GetDoesNotAliasStorage":   testGetDoesNotAliasStorage,
+		"ListDoesNotAliasStorage":  testListDoesNotAliasStorage,
+		"ConcurrentCreates":        testConcurrentCreates,
+		"ConcurrentUpdatesOneWins": testConcurrentUpdatesOneWins,
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			test(t, newRepo(t))
+		})
+	}
+}
+
+// SampleFlag returns a flag exercising every field and condition value type
+// a repository has to round-trip.
+func SampleFlag(key flags.FlagKey) flags.Flag {
+	const beta = "beta"
+
+	return flags.Flag{
+		Key:          key,
+		Description:  "Checkout experience under test",
+		Owner:        "payments",
+		Tags:         []string{"checkout", "q3"},
+		Kind:         flags.KindExperiment,
+		ExpiresAt:    time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC),
+		Type:         flags.FlagString,
+		Enabled:      true,
+		DefaultValue: flags.StringValue("control"),
+		Rules: []flags.Rule{
+			{
+				ID: beta,
+				Conditions: []flags.Condition{
+					{Attr: "plan", Op: flags.OpIn, Value: []any{"pro", "enterprise"}},
+					{Attr: "seats", Op: flags.OpEquals, Value: float64(10)},
+					{Attr: beta, Op: flags.OpEquals, Value: true},
+				},
+				Value: flags.StringValue("treatment"),
+			},
+			{
+				ID: "internal",
+				Conditions: []flags.Condition{
+					{Attr: "email", Op: flags.OpExists},
+					{Attr: "now", Op: flags.OpWindow, Value: map[string]any{"days": []any{"mon"}, "from": "09:00"}},
+				},
+				Value:   flags.StringValue("internal"),
+				Rollout: &flags.Rollout{Percentage: 12.5, BucketBy: "tenant_id"},
+			},
+		},
+		Tests: []flags.FlagTest{
+			{
+				Name:    "pro beta users get the treatment",
+				Context: flags.EvalContext{UserID: "u1", Attrs: map[string]any{"plan": "pro", "seats": float64(10), beta: true}},
+				Expect:  flags.StringValue("treatment"),
+				RuleID:  beta,
+			},
+			{
+				Name:    "everyone else gets the control",
+				Context: flags.EvalContext{TenantID: "acme", Now: time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)},
+				Expect:  flags.StringValue("control"),
+			},
+		},
+		Protected: true,
+		Version:   1,
+		UpdatedAt: time.Date(2025, 6, 1, 12, 30, 0, 123456789, time.UTC),
+		ManagedBy: flags.ManagedByFile,
+	}
+}
+
+// AssertFlagEqual compares two flags, treating UpdatedAt values that denote
+// the same instant as equal regardless of location or monotonic reading.
+func AssertFlagEqual(t *testing.T, want, got flags.Flag) {
+	t.Helper()
+
+	assert.True(t, want.UpdatedAt.Equal(got.UpdatedAt), "UpdatedAt: want %s, got %s", want.UpdatedAt, got.UpdatedAt)
+
+	want.UpdatedAt = time.Time{}
+	got.UpdatedAt = time.Time{}
+
+	assert.Equal(t, want, got)
+}
+
+func testCreateAndGet(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	ctx := context.Background()
+	flag := SampleFlag("sample")
+
+	require.NoError(t, repo.Create(ctx, flag))
+
+	got, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	AssertFlagEqual(t, flag, got)
+}
+
+func testCreateDuplicate(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, SampleFlag("sample")))
+
+	err := repo.Create(ctx, SampleFlag("sample"))
+	assert.ErrorIs(t, err, flags.ErrFlagExists)
+}
+
+func testGetNotFound(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	_, err := repo.Get(context.Background(), "nonexistent")
+	assert.ErrorIs(t, err, flags.ErrFlagNotFound)
+}
+
+func testUpdate(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, SampleFlag("sample")))
+
+	next := flags.Flag{
+		Key:          "sample",
+		Type:         flags.FlagString,
+		DefaultValue: flags.StringValue("off"),
+		Rules:        []flags.Rule{{ID: "only", Value: flags.StringValue("on")}},
+		Version:      2,
+		UpdatedAt:    time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, repo.Update(ctx, next))
+
+	got, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	AssertFlagEqual(t, next, got)
+}
+
+func testUpdateVersionConflict(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, SampleFlag("sample")))
+
+	stale := SampleFlag("sample")
+	stale.Version = 3
+
+	require.ErrorIs(t, repo.Update(ctx, stale), flags.ErrVersionConflict)
+
+	got, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	AssertFlagEqual(t, SampleFlag("sample"), got)
+}
+
+func testUpdateNotFound(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	flag := SampleFlag("nonexistent")
+	flag.Version = 2
+
+	err := repo.Update(context.Background(), flag)
+	assert.ErrorIs(t, err, flags.ErrFlagNotFound)
+}
+
+func testDelete(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, SampleFlag("sample")))
+	require.NoError(t, repo.Delete(ctx, "sample"))
+
+	_, err := repo.Get(ctx, "sample")
+	assert.ErrorIs(t, err, flags.ErrFlagNotFound)
+}
+
+func testDeleteNotFound(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	err := repo.Delete(context.Background(), "nonexistent")
+	assert.ErrorIs(t, err, flags.ErrFlagNotFound)
+}
+
+func testDeleteThenRecreate(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, SampleFlag("sample")))
+	require.NoError(t, repo.Delete(ctx, "sample"))
+
+	recreated := flags.Flag{
+		Key:          "sample",
+		Type:         flags.FlagBool,
+		DefaultValue: flags.BoolValue(false),
+		Version:      1,
+		UpdatedAt:    time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, repo.Create(ctx, recreated))
+
+	got, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	AssertFlagEqual(t, recreated, got)
+}
+
+func testListEmpty(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	list, err := repo.List(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func testListOrderedByKey(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	for _, key := range []flags.FlagKey{"charlie", "alpha", "bravo-2", "bravo"} {
+		require.NoError(t, repo.Create(ctx, SampleFlag(key)))
+	}
+
+	require.NoError(t, repo.Delete(ctx, "bravo-2"))
+
+	list, err := repo.List(ctx)
+	require.NoError(t, err)
+
+	keys := make([]flags.FlagKey, len(list))
+	for i, flag := range list {
+		keys[i] = flag.Key
+	}
+
+	assert.Equal(t, []flags.FlagKey{"alpha", "bravo", "charlie"}, keys)
+	AssertFlagEqual(t, SampleFlag("alpha"), list[0])
+}
+
+func testCreateDoesNotAliasInput(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	ctx := context.Background()
+	flag := SampleFlag("sample")
+
+	require.NoError(t, repo.Create(ctx, flag))
+
+	mutate(&flag)
+
+	got, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	AssertFlagEqual(t, SampleFlag("sample"), got)
+}
+
+func testGetDoesNotAliasStorage(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, SampleFlag("sample")))
+
+	got, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+
+	mutate(&got)
+
+	again, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	AssertFlagEqual(t, SampleFlag("sample"), again)
+}
+
+func testListDoesNotAliasStorage(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, SampleFlag("sample")))
+
+	list, err := repo.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	mutate(&list[0])
+
+	got, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	AssertFlagEqual(t, SampleFlag("sample"), got)
+}
+
+// mutate changes every nested, reference-typed part of a SampleFlag in place,
+// and its expiry, so stores that alias the caller's flag are caught.
+func mutate(flag *flags.Flag) {
+	const mutated = "mutated"
+
+	*flag.DefaultValue.String = mutated
+	flag.Tags[0] = mutated
+	flag.ExpiresAt = flag.ExpiresAt.Add(time.Hour)
+
+	flag.Rules[0].ID = mutated
+	*flag.Rules[0].Value.String = mutated
+	flag.Rules[0].Conditions[0].Attr = mutated
+	flag.Rules[0].Conditions[0].Value.([]any)[0] = mutated
+
+	window := flag.Rules[1].Conditions[1].Value.(map[string]any)
+	window["from"] = "23:00"
+	window["days"].([]any)[0] = "sun"
+	flag.Rules[1].Rollout.Percentage = 99
+	flag.Rules[1].Rollout.BucketBy = "user_id"
+
+	flag.Tests[0].Name = mutated
+	flag.Tests[0].Context.Attrs["plan"] = mutated
+	*flag.Tests[0].Expect.String = mutated
+}
+
+func testConcurrentCreates(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	const writers = 16
+
+	ctx := context.Background()
+	errs := make([]error, writers)
+
+	var wg sync.WaitGroup
+
+	for i := range writers {
+		wg.Go(func() {
+			errs[i] = repo.Create(ctx, SampleFlag(flags.FlagKey(fmt.Sprintf("flag-%02d", i))))
+		})
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	list, err := repo.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, writers)
+}
+
+func testConcurrentUpdatesOneWins(t *testing.T, repo flags.Repository) {
+	t.Helper()
+
+	const writers = 16
+
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, SampleFlag("sample")))
+
+	errs := make([]error, writers)
+
+	var wg sync.WaitGroup
+
+	for i := range writers {
+		wg.Go(func() {
+			next := SampleFlag("sample")
+			next.Version = 2
+			next.DefaultValue = flags.StringValue(fmt.Sprintf("writer-%d", i))
+			errs[i] = repo.Update(ctx, next)
+		})
+	}
+
+	wg.Wait()
+
+	winner := -1
+
+	for i, err := range errs {
+		if err == nil {
+			assert.Equal(t, -1, winner, "more than one concurrent update succeeded")
+
+			winner = i
+
+			continue
+		}
+
+		require.ErrorIs(t, err, flags.ErrVersionConflict)
+	}
+
+	require.NotEqual(t, -1, winner, "no concurrent update succeeded")
+
+	got, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Version)
+	assert.Equal(t, fmt.Sprintf("writer-%d", winner), *got.DefaultValue.String)
+}
@@ -0,0 +1,224 @@
+package flagstest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// UsageStoreFactory returns a new, empty usage store. It is called once per
+// subtest and should register any cleanup with t.Cleanup.
+type UsageStoreFactory func(t *testing.T) flags.UsageStore
+
+// RunUsageStoreSuite checks that a flags.UsageStore implementation behaves
+// like flags.MemoryUsageStore. The order of the variations of a bucket is not
+// checked.
+func RunUsageStoreSuite(t *testing.T, newStore UsageStoreFactory) {
+	t.Helper()
+
+	tests := map[string]func(t *testing.T, store flags.UsageStore){
+		"AddMergesBuckets":     testUsageAddMergesBuckets,
+		"ListsInOrder":         testUsageListsInOrder,
+		"Filters":              testUsageFilters,
+		"DoesNotAlias":         testUsageDoesNotAlias,
+		"KeepsLastEvaluated":   testUsageKeepsLastEvaluated,
+		"DeleteOnlyForgetsKey": testUsageDeleteOnlyForgetsKey,
+		"Prune":                testUsagePrune,
+		"Empty":                testUsageEmpty,
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			test(t, newStore(t))
+		})
+	}
+}
+
+// usageHour is the hour of the buckets the suite adds.
+var usageHour = time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+
+// Bucket returns a bucket of n evaluations of key in hour that all served
+// value by default, the last a minute into the hour.
+func Bucket(key flags.FlagKey, hour time.Time, value flags.Value, n int64) flags.UsageBucket {
+	return flags.UsageBucket{
+		FlagKey: key, Hour: hour, Evaluations: n, LastEvaluated: hour.Add(time.Minute),
+		Reasons:    map[flags.EvalReason]int64{flags.ReasonDefault: n},
+		Variations: []flags.VariationCount{{Value: value, Count: n}},
+	}
+}
+
+func testUsageAddMergesBuckets(t *testing.T, store flags.UsageStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{Bucket("a", usageHour, flags.StringValue("x"), 3)}))
+
+	split := Bucket("a", usageHour, flags.StringValue("y"), 4)
+	split.Reasons = map[flags.EvalReason]int64{flags.ReasonDefault: 1, flags.ReasonRuleMatch: 3}
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{split}))
+
+	all, err := store.List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, flags.FlagKey("a"), all[0].FlagKey)
+	assert.True(t, usageHour.Equal(all[0].Hour))
+	assert.Equal(t, int64(7), all[0].Evaluations)
+	assert.Equal(t, map[flags.EvalReason]int64{flags.ReasonDefault: 4, flags.ReasonRuleMatch: 3}, all[0].Reasons)
+	assert.ElementsMatch(t, []flags.VariationCount{
+		{Value: flags.StringValue("x"), Count: 3}, {Value: flags.StringValue("y"), Count: 4},
+	}, all[0].Variations)
+}
+
+func testUsageListsInOrder(t *testing.T, store flags.UsageStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{
+		Bucket("b", usageHour, flags.StringValue("x"), 1),
+		Bucket("a", usageHour.Add(time.Hour), flags.StringValue("x"), 2),
+		Bucket("a", usageHour, flags.StringValue("x"), 3),
+	}))
+
+	all, err := store.List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, flags.FlagKey("a"), all[0].FlagKey)
+	assert.Equal(t, int64(3), all[0].Evaluations)
+	assert.Equal(t, flags.FlagKey("a"), all[1].FlagKey)
+	assert.Equal(t, int64(2), all[1].Evaluations)
+	assert.Equal(t, flags.FlagKey("b"), all[2].FlagKey)
+}
+
+func testUsageFilters(t *testing.T, store flags.UsageStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{
+		Bucket("a", usageHour, flags.BoolValue(true), 1),
+		Bucket("a", usageHour.Add(time.Hour), flags.BoolValue(true), 2),
+		Bucket("b", usageHour.Add(time.Hour), flags.BoolValue(true), 3),
+	}))
+
+	since, err := store.List(ctx, flags.UsageFilter{FlagKey: "a", Since: usageHour.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, since, 1)
+	assert.Equal(t, int64(2), since[0].Evaluations)
+
+	byKey, err := store.List(ctx, flags.UsageFilter{FlagKey: "b"})
+	require.NoError(t, err)
+	require.Len(t, byKey, 1)
+	assert.Equal(t, int64(3), byKey[0].Evaluations)
+
+	missing, err := store.List(ctx, flags.UsageFilter{FlagKey: "missing"})
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+}
+
+func testUsageDoesNotAlias(t *testing.T, store flags.UsageStore) {
+	t.Helper()
+
+	ctx := context.Background()
+	bucket := Bucket("a", usageHour, flags.StringValue("x"), 3)
+
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{bucket}))
+
+	bucket.Reasons[flags.ReasonDefault] = 100
+	bucket.Variations[0].Count = 100
+
+	listed, err := store.List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+
+	listed[0].Reasons[flags.ReasonDefault] = 100
+	listed[0].Variations[0].Count = 100
+
+	again, err := store.List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	require.Len(t, again, 1)
+	assert.Equal(t, int64(3), again[0].Reasons[flags.ReasonDefault], "List returns copies")
+	assert.Equal(t, int64(3), again[0].Variations[0].Count)
+}
+
+func testUsageKeepsLastEvaluated(t *testing.T, store flags.UsageStore) {
+	t.Helper()
+
+	ctx := context.Background()
+	later := Bucket("a", usageHour, flags.BoolValue(true), 1)
+	later.LastEvaluated = usageHour.Add(30*time.Minute + time.Nanosecond)
+
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{later}))
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{Bucket("a", usageHour, flags.BoolValue(true), 1)}))
+
+	all, err := store.List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.True(t, later.LastEvaluated.Equal(all[0].LastEvaluated), "an earlier flush does not move it back")
+}
+
+func testUsageDeleteOnlyForgetsKey(t *testing.T, store flags.UsageStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{
+		Bucket("a", usageHour, flags.BoolValue(true), 1),
+		Bucket("a", usageHour.Add(time.Hour), flags.BoolValue(true), 1),
+		Bucket("b", usageHour, flags.BoolValue(true), 2),
+	}))
+	require.NoError(t, store.Delete(ctx, "a"))
+	require.NoError(t, store.Delete(ctx, "missing"))
+
+	all, err := store.List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, flags.FlagKey("b"), all[0].FlagKey)
+
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{Bucket("a", usageHour, flags.BoolValue(true), 5)}))
+
+	recreated, err := store.List(ctx, flags.UsageFilter{FlagKey: "a"})
+	require.NoError(t, err)
+	require.Len(t, recreated, 1)
+	assert.Equal(t, int64(5), recreated[0].Evaluations, "deleted usage is not merged back")
+}
+
+func testUsagePrune(t *testing.T, store flags.UsageStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{
+		Bucket("a", usageHour, flags.BoolValue(true), 1),
+		Bucket("a", usageHour.Add(time.Hour), flags.BoolValue(true), 2),
+		Bucket("b", usageHour, flags.BoolValue(true), 3),
+	}))
+	require.NoError(t, store.Prune(ctx, usageHour.Add(time.Hour)))
+
+	all, err := store.List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, flags.FlagKey("a"), all[0].FlagKey)
+	assert.Equal(t, int64(2), all[0].Evaluations)
+}
+
+func testUsageEmpty(t *testing.T, store flags.UsageStore) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	all, err := store.List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, all)
+
+	require.NoError(t, store.Add(ctx, nil))
+	require.NoError(t, store.Prune(ctx, usageHour))
+	require.NoError(t, store.Delete(ctx, "a"))
+}
@@ -0,0 +1,122 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrTestsFailed = errors.New("flag tests failed")
+
+// FlagTest pins what a flag returns for one context, so that a change that
+// alters it, such as reordering rules, is rejected. Context.Now fixes the
+// time of the evaluation; zero means the time the test runs.
+type FlagTest struct {
+	Name    string      `json:"name"`
+	Context EvalContext `json:"context"`
+	Expect  Value       `json:"expect"`
+	RuleID  string      `json:"ruleId,omitempty"` // the rule that must serve Expect; empty accepts any outcome
+}
+
+func (t FlagTest) clone() FlagTest {
+	t.Expect = t.Expect.clone()
+
+	if t.Context.Attrs != nil {
+		attrs, _ := cloneConditionValue(t.Context.Attrs).(map[string]any)
+		t.Context.Attrs = attrs
+	}
+
+	return t
+}
+
+// TestResult is the outcome of running one FlagTest.
+type TestResult struct {
+	Test   FlagTest
+	Result EvalResult
+	Passed bool
+}
+
+// TestsFailedError is returned by changes that would make a flag fail its
+// own tests. The change was not applied.
+type TestsFailedError struct {
+	Key      FlagKey
+	Failures []TestResult
+}
+
+func (e *TestsFailedError) Error() string {
+	return fmt.Sprintf("%s: %d for flag %s", ErrTestsFailed, len(e.Failures), e.Key)
+}
+
+func (e *TestsFailedError) Unwrap() error {
+	return ErrTestsFailed
+}
+
+// RunTests evaluates f for each of its tests. Tests without a time run at
+// now.
+func (f Flag) RunTests(now time.Time) []TestResult {
+	results := make([]TestResult, len(f.Tests))
+
+	for i, test := range f.Tests {
+		evalCtx := test.Context
+		if evalCtx.Now.IsZero() {
+			evalCtx.Now = now
+		}
+
+		result := f.Evaluate(evalCtx)
+		results[i] = TestResult{
+			Test:   test,
+			Result: result,
+			Passed: reflect.DeepEqual(result.Value, test.Expect) && (test.RuleID == "" || test.RuleID == result.RuleID),
+		}
+	}
+
+	return results
+}
+
+// RunTests runs the stored flag's tests.
+func (s *Service) RunTests(ctx context.Context, key FlagKey) ([]TestResult, error) {
+	ctx, span := s.tracer.Start(ctx, "flags.RunTests", trace.WithAttributes(AttrFlagKey.String(string(key))))
+	defer span.End()
+
+	if err := s.authorizer.Authorize(ctx, PermissionRead, key); err != nil {
+		return nil, SpanError(span, err)
+	}
+
+	flag, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return nil, SpanError(span, err)
+	}
+
+	return flag.RunTests(s.clock.Now()), nil
+}
+
+// check returns ErrInvalidFlag when flag is invalid and a *TestsFailedError
+// when it fails any of its tests.
+func (s *Service) check(flag Flag) error {
+	if err := flag.validate(); err != nil {
+		return err
+	}
+
+	return s.checkTests(flag)
+}
+
+// checkTests returns a *TestsFailedError when flag fails any of its tests.
+func (s *Service) checkTests(flag Flag) error {
+	var failures []TestResult
+
+	for _, result := range flag.RunTests(s.clock.Now()) {
+		if !result.Passed {
+			failures = append(failures, result)
+		}
+	}
+
+	if len(failures) > 0 {
+		return &TestsFailedError{Key: flag.Key, Failures: failures}
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package flags_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testedFlag serves "pro" to the pro plan and "beta" to beta users, with
+// tests that pin a pro beta user to the pro rule.
+func testedFlag() flags.Flag {
+	return flags.Flag{
+		Key:          "tier",
+		Type:         flags.FlagString,
+		Enabled:      true,
+		DefaultValue: flags.StringValue("free"),
+		Rules: []flags.Rule{
+			{
+				ID:         "pro",
+				Conditions: []flags.Condition{{Attr: "plan", Op: flags.OpEquals, Value: "pro"}},
+				Value:      flags.StringValue("pro"),
+			},
+			{
+				ID:         "beta",
+				Conditions: []flags.Condition{{Attr: "beta", Op: flags.OpEquals, Value: true}},
+				Value:      flags.StringValue("beta"),
+			},
+		},
+		Tests: []flags.FlagTest{
+			{
+				Name:    "pro beta users keep pro",
+				Context: flags.EvalContext{Attrs: map[string]any{"plan": "pro", "beta": true}},
+				Expect:  flags.StringValue("pro"),
+				RuleID:  "pro",
+			},
+			{
+				Name:   "anonymous users get free",
+				Expect: flags.StringValue("free"),
+			},
+		},
+	}
+}
+
+func TestFlag_RunTests(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+
+	results := testedFlag().RunTests(now)
+	require.Len(t, results, 2)
+
+	for _, result := range results {
+		assert.True(t, result.Passed, result.Test.Name)
+		assert.Equal(t, now, result.Result.EvaluatedAt)
+	}
+
+	reordered := testedFlag()
+	reordered.Rules[0], reordered.Rules[1] = reordered.Rules[1], reordered.Rules[0]
+
+	results = reordered.RunTests(now)
+	assert.False(t, results[0].Passed)
+	assert.Equal(t, "beta", results[0].Result.RuleID)
+	assert.True(t, results[1].Passed)
+
+	sameValue := testedFlag()
+	sameValue.Rules[1].Value = flags.StringValue("pro")
+	sameValue.Rules[0], sameValue.Rules[1] = sameValue.Rules[1], sameValue.Rules[0]
+
+	assert.False(t, sameValue.RunTests(now)[0].Passed, "the value must come from the expected rule")
+
+	pinned := testedFlag()
+	pinned.Tests = []flags.FlagTest{{
+		Name:    "after launch",
+		Context: flags.EvalContext{Now: now.Add(time.Hour)},
+		Expect:  flags.StringValue("free"),
+	}}
+
+	assert.Equal(t, now.Add(time.Hour), pinned.RunTests(now)[0].Result.EvaluatedAt)
+}
+
+func TestService_FlagTests(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	svc := flags.NewService(flags.NewMemoryRepository())
+
+	created, err := svc.Create(ctx, testedFlag())
+	require.NoError(t, err)
+
+	reordered := created
+	reordered.Rules = []flags.Rule{created.Rules[1], created.Rules[0]}
+
+	_, err = svc.Update(ctx, reordered)
+	require.ErrorIs(t, err, flags.ErrTestsFailed)
+
+	var failed *flags.TestsFailedError
+	require.ErrorAs(t, err, &failed)
+	assert.Equal(t, flags.FlagKey("tier"), failed.Key)
+	require.Len(t, failed.Failures, 1)
+	assert.Equal(t, "pro beta users keep pro", failed.Failures[0].Test.Name)
+
+	stored, err := svc.Get(ctx, "tier")
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.Version, "the failing update was not applied")
+
+	reordered.Tests = reordered.Tests[1:]
+
+	updated, err := svc.Update(ctx, reordered)
+	require.NoError(t, err, "changing the tests with the rules is allowed")
+	assert.Len(t, updated.Tests, 1)
+
+	broken := testedFlag()
+	broken.Key = "broken"
+	broken.DefaultValue = flags.StringValue("basic")
+
+	_, err = svc.Create(ctx, broken)
+	require.ErrorIs(t, err, flags.ErrTestsFailed)
+
+	results, err := svc.RunTests(ctx, "tier")
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.True(t, results[0].Passed)
+}
+
+func TestService_FlagTests_ProtectedFlag(t *testing.T) {
+	t.Parallel()
+
+	ctx := as("alice")
+	changes := flags.NewMemoryChangeStore()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithChangeStore(changes))
+
+	flag := testedFlag()
+	flag.Protected = true
+
+	created, err := svc.Create(ctx, flag)
+	require.NoError(t, err)
+
+	created.DefaultValue = flags.StringValue("basic")
+
+	_, err = svc.Update(ctx, created)
+	require.ErrorIs(t, err, flags.ErrTestsFailed, "a change that fails its tests is not proposed")
+
+	pending, err := changes.List(ctx, flags.ChangeFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestService_RunTests_Errors(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+
+	_, err := svc.RunTests(context.Background(), "missing")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	denied := flags.NewService(flags.NewMemoryRepository(), flags.WithAuthorizer(grant{}))
+
+	_, err = denied.RunTests(context.Background(), "tier")
+	require.ErrorIs(t, err, flags.ErrForbidden)
+}
+
+func TestTestsFailedError(t *testing.T) {
+	t.Parallel()
+
+	err := error(&flags.TestsFailedError{Key: "tier", Failures: make([]flags.TestResult, 2)})
+
+	require.ErrorIs(t, err, flags.ErrTestsFailed)
+	assert.Equal(t, "flag tests failed: 2 for flag tier", err.Error())
+}
@@ -0,0 +1,84 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrRevisionNotFound = errors.New("flag revision not found")
+
+// HistoryStore keeps every revision of every flag. A revision is the full
+// flag snapshot and is identified by its Version.
+type HistoryStore interface {
+	Append(ctx context.Context, revision Flag) error
+	List(ctx context.Context, key FlagKey) ([]Flag, error)
+	Get(ctx context.Context, key FlagKey, version int) (Flag, error)
+	// Delete forgets every revision of key, so a recreated flag starts a new
+	// history at version 1.
+	Delete(ctx context.Context, key FlagKey) error
+}
+
+type MemoryHistoryStore struct {
+	mu        sync.RWMutex
+	revisions map[FlagKey][]Flag
+}
+
+func NewMemoryHistoryStore() *MemoryHistoryStore {
+	return &MemoryHistoryStore{
+		revisions: make(map[FlagKey][]Flag),
+	}
+}
+
+func (s *MemoryHistoryStore) Append(_ context.Context, revision Flag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revisions[revision.Key] = append(s.revisions[revision.Key], revision.Clone())
+
+	return nil
+}
+
+func (s *MemoryHistoryStore) List(_ context.Context, key FlagKey) ([]Flag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := s.revisions[key]
+	if len(revisions) == 0 {
+		return nil, ErrFlagNotFound
+	}
+
+	result := make([]Flag, len(revisions))
+	for i, revision := range revisions {
+		result[i] = revision.Clone()
+	}
+
+	return result, nil
+}
+
+func (s *MemoryHistoryStore) Get(_ context.Context, key FlagKey, version int) (Flag, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := s.revisions[key]
+	if len(revisions) == 0 {
+		return Flag{}, ErrFlagNotFound
+	}
+
+	for _, revision := range revisions {
+		if revision.Version == version {
+			return revision.Clone(), nil
+		}
+	}
+
+	return Flag{}, ErrRevisionNotFound
+}
+
+func (s *MemoryHistoryStore) Delete(_ context.Context, key FlagKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.revisions, key)
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package flags
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var ErrCorruptHistory = errors.New("corrupt history log")
+
+// FileHistoryStore appends revisions and deletions as JSON lines to a single
+// file and fsyncs after every write. The revisions are kept in memory and
+// rebuilt from the file when it is opened.
+type FileHistoryStore struct {
+	mu     sync.Mutex
+	file   *os.File
+	memory *MemoryHistoryStore
+}
+
+// historyRecord is either a revision or the deletion of every revision of a
+// key.
+type historyRecord struct {
+	Revision *Flag   `json:"revision,omitempty"`
+	Delete   FlagKey `json:"delete,omitempty"`
+}
+
+// OpenFileHistoryStore loads the log at path, creating it if needed. A torn
+// final line left behind by a crash is truncated; corruption anywhere else is
+// reported as ErrCorruptHistory.
+func OpenFileHistoryStore(path string) (*FileHistoryStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open history: %w", err)
+	}
+
+	memory, size, err := readHistoryRecords(file)
+	if err == nil {
+		err = truncateTo(file, size)
+	}
+
+	if err != nil {
+		_ = file.Close()
+
+		return nil, err
+	}
+
+	return &FileHistoryStore{file: file, memory: memory}, nil
+}
+
+func (s *FileHistoryStore) Append(ctx context.Context, revision Flag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.write(historyRecord{Revision: &revision}); err != nil {
+		return err
+	}
+
+	return s.memory.Append(ctx, revision)
+}
+
+func (s *FileHistoryStore) List(ctx context.Context, key FlagKey) ([]Flag, error) {
+	return s.memory.List(ctx, key)
+}
+
+func (s *FileHistoryStore) Get(ctx context.Context, key FlagKey, version int) (Flag, error) {
+	return s.memory.Get(ctx, key, version)
+}
+
+func (s *FileHistoryStore) Delete(ctx context.Context, key FlagKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.write(historyRecord{Delete: key}); err != nil {
+		return err
+	}
+
+	return s.memory.Delete(ctx, key)
+}
+
+func (s *FileHistoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+func (s *FileHistoryStore) write(record historyRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("encode history record: %w", err)
+	}
+
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write history record: %w", err)
+	}
+
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("sync history: %w", err)
+	}
+
+	return nil
+}
+
+// readHistoryRecords replays the log into a memory store and returns the byte
+// offset just past the last valid record.
+func readHistoryRecords(file *os.File) (*MemoryHistoryStore, int64, error) {
+	reader := bufio.NewReader(file)
+	memory := NewMemoryHistoryStore()
+
+	var offset int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// An unterminated final line is a write that never completed.
+			return memory, offset, nil
+		}
+
+		if err != nil {
+			return nil, 0, fmt.Errorf("read history: %w", err)
+		}
+
+		var record historyRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+			return nil, 0, fmt.Errorf("%w: record at offset %d: %w", ErrCorruptHistory, offset, err)
+		}
+
+		if record.Revision != nil {
+			memory.revisions[record.Revision.Key] = append(memory.revisions[record.Revision.Key], *record.Revision)
+		} else {
+			delete(memory.revisions, record.Delete)
+		}
+
+		offset += int64(len(line))
+	}
+}
@@ -0,0 +1,138 @@
+package flags_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openFileHistoryStore(t *testing.T, path string) *flags.FileHistoryStore {
+	t.Helper()
+
+	store, err := flags.OpenFileHistoryStore(path)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = store.Close() })
+
+	return store
+}
+
+func TestFileHistoryStore_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunHistoryStoreSuite(t, func(t *testing.T) flags.HistoryStore {
+		t.Helper()
+
+		return openFileHistoryStore(t, filepath.Join(t.TempDir(), "history.log"))
+	})
+}
+
+func TestFileHistoryStore_PersistsAcrossReopen(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "history.log")
+	ctx := context.Background()
+
+	store, err := flags.OpenFileHistoryStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Append(ctx, flagstest.Revision("flag-a", 1)))
+	require.NoError(t, store.Append(ctx, flagstest.Revision("flag-a", 2)))
+	require.NoError(t, store.Append(ctx, flagstest.Revision("flag-b", 1)))
+	require.NoError(t, store.Delete(ctx, "flag-b"))
+	require.NoError(t, store.Close())
+
+	reopened := openFileHistoryStore(t, path)
+
+	revisions, err := reopened.List(ctx, "flag-a")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	flagstest.AssertFlagEqual(t, flagstest.Revision("flag-a", 2), revisions[1])
+
+	_, err = reopened.List(ctx, "flag-b")
+	assert.ErrorIs(t, err, flags.ErrFlagNotFound)
+}
+
+func TestService_VersionsSurviveRestart(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := flags.OpenFileRepository(dir)
+	require.NoError(t, err)
+
+	history, err := flags.OpenFileHistoryStore(filepath.Join(dir, "history.log"))
+	require.NoError(t, err)
+
+	svc := flags.NewService(repo, flags.WithHistoryStore(history))
+	created := newVersionedFlag(t, svc)
+
+	created.Enabled = false
+	_, err = svc.Update(ctx, created)
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+	require.NoError(t, history.Close())
+
+	restarted := flags.NewService(openFileRepository(t, dir),
+		flags.WithHistoryStore(openFileHistoryStore(t, filepath.Join(dir, "history.log"))))
+
+	revisions, err := restarted.Versions(ctx, "versioned")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.True(t, revisions[0].Enabled)
+	assert.False(t, revisions[1].Enabled)
+
+	changes, err := restarted.DiffVersions(ctx, "versioned", 1, 2)
+	require.NoError(t, err)
+	assert.NotEmpty(t, changes)
+}
+
+func TestFileHistoryStore_TruncatesTornTail(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "history.log")
+	ctx := context.Background()
+
+	store, err := flags.OpenFileHistoryStore(path)
+	require.NoError(t, err)
+	require.NoError(t, store.Append(ctx, flagstest.Revision("flag-a", 1)))
+	require.NoError(t, store.Close())
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"revision":{"key":"fla`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reopened := openFileHistoryStore(t, path)
+	require.NoError(t, reopened.Append(ctx, flagstest.Revision("flag-a", 2)))
+
+	again := openFileHistoryStore(t, path)
+
+	revisions, err := again.List(ctx, "flag-a")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 2)
+}
+
+func TestFileHistoryStore_RejectsCorruptRecord(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "history.log")
+	require.NoError(t, os.WriteFile(path, []byte("garbage\n{\"delete\":\"flag-a\"}\n"), 0o600))
+
+	_, err := flags.OpenFileHistoryStore(path)
+	assert.ErrorIs(t, err, flags.ErrCorruptHistory)
+}
+
+func TestFileHistoryStore_OpenError(t *testing.T) {
+	t.Parallel()
+
+	_, err := flags.OpenFileHistoryStore(filepath.Join(t.TempDir(), "missing", "history.log"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package flags_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryHistoryStore_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunHistoryStoreSuite(t, func(*testing.T) flags.HistoryStore {
+		return flags.NewMemoryHistoryStore()
+	})
+}
+
+func TestMemoryHistoryStore_ListAndGet(t *testing.T) {
+	t.Parallel()
+
+	store := flags.NewMemoryHistoryStore()
+	ctx := context.Background()
+
+	require.NoError(t, store.Append(ctx, flags.Flag{Key: "flag-a", Version: 1}))
+	require.NoError(t, store.Append(ctx, flags.Flag{Key: "flag-a", Enabled: true, Version: 2}))
+	require.NoError(t, store.Append(ctx, flags.Flag{Key: "flag-b", Version: 1}))
+
+	revisions, err := store.List(ctx, "flag-a")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 1, revisions[0].Version)
+	assert.Equal(t, 2, revisions[1].Version)
+
+	revision, err := store.Get(ctx, "flag-a", 2)
+	require.NoError(t, err)
+	assert.True(t, revision.Enabled)
+}
+
+func TestMemoryHistoryStore_NotFound(t *testing.T) {
+	t.Parallel()
+
+	store := flags.NewMemoryHistoryStore()
+	ctx := context.Background()
+
+	_, err := store.List(ctx, "missing")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	_, err = store.Get(ctx, "missing", 1)
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	require.NoError(t, store.Append(ctx, flags.Flag{Key: "flag-a", Version: 1}))
+
+	_, err = store.Get(ctx, "flag-a", 2)
+	assert.ErrorIs(t, err, flags.ErrRevisionNotFound)
+}
+
+func TestMemoryHistoryStore_RevisionsAreImmutable(t *testing.T) {
+	t.Parallel()
+
+	store := flags.NewMemoryHistoryStore()
+	ctx := context.Background()
+
+	revision := flags.Flag{Key: "flag-a", Version: 1, Rules: []flags.Rule{{ID: "rule-1"}}}
+	require.NoError(t, store.Append(ctx, revision))
+
+	revision.Rules[0].ID = "mutated"
+
+	listed, err := store.List(ctx, "flag-a")
+	require.NoError(t, err)
+
+	listed[0].Rules[0].ID = "mutated"
+
+	got, err := store.Get(ctx, "flag-a", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "rule-1", got.Rules[0].ID)
+}
+
+func TestMemoryHistoryStore_Delete(t *testing.T) {
+	t.Parallel()
+
+	store := flags.NewMemoryHistoryStore()
+	ctx := context.Background()
+
+	require.NoError(t, store.Append(ctx, flags.Flag{Key: "flag-a", Version: 1}))
+	require.NoError(t, store.Append(ctx, flags.Flag{Key: "flag-b", Version: 1}))
+	require.NoError(t, store.Delete(ctx, "flag-a"))
+
+	_, err := store.List(ctx, "flag-a")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	revisions, err := store.List(ctx, "flag-b")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 1)
+}
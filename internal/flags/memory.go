@@ -2,6 +2,8 @@ package flags
 
 import (
 	"context"
+	"slices"
+	"strings"
 	"sync"
 )
 
@@ -25,7 +27,14 @@ func (r *MemoryRepository) Get(_ context.Context, key FlagKey) (Flag, error) {
 		return Flag{}, ErrFlagNotFound
 	}
 
-	return flag, nil
+	return flag.Clone(), nil
+}
+
+func (r *MemoryRepository) List(_ context.Context) ([]Flag, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return sortedFlags(r.flags), nil
 }
 
 func (r *MemoryRepository) Create(_ context.Context, flag Flag) error {
@@ -36,7 +45,50 @@ func (r *MemoryRepository) Create(_ context.Context, flag Flag) error {
 		return ErrFlagExists
 	}
 
-	r.flags[flag.Key] = flag
+	r.flags[flag.Key] = flag.Clone()
+
+	return nil
+}
+
+func (r *MemoryRepository) Update(_ context.Context, flag Flag) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.flags[flag.Key]
+	if !exists {
+		return ErrFlagNotFound
+	}
+
+	if current.Version != flag.Version-1 {
+		return ErrVersionConflict
+	}
+
+	r.flags[flag.Key] = flag.Clone()
 
 	return nil
 }
+
+func (r *MemoryRepository) Delete(_ context.Context, key FlagKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.flags[key]; !exists {
+		return ErrFlagNotFound
+	}
+
+	delete(r.flags, key)
+
+	return nil
+}
+
+// sortedFlags returns copies of every flag in m ordered by key.
+func sortedFlags(m map[FlagKey]Flag) []Flag {
+	result := make([]Flag, 0, len(m))
+	for _, flag := range m {
+		result = append(result, flag.Clone())
+	}
+
+	slices.SortFunc(result, func(a, b Flag) int { return strings.Compare(string(a.Key), string(b.Key)) })
+
+	return result
+}
@@ -6,10 +6,19 @@ import (
 	"time"
 
 	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+func TestMemoryRepository_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunRepositorySuite(t, func(*testing.T) flags.Repository {
+		return flags.NewMemoryRepository()
+	})
+}
+
 func TestMemoryRepository_Create(t *testing.T) {
 	t.Parallel()
 
@@ -77,3 +86,43 @@ func TestMemoryRepository_Get_NotFound(t *testing.T) {
 
 	assert.ErrorIs(t, err, flags.ErrFlagNotFound)
 }
+
+func TestMemoryRepository_Update(t *testing.T) {
+	t.Parallel()
+
+	repo := flags.NewMemoryRepository()
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "test-flag", Type: flags.FlagBool, Version: 1}))
+
+	err := repo.Update(ctx, flags.Flag{Key: "test-flag", Type: flags.FlagBool, Enabled: true, Version: 2})
+	require.NoError(t, err)
+
+	got, err := repo.Get(ctx, "test-flag")
+	require.NoError(t, err)
+	assert.True(t, got.Enabled)
+	assert.Equal(t, 2, got.Version)
+}
+
+func TestMemoryRepository_Update_VersionConflict(t *testing.T) {
+	t.Parallel()
+
+	repo := flags.NewMemoryRepository()
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, flags.Flag{Key: "test-flag", Version: 1}))
+
+	err := repo.Update(ctx, flags.Flag{Key: "test-flag", Version: 3})
+
+	assert.ErrorIs(t, err, flags.ErrVersionConflict)
+}
+
+func TestMemoryRepository_Update_NotFound(t *testing.T) {
+	t.Parallel()
+
+	repo := flags.NewMemoryRepository()
+
+	err := repo.Update(context.Background(), flags.Flag{Key: "nonexistent", Version: 2})
+
+	assert.ErrorIs(t, err, flags.ErrFlagNotFound)
+}
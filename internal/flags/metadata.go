@@ -0,0 +1,49 @@
+package flags
+
+import "slices"
+
+// FlagKind is the lifecycle of a flag: what it is for and, with it, how long
+// it is meant to live.
+type FlagKind string
+
+const (
+	KindRelease    FlagKind = "release"     // gates unfinished work until it is rolled out
+	KindExperiment FlagKind = "experiment"  // splits traffic until a variation wins
+	KindOps        FlagKind = "ops"         // tunes operational behavior
+	KindPermission FlagKind = "permission"  // grants features to some tenants or plans
+	KindKillSwitch FlagKind = "kill-switch" // turns a feature off when it misbehaves
+)
+
+// Temporary reports whether flags of kind k are meant to be removed once
+// they have done their job. Flags without a kind count as temporary.
+func (k FlagKind) Temporary() bool {
+	switch k {
+	case KindOps, KindPermission, KindKillSwitch:
+		return false
+	case KindRelease, KindExperiment:
+		return true
+	default:
+		return true
+	}
+}
+
+// FlagFilter selects flags by their metadata. Empty fields match every flag.
+type FlagFilter struct {
+	Tag   string
+	Owner string
+	Kind  FlagKind
+}
+
+func (f FlagFilter) matches(flag Flag) bool {
+	return (f.Tag == "" || slices.Contains(flag.Tags, f.Tag)) &&
+		(f.Owner == "" || flag.Owner == f.Owner) &&
+		(f.Kind == "" || flag.Kind == f.Kind)
+}
+
+func (f FlagFilter) apply(all []Flag) []Flag {
+	if f == (FlagFilter{}) {
+		return all
+	}
+
+	return slices.DeleteFunc(all, func(flag Flag) bool { return !f.matches(flag) })
+}
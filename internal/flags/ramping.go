@@ -0,0 +1,276 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidRamp = errors.New("invalid rollout ramp")
+	ErrRampActive  = errors.New("rule already has an active rollout ramp")
+	ErrRampState   = errors.New("rollout ramp cannot do that now")
+)
+
+// StartRamp rolls ramp.RuleID out to the first step at once and stores the
+// ramp so RunRamps can take it through the rest. Steps must raise the
+// percentage each time, up to at most 100, and every step but the last needs
+// a positive dwell. A rule has at most one active ramp, and protected flags
+// cannot be ramped because every step would need approval.
+func (s *Service) StartRamp(ctx context.Context, ramp Ramp) (Ramp, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionWrite, ramp.FlagKey); err != nil {
+		return Ramp{}, err
+	}
+
+	if err := ramp.validate(); err != nil {
+		return Ramp{}, err
+	}
+
+	s.scheduling.Lock()
+	defer s.scheduling.Unlock()
+
+	current, err := s.repo.Get(ctx, ramp.FlagKey)
+	if err != nil {
+		return Ramp{}, err
+	}
+
+	if err := s.checkRampable(ctx, current, ramp); err != nil {
+		return Ramp{}, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return Ramp{}, err
+	}
+
+	now := s.clock.Now()
+	ramp = Ramp{
+		ID:            id,
+		FlagKey:       ramp.FlagKey,
+		RuleID:        ramp.RuleID,
+		Steps:         ramp.Steps,
+		Status:        RampRunning,
+		CreatedBy:     ActorFromContext(ctx),
+		CreatedAt:     now,
+		StepStartedAt: now,
+		UpdatedAt:     now,
+	}
+
+	if len(ramp.Steps) == 1 {
+		ramp.Status = RampCompleted
+	}
+
+	if err := s.setRollout(withRamp(ctx, ramp.ID), ramp, ramp.Percentage()); err != nil {
+		return Ramp{}, err
+	}
+
+	if err := s.ramps.Create(ctx, ramp); err != nil {
+		return Ramp{}, err
+	}
+
+	return ramp.clone(), nil
+}
+
+func (r Ramp) validate() error {
+	if r.RuleID == "" {
+		return fmt.Errorf("%w: no rule", ErrInvalidRamp)
+	}
+
+	if len(r.Steps) == 0 {
+		return fmt.Errorf("%w: no steps", ErrInvalidRamp)
+	}
+
+	previous := 0.0
+
+	for i, step := range r.Steps {
+		if step.Percentage <= previous || step.Percentage > 100 {
+			return fmt.Errorf("%w: step %d: percentages must increase up to 100", ErrInvalidRamp, i+1)
+		}
+
+		if step.Dwell <= 0 && i < len(r.Steps)-1 {
+			return fmt.Errorf("%w: step %d: dwell must be positive", ErrInvalidRamp, i+1)
+		}
+
+		previous = step.Percentage
+	}
+
+	return nil
+}
+
+// checkRampable rejects ramps on protected flags and on rules that already
+// have an active ramp.
+func (s *Service) checkRampable(ctx context.Context, current Flag, ramp Ramp) error {
+	if s.approvals.ProtectAll || current.Protected {
+		return fmt.Errorf("%w: protected flags cannot be ramped", ErrApprovalRequired)
+	}
+
+	ramps, err := s.ramps.List(ctx, RampFilter{FlagKey: ramp.FlagKey})
+	if err != nil {
+		return fmt.Errorf("list rollout ramps: %w", err)
+	}
+
+	for _, other := range ramps {
+		if other.RuleID == ramp.RuleID && other.Active() {
+			return fmt.Errorf("%w: %s", ErrRampActive, other.ID)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) Ramps(ctx context.Context, filter RampFilter) ([]Ramp, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionRead, filter.FlagKey); err != nil {
+		return nil, err
+	}
+
+	return s.ramps.List(ctx, filter)
+}
+
+// PauseRamp holds a running ramp at its current step.
+func (s *Service) PauseRamp(ctx context.Context, id string) (Ramp, error) {
+	return s.changeRamp(ctx, id, RampRunning, func(ramp *Ramp, now time.Time) error {
+		ramp.Status = RampPaused
+		ramp.PausedAt = now
+
+		return nil
+	})
+}
+
+// ResumeRamp restarts a paused ramp. The time spent paused does not count
+// towards the current step's dwell.
+func (s *Service) ResumeRamp(ctx context.Context, id string) (Ramp, error) {
+	return s.changeRamp(ctx, id, RampPaused, func(ramp *Ramp, now time.Time) error {
+		ramp.Status = RampRunning
+		ramp.StepStartedAt = ramp.StepStartedAt.Add(now.Sub(ramp.PausedAt))
+		ramp.PausedAt = time.Time{}
+
+		return nil
+	})
+}
+
+// AbortRamp stops an active ramp and rolls its rule back to 0%. A flag or
+// rule that no longer exists has nothing to roll back.
+func (s *Service) AbortRamp(ctx context.Context, id string) (Ramp, error) {
+	return s.changeRamp(ctx, id, "", func(ramp *Ramp, _ time.Time) error {
+		err := s.setRollout(withRamp(ctx, ramp.ID), *ramp, 0)
+		if err != nil && !errors.Is(err, ErrFlagNotFound) && !errors.Is(err, ErrRuleNotFound) {
+			return err
+		}
+
+		ramp.Status = RampAborted
+		ramp.PausedAt = time.Time{}
+
+		return nil
+	})
+}
+
+// changeRamp applies change to ramp id, which must be active and, unless from
+// is empty, in status from.
+func (s *Service) changeRamp(ctx context.Context, id string, from RampStatus,
+	change func(ramp *Ramp, now time.Time) error,
+) (Ramp, error) {
+	s.scheduling.Lock()
+	defer s.scheduling.Unlock()
+
+	ramp, err := s.ramps.Get(ctx, id)
+	if err != nil {
+		return Ramp{}, err
+	}
+
+	if err := s.authorizer.Authorize(ctx, PermissionWrite, ramp.FlagKey); err != nil {
+		return Ramp{}, err
+	}
+
+	if !ramp.Active() || (from != "" && ramp.Status != from) {
+		return Ramp{}, fmt.Errorf("%w: ramp is %s", ErrRampState, ramp.Status)
+	}
+
+	now := s.clock.Now()
+	if err := change(&ramp, now); err != nil {
+		return Ramp{}, err
+	}
+
+	ramp.UpdatedBy = ActorFromContext(ctx)
+	ramp.UpdatedAt = now
+
+	if err := s.ramps.Update(ctx, ramp); err != nil {
+		return Ramp{}, err
+	}
+
+	return ramp, nil
+}
+
+// RunRamps moves every running ramp whose dwell has passed to its next step
+// and returns the ramps it moved. A ramp moves at most one step per run, so a
+// service that was down does not skip steps: the missed step starts when the
+// service is back and gets its full dwell. A ramp whose step cannot be
+// applied, for example because its rule was removed, is marked failed. Steps
+// run as the ramp's creator and are not authorized again.
+func (s *Service) RunRamps(ctx context.Context) ([]Ramp, error) {
+	s.scheduling.Lock()
+	defer s.scheduling.Unlock()
+
+	running, err := s.ramps.List(ctx, RampFilter{Status: RampRunning})
+	if err != nil {
+		return nil, fmt.Errorf("list rollout ramps: %w", err)
+	}
+
+	now := s.clock.Now()
+
+	var moved []Ramp
+
+	for _, ramp := range running {
+		next := ramp.NextStepAt()
+		if next.IsZero() || next.After(now) {
+			continue
+		}
+
+		stepCtx := withRamp(WithActor(ctx, ramp.CreatedBy), ramp.ID)
+		if err := s.setRollout(stepCtx, ramp, ramp.Steps[ramp.Step+1].Percentage); err != nil {
+			ramp.Status = RampFailed
+			ramp.Error = err.Error()
+		} else {
+			ramp.Step++
+			ramp.StepStartedAt = now
+
+			if ramp.Step == len(ramp.Steps)-1 {
+				ramp.Status = RampCompleted
+			}
+		}
+
+		ramp.UpdatedAt = now
+
+		if err := s.ramps.Update(ctx, ramp); err != nil {
+			return moved, fmt.Errorf("record rollout ramp %s: %w", ramp.ID, err)
+		}
+
+		moved = append(moved, ramp)
+	}
+
+	return moved, nil
+}
+
+// setRollout rolls the ramp's rule out to percentage as a new revision of its
+// flag.
+func (s *Service) setRollout(ctx context.Context, ramp Ramp, percentage float64) error {
+	current, err := s.repo.Get(ctx, ramp.FlagKey)
+	if err != nil {
+		return err
+	}
+
+	next, err := withRollout(current, ramp.RuleID, percentage)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.update(ctx, next)
+
+	return err
+}
+
+// WatchRamps runs RunRamps at once and then every interval until ctx is done.
+// Each run that moved ramps or failed is passed to report.
+func (s *Service) WatchRamps(ctx context.Context, interval time.Duration, report func([]Ramp, error)) {
+	watch(ctx, interval, s.RunRamps, report)
+}
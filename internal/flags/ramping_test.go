@@ -0,0 +1,373 @@
+package flags_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rampSteps(percentages ...float64) []flags.RampStep {
+	steps := make([]flags.RampStep, len(percentages))
+	for i, percentage := range percentages {
+		steps[i] = flags.RampStep{Percentage: percentage, Dwell: time.Hour}
+	}
+
+	return steps
+}
+
+func newRampedService(t *testing.T, opts ...flags.Option) (*flags.Service, *flagstest.Clock) {
+	t.Helper()
+
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(), append(opts, flags.WithClock(clock))...)
+
+	flag := boolFlag("checkout", false)
+	flag.Rules = []flags.Rule{{
+		ID:      "everyone",
+		Value:   flags.BoolValue(true),
+		Rollout: &flags.Rollout{BucketBy: "tenant_id"},
+	}}
+	_, err := svc.Create(context.Background(), flag)
+	require.NoError(t, err)
+
+	return svc, clock
+}
+
+func rollout(t *testing.T, svc *flags.Service) flags.Rollout {
+	t.Helper()
+
+	flag, err := svc.Get(context.Background(), "checkout")
+	require.NoError(t, err)
+
+	return *flag.Rules[0].Rollout
+}
+
+func TestService_RunRamps(t *testing.T) {
+	t.Parallel()
+
+	svc, clock := newRampedService(t)
+	ctx := context.Background()
+
+	ramp, err := svc.StartRamp(as("alice"), flags.Ramp{
+		FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(1, 5, 25, 50, 100),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, flags.RampRunning, ramp.Status)
+	assert.Equal(t, "alice", ramp.CreatedBy)
+	assert.Equal(t, clock.Now().Add(time.Hour), ramp.NextStepAt())
+	assert.Equal(t, flags.Rollout{Percentage: 1, BucketBy: "tenant_id"}, rollout(t, svc))
+
+	moved, err := svc.RunRamps(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, moved, "the first step has not dwelt yet")
+
+	for _, percentage := range []float64{5, 25, 50} {
+		clock.Advance(time.Hour)
+
+		moved, err = svc.RunRamps(ctx)
+		require.NoError(t, err)
+		require.Len(t, moved, 1)
+		assert.InDelta(t, percentage, moved[0].Percentage(), 0)
+		assert.Equal(t, flags.RampRunning, moved[0].Status)
+		assert.InDelta(t, percentage, rollout(t, svc).Percentage, 0)
+	}
+
+	// The service was down for a day: the ramp takes one step, not three.
+	clock.Advance(24 * time.Hour)
+
+	moved, err = svc.RunRamps(ctx)
+	require.NoError(t, err)
+	require.Len(t, moved, 1)
+	assert.Equal(t, flags.RampCompleted, moved[0].Status)
+	assert.True(t, moved[0].NextStepAt().IsZero())
+	assert.InDelta(t, 100, rollout(t, svc).Percentage, 0)
+
+	versions, err := svc.Versions(ctx, "checkout")
+	require.NoError(t, err)
+	assert.Len(t, versions, 6, "one revision per step")
+
+	page, err := svc.AuditLog(ctx, flags.AuditFilter{FlagKey: "checkout"})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 6)
+
+	for _, entry := range page.Entries[1:] {
+		assert.Equal(t, "alice", entry.Actor)
+		assert.Equal(t, ramp.ID, entry.RampID)
+	}
+
+	completed, err := svc.Ramps(ctx, flags.RampFilter{FlagKey: "checkout", Status: flags.RampCompleted})
+	require.NoError(t, err)
+	assert.Len(t, completed, 1)
+}
+
+func TestService_RampControls(t *testing.T) {
+	t.Parallel()
+
+	svc, clock := newRampedService(t)
+
+	ramp, err := svc.StartRamp(as("alice"), flags.Ramp{
+		FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(10, 50, 100),
+	})
+	require.NoError(t, err)
+
+	clock.Advance(30 * time.Minute)
+
+	paused, err := svc.PauseRamp(as("bob"), ramp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, flags.RampPaused, paused.Status)
+	assert.Equal(t, "bob", paused.UpdatedBy)
+
+	_, err = svc.PauseRamp(as("bob"), ramp.ID)
+	require.ErrorIs(t, err, flags.ErrRampState)
+
+	clock.Advance(2 * time.Hour)
+
+	moved, err := svc.RunRamps(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, moved, "paused ramps do not move")
+
+	resumed, err := svc.ResumeRamp(as("bob"), ramp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, flags.RampRunning, resumed.Status)
+	assert.Equal(t, clock.Now().Add(30*time.Minute), resumed.NextStepAt(), "the pause does not eat the dwell")
+
+	_, err = svc.ResumeRamp(as("bob"), ramp.ID)
+	require.ErrorIs(t, err, flags.ErrRampState)
+
+	aborted, err := svc.AbortRamp(as("carol"), ramp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, flags.RampAborted, aborted.Status)
+	assert.Equal(t, flags.Rollout{Percentage: 0, BucketBy: "tenant_id"}, rollout(t, svc))
+
+	_, err = svc.AbortRamp(as("carol"), ramp.ID)
+	require.ErrorIs(t, err, flags.ErrRampState)
+
+	page, err := svc.AuditLog(context.Background(), flags.AuditFilter{FlagKey: "checkout"})
+	require.NoError(t, err)
+	assert.Equal(t, "carol", page.Entries[len(page.Entries)-1].Actor)
+	assert.Equal(t, ramp.ID, page.Entries[len(page.Entries)-1].RampID)
+
+	_, err = svc.PauseRamp(context.Background(), "missing")
+	require.ErrorIs(t, err, flags.ErrRampNotFound)
+
+	// The rule is free again once the ramp is over.
+	again, err := svc.StartRamp(context.Background(), flags.Ramp{
+		FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(100),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, flags.RampCompleted, again.Status, "a single step completes at once")
+}
+
+func TestService_RunRamps_Failures(t *testing.T) {
+	t.Parallel()
+
+	svc, clock := newRampedService(t)
+	ctx := context.Background()
+
+	ramp, err := svc.StartRamp(ctx, flags.Ramp{FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(10, 100)})
+	require.NoError(t, err)
+
+	flag, err := svc.Get(ctx, "checkout")
+	require.NoError(t, err)
+
+	flag.Rules = nil
+	_, err = svc.Update(ctx, flag)
+	require.NoError(t, err)
+
+	clock.Advance(time.Hour)
+
+	moved, err := svc.RunRamps(ctx)
+	require.NoError(t, err)
+	require.Len(t, moved, 1)
+	assert.Equal(t, ramp.ID, moved[0].ID)
+	assert.Equal(t, flags.RampFailed, moved[0].Status)
+	assert.Contains(t, moved[0].Error, flags.ErrRuleNotFound.Error())
+
+	_, err = svc.AbortRamp(ctx, ramp.ID)
+	require.ErrorIs(t, err, flags.ErrRampState, "failed ramps are over")
+
+	_, err = svc.StartRamp(ctx, flags.Ramp{FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(10, 100)})
+	require.ErrorIs(t, err, flags.ErrRuleNotFound)
+}
+
+func TestService_AbortRamp_DeletedFlag(t *testing.T) {
+	t.Parallel()
+
+	svc, _ := newRampedService(t)
+	ctx := context.Background()
+
+	ramp, err := svc.StartRamp(ctx, flags.Ramp{FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(10, 100)})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Delete(ctx, "checkout"))
+
+	aborted, err := svc.AbortRamp(ctx, ramp.ID)
+	require.NoError(t, err, "there is nothing to roll back")
+	assert.Equal(t, flags.RampAborted, aborted.Status)
+}
+
+func TestService_StartRamp_Errors(t *testing.T) {
+	t.Parallel()
+
+	ramps := flags.NewMemoryRampStore()
+	svc, _ := newRampedService(t, flags.WithRampStore(ramps))
+	ctx := context.Background()
+
+	invalid := map[string]flags.Ramp{
+		"no rule":        {FlagKey: "checkout", Steps: rampSteps(10)},
+		"no steps":       {FlagKey: "checkout", RuleID: "everyone"},
+		"decreasing":     {FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(50, 10)},
+		"over 100":       {FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(50, 150)},
+		"zero":           {FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(0, 10)},
+		"no dwell":       {FlagKey: "checkout", RuleID: "everyone", Steps: []flags.RampStep{{Percentage: 10}, {}}},
+		"negative dwell": {FlagKey: "checkout", RuleID: "everyone", Steps: []flags.RampStep{{Percentage: 10, Dwell: -1}, {}}},
+	}
+	for name, ramp := range invalid {
+		_, err := svc.StartRamp(ctx, ramp)
+		require.ErrorIs(t, err, flags.ErrInvalidRamp, name)
+	}
+
+	_, err := svc.StartRamp(ctx, flags.Ramp{FlagKey: "missing", RuleID: "everyone", Steps: rampSteps(10)})
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	_, err = svc.StartRamp(ctx, flags.Ramp{FlagKey: "checkout", RuleID: "missing", Steps: rampSteps(10)})
+	require.ErrorIs(t, err, flags.ErrRuleNotFound)
+
+	started, err := svc.StartRamp(ctx, flags.Ramp{FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(10, 20)})
+	require.NoError(t, err)
+
+	_, err = svc.StartRamp(ctx, flags.Ramp{FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(10, 20)})
+	require.ErrorIs(t, err, flags.ErrRampActive)
+
+	protected, _ := newRampedService(t)
+
+	flag, err := protected.Get(ctx, "checkout")
+	require.NoError(t, err)
+
+	flag.Protected = true
+	_, err = protected.Update(ctx, flag)
+	require.NoError(t, err)
+
+	_, err = protected.StartRamp(flags.WithEmergency(ctx), flags.Ramp{
+		FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(10),
+	})
+	require.ErrorIs(t, err, flags.ErrApprovalRequired, "later steps could not be applied")
+
+	viewer := flags.NewService(flags.NewMemoryRepository(),
+		flags.WithRampStore(ramps), flags.WithAuthorizer(grant{flags.PermissionRead}))
+
+	_, err = viewer.StartRamp(ctx, flags.Ramp{FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(10)})
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	_, err = viewer.PauseRamp(ctx, started.ID)
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	_, err = flags.NewService(flags.NewMemoryRepository(), flags.WithAuthorizer(grant{})).
+		Ramps(ctx, flags.RampFilter{})
+	require.ErrorIs(t, err, flags.ErrForbidden)
+}
+
+func TestService_WatchRamps(t *testing.T) {
+	t.Parallel()
+
+	svc, clock := newRampedService(t)
+
+	_, err := svc.StartRamp(context.Background(), flags.Ramp{
+		FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(10, 100),
+	})
+	require.NoError(t, err)
+
+	clock.Advance(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var reported []flags.Ramp
+
+	svc.WatchRamps(ctx, time.Hour, func(moved []flags.Ramp, err error) {
+		require.NoError(t, err)
+
+		reported = moved
+
+		cancel()
+	})
+
+	require.Len(t, reported, 1)
+	assert.Equal(t, flags.RampCompleted, reported[0].Status)
+}
+
+func TestFileRampStore_PersistsAcrossReopen(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ramps.json")
+
+	store, err := flags.OpenFileRampStore(path)
+	require.NoError(t, err)
+
+	svc, clock := newRampedService(t, flags.WithRampStore(store))
+
+	ramp, err := svc.StartRamp(context.Background(), flags.Ramp{
+		FlagKey: "checkout", RuleID: "everyone", Steps: rampSteps(10, 50, 100),
+	})
+	require.NoError(t, err)
+
+	clock.Advance(time.Hour)
+
+	_, err = svc.RunRamps(context.Background())
+	require.NoError(t, err)
+
+	reopened, err := flags.OpenFileRampStore(path)
+	require.NoError(t, err)
+
+	got, err := reopened.Get(context.Background(), ramp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.Step)
+	assert.Equal(t, time.Hour, got.Steps[0].Dwell)
+	assert.True(t, clock.Now().Equal(got.StepStartedAt))
+
+	running, err := reopened.List(context.Background(), flags.RampFilter{Status: flags.RampRunning})
+	require.NoError(t, err)
+	assert.Len(t, running, 1)
+
+	require.ErrorIs(t, reopened.Create(context.Background(), got), flags.ErrRampExists)
+	require.ErrorIs(t, reopened.Update(context.Background(), flags.Ramp{ID: "missing"}), flags.ErrRampNotFound)
+}
+
+func TestFileRampStore_WritesFailAfterClose(t *testing.T) {
+	t.Parallel()
+
+	store, err := flags.OpenFileRampStore(filepath.Join(t.TempDir(), "ramps.json"))
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	ctx := context.Background()
+	assert.ErrorIs(t, store.Create(ctx, flags.Ramp{ID: "a"}), flags.ErrStoreClosed)
+}
+
+func TestFileRampStore_Errors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.json")
+	require.NoError(t, os.WriteFile(corrupt, []byte("{"), 0o600))
+
+	_, err := flags.OpenFileRampStore(corrupt)
+	require.Error(t, err)
+
+	_, err = flags.OpenFileRampStore(dir)
+	require.Error(t, err)
+
+	store, err := flags.OpenFileRampStore(filepath.Join(dir, "missing", "ramps.json"))
+	require.NoError(t, err)
+
+	require.Error(t, store.Create(context.Background(), flags.Ramp{ID: "a"}))
+
+	_, err = store.Get(context.Background(), "a")
+	require.ErrorIs(t, err, flags.ErrRampNotFound, "failed writes are undone")
+}
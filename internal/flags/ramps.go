@@ -0,0 +1,250 @@
+package flags
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrRampNotFound = errors.New("rollout ramp not found")
+	ErrRampExists   = errors.New("rollout ramp already exists")
+)
+
+type RampStatus string
+
+const (
+	RampRunning   RampStatus = "running"
+	RampPaused    RampStatus = "paused"
+	RampCompleted RampStatus = "completed"
+	RampAborted   RampStatus = "aborted"
+	RampFailed    RampStatus = "failed"
+)
+
+// RampStep is a rollout percentage and how long it is held before the ramp
+// moves on. The last step's dwell is ignored.
+type RampStep struct {
+	Percentage float64       `json:"percentage"`
+	Dwell      time.Duration `json:"dwell"`
+}
+
+// Ramp raises the rollout of one rule of a flag through Steps. Step is the
+// index of the step in effect.
+type Ramp struct {
+	ID        string     `json:"id"`
+	FlagKey   FlagKey    `json:"flagKey"`
+	RuleID    string     `json:"ruleId"`
+	Steps     []RampStep `json:"steps"`
+	Step      int        `json:"step"`
+	Status    RampStatus `json:"status"`
+	CreatedBy string     `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	// StepStartedAt is when the current step took effect, moved forward by
+	// the time spent paused so every step gets its full dwell.
+	StepStartedAt time.Time `json:"stepStartedAt"`
+	PausedAt      time.Time `json:"pausedAt,omitzero"`
+	UpdatedBy     string    `json:"updatedBy,omitempty"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// Active reports whether the ramp still owns its rule's rollout.
+func (r Ramp) Active() bool {
+	return r.Status == RampRunning || r.Status == RampPaused
+}
+
+// Percentage returns the rollout of the step in effect.
+func (r Ramp) Percentage() float64 {
+	return r.Steps[r.Step].Percentage
+}
+
+// NextStepAt returns when the ramp moves to its next step, or the zero time
+// when it is not running or is on its last step.
+func (r Ramp) NextStepAt() time.Time {
+	if r.Status != RampRunning || r.Step >= len(r.Steps)-1 {
+		return time.Time{}
+	}
+
+	return r.StepStartedAt.Add(r.Steps[r.Step].Dwell)
+}
+
+type RampFilter struct {
+	FlagKey FlagKey
+	Status  RampStatus
+}
+
+func (f RampFilter) matches(ramp Ramp) bool {
+	return (f.FlagKey == "" || ramp.FlagKey == f.FlagKey) && (f.Status == "" || ramp.Status == f.Status)
+}
+
+// RampStore keeps rollout ramps. List returns them oldest first.
+type RampStore interface {
+	Create(ctx context.Context, ramp Ramp) error
+	Get(ctx context.Context, id string) (Ramp, error)
+	List(ctx context.Context, filter RampFilter) ([]Ramp, error)
+	Update(ctx context.Context, ramp Ramp) error
+}
+
+// WithRampStore replaces the default in-memory rollout ramp store.
+func WithRampStore(store RampStore) Option {
+	return func(s *Service) {
+		s.ramps = store
+	}
+}
+
+type MemoryRampStore struct {
+	mu    sync.RWMutex
+	ramps []Ramp
+}
+
+func NewMemoryRampStore() *MemoryRampStore {
+	return &MemoryRampStore{}
+}
+
+func (s *MemoryRampStore) Create(_ context.Context, ramp Ramp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index(ramp.ID) >= 0 {
+		return fmt.Errorf("%w: %s", ErrRampExists, ramp.ID)
+	}
+
+	s.ramps = append(s.ramps, ramp.clone())
+	slices.SortStableFunc(s.ramps, compareRamps)
+
+	return nil
+}
+
+func (s *MemoryRampStore) Get(_ context.Context, id string) (Ramp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return Ramp{}, ErrRampNotFound
+	}
+
+	return s.ramps[i].clone(), nil
+}
+
+func (s *MemoryRampStore) List(_ context.Context, filter RampFilter) ([]Ramp, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []Ramp
+
+	for _, ramp := range s.ramps {
+		if filter.matches(ramp) {
+			result = append(result, ramp.clone())
+		}
+	}
+
+	return result, nil
+}
+
+func (s *MemoryRampStore) Update(_ context.Context, ramp Ramp) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(ramp.ID)
+	if i < 0 {
+		return ErrRampNotFound
+	}
+
+	s.ramps[i] = ramp.clone()
+
+	return nil
+}
+
+func (s *MemoryRampStore) index(id string) int {
+	return slices.IndexFunc(s.ramps, func(ramp Ramp) bool { return ramp.ID == id })
+}
+
+func compareRamps(a, b Ramp) int {
+	return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ID, b.ID))
+}
+
+func (r Ramp) clone() Ramp {
+	r.Steps = slices.Clone(r.Steps)
+
+	return r
+}
+
+// FileRampStore keeps rollout ramps in memory and rewrites a JSON file on
+// every change, so running ramps survive restarts.
+type FileRampStore struct {
+	mu     sync.Mutex
+	path   string
+	memory *MemoryRampStore
+	closed bool
+}
+
+// OpenFileRampStore loads the rollout ramps in path, which is created on the
+// first change if it does not exist.
+func OpenFileRampStore(path string) (*FileRampStore, error) {
+	s := &FileRampStore{path: path, memory: NewMemoryRampStore()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read rollout ramps: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.memory.ramps); err != nil {
+		return nil, fmt.Errorf("decode rollout ramps %s: %w", path, err)
+	}
+
+	slices.SortStableFunc(s.memory.ramps, compareRamps)
+
+	return s, nil
+}
+
+func (s *FileRampStore) Create(ctx context.Context, ramp Ramp) error {
+	return s.write(func() error { return s.memory.Create(ctx, ramp) })
+}
+
+func (s *FileRampStore) Get(ctx context.Context, id string) (Ramp, error) {
+	return s.memory.Get(ctx, id)
+}
+
+func (s *FileRampStore) List(ctx context.Context, filter RampFilter) ([]Ramp, error) {
+	return s.memory.List(ctx, filter)
+}
+
+func (s *FileRampStore) Update(ctx context.Context, ramp Ramp) error {
+	return s.write(func() error { return s.memory.Update(ctx, ramp) })
+}
+
+// write applies change in memory and persists the result, undoing the change
+// when the file cannot be written.
+func (s *FileRampStore) write(change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	return rewriteJSON(&s.memory.mu, &s.memory.ramps, s.path, "rollout ramps", change)
+}
+
+// Close waits for a write in progress to finish. Later writes fail with
+// ErrStoreClosed.
+func (s *FileRampStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/serroba/features/internal/flags"
+)
+
+// HistoryStore keeps the revisions of each flag as a list of JSON documents,
+// so every replica shares one history.
+type HistoryStore struct {
+	client goredis.UniversalClient
+	prefix string
+}
+
+// History returns a history store using the repository's client and prefix.
+func (r *Repository) History() *HistoryStore {
+	return &HistoryStore{client: r.store.client, prefix: r.store.prefix}
+}
+
+func (s *HistoryStore) Append(ctx context.Context, revision flags.Flag) error {
+	doc, err := json.Marshal(revision)
+	if err != nil {
+		return fmt.Errorf("encode revision: %w", err)
+	}
+
+	if err := s.client.RPush(ctx, s.historyKey(revision.Key), doc).Err(); err != nil {
+		return fmt.Errorf("append revision: %w", err)
+	}
+
+	return nil
+}
+
+func (s *HistoryStore) List(ctx context.Context, key flags.FlagKey) ([]flags.Flag, error) {
+	docs, err := s.client.LRange(ctx, s.historyKey(key), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list revisions: %w", err)
+	}
+
+	if len(docs) == 0 {
+		return nil, flags.ErrFlagNotFound
+	}
+
+	revisions := make([]flags.Flag, len(docs))
+	for i, doc := range docs {
+		if err := json.Unmarshal([]byte(doc), &revisions[i]); err != nil {
+			return nil, fmt.Errorf("decode revision: %w", err)
+		}
+	}
+
+	return revisions, nil
+}
+
+func (s *HistoryStore) Get(ctx context.Context, key flags.FlagKey, version int) (flags.Flag, error) {
+	revisions, err := s.List(ctx, key)
+	if err != nil {
+		return flags.Flag{}, err
+	}
+
+	for _, revision := range revisions {
+		if revision.Version == version {
+			return revision, nil
+		}
+	}
+
+	return flags.Flag{}, flags.ErrRevisionNotFound
+}
+
+func (s *HistoryStore) Delete(ctx context.Context, key flags.FlagKey) error {
+	if err := s.client.Del(ctx, s.historyKey(key)).Err(); err != nil {
+		return fmt.Errorf("delete revisions: %w", err)
+	}
+
+	return nil
+}
+
+func (s *HistoryStore) historyKey(key flags.FlagKey) string {
+	return s.prefix + "history:" + string(key)
+}
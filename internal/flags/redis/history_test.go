@@ -0,0 +1,94 @@
+package redis_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/serroba/features/internal/flags/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryStore_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunHistoryStoreSuite(t, func(t *testing.T) flags.HistoryStore {
+		t.Helper()
+
+		return newRepository(t, miniredis.RunT(t)).History()
+	})
+}
+
+func TestHistoryStore_SharedByReplicas(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+
+	require.NoError(t, newRepository(t, server).History().Append(ctx, flagstest.Revision("sample", 1)))
+
+	revisions, err := newRepository(t, server).History().List(ctx, "sample")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	flagstest.AssertFlagEqual(t, flagstest.Revision("sample", 1), revisions[0])
+}
+
+func TestHistoryStore_WithPrefix(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+
+	require.NoError(t, newRepository(t, server, redis.WithPrefix("blue:")).History().
+		Append(ctx, flagstest.Revision("sample", 1)))
+
+	assert.True(t, server.Exists("{blue}:history:sample"))
+}
+
+func TestHistoryStore_ServerDown(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	store := newRepository(t, server).History()
+	ctx := context.Background()
+
+	server.Close()
+
+	require.Error(t, store.Append(ctx, flagstest.Revision("sample", 1)))
+
+	_, err := store.List(ctx, "sample")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, flags.ErrFlagNotFound)
+
+	_, err = store.Get(ctx, "sample", 1)
+	require.Error(t, err)
+
+	assert.Error(t, store.Delete(ctx, "sample"))
+}
+
+func TestHistoryStore_CorruptRevision(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	store := newRepository(t, server).History()
+
+	_, err := server.Push("{features}:history:sample", "{")
+	require.NoError(t, err)
+
+	_, err = store.List(context.Background(), "sample")
+	assert.ErrorContains(t, err, "decode revision")
+}
+
+func TestHistoryStore_UnencodableRevision(t *testing.T) {
+	t.Parallel()
+
+	store := newRepository(t, miniredis.RunT(t)).History()
+
+	revision := flagstest.Revision("sample", 1)
+	revision.Rules[0].Conditions[0].Value = make(chan int)
+
+	assert.Error(t, store.Append(context.Background(), revision))
+}
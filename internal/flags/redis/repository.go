@@ -0,0 +1,288 @@
+// Package redis implements flags.Repository on top of Redis so several server
+// replicas can share one set of flags.
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/serroba/features/internal/flags"
+)
+
+const defaultPrefix = "features:"
+
+// Each flag is a hash holding its version and JSON document. A sorted set with
+// every member at score 0 indexes the keys in lexical order for List. Every
+// mutation publishes the flag key on the changes channel.
+var (
+	createScript = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'version', ARGV[2], 'doc', ARGV[3])
+redis.call('ZADD', KEYS[2], 0, ARGV[1])
+redis.call('PUBLISH', ARGV[4], ARGV[1])
+return 1`)
+
+	updateScript = goredis.NewScript(`
+local version = redis.call('HGET', KEYS[1], 'version')
+if not version then
+	return -1
+end
+if tonumber(version) ~= tonumber(ARGV[2]) - 1 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'version', ARGV[2], 'doc', ARGV[3])
+redis.call('PUBLISH', ARGV[4], ARGV[1])
+return 1`)
+
+	deleteScript = goredis.NewScript(`
+if redis.call('DEL', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('PUBLISH', ARGV[2], ARGV[1])
+return 1`)
+)
+
+// Repository stores flags in Redis and serves Get from a
+// flags.CachedRepository. The cache entry for a key is dropped whenever any
+// replica publishes a change to it, and the whole cache is dropped whenever
+// the subscription is (re)established, since notifications may have been
+// missed meanwhile.
+type Repository struct {
+	*flags.CachedRepository
+
+	store        *store
+	cacheOptions []flags.CacheOption
+	pubsub       *goredis.PubSub
+	done         chan struct{}
+}
+
+// store talks to Redis directly, without caching.
+type store struct {
+	client goredis.UniversalClient
+	prefix string
+}
+
+type Option func(*Repository)
+
+// WithPrefix sets the prefix of every Redis key and channel the repository
+// uses. It defaults to "features:". Unless it already holds a brace, the
+// prefix, less a trailing colon, is wrapped in braces, so the default keys
+// look like "{features}:flag:<key>": Redis Cluster then hashes every key to
+// the same slot, which the scripts and transactions touching several keys
+// require. An empty prefix has no hash tag and only works with a single Redis
+// server.
+func WithPrefix(prefix string) Option {
+	return func(r *Repository) {
+		r.store.prefix = prefix
+	}
+}
+
+// WithCacheOptions configures the local read cache.
+func WithCacheOptions(opts ...flags.CacheOption) Option {
+	return func(r *Repository) {
+		r.cacheOptions = append(r.cacheOptions, opts...)
+	}
+}
+
+// New subscribes to change notifications and returns once the subscription is
+// active. The caller keeps ownership of client.
+func New(ctx context.Context, client goredis.UniversalClient, opts ...Option) (*Repository, error) {
+	r := &Repository{
+		store: &store{client: client, prefix: defaultPrefix},
+		done:  make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.store.prefix = hashTag(r.store.prefix)
+	r.CachedRepository = flags.NewCachedRepository(r.store, r.cacheOptions...)
+	r.pubsub = client.Subscribe(ctx, r.store.channel())
+
+	if _, err := r.pubsub.Receive(ctx); err != nil {
+		_ = r.pubsub.Close()
+
+		return nil, fmt.Errorf("subscribe to %s: %w", r.store.channel(), err)
+	}
+
+	go r.listen(r.pubsub.ChannelWithSubscriptions())
+
+	return r, nil
+}
+
+// Close stops listening for change notifications. It does not close the
+// Redis client.
+func (r *Repository) Close() error {
+	err := r.pubsub.Close()
+	<-r.done
+
+	return err
+}
+
+// hashTag wraps prefix, less a trailing colon, in braces, unless it is empty
+// or already holds a brace.
+func hashTag(prefix string) string {
+	name, colon := strings.CutSuffix(prefix, ":")
+	if name == "" || strings.Contains(prefix, "{") {
+		return prefix
+	}
+
+	if colon {
+		return "{" + name + "}:"
+	}
+
+	return "{" + name + "}"
+}
+
+// listen applies change notifications until the subscription is closed.
+func (r *Repository) listen(messages <-chan any) {
+	defer close(r.done)
+
+	for msg := range messages {
+		switch msg := msg.(type) {
+		case *goredis.Message:
+			r.Invalidate(flags.FlagKey(msg.Payload))
+		case *goredis.Subscription:
+			r.InvalidateAll()
+		}
+	}
+}
+
+func (s *store) Get(ctx context.Context, key flags.FlagKey) (flags.Flag, error) {
+	doc, err := s.client.HGet(ctx, s.flagKey(key), "doc").Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return flags.Flag{}, flags.ErrFlagNotFound
+	}
+
+	if err != nil {
+		return flags.Flag{}, fmt.Errorf("get flag: %w", err)
+	}
+
+	var flag flags.Flag
+	if err := json.Unmarshal(doc, &flag); err != nil {
+		return flags.Flag{}, fmt.Errorf("decode flag: %w", err)
+	}
+
+	return flag, nil
+}
+
+func (s *store) List(ctx context.Context) ([]flags.Flag, error) {
+	keys, err := s.client.ZRange(ctx, s.indexKey(), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list flags: %w", err)
+	}
+
+	pipe := s.client.Pipeline()
+
+	docs := make([]*goredis.StringCmd, len(keys))
+	for i, key := range keys {
+		docs[i] = pipe.HGet(ctx, s.flagKey(flags.FlagKey(key)), "doc")
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, goredis.Nil) {
+		return nil, fmt.Errorf("list flags: %w", err)
+	}
+
+	result := make([]flags.Flag, 0, len(keys))
+
+	for _, cmd := range docs {
+		doc, err := cmd.Bytes()
+		if errors.Is(err, goredis.Nil) {
+			continue // deleted between the two round trips
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("list flags: %w", err)
+		}
+
+		var flag flags.Flag
+		if err := json.Unmarshal(doc, &flag); err != nil {
+			return nil, fmt.Errorf("decode flag: %w", err)
+		}
+
+		result = append(result, flag)
+	}
+
+	return result, nil
+}
+
+func (s *store) Create(ctx context.Context, flag flags.Flag) error {
+	doc, err := json.Marshal(flag)
+	if err != nil {
+		return fmt.Errorf("encode flag: %w", err)
+	}
+
+	created, err := createScript.Run(ctx, s.client,
+		[]string{s.flagKey(flag.Key), s.indexKey()},
+		string(flag.Key), flag.Version, doc, s.channel(),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("create flag: %w", err)
+	}
+
+	if created == 0 {
+		return flags.ErrFlagExists
+	}
+
+	return nil
+}
+
+func (s *store) Update(ctx context.Context, flag flags.Flag) error {
+	doc, err := json.Marshal(flag)
+	if err != nil {
+		return fmt.Errorf("encode flag: %w", err)
+	}
+
+	updated, err := updateScript.Run(ctx, s.client,
+		[]string{s.flagKey(flag.Key)},
+		string(flag.Key), flag.Version, doc, s.channel(),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("update flag: %w", err)
+	}
+
+	switch updated {
+	case -1:
+		return flags.ErrFlagNotFound
+	case 0:
+		return flags.ErrVersionConflict
+	default:
+		return nil
+	}
+}
+
+func (s *store) Delete(ctx context.Context, key flags.FlagKey) error {
+	deleted, err := deleteScript.Run(ctx, s.client,
+		[]string{s.flagKey(key), s.indexKey()},
+		string(key), s.channel(),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("delete flag: %w", err)
+	}
+
+	if deleted == 0 {
+		return flags.ErrFlagNotFound
+	}
+
+	return nil
+}
+
+func (s *store) flagKey(key flags.FlagKey) string {
+	return s.prefix + "flag:" + string(key)
+}
+
+func (s *store) indexKey() string {
+	return s.prefix + "keys"
+}
+
+func (s *store) channel() string {
+	return s.prefix + "changes"
+}
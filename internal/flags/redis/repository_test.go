@@ -0,0 +1,274 @@
+package redis_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/serroba/features/internal/flags/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRepository(t *testing.T, server *miniredis.Miniredis, opts ...redis.Option) *redis.Repository {
+	t.Helper()
+
+	repo, err := redis.New(context.Background(), newClient(t, server.Addr()), opts...)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = repo.Close() })
+
+	return repo
+}
+
+// newClient returns a client that fails fast instead of retrying, so tests
+// that stop the server do not wait out the backoff.
+func newClient(t *testing.T, addr string) *goredis.Client {
+	t.Helper()
+
+	client := goredis.NewClient(&goredis.Options{Addr: addr, MaxRetries: -1})
+
+	t.Cleanup(func() { _ = client.Close() })
+
+	return client
+}
+
+// overwrite changes the stored document behind the repository's back, without
+// publishing a notification.
+func overwrite(t *testing.T, server *miniredis.Miniredis, flag flags.Flag) {
+	t.Helper()
+
+	doc, err := json.Marshal(flag)
+	require.NoError(t, err)
+
+	server.HSet("{features}:flag:"+string(flag.Key), "doc", string(doc))
+}
+
+func TestRepository_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunRepositorySuite(t, func(t *testing.T) flags.Repository {
+		t.Helper()
+
+		return newRepository(t, miniredis.RunT(t))
+	})
+}
+
+func TestRepository_ReplicasSeeEachOthersChanges(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	first := newRepository(t, server)
+	second := newRepository(t, server)
+	ctx := context.Background()
+
+	require.NoError(t, first.Create(ctx, flagstest.SampleFlag("sample")))
+
+	// Warm the second replica's cache before changing the flag on the first.
+	got, err := second.Get(ctx, "sample")
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.Version)
+
+	next := flagstest.SampleFlag("sample")
+	next.Version = 2
+	next.Enabled = false
+	require.NoError(t, first.Update(ctx, next))
+
+	assert.Eventually(t, func() bool {
+		got, err := second.Get(ctx, "sample")
+
+		return err == nil && got.Version == 2 && !got.Enabled
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, first.Delete(ctx, "sample"))
+
+	assert.Eventually(t, func() bool {
+		_, err := second.Get(ctx, "sample")
+
+		return errors.Is(err, flags.ErrFlagNotFound)
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestRepository_GetIsServedFromCacheUntilNotified(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	repo := newRepository(t, server)
+	ctx := context.Background()
+
+	// Seeding through the server keeps the repository's own notification from
+	// racing with the cache fill below.
+	overwrite(t, server, flagstest.SampleFlag("sample"))
+
+	_, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+
+	changed := flagstest.SampleFlag("sample")
+	changed.Enabled = false
+	overwrite(t, server, changed)
+
+	got, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	assert.True(t, got.Enabled, "expected the cached flag")
+
+	server.Publish("{features}:changes", "sample")
+
+	assert.Eventually(t, func() bool {
+		got, err := repo.Get(ctx, "sample")
+
+		return err == nil && !got.Enabled
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestRepository_WithCacheOptions(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	repo := newRepository(t, server, redis.WithCacheOptions(flags.WithCacheSize(0)))
+	ctx := context.Background()
+
+	overwrite(t, server, flagstest.SampleFlag("sample"))
+
+	_, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+
+	changed := flagstest.SampleFlag("sample")
+	changed.Enabled = false
+	overwrite(t, server, changed)
+
+	got, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	assert.False(t, got.Enabled, "caching is disabled")
+}
+
+func TestRepository_ResubscribeFlushesCache(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	repo := newRepository(t, server)
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, flagstest.SampleFlag("sample")))
+
+	_, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+
+	// A change made while the subscription is down is never announced.
+	server.Close()
+
+	changed := flagstest.SampleFlag("sample")
+	changed.Enabled = false
+	overwrite(t, server, changed)
+
+	require.NoError(t, server.Restart())
+
+	assert.Eventually(t, func() bool {
+		got, err := repo.Get(ctx, "sample")
+
+		return err == nil && !got.Enabled
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRepository_WithPrefix(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	blue := newRepository(t, server, redis.WithPrefix("blue:"))
+	green := newRepository(t, server, redis.WithPrefix("green:"))
+	ctx := context.Background()
+
+	require.NoError(t, blue.Create(ctx, flagstest.SampleFlag("sample")))
+
+	_, err := green.Get(ctx, "sample")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	assert.True(t, server.Exists("{blue}:flag:sample"))
+	assert.True(t, server.Exists("{blue}:keys"))
+}
+
+func TestRepository_HashTag(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"blue":     "{blue}flag:sample",
+		"blue:v2:": "{blue:v2}:flag:sample",
+		"{blue}-":  "{blue}-flag:sample",
+		"":         "flag:sample",
+	}
+
+	for prefix, key := range tests {
+		server := miniredis.RunT(t)
+		require.NoError(t, newRepository(t, server, redis.WithPrefix(prefix)).
+			Create(context.Background(), flagstest.SampleFlag("sample")))
+		assert.True(t, server.Exists(key), "prefix %q", prefix)
+	}
+}
+
+func TestNew_Unreachable(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	_, err := redis.New(context.Background(), newClient(t, addr))
+	assert.Error(t, err)
+}
+
+func TestRepository_ServerDown(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	repo := newRepository(t, server)
+	ctx := context.Background()
+
+	server.Close()
+
+	_, err := repo.Get(ctx, "sample")
+	require.Error(t, err)
+	require.NotErrorIs(t, err, flags.ErrFlagNotFound)
+
+	_, err = repo.List(ctx)
+	require.Error(t, err)
+
+	require.Error(t, repo.Create(ctx, flagstest.SampleFlag("sample")))
+	require.Error(t, repo.Update(ctx, flagstest.SampleFlag("sample")))
+	require.Error(t, repo.Delete(ctx, "sample"))
+}
+
+func TestRepository_CorruptDocument(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	repo := newRepository(t, server)
+	ctx := context.Background()
+
+	server.HSet("{features}:flag:sample", "doc", "{")
+	_, err := server.ZAdd("{features}:keys", 0, "sample")
+	require.NoError(t, err)
+
+	_, err = repo.Get(ctx, "sample")
+	require.Error(t, err)
+
+	_, err = repo.List(ctx)
+	require.Error(t, err)
+}
+
+func TestRepository_UnencodableFlag(t *testing.T) {
+	t.Parallel()
+
+	repo := newRepository(t, miniredis.RunT(t))
+	ctx := context.Background()
+
+	flag := flagstest.SampleFlag("sample")
+	flag.Rules[0].Conditions[0].Value = make(chan int)
+
+	require.Error(t, repo.Create(ctx, flag))
+	require.Error(t, repo.Update(ctx, flag))
+}
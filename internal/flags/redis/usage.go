@@ -0,0 +1,266 @@
+package redis
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/serroba/features/internal/flags"
+)
+
+// lastLayout formats LastEvaluated with a fixed width, so the add script can
+// compare timestamps as strings.
+const lastLayout = "2006-01-02T15:04:05.000000000Z"
+
+// Hash fields of a usage bucket. Each reason and variation has a counter
+// field named by its prefix and the reason or JSON encoded value.
+const (
+	fieldFlag        = "flag"
+	fieldHour        = "hour"
+	fieldLast        = "last"
+	fieldEvaluations = "evaluations"
+	reasonPrefix     = "reason:"
+	variationPrefix  = "variation:"
+)
+
+// addUsageScript indexes the bucket in KEYS[1] in the set KEYS[2] and adds
+// the counters in ARGV[4:], given as field and increment pairs. ARGV[1] and
+// ARGV[2] are the flag key and hour, and ARGV[3] the last evaluation, which
+// only moves forward.
+var addUsageScript = goredis.NewScript(`
+redis.call('SADD', KEYS[2], KEYS[1])
+redis.call('HSET', KEYS[1], 'flag', ARGV[1], 'hour', ARGV[2])
+local last = redis.call('HGET', KEYS[1], 'last')
+if not last or last < ARGV[3] then
+	redis.call('HSET', KEYS[1], 'last', ARGV[3])
+end
+for i = 4, #ARGV, 2 do
+	redis.call('HINCRBY', KEYS[1], ARGV[i], ARGV[i + 1])
+end
+return 1`)
+
+// UsageStore keeps each usage bucket as a hash of counters, so replicas
+// flushing the same flag and hour add to one bucket. A set indexes the
+// buckets; it shares the hash tag of the bucket keys, so the add script also
+// runs on Redis Cluster. Variations are listed in no particular order.
+type UsageStore struct {
+	client goredis.UniversalClient
+	prefix string
+}
+
+// Usage returns a usage store using the repository's client and prefix.
+func (r *Repository) Usage() *UsageStore {
+	return &UsageStore{client: r.store.client, prefix: r.store.prefix}
+}
+
+// storedBucket is a bucket and the key of its hash.
+type storedBucket struct {
+	key    string
+	bucket flags.UsageBucket
+}
+
+func (s *UsageStore) Add(ctx context.Context, buckets []flags.UsageBucket) error {
+	for _, bucket := range buckets {
+		args, err := usageArgs(bucket)
+		if err != nil {
+			return err
+		}
+
+		keys := []string{s.bucketKey(bucket), s.indexKey()}
+		if err := addUsageScript.Run(ctx, s.client, keys, args...).Err(); err != nil {
+			return fmt.Errorf("add usage: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *UsageStore) List(ctx context.Context, filter flags.UsageFilter) ([]flags.UsageBucket, error) {
+	stored, err := s.buckets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []flags.UsageBucket
+
+	for _, entry := range stored {
+		if (filter.FlagKey == "" || entry.bucket.FlagKey == filter.FlagKey) && !entry.bucket.Hour.Before(filter.Since) {
+			result = append(result, entry.bucket)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b flags.UsageBucket) int {
+		return cmp.Or(strings.Compare(string(a.FlagKey), string(b.FlagKey)), a.Hour.Compare(b.Hour))
+	})
+
+	return result, nil
+}
+
+func (s *UsageStore) Delete(ctx context.Context, key flags.FlagKey) error {
+	return s.remove(ctx, func(bucket flags.UsageBucket) bool { return bucket.FlagKey == key })
+}
+
+func (s *UsageStore) Prune(ctx context.Context, before time.Time) error {
+	return s.remove(ctx, func(bucket flags.UsageBucket) bool { return bucket.Hour.Before(before) })
+}
+
+// remove deletes the buckets matching match and drops them from the index.
+func (s *UsageStore) remove(ctx context.Context, match func(flags.UsageBucket) bool) error {
+	stored, err := s.buckets(ctx)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+
+	for _, entry := range stored {
+		if match(entry.bucket) {
+			keys = append(keys, entry.key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	_, err = s.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Del(ctx, keys...)
+		pipe.SRem(ctx, s.indexKey(), keys)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("delete usage: %w", err)
+	}
+
+	return nil
+}
+
+// buckets reads every indexed bucket. Buckets deleted since they were
+// indexed are skipped.
+func (s *UsageStore) buckets(ctx context.Context) ([]storedBucket, error) {
+	keys, err := s.client.SMembers(ctx, s.indexKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list usage: %w", err)
+	}
+
+	cmds := make([]*goredis.MapStringStringCmd, len(keys))
+
+	_, err = s.client.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		for i, key := range keys {
+			cmds[i] = pipe.HGetAll(ctx, key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read usage: %w", err)
+	}
+
+	stored := make([]storedBucket, 0, len(keys))
+
+	for i, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			continue
+		}
+
+		bucket, err := decodeUsage(cmd.Val())
+		if err != nil {
+			return nil, fmt.Errorf("decode usage %s: %w", keys[i], err)
+		}
+
+		stored = append(stored, storedBucket{key: keys[i], bucket: bucket})
+	}
+
+	return stored, nil
+}
+
+// usageArgs returns the arguments of addUsageScript for bucket.
+func usageArgs(bucket flags.UsageBucket) ([]any, error) {
+	args := []any{
+		string(bucket.FlagKey), bucket.Hour.UTC().Format(time.RFC3339), bucket.LastEvaluated.UTC().Format(lastLayout),
+		fieldEvaluations, bucket.Evaluations,
+	}
+
+	for reason, n := range bucket.Reasons {
+		args = append(args, reasonPrefix+string(reason), n)
+	}
+
+	for _, variation := range bucket.Variations {
+		value, err := json.Marshal(variation.Value)
+		if err != nil {
+			return nil, fmt.Errorf("encode variation: %w", err)
+		}
+
+		args = append(args, variationPrefix+string(value), variation.Count)
+	}
+
+	return args, nil
+}
+
+func decodeUsage(fields map[string]string) (flags.UsageBucket, error) {
+	bucket := flags.UsageBucket{FlagKey: flags.FlagKey(fields[fieldFlag]), Reasons: map[flags.EvalReason]int64{}}
+
+	var err error
+
+	if bucket.Hour, err = time.Parse(time.RFC3339, fields[fieldHour]); err != nil {
+		return flags.UsageBucket{}, err
+	}
+
+	if bucket.LastEvaluated, err = time.Parse(time.RFC3339Nano, fields[fieldLast]); err != nil {
+		return flags.UsageBucket{}, err
+	}
+
+	for field, value := range fields {
+		if err := decodeCounter(&bucket, field, value); err != nil {
+			return flags.UsageBucket{}, err
+		}
+	}
+
+	return bucket, nil
+}
+
+// decodeCounter sets the counter in field of bucket. Other fields are ignored.
+func decodeCounter(bucket *flags.UsageBucket, field, value string) error {
+	reason, isReason := strings.CutPrefix(field, reasonPrefix)
+	encoded, isVariation := strings.CutPrefix(field, variationPrefix)
+
+	if field != fieldEvaluations && !isReason && !isVariation {
+		return nil
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("counter %s: %w", field, err)
+	}
+
+	switch {
+	case isReason:
+		bucket.Reasons[flags.EvalReason(reason)] = n
+	case isVariation:
+		var variation flags.Value
+		if err := json.Unmarshal([]byte(encoded), &variation); err != nil {
+			return fmt.Errorf("variation %s: %w", encoded, err)
+		}
+
+		bucket.Variations = append(bucket.Variations, flags.VariationCount{Value: variation, Count: n})
+	default:
+		bucket.Evaluations = n
+	}
+
+	return nil
+}
+
+func (s *UsageStore) bucketKey(bucket flags.UsageBucket) string {
+	return s.prefix + "usage:" + string(bucket.FlagKey) + ":" + strconv.FormatInt(bucket.Hour.Unix(), 10)
+}
+
+func (s *UsageStore) indexKey() string {
+	return s.prefix + "usage"
+}
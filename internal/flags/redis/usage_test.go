@@ -0,0 +1,117 @@
+package redis_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/serroba/features/internal/flags/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var usageHour = time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+
+func TestUsageStore_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunUsageStoreSuite(t, func(t *testing.T) flags.UsageStore {
+		t.Helper()
+
+		return newRepository(t, miniredis.RunT(t)).Usage()
+	})
+}
+
+func TestUsageStore_SharedByReplicas(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+
+	require.NoError(t, newRepository(t, server).Usage().Add(ctx, []flags.UsageBucket{
+		flagstest.Bucket("checkout", usageHour, flags.BoolValue(true), 2),
+	}))
+	require.NoError(t, newRepository(t, server).Usage().Add(ctx, []flags.UsageBucket{
+		flagstest.Bucket("checkout", usageHour, flags.BoolValue(true), 3),
+	}))
+
+	buckets, err := newRepository(t, server).Usage().List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	require.Len(t, buckets, 1)
+	assert.Equal(t, int64(5), buckets[0].Evaluations)
+	assert.Equal(t, []flags.VariationCount{{Value: flags.BoolValue(true), Count: 5}}, buckets[0].Variations)
+}
+
+func TestUsageStore_WithPrefix(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+
+	require.NoError(t, newRepository(t, server, redis.WithPrefix("blue:")).Usage().Add(context.Background(),
+		[]flags.UsageBucket{flagstest.Bucket("checkout", usageHour, flags.BoolValue(true), 1)}))
+
+	assert.True(t, server.Exists("{blue}:usage"))
+	assert.True(t, server.Exists("{blue}:usage:checkout:1748851200"))
+}
+
+func TestUsageStore_ServerDown(t *testing.T) {
+	t.Parallel()
+
+	server := miniredis.RunT(t)
+	store := newRepository(t, server).Usage()
+	ctx := context.Background()
+
+	server.Close()
+
+	bucket := flagstest.Bucket("checkout", usageHour, flags.BoolValue(true), 1)
+	require.Error(t, store.Add(ctx, []flags.UsageBucket{bucket}))
+
+	_, err := store.List(ctx, flags.UsageFilter{})
+	require.Error(t, err)
+
+	require.Error(t, store.Delete(ctx, "checkout"))
+	assert.Error(t, store.Prune(ctx, usageHour))
+}
+
+func TestUsageStore_CorruptBucket(t *testing.T) {
+	t.Parallel()
+
+	for name, field := range map[string][2]string{
+		"hour":      {"hour", "x"},
+		"last":      {"last", "x"},
+		"counter":   {"evaluations", "x"},
+		"variation": {"variation:{", "1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := miniredis.RunT(t)
+			store := newRepository(t, server).Usage()
+			ctx := context.Background()
+
+			require.NoError(t, store.Add(ctx, []flags.UsageBucket{
+				flagstest.Bucket("checkout", usageHour, flags.BoolValue(true), 1),
+			}))
+
+			server.HSet("{features}:usage:checkout:1748851200", field[0], field[1])
+
+			_, err := store.List(ctx, flags.UsageFilter{})
+			require.ErrorContains(t, err, "decode usage")
+			assert.Error(t, store.Delete(ctx, "checkout"))
+		})
+	}
+}
+
+func TestUsageStore_UnencodableVariation(t *testing.T) {
+	t.Parallel()
+
+	store := newRepository(t, miniredis.RunT(t)).Usage()
+
+	assert.Error(t, store.Add(context.Background(), []flags.UsageBucket{
+		flagstest.Bucket("checkout", usageHour, flags.NumberValue(math.NaN()), 1),
+	}))
+}
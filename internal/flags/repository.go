@@ -6,11 +6,18 @@ import (
 )
 
 var (
-	ErrFlagNotFound = errors.New("flag not found")
-	ErrFlagExists   = errors.New("flag already exists")
+	ErrFlagNotFound    = errors.New("flag not found")
+	ErrFlagExists      = errors.New("flag already exists")
+	ErrVersionConflict = errors.New("flag version conflict")
 )
 
+// Repository stores flags. Implementations must return values that do not
+// alias their internal state and must list flags ordered by key.
 type Repository interface {
 	Get(ctx context.Context, key FlagKey) (Flag, error)
+	List(ctx context.Context) ([]Flag, error)
 	Create(ctx context.Context, flag Flag) error
+	// Update replaces a stored flag only if its version is flag.Version-1.
+	Update(ctx context.Context, flag Flag) error
+	Delete(ctx context.Context, key FlagKey) error
 }
@@ -0,0 +1,77 @@
+package flags
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+)
+
+var ErrRuleNotFound = errors.New("rule not found")
+
+// rolloutBuckets is how many buckets contexts are hashed into, which gives
+// rollouts a granularity of 0.001%.
+const rolloutBuckets = 100_000
+
+// Rollout limits a rule to a percentage of the contexts it matches. Contexts
+// are placed in buckets by hashing the flag key with an attribute, so a
+// context stays in or out of the rollout for as long as the percentage does
+// not change, and contexts that are in stay in when it grows.
+type Rollout struct {
+	Percentage float64 `json:"percentage"` // 0 to 100
+	// BucketBy names the attribute hashed into buckets; user_id by default.
+	// Use tenant_id to roll out to whole tenants.
+	BucketBy string `json:"bucketBy,omitempty"`
+}
+
+// includes reports whether evalCtx falls in the rollout of flag key. Contexts
+// without the attribute are never included; a nil rollout includes all.
+func (r *Rollout) includes(key FlagKey, evalCtx EvalContext) bool {
+	if r == nil {
+		return true
+	}
+
+	value := evalCtx.GetAttr(cmp.Or(r.BucketBy, "user_id"))
+	if value == nil || value == "" {
+		return false
+	}
+
+	return float64(bucket(key, fmt.Sprint(value))) < r.Percentage*rolloutBuckets/100
+}
+
+// bucket returns the bucket, in [0, rolloutBuckets), value falls in for key.
+// Hashing the key too keeps the contexts in one flag's rollout independent
+// of those in another's.
+func bucket(key FlagKey, value string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	_, _ = h.Write([]byte{'/'})
+	_, _ = h.Write([]byte(value))
+
+	return int(h.Sum32() % rolloutBuckets)
+}
+
+// withRollout returns a copy of flag whose rule ruleID is rolled out to
+// percentage, keeping the attribute it buckets by.
+func withRollout(flag Flag, ruleID string, percentage float64) (Flag, error) {
+	next := flag.Clone()
+
+	i := slices.IndexFunc(next.Rules, func(rule Rule) bool { return rule.ID == ruleID })
+	if i < 0 {
+		return Flag{}, fmt.Errorf("%w: %s", ErrRuleNotFound, ruleID)
+	}
+
+	rollout := Rollout{Percentage: percentage}
+	if current := next.Rules[i].Rollout; current != nil {
+		rollout.BucketBy = current.BucketBy
+	}
+
+	next.Rules[i].Rollout = &rollout
+
+	return next, nil
+}
+
+func validPercentage(percentage float64) bool {
+	return percentage >= 0 && percentage <= 100
+}
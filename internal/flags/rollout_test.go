@@ -0,0 +1,106 @@
+package flags_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+)
+
+func rolledOut(percentage float64, bucketBy string) flags.Flag {
+	return flags.Flag{
+		Key:          "new-checkout",
+		Enabled:      true,
+		DefaultValue: flags.BoolValue(false),
+		Rules: []flags.Rule{{
+			ID:      "everyone",
+			Value:   flags.BoolValue(true),
+			Rollout: &flags.Rollout{Percentage: percentage, BucketBy: bucketBy},
+		}},
+	}
+}
+
+func included(flag flags.Flag, users int) map[string]bool {
+	in := map[string]bool{}
+
+	for i := range users {
+		user := fmt.Sprintf("user-%d", i)
+		if flag.Evaluate(flags.EvalContext{UserID: user}).Reason == flags.ReasonRuleMatch {
+			in[user] = true
+		}
+	}
+
+	return in
+}
+
+func TestRollout_Percentage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("includes roughly the percentage of users", func(t *testing.T) {
+		t.Parallel()
+
+		assert.InDelta(t, 2500, len(included(rolledOut(25, ""), 10_000)), 200)
+	})
+
+	t.Run("keeps users in as the percentage grows", func(t *testing.T) {
+		t.Parallel()
+
+		previous := included(rolledOut(1, ""), 5_000)
+		for _, percentage := range []float64{5, 25, 50, 100} {
+			current := included(rolledOut(percentage, ""), 5_000)
+			for user := range previous {
+				assert.True(t, current[user], "%s left the rollout at %v%%", user, percentage)
+			}
+
+			previous = current
+		}
+
+		assert.Len(t, previous, 5_000)
+	})
+
+	t.Run("is stable for a user", func(t *testing.T) {
+		t.Parallel()
+
+		first := included(rolledOut(30, ""), 1_000)
+		second := included(rolledOut(30, ""), 1_000)
+		assert.Equal(t, first, second)
+	})
+
+	t.Run("includes nobody at zero", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Empty(t, included(rolledOut(0, ""), 1_000))
+	})
+
+	t.Run("skips contexts without the attribute", func(t *testing.T) {
+		t.Parallel()
+
+		result := rolledOut(100, "").Evaluate(flags.EvalContext{})
+		assert.Equal(t, flags.ReasonDefault, result.Reason)
+	})
+
+	t.Run("buckets by another attribute", func(t *testing.T) {
+		t.Parallel()
+
+		flag := rolledOut(50, "tenant_id")
+
+		var in, out int
+
+		for i := range 200 {
+			tenant := fmt.Sprintf("tenant-%d", i)
+			first := flag.Evaluate(flags.EvalContext{TenantID: tenant, UserID: "a"}).Reason
+			second := flag.Evaluate(flags.EvalContext{TenantID: tenant, UserID: "b"}).Reason
+			assert.Equal(t, first, second, "a tenant's users are in or out together")
+
+			if first == flags.ReasonRuleMatch {
+				in++
+			} else {
+				out++
+			}
+		}
+
+		assert.Positive(t, in)
+		assert.Positive(t, out)
+	})
+}
@@ -0,0 +1,236 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidSchedule = errors.New("invalid scheduled change")
+	ErrScheduleClosed  = errors.New("scheduled change is not pending")
+)
+
+// Schedule stores change to be made to its flag at change.At, which must be
+// in the future. The change runs as the caller: its audit entry names the
+// caller as the actor and links to the scheduled change.
+func (s *Service) Schedule(ctx context.Context, change ScheduledChange) (ScheduledChange, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionWrite, change.FlagKey); err != nil {
+		return ScheduledChange{}, err
+	}
+
+	now := s.clock.Now()
+	if err := change.validate(now); err != nil {
+		return ScheduledChange{}, err
+	}
+
+	current, err := s.repo.Get(ctx, change.FlagKey)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	if err := checkManager(ctx, current); err != nil {
+		return ScheduledChange{}, err
+	}
+
+	id, err := newID()
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	change.ID = id
+	change.CreatedBy = ActorFromContext(ctx)
+	change.CreatedAt = now
+	change.Status = SchedulePending
+	change.RanAt = time.Time{}
+	change.AppliedVersion = 0
+	change.Error = ""
+	change.CanceledBy = ""
+	change.CanceledAt = time.Time{}
+
+	if change.Action != ScheduleSetRules {
+		change.Rules = nil
+	}
+
+	if change.Action != ScheduleSetRollout {
+		change.RuleID = ""
+		change.Percentage = 0
+	}
+
+	s.scheduling.Lock()
+	defer s.scheduling.Unlock()
+
+	if err := s.schedules.Create(ctx, change); err != nil {
+		return ScheduledChange{}, err
+	}
+
+	return change.clone(), nil
+}
+
+func (c ScheduledChange) validate(now time.Time) error {
+	switch c.Action {
+	case ScheduleEnable, ScheduleDisable:
+	case ScheduleSetRules:
+		if err := validateRules(c.Rules); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+		}
+	case ScheduleSetRollout:
+		if c.RuleID == "" || !validPercentage(c.Percentage) {
+			return fmt.Errorf("%w: set-rollout needs a rule and a percentage from 0 to 100", ErrInvalidSchedule)
+		}
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidSchedule, c.Action)
+	}
+
+	if !c.At.After(now) {
+		return fmt.Errorf("%w: %s is not in the future", ErrInvalidSchedule, c.At.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func (s *Service) ScheduledChanges(ctx context.Context, filter ScheduleFilter) ([]ScheduledChange, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionRead, filter.FlagKey); err != nil {
+		return nil, err
+	}
+
+	return s.schedules.List(ctx, filter)
+}
+
+// CancelSchedule cancels a pending scheduled change.
+func (s *Service) CancelSchedule(ctx context.Context, id string) (ScheduledChange, error) {
+	s.scheduling.Lock()
+	defer s.scheduling.Unlock()
+
+	change, err := s.schedules.Get(ctx, id)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	if err := s.authorizer.Authorize(ctx, PermissionWrite, change.FlagKey); err != nil {
+		return ScheduledChange{}, err
+	}
+
+	if change.Status != SchedulePending {
+		return ScheduledChange{}, fmt.Errorf("%w: %s", ErrScheduleClosed, change.Status)
+	}
+
+	change.Status = ScheduleCanceled
+	change.CanceledBy = ActorFromContext(ctx)
+	change.CanceledAt = s.clock.Now()
+
+	if err := s.schedules.Update(ctx, change); err != nil {
+		return ScheduledChange{}, err
+	}
+
+	return change, nil
+}
+
+// RunSchedules applies the pending changes that are due, in the order the
+// store lists them, and returns them with their outcome. Changes missed while
+// the service was down run late, in that same order, so a restart replays
+// them deterministically. A change that cannot be applied, for example
+// because its flag was deleted or needs approval, is marked failed and does
+// not block the ones after it. Permissions were checked when the changes
+// were scheduled and are not checked again.
+func (s *Service) RunSchedules(ctx context.Context) ([]ScheduledChange, error) {
+	s.scheduling.Lock()
+	defer s.scheduling.Unlock()
+
+	pending, err := s.schedules.List(ctx, ScheduleFilter{Status: SchedulePending})
+	if err != nil {
+		return nil, fmt.Errorf("list scheduled changes: %w", err)
+	}
+
+	now := s.clock.Now()
+
+	var ran []ScheduledChange
+
+	for _, change := range pending {
+		if change.At.After(now) {
+			break
+		}
+
+		flag, err := s.runSchedule(ctx, change)
+
+		change.RanAt = now
+		if err != nil {
+			change.Status = ScheduleFailed
+			change.Error = err.Error()
+		} else {
+			change.Status = ScheduleApplied
+			change.AppliedVersion = flag.Version
+		}
+
+		if err := s.schedules.Update(ctx, change); err != nil {
+			return ran, fmt.Errorf("record scheduled change %s: %w", change.ID, err)
+		}
+
+		ran = append(ran, change)
+	}
+
+	return ran, nil
+}
+
+func (s *Service) runSchedule(ctx context.Context, change ScheduledChange) (Flag, error) {
+	ctx = withSchedule(WithActor(ctx, change.CreatedBy), change.ID)
+
+	current, err := s.repo.Get(ctx, change.FlagKey)
+	if err != nil {
+		return Flag{}, err
+	}
+
+	next := current.Clone()
+
+	switch change.Action {
+	case ScheduleEnable:
+		next.Enabled = true
+	case ScheduleDisable:
+		next.Enabled = false
+	case ScheduleSetRules:
+		next.Rules = Flag{Rules: change.Rules}.Clone().Rules
+	case ScheduleSetRollout:
+		if next, err = withRollout(current, change.RuleID, change.Percentage); err != nil {
+			return Flag{}, err
+		}
+	}
+
+	return s.update(ctx, next)
+}
+
+// WatchSchedules runs the due scheduled changes at once, to catch up on
+// those missed while the service was down, and then every interval until ctx
+// is done. Each run that applied changes or failed is passed to report.
+func (s *Service) WatchSchedules(ctx context.Context, interval time.Duration,
+	report func([]ScheduledChange, error),
+) {
+	watch(ctx, interval, s.RunSchedules, report)
+}
+
+// watch calls run at once and then every interval until ctx is done, passing
+// each result that did something or failed to report.
+func watch[T any](ctx context.Context, interval time.Duration,
+	run func(context.Context) ([]T, error), report func([]T, error),
+) {
+	tick := func() {
+		ran, err := run(ctx)
+		if len(ran) > 0 || err != nil {
+			report(ran, err)
+		}
+	}
+
+	tick()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			tick()
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package flags_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeClock returns a clock stopped at Monday 2025-06-02 08:00 UTC.
+func newFakeClock() *flagstest.Clock {
+	return flagstest.NewClock(time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC))
+}
+
+func TestService_RunSchedules(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+
+	_, err := svc.Create(context.Background(), boolFlag("promo", false))
+	require.NoError(t, err)
+
+	rules := []flags.Rule{{
+		ID:         "pro",
+		Conditions: []flags.Condition{{Attr: "plan", Op: flags.OpEquals, Value: "pro"}},
+		Value:      flags.BoolValue(true),
+	}}
+
+	enable, err := svc.Schedule(as("alice"), flags.ScheduledChange{
+		FlagKey: "promo", Action: flags.ScheduleEnable, At: clock.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, flags.SchedulePending, enable.Status)
+	assert.Equal(t, "alice", enable.CreatedBy)
+	assert.Equal(t, clock.Now(), enable.CreatedAt)
+
+	setRules, err := svc.Schedule(as("bob"), flags.ScheduledChange{
+		FlagKey: "promo", Action: flags.ScheduleSetRules, Rules: rules, At: clock.Now().Add(2 * time.Hour),
+	})
+	require.NoError(t, err)
+
+	ran, err := svc.RunSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, ran, "nothing is due yet")
+
+	clock.Advance(time.Hour)
+
+	ran, err = svc.RunSchedules(context.Background())
+	require.NoError(t, err)
+	require.Len(t, ran, 1)
+	assert.Equal(t, enable.ID, ran[0].ID)
+	assert.Equal(t, flags.ScheduleApplied, ran[0].Status)
+	assert.Equal(t, 2, ran[0].AppliedVersion)
+	assert.Equal(t, clock.Now(), ran[0].RanAt)
+
+	flag, err := svc.Get(context.Background(), "promo")
+	require.NoError(t, err)
+	assert.True(t, flag.Enabled)
+	assert.Empty(t, flag.Rules)
+
+	clock.Advance(time.Hour)
+
+	ran, err = svc.RunSchedules(context.Background())
+	require.NoError(t, err)
+	require.Len(t, ran, 1)
+	assert.Equal(t, setRules.ID, ran[0].ID)
+
+	flag, err = svc.Get(context.Background(), "promo")
+	require.NoError(t, err)
+	assert.Equal(t, rules, flag.Rules)
+
+	page, err := svc.AuditLog(context.Background(), flags.AuditFilter{FlagKey: "promo"})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 3)
+	assert.Equal(t, "alice", page.Entries[1].Actor)
+	assert.Equal(t, enable.ID, page.Entries[1].ScheduleID)
+	assert.Equal(t, "bob", page.Entries[2].Actor)
+	assert.Equal(t, setRules.ID, page.Entries[2].ScheduleID)
+
+	applied, err := svc.ScheduledChanges(context.Background(), flags.ScheduleFilter{Status: flags.ScheduleApplied})
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+}
+
+func TestService_RunSchedules_CatchesUpInOrder(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+
+	_, err := svc.Create(context.Background(), boolFlag("maintenance", false))
+	require.NoError(t, err)
+
+	schedule := func(action flags.ScheduleAction, at time.Duration) flags.ScheduledChange {
+		change, err := svc.Schedule(context.Background(), flags.ScheduledChange{
+			FlagKey: "maintenance", Action: action, At: clock.Now().Add(at),
+		})
+		require.NoError(t, err)
+
+		return change
+	}
+
+	// Scheduled out of order; they must run by time.
+	off := schedule(flags.ScheduleDisable, 3*time.Hour)
+	on := schedule(flags.ScheduleEnable, time.Hour)
+	later := schedule(flags.ScheduleEnable, 5*time.Hour)
+
+	// The server was down for four hours.
+	clock.Advance(4 * time.Hour)
+
+	ran, err := svc.RunSchedules(context.Background())
+	require.NoError(t, err)
+	require.Len(t, ran, 2)
+	assert.Equal(t, on.ID, ran[0].ID)
+	assert.Equal(t, off.ID, ran[1].ID)
+	assert.True(t, ran[0].RanAt.After(ran[0].At), "missed changes record when they actually ran")
+
+	flag, err := svc.Get(context.Background(), "maintenance")
+	require.NoError(t, err)
+	assert.False(t, flag.Enabled)
+	assert.Equal(t, 3, flag.Version)
+
+	pending, err := svc.ScheduledChanges(context.Background(), flags.ScheduleFilter{Status: flags.SchedulePending})
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, later.ID, pending[0].ID)
+}
+
+func TestService_RunSchedules_Failures(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+
+	_, err := svc.Create(context.Background(), boolFlag("doomed", false))
+	require.NoError(t, err)
+
+	protected := boolFlag("guarded", false)
+	protected.Protected = true
+	_, err = svc.Create(context.Background(), protected)
+	require.NoError(t, err)
+
+	for _, key := range []flags.FlagKey{"doomed", "guarded"} {
+		_, err = svc.Schedule(as("alice"), flags.ScheduledChange{
+			FlagKey: key, Action: flags.ScheduleEnable, At: clock.Now().Add(time.Minute),
+		})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, svc.Delete(context.Background(), "doomed"))
+	clock.Advance(time.Minute)
+
+	ran, err := svc.RunSchedules(context.Background())
+	require.NoError(t, err)
+	require.Len(t, ran, 2)
+
+	byKey := map[flags.FlagKey]flags.ScheduledChange{ran[0].FlagKey: ran[0], ran[1].FlagKey: ran[1]}
+	assert.Equal(t, flags.ScheduleFailed, byKey["doomed"].Status)
+	assert.Equal(t, flags.ErrFlagNotFound.Error(), byKey["doomed"].Error)
+	assert.Equal(t, flags.ScheduleFailed, byKey["guarded"].Status)
+	assert.Contains(t, byKey["guarded"].Error, "opened change request", "protected flags still need approval")
+
+	changes, err := svc.ChangeRequests(context.Background(), flags.ChangeFilter{FlagKey: "guarded"})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "alice", changes[0].Requester)
+}
+
+func TestService_RunSchedules_SetRollout(t *testing.T) {
+	t.Parallel()
+
+	svc, clock := newRampedService(t)
+
+	_, err := svc.Schedule(context.Background(), flags.ScheduledChange{
+		FlagKey: "checkout", Action: flags.ScheduleSetRollout, RuleID: "everyone", Percentage: 20,
+		At: clock.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	missing, err := svc.Schedule(context.Background(), flags.ScheduledChange{
+		FlagKey: "checkout", Action: flags.ScheduleSetRollout, RuleID: "missing", Percentage: 20,
+		At: clock.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	for _, invalid := range []flags.ScheduledChange{
+		{FlagKey: "checkout", Action: flags.ScheduleSetRollout, Percentage: 20},
+		{FlagKey: "checkout", Action: flags.ScheduleSetRollout, RuleID: "everyone", Percentage: 101},
+	} {
+		invalid.At = clock.Now().Add(time.Hour)
+		_, err = svc.Schedule(context.Background(), invalid)
+		require.ErrorIs(t, err, flags.ErrInvalidSchedule)
+	}
+
+	clock.Advance(time.Hour)
+
+	ran, err := svc.RunSchedules(context.Background())
+	require.NoError(t, err)
+	require.Len(t, ran, 2)
+	assert.Equal(t, flags.Rollout{Percentage: 20, BucketBy: "tenant_id"}, rollout(t, svc))
+
+	failed, err := svc.ScheduledChanges(context.Background(), flags.ScheduleFilter{Status: flags.ScheduleFailed})
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, missing.ID, failed[0].ID)
+}
+
+func TestService_Schedule_Errors(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	schedules := flags.NewMemoryScheduleStore()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock), flags.WithScheduleStore(schedules))
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, boolFlag("promo", false))
+	require.NoError(t, err)
+
+	_, err = svc.Create(flags.WithManager(ctx, flags.ManagedByFile), boolFlag("from-file", false))
+	require.NoError(t, err)
+
+	future := clock.Now().Add(time.Hour)
+
+	_, err = svc.Schedule(ctx, flags.ScheduledChange{FlagKey: "promo", Action: "explode", At: future})
+	require.ErrorIs(t, err, flags.ErrInvalidSchedule)
+
+	_, err = svc.Schedule(ctx, flags.ScheduledChange{FlagKey: "promo", Action: flags.ScheduleEnable, At: clock.Now()})
+	require.ErrorIs(t, err, flags.ErrInvalidSchedule)
+
+	_, err = svc.Schedule(ctx, flags.ScheduledChange{
+		FlagKey: "promo", Action: flags.ScheduleSetRules, At: future,
+		Rules: []flags.Rule{{ID: "late", Conditions: []flags.Condition{{Attr: "now", Op: flags.OpAfter, Value: "soon"}}}},
+	})
+	require.ErrorIs(t, err, flags.ErrInvalidSchedule)
+	require.ErrorIs(t, err, flags.ErrInvalidFlag)
+
+	_, err = svc.Schedule(ctx, flags.ScheduledChange{FlagKey: "missing", Action: flags.ScheduleEnable, At: future})
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	_, err = svc.Schedule(ctx, flags.ScheduledChange{FlagKey: "from-file", Action: flags.ScheduleEnable, At: future})
+	require.ErrorIs(t, err, flags.ErrFlagReadOnly)
+
+	change, err := svc.Schedule(ctx, flags.ScheduledChange{
+		FlagKey: "promo", Action: flags.ScheduleEnable, At: future,
+		Rules: []flags.Rule{{ID: "ignored"}}, RuleID: "ignored", Status: flags.ScheduleApplied,
+	})
+	require.NoError(t, err)
+	assert.Nil(t, change.Rules, "only set-rules keeps rules")
+	assert.Empty(t, change.RuleID, "only set-rollout keeps the rule to roll out")
+	assert.Equal(t, flags.SchedulePending, change.Status)
+
+	canceled, err := svc.CancelSchedule(as("bob"), change.ID)
+	require.NoError(t, err)
+	assert.Equal(t, flags.ScheduleCanceled, canceled.Status)
+	assert.Equal(t, "bob", canceled.CanceledBy)
+
+	_, err = svc.CancelSchedule(ctx, change.ID)
+	require.ErrorIs(t, err, flags.ErrScheduleClosed)
+
+	_, err = svc.CancelSchedule(ctx, "missing")
+	require.ErrorIs(t, err, flags.ErrScheduleNotFound)
+
+	clock.Advance(2 * time.Hour)
+
+	ran, err := svc.RunSchedules(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, ran, "canceled changes do not run")
+
+	viewer := flags.NewService(flags.NewMemoryRepository(),
+		flags.WithScheduleStore(schedules), flags.WithAuthorizer(grant{flags.PermissionRead}))
+
+	_, err = viewer.Schedule(ctx, flags.ScheduledChange{FlagKey: "promo", Action: flags.ScheduleEnable, At: future})
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	_, err = viewer.CancelSchedule(ctx, change.ID)
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	_, err = flags.NewService(flags.NewMemoryRepository(), flags.WithAuthorizer(grant{})).
+		ScheduledChanges(ctx, flags.ScheduleFilter{})
+	require.ErrorIs(t, err, flags.ErrForbidden)
+}
+
+func TestService_WatchSchedules(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+
+	_, err := svc.Create(context.Background(), boolFlag("promo", false))
+	require.NoError(t, err)
+
+	_, err = svc.Schedule(context.Background(), flags.ScheduledChange{
+		FlagKey: "promo", Action: flags.ScheduleEnable, At: clock.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	clock.Advance(time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var reported []flags.ScheduledChange
+
+	// The missed change runs before the first tick, which never comes.
+	svc.WatchSchedules(ctx, time.Hour, func(ran []flags.ScheduledChange, err error) {
+		require.NoError(t, err)
+
+		reported = ran
+
+		cancel()
+	})
+
+	require.Len(t, reported, 1)
+	assert.Equal(t, flags.ScheduleApplied, reported[0].Status)
+}
+
+func TestFileScheduleStore_PersistsAcrossReopen(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "schedules.json")
+	clock := newFakeClock()
+	repo := flags.NewMemoryRepository()
+
+	store, err := flags.OpenFileScheduleStore(path)
+	require.NoError(t, err)
+
+	svc := flags.NewService(repo, flags.WithClock(clock), flags.WithScheduleStore(store))
+
+	_, err = svc.Create(context.Background(), boolFlag("promo", false))
+	require.NoError(t, err)
+
+	for _, at := range []time.Duration{2 * time.Hour, time.Hour} {
+		_, err = svc.Schedule(context.Background(), flags.ScheduledChange{
+			FlagKey: "promo", Action: flags.ScheduleSetRules, At: clock.Now().Add(at),
+			Rules: []flags.Rule{{ID: "all", Value: flags.BoolValue(true)}},
+		})
+		require.NoError(t, err)
+	}
+
+	// Restart after both were due.
+	clock.Advance(3 * time.Hour)
+
+	reopened, err := flags.OpenFileScheduleStore(path)
+	require.NoError(t, err)
+
+	pending, err := reopened.List(context.Background(), flags.ScheduleFilter{Status: flags.SchedulePending})
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	assert.True(t, pending[0].At.Before(pending[1].At))
+	assert.Equal(t, flags.BoolValue(true), pending[0].Rules[0].Value)
+
+	restarted := flags.NewService(repo, flags.WithClock(clock), flags.WithScheduleStore(reopened))
+
+	ran, err := restarted.RunSchedules(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, ran, 2)
+
+	again, err := flags.OpenFileScheduleStore(path)
+	require.NoError(t, err)
+
+	applied, err := again.List(context.Background(), flags.ScheduleFilter{Status: flags.ScheduleApplied})
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+
+	require.ErrorIs(t, again.Create(context.Background(), applied[0]), flags.ErrScheduleExists)
+	require.ErrorIs(t, again.Update(context.Background(), flags.ScheduledChange{ID: "missing"}),
+		flags.ErrScheduleNotFound)
+
+	_, err = again.Get(context.Background(), "missing")
+	require.ErrorIs(t, err, flags.ErrScheduleNotFound)
+}
+
+func TestFileScheduleStore_WritesFailAfterClose(t *testing.T) {
+	t.Parallel()
+
+	store, err := flags.OpenFileScheduleStore(filepath.Join(t.TempDir(), "schedules.json"))
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	ctx := context.Background()
+	assert.ErrorIs(t, store.Create(ctx, flags.ScheduledChange{ID: "a"}), flags.ErrStoreClosed)
+}
+
+func TestFileScheduleStore_Errors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.json")
+	require.NoError(t, os.WriteFile(corrupt, []byte("{"), 0o600))
+
+	_, err := flags.OpenFileScheduleStore(corrupt)
+	require.Error(t, err)
+
+	_, err = flags.OpenFileScheduleStore(dir)
+	require.Error(t, err)
+
+	store, err := flags.OpenFileScheduleStore(filepath.Join(dir, "missing", "schedules.json"))
+	require.NoError(t, err)
+
+	require.Error(t, store.Create(context.Background(), flags.ScheduledChange{ID: "a"}))
+
+	_, err = store.Get(context.Background(), "a")
+	require.ErrorIs(t, err, flags.ErrScheduleNotFound, "failed writes are undone")
+}
@@ -0,0 +1,238 @@
+package flags
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("scheduled change not found")
+	ErrScheduleExists   = errors.New("scheduled change already exists")
+)
+
+type ScheduleAction string
+
+const (
+	ScheduleEnable   ScheduleAction = "enable"
+	ScheduleDisable  ScheduleAction = "disable"
+	ScheduleSetRules ScheduleAction = "set-rules"
+	// ScheduleSetRollout rolls rule RuleID out to Percentage.
+	ScheduleSetRollout ScheduleAction = "set-rollout"
+)
+
+type ScheduleStatus string
+
+const (
+	SchedulePending  ScheduleStatus = "pending"
+	ScheduleApplied  ScheduleStatus = "applied"
+	ScheduleFailed   ScheduleStatus = "failed"
+	ScheduleCanceled ScheduleStatus = "canceled"
+)
+
+// ScheduledChange is a mutation of a flag to be made at a future time. Rules
+// replaces the flag's rules for ScheduleSetRules; RuleID and Percentage say
+// which rule ScheduleSetRollout rolls out and how far. They are ignored for
+// other actions.
+type ScheduledChange struct {
+	ID         string         `json:"id"`
+	FlagKey    FlagKey        `json:"flagKey"`
+	Action     ScheduleAction `json:"action"`
+	Rules      []Rule         `json:"rules,omitempty"`
+	RuleID     string         `json:"ruleId,omitempty"`
+	Percentage float64        `json:"percentage,omitempty"`
+	At         time.Time      `json:"at"`
+	CreatedBy  string         `json:"createdBy"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	Status     ScheduleStatus `json:"status"`
+	// RanAt is when the change was applied or failed, which is later than At
+	// when the schedule was missed.
+	RanAt          time.Time `json:"ranAt,omitzero"`
+	AppliedVersion int       `json:"appliedVersion,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	CanceledBy     string    `json:"canceledBy,omitempty"`
+	CanceledAt     time.Time `json:"canceledAt,omitzero"`
+}
+
+type ScheduleFilter struct {
+	FlagKey FlagKey
+	Status  ScheduleStatus
+}
+
+func (f ScheduleFilter) matches(change ScheduledChange) bool {
+	return (f.FlagKey == "" || change.FlagKey == f.FlagKey) && (f.Status == "" || change.Status == f.Status)
+}
+
+// ScheduleStore keeps scheduled changes. List returns them in the order they
+// run: by At, then by creation time, then by ID.
+type ScheduleStore interface {
+	Create(ctx context.Context, change ScheduledChange) error
+	Get(ctx context.Context, id string) (ScheduledChange, error)
+	List(ctx context.Context, filter ScheduleFilter) ([]ScheduledChange, error)
+	Update(ctx context.Context, change ScheduledChange) error
+}
+
+// WithScheduleStore replaces the default in-memory scheduled change store.
+func WithScheduleStore(store ScheduleStore) Option {
+	return func(s *Service) {
+		s.schedules = store
+	}
+}
+
+type MemoryScheduleStore struct {
+	mu      sync.RWMutex
+	changes []ScheduledChange
+}
+
+func NewMemoryScheduleStore() *MemoryScheduleStore {
+	return &MemoryScheduleStore{}
+}
+
+func (s *MemoryScheduleStore) Create(_ context.Context, change ScheduledChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index(change.ID) >= 0 {
+		return fmt.Errorf("%w: %s", ErrScheduleExists, change.ID)
+	}
+
+	s.changes = append(s.changes, change.clone())
+	slices.SortStableFunc(s.changes, compareSchedules)
+
+	return nil
+}
+
+func (s *MemoryScheduleStore) Get(_ context.Context, id string) (ScheduledChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i := s.index(id)
+	if i < 0 {
+		return ScheduledChange{}, ErrScheduleNotFound
+	}
+
+	return s.changes[i].clone(), nil
+}
+
+func (s *MemoryScheduleStore) List(_ context.Context, filter ScheduleFilter) ([]ScheduledChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []ScheduledChange
+
+	for _, change := range s.changes {
+		if filter.matches(change) {
+			result = append(result, change.clone())
+		}
+	}
+
+	return result, nil
+}
+
+func (s *MemoryScheduleStore) Update(_ context.Context, change ScheduledChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.index(change.ID)
+	if i < 0 {
+		return ErrScheduleNotFound
+	}
+
+	s.changes[i] = change.clone()
+	slices.SortStableFunc(s.changes, compareSchedules)
+
+	return nil
+}
+
+func (s *MemoryScheduleStore) index(id string) int {
+	return slices.IndexFunc(s.changes, func(change ScheduledChange) bool { return change.ID == id })
+}
+
+func compareSchedules(a, b ScheduledChange) int {
+	return cmp.Or(a.At.Compare(b.At), a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ID, b.ID))
+}
+
+func (c ScheduledChange) clone() ScheduledChange {
+	c.Rules = Flag{Rules: c.Rules}.Clone().Rules
+
+	return c
+}
+
+// FileScheduleStore keeps scheduled changes in memory and rewrites a JSON
+// file on every change, so pending changes survive restarts.
+type FileScheduleStore struct {
+	mu     sync.Mutex
+	path   string
+	memory *MemoryScheduleStore
+	closed bool
+}
+
+// OpenFileScheduleStore loads the scheduled changes in path, which is created
+// on the first change if it does not exist.
+func OpenFileScheduleStore(path string) (*FileScheduleStore, error) {
+	s := &FileScheduleStore{path: path, memory: NewMemoryScheduleStore()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read scheduled changes: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.memory.changes); err != nil {
+		return nil, fmt.Errorf("decode scheduled changes %s: %w", path, err)
+	}
+
+	slices.SortStableFunc(s.memory.changes, compareSchedules)
+
+	return s, nil
+}
+
+func (s *FileScheduleStore) Create(ctx context.Context, change ScheduledChange) error {
+	return s.write(func() error { return s.memory.Create(ctx, change) })
+}
+
+func (s *FileScheduleStore) Get(ctx context.Context, id string) (ScheduledChange, error) {
+	return s.memory.Get(ctx, id)
+}
+
+func (s *FileScheduleStore) List(ctx context.Context, filter ScheduleFilter) ([]ScheduledChange, error) {
+	return s.memory.List(ctx, filter)
+}
+
+func (s *FileScheduleStore) Update(ctx context.Context, change ScheduledChange) error {
+	return s.write(func() error { return s.memory.Update(ctx, change) })
+}
+
+// write applies change in memory and persists the result, undoing the change
+// when the file cannot be written.
+func (s *FileScheduleStore) write(change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	return rewriteJSON(&s.memory.mu, &s.memory.changes, s.path, "scheduled changes", change)
+}
+
+// Close waits for a write in progress to finish. Later writes fail with
+// ErrStoreClosed.
+func (s *FileScheduleStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	return nil
+}
@@ -2,32 +2,429 @@ package flags
 
 import (
 	"context"
-	"time"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
+var ErrFlagReadOnly = errors.New("flag is read-only")
+
 type Service struct {
-	repo Repository
+	repo       Repository
+	audit      AuditStore
+	history    HistoryStore
+	authorizer Authorizer
+	changes    ChangeStore
+	approvals  ApprovalPolicy
+	schedules  ScheduleStore
+	ramps      RampStore
+	clock      Clock
+	recorders  []EvaluationRecorder
+	tracer     trace.Tracer
+	usageStore UsageStore
+	usage      *usageTracker
+
+	// imports serializes imports, which plan against a snapshot of all flags.
+	imports sync.Mutex
+	// reviews serializes changes to change requests.
+	reviews sync.Mutex
+	// scheduling serializes changes to scheduled changes and rollout ramps
+	// and their runs.
+	scheduling sync.Mutex
+}
+
+type Option func(*Service)
+
+// WithAuditStore replaces the default in-memory audit log.
+func WithAuditStore(store AuditStore) Option {
+	return func(s *Service) {
+		s.audit = store
+	}
+}
+
+// WithHistoryStore replaces the default in-memory revision history.
+func WithHistoryStore(store HistoryStore) Option {
+	return func(s *Service) {
+		s.history = store
+	}
+}
+
+// EvaluationRecorder observes the evaluations served by Evaluate, for metrics
+// and usage statistics. It is called on the evaluating goroutine, so it must
+// be quick and safe for concurrent use.
+type EvaluationRecorder interface {
+	RecordEvaluation(ctx context.Context, result EvalResult)
 }
 
-func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+// WithEvaluationRecorder adds a recorder of evaluations; every recorder sees
+// every evaluation.
+func WithEvaluationRecorder(recorder EvaluationRecorder) Option {
+	return func(s *Service) {
+		s.recorders = append(s.recorders, recorder)
+	}
 }
 
+func NewService(repo Repository, opts ...Option) *Service {
+	s := &Service{
+		repo:       repo,
+		audit:      NewMemoryAuditStore(),
+		history:    NewMemoryHistoryStore(),
+		authorizer: allowAll{},
+		changes:    NewMemoryChangeStore(),
+		approvals:  ApprovalPolicy{Approvals: 1},
+		schedules:  NewMemoryScheduleStore(),
+		ramps:      NewMemoryRampStore(),
+		clock:      systemClock{},
+		tracer:     defaultTracer(),
+		usageStore: NewMemoryUsageStore(),
+		usage:      newUsageTracker(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.traceStores()
+
+	return s
+}
+
+// Create stores a new flag at version 1. When every flag is protected it
+// opens a change request instead and returns a *PendingChangeError.
 func (s *Service) Create(ctx context.Context, flag Flag) (Flag, error) {
-	flag.UpdatedAt = time.Now()
+	if err := s.authorizer.Authorize(ctx, PermissionWrite, flag.Key); err != nil {
+		return Flag{}, err
+	}
+
+	if s.needsApproval(ctx, nil) {
+		return Flag{}, s.propose(ctx, AuditCreate, nil, &flag)
+	}
+
+	return s.create(ctx, flag)
+}
+
+func (s *Service) create(ctx context.Context, flag Flag) (Flag, error) {
+	ctx, span := s.tracer.Start(ctx, "flags.Create", trace.WithAttributes(AttrFlagKey.String(string(flag.Key))))
+	defer span.End()
+
+	flag, err := s.createFlag(ctx, flag)
+
+	return flag, SpanError(span, err)
+}
+
+func (s *Service) createFlag(ctx context.Context, flag Flag) (Flag, error) {
+	flag.Version = 1
+	flag.UpdatedAt = s.clock.Now()
+	flag.ManagedBy = ManagerFromContext(ctx)
+
+	if err := s.check(flag); err != nil {
+		return Flag{}, err
+	}
 
 	if err := s.repo.Create(ctx, flag); err != nil {
 		return Flag{}, err
 	}
 
+	if err := s.commit(ctx, AuditCreate, nil, &flag); err != nil {
+		return Flag{}, err
+	}
+
 	return flag, nil
 }
 
+func (s *Service) Get(ctx context.Context, key FlagKey) (Flag, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionRead, key); err != nil {
+		return Flag{}, err
+	}
+
+	return s.repo.Get(ctx, key)
+}
+
+// List returns the flags that match filter, ordered by key.
+func (s *Service) List(ctx context.Context, filter FlagFilter) ([]Flag, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionRead, ""); err != nil {
+		return nil, err
+	}
+
+	all, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter.apply(all), nil
+}
+
+// Update replaces the flag's definition. When flag.Version is non-zero it
+// must match the stored version, otherwise ErrVersionConflict is returned.
+// Updates of protected flags open a change request instead and return a
+// *PendingChangeError. Like every change, an update that fails the flag's
+// new tests returns a *TestsFailedError.
+func (s *Service) Update(ctx context.Context, flag Flag) (Flag, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionWrite, flag.Key); err != nil {
+		return Flag{}, err
+	}
+
+	return s.update(ctx, flag)
+}
+
+func (s *Service) update(ctx context.Context, flag Flag) (Flag, error) {
+	current, err := s.repo.Get(ctx, flag.Key)
+	if err != nil {
+		return Flag{}, err
+	}
+
+	if flag.Version != 0 && flag.Version != current.Version {
+		return Flag{}, ErrVersionConflict
+	}
+
+	if s.needsApproval(ctx, &current) {
+		return Flag{}, s.propose(ctx, AuditUpdate, &current, &flag)
+	}
+
+	return s.replace(ctx, AuditUpdate, current, flag)
+}
+
+// Delete removes the flag and its revision history. The audit log keeps the
+// last snapshot. Deleting a protected flag opens a change request instead and
+// returns a *PendingChangeError.
+func (s *Service) Delete(ctx context.Context, key FlagKey) error {
+	if err := s.authorizer.Authorize(ctx, PermissionWrite, key); err != nil {
+		return err
+	}
+
+	return s.delete(ctx, key)
+}
+
+func (s *Service) delete(ctx context.Context, key FlagKey) error {
+	current, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if s.needsApproval(ctx, &current) {
+		return s.propose(ctx, AuditDelete, &current, nil)
+	}
+
+	return s.remove(ctx, current)
+}
+
+func (s *Service) remove(ctx context.Context, current Flag) error {
+	ctx, span := s.tracer.Start(ctx, "flags.Remove", trace.WithAttributes(AttrFlagKey.String(string(current.Key))))
+	defer span.End()
+
+	return SpanError(span, s.removeFlag(ctx, current))
+}
+
+func (s *Service) removeFlag(ctx context.Context, current Flag) error {
+	if err := checkManager(ctx, current); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, current.Key); err != nil {
+		return err
+	}
+
+	if err := s.history.Delete(ctx, current.Key); err != nil {
+		return fmt.Errorf("delete revisions: %w", err)
+	}
+
+	s.usage.drop(current.Key)
+
+	if err := s.usageStore.Delete(ctx, current.Key); err != nil {
+		return fmt.Errorf("delete usage: %w", err)
+	}
+
+	return s.commit(ctx, AuditDelete, &current, nil)
+}
+
+// Evaluate evaluates the flag for evalCtx. Time conditions are checked
+// against evalCtx.Now, or the service's clock when it is zero. Every
+// evaluation is counted in the flag's usage; see Usage.
 func (s *Service) Evaluate(ctx context.Context, key FlagKey, evalCtx EvalContext) (EvalResult, error) {
+	ctx, span := s.tracer.Start(ctx, "flags.Evaluate", trace.WithAttributes(AttrFlagKey.String(string(key))))
+	defer span.End()
+
 	flag, err := s.repo.Get(ctx, key)
 	if err != nil {
-		return EvalResult{}, err
+		return EvalResult{}, SpanError(span, err)
+	}
+
+	now := s.clock.Now()
+	if evalCtx.Now.IsZero() {
+		evalCtx.Now = now
+	}
+
+	result := s.evaluateRules(ctx, flag, evalCtx)
+
+	span.SetAttributes(AttrReason.String(string(result.Reason)), AttrRuleID.String(result.RuleID))
+	s.usage.record(result, now)
+
+	for _, recorder := range s.recorders {
+		recorder.RecordEvaluation(ctx, result)
+	}
+
+	return result, nil
+}
+
+func (s *Service) AuditLog(ctx context.Context, filter AuditFilter) (AuditPage, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionRead, filter.FlagKey); err != nil {
+		return AuditPage{}, err
+	}
+
+	return s.audit.List(ctx, filter)
+}
+
+func (s *Service) Versions(ctx context.Context, key FlagKey) ([]Flag, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionRead, key); err != nil {
+		return nil, err
+	}
+
+	return s.history.List(ctx, key)
+}
+
+func (s *Service) Version(ctx context.Context, key FlagKey, version int) (Flag, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionRead, key); err != nil {
+		return Flag{}, err
+	}
+
+	return s.history.Get(ctx, key, version)
+}
+
+func (s *Service) DiffVersions(ctx context.Context, key FlagKey, from, to int) ([]FieldChange, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionRead, key); err != nil {
+		return nil, err
+	}
+
+	before, err := s.history.Get(ctx, key, from)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := s.history.Get(ctx, key, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return Diff(&before, &after), nil
+}
+
+// Rollback creates a new revision whose content equals revision version.
+// Rolling back a protected flag opens a change request instead and returns a
+// *PendingChangeError.
+func (s *Service) Rollback(ctx context.Context, key FlagKey, version int) (Flag, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionWrite, key); err != nil {
+		return Flag{}, err
+	}
+
+	target, err := s.history.Get(ctx, key, version)
+	if err != nil {
+		return Flag{}, err
+	}
+
+	current, err := s.repo.Get(ctx, key)
+	if err != nil {
+		return Flag{}, err
+	}
+
+	if s.needsApproval(ctx, &current) {
+		return Flag{}, s.propose(ctx, AuditRollback, &current, &target)
+	}
+
+	return s.replace(ctx, AuditRollback, current, target)
+}
+
+func (s *Service) replace(ctx context.Context, action AuditAction, current, next Flag) (Flag, error) {
+	ctx, span := s.tracer.Start(ctx, "flags.Replace", trace.WithAttributes(
+		AttrFlagKey.String(string(current.Key)), AttrAction.String(string(action))))
+	defer span.End()
+
+	next, err := s.replaceFlag(ctx, action, current, next)
+
+	return next, SpanError(span, err)
+}
+
+func (s *Service) replaceFlag(ctx context.Context, action AuditAction, current, next Flag) (Flag, error) {
+	if err := checkManager(ctx, current); err != nil {
+		return Flag{}, err
+	}
+
+	next.Key = current.Key
+	next.Version = current.Version + 1
+	next.UpdatedAt = s.clock.Now()
+	next.ManagedBy = ManagerFromContext(ctx)
+
+	if err := s.check(next); err != nil {
+		return Flag{}, err
+	}
+
+	if err := s.repo.Update(ctx, next); err != nil {
+		return Flag{}, err
+	}
+
+	if err := s.commit(ctx, action, &current, &next); err != nil {
+		return Flag{}, err
+	}
+
+	return next, nil
+}
+
+// checkManager rejects writes to a managed flag unless they come from its
+// manager. Any manager may take over a flag that has none.
+func checkManager(ctx context.Context, current Flag) error {
+	if current.ManagedBy != "" && current.ManagedBy != ManagerFromContext(ctx) {
+		return fmt.Errorf("%w: managed by %s", ErrFlagReadOnly, current.ManagedBy)
+	}
+
+	return nil
+}
+
+// commit records a successful mutation in the revision history and the audit
+// log. after is nil when the flag no longer exists.
+func (s *Service) commit(ctx context.Context, action AuditAction, before, after *Flag) error {
+	key := FlagKey("")
+
+	switch {
+	case after != nil:
+		key = after.Key
+	case before != nil:
+		key = before.Key
+	}
+
+	ctx, span := s.tracer.Start(ctx, "flags.Commit", trace.WithAttributes(
+		AttrFlagKey.String(string(key)), AttrAction.String(string(action))))
+	defer span.End()
+
+	return SpanError(span, s.record(ctx, action, key, before, after))
+}
+
+func (s *Service) record(ctx context.Context, action AuditAction, key FlagKey, before, after *Flag) error {
+	if after != nil {
+		if err := s.history.Append(ctx, *after); err != nil {
+			return fmt.Errorf("record revision: %w", err)
+		}
+	}
+
+	approval, _ := approvalFromContext(ctx)
+
+	_, err := s.audit.Append(ctx, AuditEntry{
+		Time:       s.clock.Now(),
+		Actor:      ActorFromContext(ctx),
+		Action:     action,
+		FlagKey:    key,
+		Before:     before,
+		After:      after,
+		Diff:       Diff(before, after),
+		RequestID:  RequestIDFromContext(ctx),
+		ChangeID:   approval.changeID,
+		Emergency:  EmergencyFromContext(ctx),
+		ScheduleID: scheduleFromContext(ctx),
+		RampID:     rampFromContext(ctx),
+	})
+	if err != nil {
+		return fmt.Errorf("record audit entry: %w", err)
 	}
 
-	return flag.Evaluate(evalCtx), nil
+	return nil
 }
@@ -2,7 +2,9 @@ package flags_test
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/serroba/features/internal/flags"
 	"github.com/stretchr/testify/assert"
@@ -12,8 +14,8 @@ import (
 func TestService_Create(t *testing.T) {
 	t.Parallel()
 
-	repo := flags.NewMemoryRepository()
-	svc := flags.NewService(repo)
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
 	ctx := context.Background()
 
 	input := flags.Flag{
@@ -27,7 +29,7 @@ func TestService_Create(t *testing.T) {
 	require.NoError(t, err)
 
 	assert.Equal(t, flags.FlagKey("new-feature"), created.Key)
-	assert.False(t, created.UpdatedAt.IsZero())
+	assert.Equal(t, clock.Now(), created.UpdatedAt)
 }
 
 func TestService_Create_Duplicate(t *testing.T) {
@@ -331,6 +333,91 @@ func TestService_Evaluate_RuleMatch_StartsWith(t *testing.T) {
 	assert.Equal(t, flags.ReasonDefault, result.Reason)
 }
 
+func TestService_Evaluate_TimeWindow(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock() // Monday 2025-06-02 08:00 UTC
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+	ctx := context.Background()
+
+	flag := flags.Flag{
+		Key:          "maintenance",
+		Type:         flags.FlagBool,
+		Enabled:      true,
+		DefaultValue: flags.BoolValue(false),
+		Rules: []flags.Rule{
+			{
+				ID: "nightly",
+				Conditions: []flags.Condition{{Attr: "now", Op: flags.OpWindow, Value: map[string]any{
+					"days": []any{"mon", "tue"}, "from": "10:00", "to": "11:00", "tz": "Europe/Amsterdam",
+				}}},
+				Value: flags.BoolValue(true),
+			},
+		},
+	}
+	_, err := svc.Create(ctx, flag)
+	require.NoError(t, err)
+
+	result, err := svc.Evaluate(ctx, "maintenance", flags.EvalContext{})
+	require.NoError(t, err)
+	assert.Equal(t, flags.ReasonRuleMatch, result.Reason, "08:00 UTC is 10:00 in Amsterdam")
+	assert.Equal(t, clock.Now(), result.EvaluatedAt)
+
+	clock.Advance(time.Hour)
+
+	result, err = svc.Evaluate(ctx, "maintenance", flags.EvalContext{})
+	require.NoError(t, err)
+	assert.Equal(t, flags.ReasonDefault, result.Reason)
+
+	result, err = svc.Evaluate(ctx, "maintenance", flags.EvalContext{Now: clock.Now().Add(23 * time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, flags.ReasonRuleMatch, result.Reason, "an explicit time wins over the clock")
+}
+
+func TestService_RejectsInvalidTimeConditions(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	ctx := context.Background()
+
+	created := newProtectedFlag(t, svc)
+
+	for _, cond := range []flags.Condition{
+		{Attr: "now", Op: flags.OpBefore, Value: "tomorrow"},
+		{Attr: "now", Op: flags.OpAfter, Value: float64(1)},
+		{Attr: "now", Op: flags.OpBetween, Value: []any{"2025-06-01T00:00:00Z"}},
+		{Attr: "now", Op: flags.OpBetween, Value: []any{"2025-06-02T00:00:00Z", "2025-06-01T00:00:00Z"}},
+		{Attr: "now", Op: flags.OpWindow, Value: map[string]any{"tz": "Mars/Olympus_Mons"}},
+		{Attr: "now", Op: flags.OpWindow, Value: map[string]any{"to": "25:00"}},
+		{Attr: "now", Op: flags.OpWindow, Value: map[string]any{"days": []any{"someday"}}},
+	} {
+		flag := boolFlag("timed", true)
+		flag.Rules = []flags.Rule{{ID: "timed", Conditions: []flags.Condition{cond}, Value: flags.BoolValue(true)}}
+
+		_, err := svc.Create(ctx, flag)
+		require.ErrorIs(t, err, flags.ErrInvalidFlag, "%v", cond.Value)
+
+		proposed := created
+		proposed.Rules = flag.Rules
+
+		_, err = svc.Update(as("alice"), proposed)
+		require.ErrorIs(t, err, flags.ErrInvalidFlag, "a change request must be valid too")
+	}
+
+	valid := boolFlag("timed", true)
+	valid.Rules = []flags.Rule{{
+		ID: "timed",
+		Conditions: []flags.Condition{
+			{Attr: "now", Op: flags.OpBetween, Value: []any{"2025-06-01T00:00:00Z", "2025-06-02T00:00:00+02:00"}},
+			{Attr: "now", Op: flags.OpWindow, Value: map[string]any{"days": []any{"Mon"}, "to": "24:00", "tz": "Asia/Tokyo"}},
+		},
+		Value: flags.BoolValue(true),
+	}}
+
+	_, err := svc.Create(ctx, valid)
+	assert.NoError(t, err)
+}
+
 func TestService_Evaluate_StringValue(t *testing.T) {
 	t.Parallel()
 
@@ -419,3 +506,422 @@ func TestService_Evaluate_NumberValue(t *testing.T) {
 	assert.Equal(t, flags.ReasonDefault, result.Reason)
 	assert.InDelta(t, float64(100), *result.Value.Number, 0.001)
 }
+
+func TestService_Create_RecordsAudit(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(),
+		flags.WithAuditStore(flags.NewMemoryAuditStore()), flags.WithClock(clock))
+	ctx := flags.WithRequestID(flags.WithActor(context.Background(), "alice"), "req-1")
+
+	created, err := svc.Create(ctx, flags.Flag{
+		Key:          "audited",
+		Type:         flags.FlagBool,
+		Enabled:      true,
+		DefaultValue: flags.BoolValue(false),
+	})
+	require.NoError(t, err)
+
+	page, err := svc.AuditLog(ctx, flags.AuditFilter{FlagKey: "audited"})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 1)
+
+	entry := page.Entries[0]
+	assert.Equal(t, flags.AuditCreate, entry.Action)
+	assert.Equal(t, "alice", entry.Actor)
+	assert.Equal(t, "req-1", entry.RequestID)
+	assert.Nil(t, entry.Before)
+	assert.Equal(t, &created, entry.After)
+	assert.Equal(t, flags.Diff(nil, &created), entry.Diff)
+	assert.Equal(t, clock.Now(), entry.Time)
+}
+
+func TestService_Create_AnonymousActor(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, flags.Flag{Key: "anon", Type: flags.FlagBool})
+	require.NoError(t, err)
+
+	page, err := svc.AuditLog(ctx, flags.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 1)
+	assert.Equal(t, flags.AnonymousActor, page.Entries[0].Actor)
+	assert.Empty(t, page.Entries[0].RequestID)
+}
+
+func TestService_Create_DuplicateIsNotAudited(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, flags.Flag{Key: "dup", Type: flags.FlagBool})
+	require.NoError(t, err)
+
+	_, err = svc.Create(ctx, flags.Flag{Key: "dup", Type: flags.FlagBool})
+	require.ErrorIs(t, err, flags.ErrFlagExists)
+
+	page, err := svc.AuditLog(ctx, flags.AuditFilter{})
+	require.NoError(t, err)
+	assert.Len(t, page.Entries, 1)
+}
+
+type failingAuditStore struct {
+	flags.AuditStore
+}
+
+func (failingAuditStore) Append(context.Context, flags.AuditEntry) (flags.AuditEntry, error) {
+	return flags.AuditEntry{}, errors.New("disk full")
+}
+
+func TestService_Create_AuditFailure(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithAuditStore(failingAuditStore{}))
+
+	_, err := svc.Create(context.Background(), flags.Flag{Key: "flag-a", Type: flags.FlagBool})
+	assert.ErrorContains(t, err, "disk full")
+}
+
+func newVersionedFlag(t *testing.T, svc *flags.Service) flags.Flag {
+	t.Helper()
+
+	created, err := svc.Create(context.Background(), flags.Flag{
+		Key:          "versioned",
+		Type:         flags.FlagBool,
+		Enabled:      true,
+		DefaultValue: flags.BoolValue(false),
+		Rules: []flags.Rule{
+			{
+				ID:         "beta",
+				Conditions: []flags.Condition{{Attr: "plan", Op: flags.OpEquals, Value: "beta"}},
+				Value:      flags.BoolValue(true),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	return created
+}
+
+func TestService_Create_StartsAtVersionOne(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newVersionedFlag(t, svc)
+
+	assert.Equal(t, 1, created.Version)
+
+	revisions, err := svc.Versions(context.Background(), "versioned")
+	require.NoError(t, err)
+	assert.Equal(t, []flags.Flag{created}, revisions)
+}
+
+func TestService_Get(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newVersionedFlag(t, svc)
+
+	got, err := svc.Get(context.Background(), "versioned")
+	require.NoError(t, err)
+	assert.Equal(t, created, got)
+}
+
+func TestService_Update(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newVersionedFlag(t, svc)
+	ctx := context.Background()
+
+	next := created
+	next.Rules = nil
+	next.Version = 0
+
+	updated, err := svc.Update(ctx, next)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, updated.Version)
+	assert.Empty(t, updated.Rules)
+	assert.False(t, updated.UpdatedAt.Before(created.UpdatedAt))
+
+	page, err := svc.AuditLog(ctx, flags.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 2)
+	assert.Equal(t, flags.AuditUpdate, page.Entries[1].Action)
+	assert.Equal(t, &created, page.Entries[1].Before)
+	assert.Equal(t, &updated, page.Entries[1].After)
+}
+
+func TestService_Update_StaleVersion(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newVersionedFlag(t, svc)
+	ctx := context.Background()
+
+	_, err := svc.Update(ctx, created)
+	require.NoError(t, err)
+
+	_, err = svc.Update(ctx, created)
+	assert.ErrorIs(t, err, flags.ErrVersionConflict)
+}
+
+func TestService_Update_NotFound(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+
+	_, err := svc.Update(context.Background(), flags.Flag{Key: "nonexistent"})
+	assert.ErrorIs(t, err, flags.ErrFlagNotFound)
+}
+
+func TestService_VersionsAndDiff(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newVersionedFlag(t, svc)
+	ctx := context.Background()
+
+	next := created
+	next.Enabled = false
+
+	_, err := svc.Update(ctx, next)
+	require.NoError(t, err)
+
+	revision, err := svc.Version(ctx, "versioned", 1)
+	require.NoError(t, err)
+	assert.True(t, revision.Enabled)
+
+	changes, err := svc.DiffVersions(ctx, "versioned", 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []flags.FieldChange{
+		{Path: "enabled", Kind: flags.ChangeModified, Before: true, After: false},
+	}, changes)
+
+	_, err = svc.DiffVersions(ctx, "versioned", 3, 1)
+	require.ErrorIs(t, err, flags.ErrRevisionNotFound)
+
+	_, err = svc.DiffVersions(ctx, "versioned", 1, 3)
+	assert.ErrorIs(t, err, flags.ErrRevisionNotFound)
+}
+
+func TestService_Rollback(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newVersionedFlag(t, svc)
+	ctx := context.Background()
+
+	broken := created
+	broken.Rules = []flags.Rule{
+		{
+			ID:         "beta",
+			Conditions: []flags.Condition{{Attr: "plan", Op: flags.OpExists}},
+			Value:      flags.BoolValue(true),
+		},
+	}
+
+	_, err := svc.Update(ctx, broken)
+	require.NoError(t, err)
+
+	restored, err := svc.Rollback(ctx, "versioned", 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, restored.Version)
+	assert.Empty(t, flags.Diff(&created, &restored))
+
+	current, err := svc.Get(ctx, "versioned")
+	require.NoError(t, err)
+	assert.Equal(t, restored, current)
+
+	page, err := svc.AuditLog(ctx, flags.AuditFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, flags.AuditRollback, page.Entries[len(page.Entries)-1].Action)
+
+	revisions, err := svc.Versions(ctx, "versioned")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 3)
+}
+
+func TestService_Rollback_UnknownRevision(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	newVersionedFlag(t, svc)
+
+	_, err := svc.Rollback(context.Background(), "versioned", 5)
+	assert.ErrorIs(t, err, flags.ErrRevisionNotFound)
+}
+
+type failingHistoryStore struct {
+	flags.HistoryStore
+}
+
+func (failingHistoryStore) Append(context.Context, flags.Flag) error {
+	return errors.New("history unavailable")
+}
+
+func TestService_Create_HistoryFailure(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithHistoryStore(failingHistoryStore{}))
+
+	_, err := svc.Create(context.Background(), flags.Flag{Key: "flag-a", Type: flags.FlagBool})
+	assert.ErrorContains(t, err, "history unavailable")
+}
+
+func TestService_List(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	ctx := context.Background()
+
+	for _, key := range []flags.FlagKey{"flag-b", "flag-a"} {
+		_, err := svc.Create(ctx, flags.Flag{Key: key, Type: flags.FlagBool})
+		require.NoError(t, err)
+	}
+
+	list, err := svc.List(ctx, flags.FlagFilter{})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, flags.FlagKey("flag-a"), list[0].Key)
+	assert.Equal(t, flags.FlagKey("flag-b"), list[1].Key)
+}
+
+func TestService_List_Filter(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	ctx := context.Background()
+
+	for _, flag := range []flags.Flag{
+		{Key: "checkout", Owner: "payments", Tags: []string{"web", "q3"}, Kind: flags.KindRelease},
+		{Key: "refunds", Owner: "payments", Tags: []string{"ops"}, Kind: flags.KindKillSwitch},
+		{Key: "search", Owner: "search", Tags: []string{"web"}, Kind: flags.KindExperiment},
+	} {
+		flag.Type = flags.FlagBool
+		_, err := svc.Create(ctx, flag)
+		require.NoError(t, err)
+	}
+
+	keys := func(filter flags.FlagFilter) []flags.FlagKey {
+		list, err := svc.List(ctx, filter)
+		require.NoError(t, err)
+
+		result := make([]flags.FlagKey, len(list))
+		for i, flag := range list {
+			result[i] = flag.Key
+		}
+
+		return result
+	}
+
+	assert.Equal(t, []flags.FlagKey{"checkout", "search"}, keys(flags.FlagFilter{Tag: "web"}))
+	assert.Equal(t, []flags.FlagKey{"checkout", "refunds"}, keys(flags.FlagFilter{Owner: "payments"}))
+	assert.Equal(t, []flags.FlagKey{"search"}, keys(flags.FlagFilter{Kind: flags.KindExperiment}))
+	assert.Equal(t, []flags.FlagKey{"checkout"}, keys(flags.FlagFilter{Tag: "web", Owner: "payments"}))
+	assert.Empty(t, keys(flags.FlagFilter{Owner: "nobody"}))
+}
+
+func TestService_Delete(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newVersionedFlag(t, svc)
+	ctx := context.Background()
+
+	_, err := svc.Update(ctx, created)
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Delete(ctx, "versioned"))
+
+	_, err = svc.Get(ctx, "versioned")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	page, err := svc.AuditLog(ctx, flags.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Entries, 3)
+	assert.Equal(t, flags.AuditDelete, page.Entries[2].Action)
+	assert.Nil(t, page.Entries[2].After)
+	assert.Equal(t, 2, page.Entries[2].Before.Version)
+
+	// A recreated flag starts a fresh history.
+	recreated := newVersionedFlag(t, svc)
+
+	revisions, err := svc.Versions(ctx, "versioned")
+	require.NoError(t, err)
+	assert.Equal(t, []flags.Flag{recreated}, revisions)
+}
+
+func TestService_Delete_NotFound(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+
+	assert.ErrorIs(t, svc.Delete(context.Background(), "missing"), flags.ErrFlagNotFound)
+}
+
+func TestService_ManagedFlagsAreReadOnly(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	fileCtx := flags.WithManager(context.Background(), flags.ManagedByFile)
+	apiCtx := context.Background()
+
+	created, err := svc.Create(fileCtx, flags.Flag{Key: "managed", Type: flags.FlagBool})
+	require.NoError(t, err)
+	assert.Equal(t, flags.ManagedByFile, created.ManagedBy)
+
+	_, err = svc.Update(apiCtx, created)
+	require.ErrorIs(t, err, flags.ErrFlagReadOnly)
+
+	_, err = svc.Rollback(apiCtx, "managed", 1)
+	require.ErrorIs(t, err, flags.ErrFlagReadOnly)
+
+	require.ErrorIs(t, svc.Delete(apiCtx, "managed"), flags.ErrFlagReadOnly)
+
+	updated, err := svc.Update(fileCtx, created)
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+
+	require.NoError(t, svc.Delete(fileCtx, "managed"))
+}
+
+func TestService_ManagerTakesOverUnmanagedFlag(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	created := newVersionedFlag(t, svc)
+	fileCtx := flags.WithManager(context.Background(), flags.ManagedByFile)
+
+	updated, err := svc.Update(fileCtx, created)
+	require.NoError(t, err)
+	assert.Equal(t, flags.ManagedByFile, updated.ManagedBy)
+
+	_, err = svc.Update(context.Background(), updated)
+	assert.ErrorIs(t, err, flags.ErrFlagReadOnly)
+}
+
+type failingDeleteHistoryStore struct {
+	*flags.MemoryHistoryStore
+}
+
+func (failingDeleteHistoryStore) Delete(context.Context, flags.FlagKey) error {
+	return errors.New("history unavailable")
+}
+
+func TestService_Delete_HistoryFailure(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository(),
+		flags.WithHistoryStore(failingDeleteHistoryStore{flags.NewMemoryHistoryStore()}))
+	newVersionedFlag(t, svc)
+
+	assert.ErrorContains(t, svc.Delete(context.Background(), "versioned"), "history unavailable")
+}
@@ -0,0 +1,56 @@
+package flags
+
+import (
+	"context"
+	"reflect"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Simulation is the result of evaluating one context against a flag as it
+// is stored and as it is proposed.
+type Simulation struct {
+	Context  EvalContext
+	Current  EvalResult
+	Proposed EvalResult
+	// Changed reports whether the proposed flag returns a different value.
+	Changed bool
+}
+
+// Simulate evaluates each context against the stored flag with proposed's
+// key and against proposed itself, without storing anything. Evaluation is
+// the same as Evaluate's; contexts without a time are evaluated at a single
+// instant so both sides see the same now.
+func (s *Service) Simulate(ctx context.Context, proposed Flag, contexts []EvalContext) ([]Simulation, error) {
+	ctx, span := s.tracer.Start(ctx, "flags.Simulate",
+		trace.WithAttributes(AttrFlagKey.String(string(proposed.Key))))
+	defer span.End()
+
+	if err := s.authorizer.Authorize(ctx, PermissionRead, proposed.Key); err != nil {
+		return nil, SpanError(span, err)
+	}
+
+	current, err := s.repo.Get(ctx, proposed.Key)
+	if err != nil {
+		return nil, SpanError(span, err)
+	}
+
+	now := s.clock.Now()
+	simulations := make([]Simulation, len(contexts))
+
+	for i, evalCtx := range contexts {
+		if evalCtx.Now.IsZero() {
+			evalCtx.Now = now
+		}
+
+		simulation := Simulation{
+			Context:  evalCtx,
+			Current:  current.Evaluate(evalCtx),
+			Proposed: proposed.Evaluate(evalCtx),
+		}
+		simulation.Changed = !reflect.DeepEqual(simulation.Current.Value, simulation.Proposed.Value)
+		simulations[i] = simulation
+	}
+
+	return simulations, nil
+}
@@ -0,0 +1,82 @@
+package flags_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func planFlag(plans ...any) flags.Flag {
+	return flags.Flag{
+		Key:          "checkout",
+		Type:         flags.FlagBool,
+		Enabled:      true,
+		DefaultValue: flags.BoolValue(false),
+		Rules: []flags.Rule{{
+			ID:         "paid",
+			Conditions: []flags.Condition{{Attr: "plan", Op: flags.OpIn, Value: plans}},
+			Value:      flags.BoolValue(true),
+		}},
+	}
+}
+
+func TestService_Simulate(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, planFlag("pro"))
+	require.NoError(t, err)
+
+	contexts := []flags.EvalContext{
+		{UserID: "a", Attrs: map[string]any{"plan": "pro"}},
+		{UserID: "b", Attrs: map[string]any{"plan": "team"}},
+		{UserID: "c", Attrs: map[string]any{"plan": "free"}},
+	}
+
+	simulations, err := svc.Simulate(ctx, planFlag("pro", "team"), contexts)
+	require.NoError(t, err)
+	require.Len(t, simulations, 3)
+
+	changed := make([]bool, len(simulations))
+	for i, simulation := range simulations {
+		changed[i] = simulation.Changed
+		assert.Equal(t, clock.Now(), simulation.Current.EvaluatedAt)
+		assert.Equal(t, clock.Now(), simulation.Proposed.EvaluatedAt)
+	}
+
+	assert.Equal(t, []bool{false, true, false}, changed)
+	assert.Equal(t, flags.ReasonDefault, simulations[1].Current.Reason)
+	assert.Equal(t, flags.ReasonRuleMatch, simulations[1].Proposed.Reason)
+
+	stored, err := svc.Get(ctx, "checkout")
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.Version, "simulating stores nothing")
+
+	disabled := planFlag("pro")
+	disabled.Enabled = false
+
+	simulations, err = svc.Simulate(ctx, disabled, contexts[:1])
+	require.NoError(t, err)
+	assert.True(t, simulations[0].Changed)
+	assert.Equal(t, flags.ReasonDisabled, simulations[0].Proposed.Reason)
+}
+
+func TestService_Simulate_Errors(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+
+	_, err := svc.Simulate(context.Background(), planFlag("pro"), []flags.EvalContext{{}})
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	denied := flags.NewService(flags.NewMemoryRepository(), flags.WithAuthorizer(grant{}))
+
+	_, err = denied.Simulate(context.Background(), planFlag("pro"), []flags.EvalContext{{}})
+	require.ErrorIs(t, err, flags.ErrForbidden)
+}
@@ -0,0 +1,131 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/serroba/features/internal/flags"
+)
+
+// HistoryStore keeps flag revisions in the history table, as JSON documents
+// in the order they were appended.
+type HistoryStore struct {
+	db *sql.DB
+}
+
+// History returns a history store sharing the repository's database. It must
+// not be used after the repository is closed.
+func (r *Repository) History() *HistoryStore {
+	return &HistoryStore{db: r.db}
+}
+
+func (s *HistoryStore) Append(ctx context.Context, revision flags.Flag) error {
+	doc, err := json.Marshal(revision)
+	if err != nil {
+		return fmt.Errorf("encode revision: %w", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO history (flag_key, version, revision) VALUES (?, ?, ?)`,
+		string(revision.Key), revision.Version, string(doc))
+	if err != nil {
+		return fmt.Errorf("insert revision: %w", err)
+	}
+
+	return nil
+}
+
+func (s *HistoryStore) List(ctx context.Context, key flags.FlagKey) ([]flags.Flag, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT revision FROM history WHERE flag_key = ? ORDER BY seq`, string(key))
+	if err != nil {
+		return nil, fmt.Errorf("select revisions: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	var revisions []flags.Flag
+
+	for rows.Next() {
+		var doc string
+		if err := rows.Scan(&doc); err != nil {
+			return nil, fmt.Errorf("scan revision: %w", err)
+		}
+
+		revision, err := decodeRevision(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select revisions: %w", err)
+	}
+
+	if len(revisions) == 0 {
+		return nil, flags.ErrFlagNotFound
+	}
+
+	return revisions, nil
+}
+
+func (s *HistoryStore) Get(ctx context.Context, key flags.FlagKey, version int) (flags.Flag, error) {
+	var doc string
+
+	err := inTx(ctx, s.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx,
+			`SELECT revision FROM history WHERE flag_key = ? AND version = ? ORDER BY seq LIMIT 1`,
+			string(key), version,
+		).Scan(&doc)
+		if errors.Is(err, sql.ErrNoRows) {
+			return missingRevision(ctx, tx, key)
+		}
+
+		if err != nil {
+			return fmt.Errorf("select revision: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return flags.Flag{}, err
+	}
+
+	return decodeRevision(doc)
+}
+
+func (s *HistoryStore) Delete(ctx context.Context, key flags.FlagKey) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM history WHERE flag_key = ?`, string(key)); err != nil {
+		return fmt.Errorf("delete revisions: %w", err)
+	}
+
+	return nil
+}
+
+// missingRevision tells a key without any history from a missing version.
+func missingRevision(ctx context.Context, tx *sql.Tx, key flags.FlagKey) error {
+	var exists bool
+
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM history WHERE flag_key = ?)`, string(key)).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check revisions: %w", err)
+	}
+
+	if exists {
+		return flags.ErrRevisionNotFound
+	}
+
+	return flags.ErrFlagNotFound
+}
+
+func decodeRevision(doc string) (flags.Flag, error) {
+	var revision flags.Flag
+	if err := json.Unmarshal([]byte(doc), &revision); err != nil {
+		return flags.Flag{}, fmt.Errorf("decode revision: %w", err)
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,87 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/serroba/features/internal/flags/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryStore_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunHistoryStoreSuite(t, func(t *testing.T) flags.HistoryStore {
+		t.Helper()
+
+		return openRepository(t, ":memory:").History()
+	})
+}
+
+func TestHistoryStore_PersistsAcrossReopen(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "features.db")
+	ctx := context.Background()
+
+	repo, err := sqlite.Open(ctx, path)
+	require.NoError(t, err)
+	require.NoError(t, repo.History().Append(ctx, flagstest.Revision("sample", 1)))
+	require.NoError(t, repo.History().Append(ctx, flagstest.Revision("sample", 2)))
+	require.NoError(t, repo.Close())
+
+	revisions, err := openRepository(t, path).History().List(ctx, "sample")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	flagstest.AssertFlagEqual(t, flagstest.Revision("sample", 2), revisions[1])
+}
+
+func TestHistoryStore_Closed(t *testing.T) {
+	t.Parallel()
+
+	repo, err := sqlite.Open(context.Background(), ":memory:")
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	store := repo.History()
+	ctx := context.Background()
+
+	require.Error(t, store.Append(ctx, flagstest.Revision("sample", 1)))
+
+	_, err = store.List(ctx, "sample")
+	require.Error(t, err)
+
+	_, err = store.Get(ctx, "sample", 1)
+	require.Error(t, err)
+
+	assert.Error(t, store.Delete(ctx, "sample"))
+}
+
+func TestHistoryStore_CorruptRevision(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "features.db")
+	store := openRepository(t, path).History()
+	ctx := context.Background()
+
+	require.NoError(t, store.Append(ctx, flagstest.Revision("sample", 1)))
+
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.ExecContext(ctx, `UPDATE history SET revision = 'x'`)
+	require.NoError(t, err)
+
+	_, err = store.List(ctx, "sample")
+	require.ErrorContains(t, err, "decode revision")
+
+	_, err = store.Get(ctx, "sample", 1)
+	assert.ErrorContains(t, err, "decode revision")
+}
@@ -0,0 +1,27 @@
+CREATE TABLE flags (
+    key           TEXT    NOT NULL PRIMARY KEY,
+    type          TEXT    NOT NULL,
+    enabled       INTEGER NOT NULL,
+    default_value TEXT    NOT NULL, -- JSON encoded flags.Value
+    version       INTEGER NOT NULL,
+    updated_at    TEXT    NOT NULL  -- RFC 3339 with nanoseconds, UTC
+);
+
+CREATE TABLE rules (
+    flag_key TEXT    NOT NULL REFERENCES flags (key) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    id       TEXT    NOT NULL,
+    value    TEXT    NOT NULL, -- JSON encoded flags.Value
+    PRIMARY KEY (flag_key, position)
+);
+
+CREATE TABLE conditions (
+    flag_key      TEXT    NOT NULL,
+    rule_position INTEGER NOT NULL,
+    position      INTEGER NOT NULL,
+    attr          TEXT    NOT NULL,
+    op            TEXT    NOT NULL,
+    value         TEXT    NOT NULL, -- JSON encoded condition value
+    PRIMARY KEY (flag_key, rule_position, position),
+    FOREIGN KEY (flag_key, rule_position) REFERENCES rules (flag_key, position) ON DELETE CASCADE
+);
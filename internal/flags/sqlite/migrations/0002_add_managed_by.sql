@@ -0,0 +1 @@
+ALTER TABLE flags ADD COLUMN managed_by TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE flags ADD COLUMN protected INTEGER NOT NULL DEFAULT 0;
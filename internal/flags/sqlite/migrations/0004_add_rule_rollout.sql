@@ -0,0 +1 @@
+ALTER TABLE rules ADD COLUMN rollout TEXT;
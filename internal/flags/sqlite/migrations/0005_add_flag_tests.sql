@@ -0,0 +1 @@
+ALTER TABLE flags ADD COLUMN tests TEXT; -- JSON encoded []flags.FlagTest, NULL when there are none
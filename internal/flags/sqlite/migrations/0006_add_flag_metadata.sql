@@ -0,0 +1,5 @@
+ALTER TABLE flags ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE flags ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE flags ADD COLUMN tags TEXT; -- JSON encoded []string, NULL when there are none
+ALTER TABLE flags ADD COLUMN kind TEXT NOT NULL DEFAULT '';
+ALTER TABLE flags ADD COLUMN expires_at TEXT; -- RFC 3339, NULL when the flag is not expected to be removed
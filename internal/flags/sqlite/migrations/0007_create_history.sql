@@ -0,0 +1,8 @@
+CREATE TABLE history (
+    seq      INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    flag_key TEXT    NOT NULL,
+    version  INTEGER NOT NULL,
+    revision TEXT    NOT NULL -- JSON encoded flags.Flag
+);
+
+CREATE INDEX history_flag_key ON history (flag_key, seq);
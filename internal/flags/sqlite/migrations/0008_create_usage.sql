@@ -0,0 +1,8 @@
+CREATE TABLE usage (
+    flag_key TEXT    NOT NULL,
+    hour     INTEGER NOT NULL, -- Unix time of the start of the hour
+    bucket   TEXT    NOT NULL, -- JSON encoded flags.UsageBucket
+    PRIMARY KEY (flag_key, hour)
+);
+
+CREATE INDEX usage_hour ON usage (hour);
@@ -0,0 +1,485 @@
+// Package sqlite implements flags.Repository on top of an SQLite database.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+)
+
+type Repository struct {
+	db *sql.DB
+}
+
+// Open opens (or creates) the database at path and applies any pending
+// migrations. Use ":memory:" for a private in-memory database.
+func Open(ctx context.Context, path string) (*Repository, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	// SQLite allows a single writer; one connection also keeps ":memory:"
+	// databases from being private to each pooled connection.
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(ctx, db); err != nil {
+		_ = db.Close()
+
+		return nil, err
+	}
+
+	return &Repository{db: db}, nil
+}
+
+func (r *Repository) Close() error {
+	return r.db.Close()
+}
+
+func (r *Repository) Get(ctx context.Context, key flags.FlagKey) (flags.Flag, error) {
+	var flag flags.Flag
+
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		var err error
+
+		flag, err = getFlag(ctx, tx, key)
+
+		return err
+	})
+
+	return flag, err
+}
+
+func (r *Repository) List(ctx context.Context) ([]flags.Flag, error) {
+	var result []flags.Flag
+
+	err := inTx(ctx, r.db, func(tx *sql.Tx) error {
+		keys, err := listKeys(ctx, tx)
+		if err != nil {
+			return err
+		}
+
+		result = make([]flags.Flag, 0, len(keys))
+
+		for _, key := range keys {
+			flag, err := getFlag(ctx, tx, key)
+			if err != nil {
+				return err
+			}
+
+			result = append(result, flag)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+func (r *Repository) Create(ctx context.Context, flag flags.Flag) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		row, err := encodeFlag(flag)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO flags (key, type, enabled, protected, default_value, version, updated_at, managed_by, tests,
+			                   description, owner, tags, kind, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (key) DO NOTHING`,
+			row.key, row.flagType, row.enabled, row.protected, row.defaultValue, row.version, row.updatedAt,
+			row.managedBy, row.tests, row.description, row.owner, row.tags, row.kind, row.expiresAt,
+		)
+		if err != nil {
+			return fmt.Errorf("insert flag: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("insert flag: %w", err)
+		}
+
+		if affected == 0 {
+			return flags.ErrFlagExists
+		}
+
+		return insertRules(ctx, tx, flag)
+	})
+}
+
+func (r *Repository) Update(ctx context.Context, flag flags.Flag) error {
+	return inTx(ctx, r.db, func(tx *sql.Tx) error {
+		row, err := encodeFlag(flag)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `
+			UPDATE flags
+			SET type = ?, enabled = ?, protected = ?, default_value = ?, version = ?, updated_at = ?, managed_by = ?,
+			    tests = ?, description = ?, owner = ?, tags = ?, kind = ?, expires_at = ?
+			WHERE key = ? AND version = ?`,
+			row.flagType, row.enabled, row.protected, row.defaultValue, row.version, row.updatedAt, row.managedBy,
+			row.tests, row.description, row.owner, row.tags, row.kind, row.expiresAt, row.key, flag.Version-1,
+		)
+		if err != nil {
+			return fmt.Errorf("update flag: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("update flag: %w", err)
+		}
+
+		if affected == 0 {
+			return missingOrConflict(ctx, tx, flag.Key)
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM rules WHERE flag_key = ?`, row.key); err != nil {
+			return fmt.Errorf("delete rules: %w", err)
+		}
+
+		return insertRules(ctx, tx, flag)
+	})
+}
+
+func (r *Repository) Delete(ctx context.Context, key flags.FlagKey) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM flags WHERE key = ?`, string(key))
+	if err != nil {
+		return fmt.Errorf("delete flag: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete flag: %w", err)
+	}
+
+	if affected == 0 {
+		return flags.ErrFlagNotFound
+	}
+
+	return nil
+}
+
+func listKeys(ctx context.Context, tx *sql.Tx) ([]flags.FlagKey, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT key FROM flags ORDER BY key`)
+	if err != nil {
+		return nil, fmt.Errorf("select flags: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	var keys []flags.FlagKey
+
+	for rows.Next() {
+		var key flags.FlagKey
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("scan flag: %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select flags: %w", err)
+	}
+
+	return keys, nil
+}
+
+func missingOrConflict(ctx context.Context, tx *sql.Tx, key flags.FlagKey) error {
+	var exists bool
+
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM flags WHERE key = ?)`, string(key)).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check flag: %w", err)
+	}
+
+	if exists {
+		return flags.ErrVersionConflict
+	}
+
+	return flags.ErrFlagNotFound
+}
+
+type flagRow struct {
+	key          string
+	flagType     string
+	enabled      bool
+	protected    bool
+	defaultValue string
+	version      int
+	updatedAt    string
+	managedBy    string
+	tests        sql.NullString
+	description  string
+	owner        string
+	tags         sql.NullString
+	kind         string
+	expiresAt    sql.NullString
+}
+
+func encodeFlag(flag flags.Flag) (flagRow, error) {
+	defaultValue, err := json.Marshal(flag.DefaultValue)
+	if err != nil {
+		return flagRow{}, fmt.Errorf("encode default value: %w", err)
+	}
+
+	var tests sql.NullString
+
+	if len(flag.Tests) > 0 {
+		data, err := json.Marshal(flag.Tests)
+		if err != nil {
+			return flagRow{}, fmt.Errorf("encode tests: %w", err)
+		}
+
+		tests = sql.NullString{String: string(data), Valid: true}
+	}
+
+	var tags sql.NullString
+
+	if len(flag.Tags) > 0 {
+		data, err := json.Marshal(flag.Tags)
+		if err != nil {
+			return flagRow{}, fmt.Errorf("encode tags: %w", err)
+		}
+
+		tags = sql.NullString{String: string(data), Valid: true}
+	}
+
+	var expiresAt sql.NullString
+
+	if !flag.ExpiresAt.IsZero() {
+		expiresAt = sql.NullString{String: flag.ExpiresAt.UTC().Format(time.RFC3339Nano), Valid: true}
+	}
+
+	return flagRow{
+		key:          string(flag.Key),
+		flagType:     string(flag.Type),
+		enabled:      flag.Enabled,
+		protected:    flag.Protected,
+		defaultValue: string(defaultValue),
+		version:      flag.Version,
+		updatedAt:    flag.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		managedBy:    flag.ManagedBy,
+		tests:        tests,
+		description:  flag.Description,
+		owner:        flag.Owner,
+		tags:         tags,
+		kind:         string(flag.Kind),
+		expiresAt:    expiresAt,
+	}, nil
+}
+
+func insertRules(ctx context.Context, tx *sql.Tx, flag flags.Flag) error {
+	for i, rule := range flag.Rules {
+		value, err := json.Marshal(rule.Value)
+		if err != nil {
+			return fmt.Errorf("encode rule value: %w", err)
+		}
+
+		var rollout sql.NullString
+
+		if rule.Rollout != nil {
+			data, err := json.Marshal(rule.Rollout)
+			if err != nil {
+				return fmt.Errorf("encode rule rollout: %w", err)
+			}
+
+			rollout = sql.NullString{String: string(data), Valid: true}
+		}
+
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO rules (flag_key, position, id, value, rollout) VALUES (?, ?, ?, ?, ?)`,
+			string(flag.Key), i, rule.ID, string(value), rollout,
+		)
+		if err != nil {
+			return fmt.Errorf("insert rule: %w", err)
+		}
+
+		for j, cond := range rule.Conditions {
+			condValue, err := json.Marshal(cond.Value)
+			if err != nil {
+				return fmt.Errorf("encode condition value: %w", err)
+			}
+
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO conditions (flag_key, rule_position, position, attr, op, value)
+				VALUES (?, ?, ?, ?, ?, ?)`,
+				string(flag.Key), i, j, cond.Attr, string(cond.Op), string(condValue),
+			)
+			if err != nil {
+				return fmt.Errorf("insert condition: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func getFlag(ctx context.Context, tx *sql.Tx, key flags.FlagKey) (flags.Flag, error) {
+	var (
+		flag                    flags.Flag
+		defaultValue, updatedAt string
+		tests, tags, expiresAt  sql.NullString
+	)
+
+	err := tx.QueryRowContext(ctx, `
+		SELECT key, type, enabled, protected, default_value, version, updated_at, managed_by, tests,
+		       description, owner, tags, kind, expires_at
+		FROM flags WHERE key = ?`,
+		string(key),
+	).Scan(&flag.Key, &flag.Type, &flag.Enabled, &flag.Protected, &defaultValue, &flag.Version, &updatedAt,
+		&flag.ManagedBy, &tests, &flag.Description, &flag.Owner, &tags, &flag.Kind, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return flags.Flag{}, flags.ErrFlagNotFound
+	}
+
+	if err != nil {
+		return flags.Flag{}, fmt.Errorf("select flag: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(defaultValue), &flag.DefaultValue); err != nil {
+		return flags.Flag{}, fmt.Errorf("decode default value: %w", err)
+	}
+
+	if flag.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+		return flags.Flag{}, fmt.Errorf("decode updated_at: %w", err)
+	}
+
+	if tests.Valid {
+		if err := json.Unmarshal([]byte(tests.String), &flag.Tests); err != nil {
+			return flags.Flag{}, fmt.Errorf("decode tests: %w", err)
+		}
+	}
+
+	if err := decodeMetadata(&flag, tags, expiresAt); err != nil {
+		return flags.Flag{}, err
+	}
+
+	if flag.Rules, err = getRules(ctx, tx, key); err != nil {
+		return flags.Flag{}, err
+	}
+
+	return flag, nil
+}
+
+func decodeMetadata(flag *flags.Flag, tags, expiresAt sql.NullString) error {
+	if tags.Valid {
+		if err := json.Unmarshal([]byte(tags.String), &flag.Tags); err != nil {
+			return fmt.Errorf("decode tags: %w", err)
+		}
+	}
+
+	if expiresAt.Valid {
+		var err error
+		if flag.ExpiresAt, err = time.Parse(time.RFC3339Nano, expiresAt.String); err != nil {
+			return fmt.Errorf("decode expires_at: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func getRules(ctx context.Context, tx *sql.Tx, key flags.FlagKey) ([]flags.Rule, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, value, rollout FROM rules WHERE flag_key = ? ORDER BY position`, string(key))
+	if err != nil {
+		return nil, fmt.Errorf("select rules: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	var rules []flags.Rule
+
+	for rows.Next() {
+		var (
+			rule    flags.Rule
+			value   string
+			rollout sql.NullString
+		)
+
+		if err := rows.Scan(&rule.ID, &value, &rollout); err != nil {
+			return nil, fmt.Errorf("scan rule: %w", err)
+		}
+
+		if err := json.Unmarshal([]byte(value), &rule.Value); err != nil {
+			return nil, fmt.Errorf("decode rule value: %w", err)
+		}
+
+		if rollout.Valid {
+			if err := json.Unmarshal([]byte(rollout.String), &rule.Rollout); err != nil {
+				return nil, fmt.Errorf("decode rule rollout: %w", err)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select rules: %w", err)
+	}
+
+	return rules, attachConditions(ctx, tx, key, rules)
+}
+
+func attachConditions(ctx context.Context, tx *sql.Tx, key flags.FlagKey, rules []flags.Rule) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT rule_position, attr, op, value FROM conditions
+		WHERE flag_key = ? ORDER BY rule_position, position`, string(key))
+	if err != nil {
+		return fmt.Errorf("select conditions: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var (
+			position int
+			cond     flags.Condition
+			value    string
+		)
+
+		if err := rows.Scan(&position, &cond.Attr, &cond.Op, &value); err != nil {
+			return fmt.Errorf("scan condition: %w", err)
+		}
+
+		if err := json.Unmarshal([]byte(value), &cond.Value); err != nil {
+			return fmt.Errorf("decode condition value: %w", err)
+		}
+
+		rules[position].Conditions = append(rules[position].Conditions, cond)
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("select conditions: %w", err)
+	}
+
+	return nil
+}
+
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
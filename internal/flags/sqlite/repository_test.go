@@ -0,0 +1,193 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/serroba/features/internal/flags/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openRepository(t *testing.T, path string) *sqlite.Repository {
+	t.Helper()
+
+	repo, err := sqlite.Open(context.Background(), path)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = repo.Close() })
+
+	return repo
+}
+
+func TestRepository_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunRepositorySuite(t, func(t *testing.T) flags.Repository {
+		t.Helper()
+
+		return openRepository(t, ":memory:")
+	})
+}
+
+func TestRepository_PersistsAcrossReopen(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "features.db")
+	ctx := context.Background()
+
+	repo, err := sqlite.Open(ctx, path)
+	require.NoError(t, err)
+	require.NoError(t, repo.Create(ctx, flagstest.SampleFlag("sample")))
+	require.NoError(t, repo.Close())
+
+	reopened := openRepository(t, path)
+
+	got, err := reopened.Get(ctx, "sample")
+	require.NoError(t, err)
+	flagstest.AssertFlagEqual(t, flagstest.SampleFlag("sample"), got)
+}
+
+func TestRepository_MigrationsAreRecorded(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "features.db")
+
+	openRepository(t, path)
+	// Opening an already migrated database must be a no-op.
+	openRepository(t, path)
+
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	var versions []int
+
+	rows, err := db.QueryContext(context.Background(), `SELECT version FROM schema_migrations ORDER BY version`)
+	require.NoError(t, err)
+
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var version int
+		require.NoError(t, rows.Scan(&version))
+
+		versions = append(versions, version)
+	}
+
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8}, versions)
+}
+
+func TestRepository_UpdateIsTransactional(t *testing.T) {
+	t.Parallel()
+
+	repo := openRepository(t, ":memory:")
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, flagstest.SampleFlag("sample")))
+
+	// A condition value that fails to encode aborts the update after the flag
+	// row was written; the previous revision must be left untouched.
+	next := flagstest.SampleFlag("sample")
+	next.Version = 2
+	next.Rules[1].Conditions[0].Value = make(chan int)
+
+	require.Error(t, repo.Update(ctx, next))
+
+	got, err := repo.Get(ctx, "sample")
+	require.NoError(t, err)
+	flagstest.AssertFlagEqual(t, flagstest.SampleFlag("sample"), got)
+}
+
+func TestOpen_InvalidPath(t *testing.T) {
+	t.Parallel()
+
+	_, err := sqlite.Open(context.Background(), filepath.Join(t.TempDir(), "missing", "features.db"))
+	assert.Error(t, err)
+}
+
+func TestRepository_Closed(t *testing.T) {
+	t.Parallel()
+
+	repo, err := sqlite.Open(context.Background(), ":memory:")
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	ctx := context.Background()
+
+	_, err = repo.Get(ctx, "sample")
+	require.Error(t, err)
+
+	_, err = repo.List(ctx)
+	require.Error(t, err)
+
+	require.Error(t, repo.Create(ctx, flagstest.SampleFlag("sample")))
+	require.Error(t, repo.Update(ctx, flagstest.SampleFlag("sample")))
+	assert.Error(t, repo.Delete(ctx, "sample"))
+}
+
+func TestRepository_UnencodableCondition(t *testing.T) {
+	t.Parallel()
+
+	repo := openRepository(t, ":memory:")
+	ctx := context.Background()
+
+	flag := flagstest.SampleFlag("sample")
+	flag.Rules[1].Conditions[0].Value = make(chan int)
+
+	require.Error(t, repo.Create(ctx, flag))
+
+	_, err := repo.Get(ctx, "sample")
+	assert.ErrorIs(t, err, flags.ErrFlagNotFound, "a failed create must leave nothing behind")
+}
+
+func TestRepository_CorruptRows(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "default value", query: `UPDATE flags SET default_value = 'x'`, want: "decode default value"},
+		{name: "updated at", query: `UPDATE flags SET updated_at = 'yesterday'`, want: "decode updated_at"},
+		{name: "rule value", query: `UPDATE rules SET value = 'x'`, want: "decode rule value"},
+		{name: "rule rollout", query: `UPDATE rules SET rollout = 'x'`, want: "decode rule rollout"},
+		{name: "tests", query: `UPDATE flags SET tests = 'x'`, want: "decode tests"},
+		{name: "tags", query: `UPDATE flags SET tags = 'x'`, want: "decode tags"},
+		{name: "expires at", query: `UPDATE flags SET expires_at = 'soon'`, want: "decode expires_at"},
+		{name: "condition value", query: `UPDATE conditions SET value = 'x'`, want: "decode condition value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "features.db")
+			repo := openRepository(t, path)
+			ctx := context.Background()
+
+			require.NoError(t, repo.Create(ctx, flagstest.SampleFlag("sample")))
+
+			db, err := sql.Open("sqlite", path)
+			require.NoError(t, err)
+
+			t.Cleanup(func() { _ = db.Close() })
+
+			_, err = db.ExecContext(ctx, tt.query)
+			require.NoError(t, err)
+
+			_, err = repo.Get(ctx, "sample")
+			require.ErrorContains(t, err, tt.want)
+
+			_, err = repo.List(ctx)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+)
+
+// UsageStore keeps one row per flag and hour in the usage table, holding the
+// bucket as a JSON document. Add merges buckets in a transaction, so servers
+// sharing the database add to the same counts.
+type UsageStore struct {
+	db *sql.DB
+}
+
+// Usage returns a usage store sharing the repository's database. It must not
+// be used after the repository is closed.
+func (r *Repository) Usage() *UsageStore {
+	return &UsageStore{db: r.db}
+}
+
+func (s *UsageStore) Add(ctx context.Context, buckets []flags.UsageBucket) error {
+	return inTx(ctx, s.db, func(tx *sql.Tx) error {
+		for _, bucket := range buckets {
+			if err := addBucket(ctx, tx, bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func addBucket(ctx context.Context, tx *sql.Tx, bucket flags.UsageBucket) error {
+	var doc string
+
+	err := tx.QueryRowContext(ctx, `SELECT bucket FROM usage WHERE flag_key = ? AND hour = ?`,
+		string(bucket.FlagKey), bucket.Hour.Unix()).Scan(&doc)
+
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return fmt.Errorf("select usage: %w", err)
+	default:
+		stored, err := decodeBucket(doc)
+		if err != nil {
+			return err
+		}
+
+		bucket = stored.Merge(bucket)
+	}
+
+	encoded, err := json.Marshal(bucket)
+	if err != nil {
+		return fmt.Errorf("encode usage: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO usage (flag_key, hour, bucket) VALUES (?, ?, ?)
+		ON CONFLICT (flag_key, hour) DO UPDATE SET bucket = excluded.bucket`,
+		string(bucket.FlagKey), bucket.Hour.Unix(), string(encoded))
+	if err != nil {
+		return fmt.Errorf("upsert usage: %w", err)
+	}
+
+	return nil
+}
+
+func (s *UsageStore) List(ctx context.Context, filter flags.UsageFilter) ([]flags.UsageBucket, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT bucket FROM usage
+		WHERE (? = '' OR flag_key = ?) AND hour >= ? ORDER BY flag_key, hour`,
+		string(filter.FlagKey), string(filter.FlagKey), filter.Since.Unix())
+	if err != nil {
+		return nil, fmt.Errorf("select usage: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	var buckets []flags.UsageBucket
+
+	for rows.Next() {
+		var doc string
+		if err := rows.Scan(&doc); err != nil {
+			return nil, fmt.Errorf("scan usage: %w", err)
+		}
+
+		bucket, err := decodeBucket(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("select usage: %w", err)
+	}
+
+	return buckets, nil
+}
+
+func (s *UsageStore) Delete(ctx context.Context, key flags.FlagKey) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM usage WHERE flag_key = ?`, string(key)); err != nil {
+		return fmt.Errorf("delete usage: %w", err)
+	}
+
+	return nil
+}
+
+func (s *UsageStore) Prune(ctx context.Context, before time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM usage WHERE hour < ?`, before.Unix()); err != nil {
+		return fmt.Errorf("prune usage: %w", err)
+	}
+
+	return nil
+}
+
+func decodeBucket(doc string) (flags.UsageBucket, error) {
+	var bucket flags.UsageBucket
+	if err := json.Unmarshal([]byte(doc), &bucket); err != nil {
+		return flags.UsageBucket{}, fmt.Errorf("decode usage: %w", err)
+	}
+
+	return bucket, nil
+}
@@ -0,0 +1,92 @@
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/serroba/features/internal/flags/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var usageHour = time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+
+func TestUsageStore_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunUsageStoreSuite(t, func(t *testing.T) flags.UsageStore {
+		t.Helper()
+
+		return openRepository(t, ":memory:").Usage()
+	})
+}
+
+func TestUsageStore_SharedByServers(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "features.db")
+	first := openRepository(t, path).Usage()
+	second := openRepository(t, path).Usage()
+	ctx := context.Background()
+
+	require.NoError(t, first.Add(ctx, []flags.UsageBucket{
+		flagstest.Bucket("checkout", usageHour, flags.BoolValue(true), 2),
+	}))
+	require.NoError(t, second.Add(ctx, []flags.UsageBucket{
+		flagstest.Bucket("checkout", usageHour, flags.BoolValue(true), 3),
+	}))
+
+	buckets, err := first.List(ctx, flags.UsageFilter{FlagKey: "checkout"})
+	require.NoError(t, err)
+	require.Len(t, buckets, 1)
+	assert.Equal(t, int64(5), buckets[0].Evaluations)
+}
+
+func TestUsageStore_Closed(t *testing.T) {
+	t.Parallel()
+
+	repo, err := sqlite.Open(context.Background(), ":memory:")
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	store := repo.Usage()
+	ctx := context.Background()
+
+	bucket := flagstest.Bucket("checkout", usageHour, flags.BoolValue(true), 1)
+	require.Error(t, store.Add(ctx, []flags.UsageBucket{bucket}))
+
+	_, err = store.List(ctx, flags.UsageFilter{})
+	require.Error(t, err)
+
+	require.Error(t, store.Delete(ctx, "checkout"))
+	assert.Error(t, store.Prune(ctx, usageHour))
+}
+
+func TestUsageStore_CorruptBucket(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "features.db")
+	store := openRepository(t, path).Usage()
+	ctx := context.Background()
+	bucket := flagstest.Bucket("checkout", usageHour, flags.BoolValue(true), 1)
+
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{bucket}))
+
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = db.ExecContext(ctx, `UPDATE usage SET bucket = 'x'`)
+	require.NoError(t, err)
+
+	_, err = store.List(ctx, flags.UsageFilter{})
+	require.ErrorContains(t, err, "decode usage")
+
+	assert.ErrorContains(t, store.Add(ctx, []flags.UsageBucket{bucket}), "decode usage")
+}
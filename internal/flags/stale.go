@@ -0,0 +1,112 @@
+package flags
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultStaleAge is how long a flag must go unchanged before StaleFlags
+// considers it when no age is given.
+const DefaultStaleAge = 30 * 24 * time.Hour
+
+// StaleKind says why a flag is stale.
+type StaleKind string
+
+const (
+	// StaleExpired flags are past their ExpiresAt.
+	StaleExpired StaleKind = "expired"
+	// StaleUnused flags were not evaluated in the period.
+	StaleUnused StaleKind = "unused"
+	// StaleSingleValue flags are temporary and served the same value to every
+	// evaluation in the period, so they can be replaced by that value.
+	StaleSingleValue StaleKind = "single_value"
+)
+
+// StaleFlag is a flag that is likely safe to remove.
+type StaleFlag struct {
+	Flag Flag
+	Kind StaleKind
+	// LastEvaluated covers all retained usage; it is zero when the flag was
+	// never evaluated in that time.
+	LastEvaluated time.Time
+	// Evaluations counts the evaluations in the period.
+	Evaluations int64
+	// Value is the value served, for StaleSingleValue flags.
+	Value Value
+}
+
+// StaleReport lists the stale flags by key. The period starts at Since.
+type StaleReport struct {
+	Since time.Time
+	Flags []StaleFlag
+}
+
+// StaleFlags reports the flags matching filter that are past their ExpiresAt,
+// or that have not changed for age and were either not evaluated in that time
+// or, for temporary flags, served a single value to every evaluation. A flag
+// is reported once, with the first of those kinds that applies. A zero age
+// means DefaultStaleAge; ages beyond UsageRetention are cut to it, since older
+// usage is not kept.
+func (s *Service) StaleFlags(ctx context.Context, age time.Duration, filter FlagFilter) (StaleReport, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionRead, ""); err != nil {
+		return StaleReport{}, err
+	}
+
+	if age <= 0 {
+		age = DefaultStaleAge
+	}
+
+	now := s.clock.Now()
+	since := now.Add(-min(age, UsageRetention)).UTC().Truncate(time.Hour)
+
+	all, err := s.repo.List(ctx)
+	if err != nil {
+		return StaleReport{}, err
+	}
+
+	stored, err := s.usageStore.List(ctx, UsageFilter{})
+	if err != nil {
+		return StaleReport{}, err
+	}
+
+	usage := map[FlagKey][]UsageBucket{}
+	for _, bucket := range mergeUsage(stored, s.usage.all()) {
+		usage[bucket.FlagKey] = append(usage[bucket.FlagKey], bucket)
+	}
+
+	report := StaleReport{Since: since, Flags: []StaleFlag{}}
+
+	for _, flag := range filter.apply(all) {
+		if stale, ok := staleFlag(flag, summarizeUsage(flag.Key, since, usage[flag.Key]), now); ok {
+			report.Flags = append(report.Flags, stale)
+		}
+	}
+
+	slices.SortFunc(report.Flags, func(a, b StaleFlag) int {
+		return strings.Compare(string(a.Flag.Key), string(b.Flag.Key))
+	})
+
+	return report, nil
+}
+
+func staleFlag(flag Flag, usage FlagUsage, now time.Time) (StaleFlag, bool) {
+	stale := StaleFlag{Flag: flag, LastEvaluated: usage.LastEvaluated, Evaluations: usage.Evaluations}
+
+	switch {
+	case !flag.ExpiresAt.IsZero() && !flag.ExpiresAt.After(now):
+		stale.Kind = StaleExpired
+	case flag.UpdatedAt.After(usage.Since):
+		return StaleFlag{}, false
+	case usage.Evaluations == 0:
+		stale.Kind = StaleUnused
+	case len(usage.Variations) == 1 && flag.Kind.Temporary():
+		stale.Kind = StaleSingleValue
+		stale.Value = usage.Variations[0].Value
+	default:
+		return StaleFlag{}, false
+	}
+
+	return stale, true
+}
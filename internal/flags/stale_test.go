@@ -0,0 +1,190 @@
+package flags_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_StaleFlags(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+	start := clock.Now()
+
+	for _, key := range []flags.FlagKey{"forgotten", "never", "settled", "varied"} {
+		_, err := svc.Create(ctx, proFlag(key))
+		require.NoError(t, err)
+	}
+
+	_, err := svc.Evaluate(ctx, "forgotten", proUser)
+	require.NoError(t, err)
+
+	clock.Advance(20 * 24 * time.Hour)
+
+	for _, evalCtx := range []flags.EvalContext{proUser, proUser} {
+		_, err := svc.Evaluate(ctx, "settled", evalCtx)
+		require.NoError(t, err)
+	}
+
+	_, err = svc.FlushUsage(ctx)
+	require.NoError(t, err)
+
+	for _, evalCtx := range []flags.EvalContext{proUser, {}} {
+		_, err := svc.Evaluate(ctx, "varied", evalCtx)
+		require.NoError(t, err)
+	}
+
+	clock.Advance(11 * 24 * time.Hour)
+
+	_, err = svc.Create(ctx, proFlag("fresh"))
+	require.NoError(t, err)
+
+	report, err := svc.StaleFlags(ctx, 0, flags.FlagFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, clock.Now().Add(-flags.DefaultStaleAge), report.Since)
+	require.Len(t, report.Flags, 3, "changed and varied flags are not stale")
+
+	forgotten := report.Flags[0]
+	assert.Equal(t, flags.FlagKey("forgotten"), forgotten.Flag.Key)
+	assert.Equal(t, flags.StaleUnused, forgotten.Kind)
+	assert.Equal(t, start, forgotten.LastEvaluated, "evaluations before the period still count as the last")
+	assert.Zero(t, forgotten.Evaluations)
+
+	never := report.Flags[1]
+	assert.Equal(t, flags.FlagKey("never"), never.Flag.Key)
+	assert.Equal(t, flags.StaleUnused, never.Kind)
+	assert.True(t, never.LastEvaluated.IsZero())
+
+	settled := report.Flags[2]
+	assert.Equal(t, flags.FlagKey("settled"), settled.Flag.Key)
+	assert.Equal(t, flags.StaleSingleValue, settled.Kind)
+	assert.Equal(t, int64(2), settled.Evaluations)
+	assert.Equal(t, flags.BoolValue(true), settled.Value)
+
+	report, err = svc.StaleFlags(ctx, 10*24*time.Hour, flags.FlagFilter{})
+	require.NoError(t, err)
+	require.Len(t, report.Flags, 4)
+
+	for _, stale := range report.Flags {
+		assert.Equal(t, flags.StaleUnused, stale.Kind, stale.Flag.Key)
+	}
+
+	report, err = svc.StaleFlags(ctx, 365*24*time.Hour, flags.FlagFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, clock.Now().Add(-flags.UsageRetention), report.Since, "usage is only kept so long")
+	assert.Empty(t, report.Flags)
+}
+
+func TestService_StaleFlags_PermanentKinds(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+
+	killSwitch := proFlag("checkout")
+	killSwitch.Owner = "payments"
+	killSwitch.Kind = flags.KindKillSwitch
+
+	unused := proFlag("beta-access")
+	unused.Owner = "growth"
+	unused.Kind = flags.KindPermission
+
+	retired := proFlag("legacy-ops")
+	retired.Owner = "sre"
+	retired.Kind = flags.KindOps
+	retired.ExpiresAt = clock.Now().Add(24 * time.Hour)
+
+	for _, flag := range []flags.Flag{killSwitch, unused, retired} {
+		_, err := svc.Create(ctx, flag)
+		require.NoError(t, err)
+	}
+
+	clock.Advance(flags.DefaultStaleAge)
+
+	evaluateTimes(t, svc, proUser, 3)
+
+	report, err := svc.StaleFlags(ctx, 0, flags.FlagFilter{})
+	require.NoError(t, err)
+	require.Len(t, report.Flags, 2, "permanent flags serving one value are not stale")
+
+	assert.Equal(t, flags.FlagKey("beta-access"), report.Flags[0].Flag.Key)
+	assert.Equal(t, flags.StaleUnused, report.Flags[0].Kind)
+	assert.Equal(t, "growth", report.Flags[0].Flag.Owner)
+
+	assert.Equal(t, flags.FlagKey("legacy-ops"), report.Flags[1].Flag.Key)
+	assert.Equal(t, flags.StaleExpired, report.Flags[1].Kind)
+	assert.Equal(t, "sre", report.Flags[1].Flag.Owner)
+}
+
+func TestService_StaleFlags_Expired(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+
+	release := proFlag("new-checkout")
+	release.Owner = "payments"
+	release.Kind = flags.KindRelease
+	release.ExpiresAt = clock.Now().Add(24 * time.Hour)
+
+	later := proFlag("new-search")
+	later.Owner = "search"
+	later.ExpiresAt = clock.Now().Add(48 * time.Hour)
+
+	for _, flag := range []flags.Flag{release, later} {
+		_, err := svc.Create(ctx, flag)
+		require.NoError(t, err)
+	}
+
+	report, err := svc.StaleFlags(ctx, 0, flags.FlagFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Flags)
+
+	clock.Advance(24 * time.Hour)
+
+	report, err = svc.StaleFlags(ctx, 0, flags.FlagFilter{})
+	require.NoError(t, err)
+	require.Len(t, report.Flags, 1, "flags are expired from their ExpiresAt on, however recently changed")
+
+	expired := report.Flags[0]
+	assert.Equal(t, flags.FlagKey("new-checkout"), expired.Flag.Key)
+	assert.Equal(t, "payments", expired.Flag.Owner)
+	assert.Equal(t, flags.StaleExpired, expired.Kind)
+
+	clock.Advance(24 * time.Hour)
+
+	report, err = svc.StaleFlags(ctx, 0, flags.FlagFilter{Owner: "search"})
+	require.NoError(t, err)
+	require.Len(t, report.Flags, 1)
+	assert.Equal(t, flags.FlagKey("new-search"), report.Flags[0].Flag.Key)
+}
+
+func TestService_StaleFlags_Errors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	_, err := flags.NewService(flags.NewMemoryRepository(), flags.WithAuthorizer(grant{})).
+		StaleFlags(ctx, 0, flags.FlagFilter{})
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	errList := errors.New("list failed")
+	repo := &failingRepository{MemoryRepository: flags.NewMemoryRepository(), listErr: errList}
+
+	_, err = flags.NewService(repo).StaleFlags(ctx, 0, flags.FlagFilter{})
+	require.ErrorIs(t, err, errList)
+
+	_, err = flags.NewService(flags.NewMemoryRepository(), flags.WithUsageStore(failingUsageStore{})).
+		StaleFlags(ctx, 0, flags.FlagFilter{})
+	require.ErrorIs(t, err, errUsageStore)
+}
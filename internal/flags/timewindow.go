@@ -0,0 +1,382 @@
+package flags
+
+import (
+	"cmp"
+	"container/list"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minutesPerDay = 24 * 60
+	// maxParsedTimes bounds each cache of parsed condition values.
+	maxParsedTimes = 1024
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+var (
+	// locations caches time zones, which time.LoadLocation reads from disk.
+	locations sync.Map
+	// windows and timestamps cache the values of time conditions, so each is
+	// parsed once rather than on every evaluation. They are bounded because
+	// the rules evaluated, such as those of a simulation, come from callers.
+	windows    = newParsedCache[windowKey, timeWindow](maxParsedTimes)
+	timestamps = newParsedCache[string, time.Time](maxParsedTimes)
+)
+
+// timeOps match a timestamp against a time condition's value.
+var timeOps = map[ConditionOp]func(at time.Time, value any) bool{
+	OpBefore: func(at time.Time, value any) bool {
+		bound, ok := conditionTime(value)
+
+		return ok && at.Before(bound)
+	},
+	OpAfter: func(at time.Time, value any) bool {
+		bound, ok := conditionTime(value)
+
+		return ok && !at.Before(bound)
+	},
+	OpBetween: func(at time.Time, value any) bool {
+		from, to, ok := toBounds(value)
+
+		return ok && !at.Before(from) && at.Before(to)
+	},
+	OpWindow: func(at time.Time, value any) bool {
+		window, err := lookupWindow(value)
+
+		return err == nil && window.contains(at)
+	},
+}
+
+func (c Condition) matchesTime(attrValue any) bool {
+	at, ok := toTime(attrValue)
+
+	return ok && timeOps[c.Op](at, c.Value)
+}
+
+// validateTime reports why the value of a time condition can never match.
+func (c Condition) validateTime() error {
+	switch c.Op {
+	case OpBefore, OpAfter:
+		if _, ok := conditionTime(c.Value); !ok {
+			return fmt.Errorf("%s needs an RFC 3339 timestamp, got %v", c.Op, c.Value)
+		}
+	case OpBetween:
+		from, to, ok := toBounds(c.Value)
+		if !ok {
+			return fmt.Errorf("%s needs two RFC 3339 timestamps, got %v", c.Op, c.Value)
+		}
+
+		if !from.Before(to) {
+			return fmt.Errorf("%s needs its first timestamp before the second", c.Op)
+		}
+	case OpWindow:
+		_, err := lookupWindow(c.Value)
+
+		return err
+	case OpEquals, OpNotEquals, OpIn, OpNotIn, OpExists, OpStartsWith:
+	}
+
+	return nil
+}
+
+// toBounds reads the [from, to) pair of OpBetween.
+func toBounds(value any) (time.Time, time.Time, bool) {
+	bounds, ok := value.([]any)
+	if !ok || len(bounds) != 2 {
+		return time.Time{}, time.Time{}, false
+	}
+
+	from, fromOk := conditionTime(bounds[0])
+	to, toOk := conditionTime(bounds[1])
+
+	return from, to, fromOk && toOk
+}
+
+// toTime accepts times and RFC 3339 strings, the form timestamps take in
+// JSON.
+func toTime(value any) (time.Time, bool) {
+	switch value := value.(type) {
+	case time.Time:
+		return value, true
+	case string:
+		t, err := time.Parse(time.RFC3339, value)
+
+		return t, err == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+// conditionTime is toTime for the value of a condition, which is kept parsed.
+func conditionTime(value any) (time.Time, bool) {
+	text, ok := value.(string)
+	if !ok {
+		return toTime(value)
+	}
+
+	t, err := timestamps.get(text, func() (time.Time, error) {
+		return time.Parse(time.RFC3339, text)
+	})
+
+	return t, err == nil
+}
+
+// timeWindow is a recurring window: the minutes [from, to) of the given days
+// in location. to is at most minutesPerDay; a window with to <= from wraps
+// past midnight.
+type timeWindow struct {
+	days     map[time.Weekday]bool // nil means every day
+	from, to int
+	location *time.Location
+}
+
+func (w timeWindow) contains(at time.Time) bool {
+	local := at.In(w.location)
+	if w.days != nil && !w.days[local.Weekday()] {
+		return false
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	if w.from < w.to {
+		return minute >= w.from && minute < w.to
+	}
+
+	return minute >= w.from || minute < w.to
+}
+
+// windowKey identifies a window whose fields are all strings. Days are joined
+// with commas.
+type windowKey struct {
+	days, from, to, tz windowField
+}
+
+type windowField struct {
+	value string
+	set   bool
+}
+
+// lookupWindow parses value, or returns the result of parsing an identical
+// window before.
+func lookupWindow(value any) (timeWindow, error) {
+	key, ok := windowKeyOf(value)
+	if !ok {
+		return parseWindow(value)
+	}
+
+	return windows.get(key, func() (timeWindow, error) {
+		return parseWindow(value)
+	})
+}
+
+// windowKeyOf returns false for values that cannot be keyed, which are never
+// valid windows.
+func windowKeyOf(value any) (windowKey, bool) {
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return windowKey{}, false
+	}
+
+	from, fromOk := stringField(fields["from"])
+	to, toOk := stringField(fields["to"])
+	tz, tzOk := stringField(fields["tz"])
+	days, daysOk := daysField(fields["days"])
+
+	if !fromOk || !toOk || !tzOk || !daysOk {
+		return windowKey{}, false
+	}
+
+	return windowKey{days: days, from: from, to: to, tz: tz}, true
+}
+
+func stringField(value any) (windowField, bool) {
+	switch text := value.(type) {
+	case nil:
+		return windowField{}, true
+	case string:
+		return windowField{value: text, set: true}, true
+	default:
+		return windowField{}, false
+	}
+}
+
+// daysField joins the day names. Names holding a comma are unknown days and
+// cannot be keyed without clashing with another list.
+func daysField(value any) (windowField, bool) {
+	if value == nil {
+		return windowField{}, true
+	}
+
+	names, ok := value.([]any)
+	if !ok {
+		return windowField{}, false
+	}
+
+	texts := make([]string, len(names))
+
+	for i, name := range names {
+		text, ok := name.(string)
+		if !ok || strings.Contains(text, ",") {
+			return windowField{}, false
+		}
+
+		texts[i] = text
+	}
+
+	return windowField{value: strings.Join(texts, ","), set: true}, true
+}
+
+func parseWindow(value any) (timeWindow, error) {
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return timeWindow{}, errors.New("window must be an object")
+	}
+
+	days, err := parseDays(fields["days"])
+	if err != nil {
+		return timeWindow{}, err
+	}
+
+	from, err := parseClock(fields["from"], 0)
+	if err != nil {
+		return timeWindow{}, err
+	}
+
+	to, err := parseClock(fields["to"], minutesPerDay)
+	if err != nil {
+		return timeWindow{}, err
+	}
+
+	tz, _ := fields["tz"].(string)
+
+	location, err := loadLocation(cmp.Or(tz, "UTC"))
+	if err != nil {
+		return timeWindow{}, err
+	}
+
+	return timeWindow{days: days, from: from, to: to, location: location}, nil
+}
+
+// parseDays parses a list of day names; no list means every day.
+func parseDays(value any) (map[time.Weekday]bool, error) {
+	if value == nil {
+		return nil, nil //nolint:nilnil // a nil set means every day
+	}
+
+	names, ok := value.([]any)
+	if !ok {
+		return nil, errors.New("days must be a list")
+	}
+
+	days := make(map[time.Weekday]bool, len(names))
+
+	for _, name := range names {
+		text, _ := name.(string)
+
+		day, ok := weekdays[strings.ToLower(text)]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %v", name)
+		}
+
+		days[day] = true
+	}
+
+	return days, nil
+}
+
+// parseClock parses HH:MM into minutes since midnight, or returns fallback
+// when value is missing. 24:00 is the end of the day.
+func parseClock(value any, fallback int) (int, error) {
+	if value == nil {
+		return fallback, nil
+	}
+
+	text, _ := value.(string)
+	if text == "24:00" {
+		return minutesPerDay, nil
+	}
+
+	t, err := time.Parse("15:04", text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %v", value)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	if location, ok := locations.Load(name); ok {
+		return location.(*time.Location), nil
+	}
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("load time zone: %w", err)
+	}
+
+	locations.Store(name, location)
+
+	return location, nil
+}
+
+// parsedCache keeps the most recently used of at most size parsed values.
+// Values that fail to parse are not kept.
+type parsedCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	entries map[K]*list.Element
+	lru     *list.List // of *parsedEntry, most recently used first
+}
+
+type parsedEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func newParsedCache[K comparable, V any](size int) *parsedCache[K, V] {
+	return &parsedCache[K, V]{size: size, entries: make(map[K]*list.Element), lru: list.New()}
+}
+
+// get returns the value kept for key, or the result of parse.
+func (c *parsedCache[K, V]) get(key K, parse func() (V, error)) (V, error) {
+	c.mu.Lock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		value := elem.Value.(*parsedEntry[K, V]).value
+		c.mu.Unlock()
+
+		return value, nil
+	}
+
+	c.mu.Unlock()
+
+	value, err := parse()
+	if err != nil {
+		return value, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		return value, nil
+	}
+
+	c.entries[key] = c.lru.PushFront(&parsedEntry[K, V]{key: key, value: value})
+
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*parsedEntry[K, V]).key)
+	}
+
+	return value, nil
+}
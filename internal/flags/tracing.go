@@ -0,0 +1,184 @@
+package flags
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName names the tracer of the service's spans.
+const TracerName = "github.com/serroba/features/internal/flags"
+
+// Span attributes set by the service, and by the repository and HTTP spans
+// that share its trace.
+const (
+	AttrFlagKey  = attribute.Key("flag.key")
+	AttrReason   = attribute.Key("flag.reason")
+	AttrRuleID   = attribute.Key("flag.rule_id")
+	AttrMatched  = attribute.Key("flag.rule_matched")
+	AttrAction   = attribute.Key("flag.action")
+	AttrChangeID = attribute.Key("change.id")
+)
+
+// WithTracerProvider replaces the global tracer provider, which records
+// nothing until the server configures one.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(s *Service) {
+		s.tracer = provider.Tracer(TracerName)
+	}
+}
+
+func defaultTracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(TracerName)
+}
+
+// SpanError marks span as failed with err and returns err. Missing flags,
+// revisions and change requests, taken keys and version conflicts are answers
+// rather than failures, so they leave the span's status unset.
+func SpanError(span trace.Span, err error) error {
+	if err == nil || errors.Is(err, ErrFlagNotFound) || errors.Is(err, ErrFlagExists) ||
+		errors.Is(err, ErrVersionConflict) || errors.Is(err, ErrRevisionNotFound) || errors.Is(err, ErrChangeNotFound) {
+		return err
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+
+	return err
+}
+
+// evaluateRules evaluates flag's rules in a span of their own. When the span
+// is recorded, each rule checked gets a child span saying whether it served.
+func (s *Service) evaluateRules(ctx context.Context, flag Flag, evalCtx EvalContext) EvalResult {
+	ctx, span := s.tracer.Start(ctx, "flags.EvaluateRules",
+		trace.WithAttributes(attribute.Int("flag.rules", len(flag.Rules))))
+	defer span.End()
+
+	if !span.IsRecording() {
+		return flag.Evaluate(evalCtx)
+	}
+
+	return flag.evaluate(evalCtx, func(rule Rule, evalCtx EvalContext) bool {
+		_, ruleSpan := s.tracer.Start(ctx, "flags.MatchRule", trace.WithAttributes(AttrRuleID.String(rule.ID)))
+		defer ruleSpan.End()
+
+		served := flag.serves(rule, evalCtx)
+		ruleSpan.SetAttributes(AttrMatched.Bool(served))
+
+		return served
+	})
+}
+
+// traceStores wraps the audit, history and change request stores so every
+// call to them is a span, like the repository calls the server traces.
+func (s *Service) traceStores() {
+	s.audit = tracedAuditStore{store: s.audit, tracer: s.tracer}
+	s.history = tracedHistoryStore{store: s.history, tracer: s.tracer}
+	s.changes = tracedChangeStore{store: s.changes, tracer: s.tracer}
+}
+
+type tracedAuditStore struct {
+	store  AuditStore
+	tracer trace.Tracer
+}
+
+func (t tracedAuditStore) Append(ctx context.Context, entry AuditEntry) (AuditEntry, error) {
+	ctx, span := t.tracer.Start(ctx, "audit.append", trace.WithAttributes(
+		AttrFlagKey.String(string(entry.FlagKey)), AttrAction.String(string(entry.Action))))
+	defer span.End()
+
+	entry, err := t.store.Append(ctx, entry)
+
+	return entry, SpanError(span, err)
+}
+
+func (t tracedAuditStore) List(ctx context.Context, filter AuditFilter) (AuditPage, error) {
+	ctx, span := t.tracer.Start(ctx, "audit.list")
+	defer span.End()
+
+	page, err := t.store.List(ctx, filter)
+
+	return page, SpanError(span, err)
+}
+
+type tracedHistoryStore struct {
+	store  HistoryStore
+	tracer trace.Tracer
+}
+
+func (t tracedHistoryStore) Append(ctx context.Context, revision Flag) error {
+	ctx, span := t.tracer.Start(ctx, "history.append", trace.WithAttributes(
+		AttrFlagKey.String(string(revision.Key)), attribute.Int("flag.version", revision.Version)))
+	defer span.End()
+
+	return SpanError(span, t.store.Append(ctx, revision))
+}
+
+func (t tracedHistoryStore) List(ctx context.Context, key FlagKey) ([]Flag, error) {
+	ctx, span := t.tracer.Start(ctx, "history.list", trace.WithAttributes(AttrFlagKey.String(string(key))))
+	defer span.End()
+
+	revisions, err := t.store.List(ctx, key)
+
+	return revisions, SpanError(span, err)
+}
+
+func (t tracedHistoryStore) Get(ctx context.Context, key FlagKey, version int) (Flag, error) {
+	ctx, span := t.tracer.Start(ctx, "history.get", trace.WithAttributes(
+		AttrFlagKey.String(string(key)), attribute.Int("flag.version", version)))
+	defer span.End()
+
+	revision, err := t.store.Get(ctx, key, version)
+
+	return revision, SpanError(span, err)
+}
+
+func (t tracedHistoryStore) Delete(ctx context.Context, key FlagKey) error {
+	ctx, span := t.tracer.Start(ctx, "history.delete", trace.WithAttributes(AttrFlagKey.String(string(key))))
+	defer span.End()
+
+	return SpanError(span, t.store.Delete(ctx, key))
+}
+
+type tracedChangeStore struct {
+	store  ChangeStore
+	tracer trace.Tracer
+}
+
+func (t tracedChangeStore) Create(ctx context.Context, change ChangeRequest) error {
+	ctx, span := t.tracer.Start(ctx, "changes.create", trace.WithAttributes(
+		AttrChangeID.String(change.ID), AttrFlagKey.String(string(change.FlagKey))))
+	defer span.End()
+
+	return SpanError(span, t.store.Create(ctx, change))
+}
+
+func (t tracedChangeStore) Get(ctx context.Context, id string) (ChangeRequest, error) {
+	ctx, span := t.tracer.Start(ctx, "changes.get", trace.WithAttributes(AttrChangeID.String(id)))
+	defer span.End()
+
+	change, err := t.store.Get(ctx, id)
+
+	return change, SpanError(span, err)
+}
+
+func (t tracedChangeStore) List(ctx context.Context, filter ChangeFilter) ([]ChangeRequest, error) {
+	ctx, span := t.tracer.Start(ctx, "changes.list")
+	defer span.End()
+
+	changes, err := t.store.List(ctx, filter)
+
+	return changes, SpanError(span, err)
+}
+
+func (t tracedChangeStore) Update(ctx context.Context, change ChangeRequest) error {
+	ctx, span := t.tracer.Start(ctx, "changes.update", trace.WithAttributes(
+		AttrChangeID.String(change.ID), AttrFlagKey.String(string(change.FlagKey))))
+	defer span.End()
+
+	return SpanError(span, t.store.Update(ctx, change))
+}
@@ -0,0 +1,263 @@
+package flags_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTracedService(t *testing.T, opts ...flags.Option) (*flags.Service, *tracetest.SpanRecorder) {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	svc := flags.NewService(flags.NewMemoryRepository(), append(opts, flags.WithTracerProvider(provider))...)
+
+	return svc, recorder
+}
+
+func TestService_Evaluate_Spans(t *testing.T) {
+	t.Parallel()
+
+	svc, recorder := newTracedService(t)
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, flagstest.SampleFlag("checkout"))
+	require.NoError(t, err)
+
+	created := len(recorder.Ended())
+
+	result, err := svc.Evaluate(ctx, "checkout", flags.EvalContext{
+		Attrs: map[string]any{"plan": "pro", "seats": float64(10), "beta": false},
+	})
+	require.NoError(t, err)
+	require.Equal(t, flags.ReasonDefault, result.Reason)
+
+	spans := recorder.Ended()[created:]
+	require.Len(t, spans, 4)
+
+	beta, internal, rules, evaluate := spans[0], spans[1], spans[2], spans[3]
+	assert.Equal(t, "flags.EvaluateRules", rules.Name())
+	assert.Equal(t, evaluate.SpanContext().SpanID(), rules.Parent().SpanID())
+	assert.Contains(t, rules.Attributes(), attribute.Int("flag.rules", 2))
+
+	for i, span := range []sdktrace.ReadOnlySpan{beta, internal} {
+		assert.Equal(t, "flags.MatchRule", span.Name())
+		assert.Equal(t, rules.SpanContext().SpanID(), span.Parent().SpanID(), "rules are checked within the rules span")
+		assert.Contains(t, span.Attributes(), flags.AttrRuleID.String([]string{"beta", "internal"}[i]))
+		assert.Contains(t, span.Attributes(), flags.AttrMatched.Bool(false))
+	}
+
+	assert.Equal(t, "flags.Evaluate", evaluate.Name())
+	assert.Equal(t, codes.Unset, evaluate.Status().Code)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		flags.AttrFlagKey.String("checkout"),
+		flags.AttrReason.String(string(result.Reason)),
+		flags.AttrRuleID.String(result.RuleID),
+	}, evaluate.Attributes())
+}
+
+func TestService_Evaluate_MatchedRuleSpan(t *testing.T) {
+	t.Parallel()
+
+	svc, recorder := newTracedService(t)
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, flagstest.SampleFlag("checkout"))
+	require.NoError(t, err)
+
+	created := len(recorder.Ended())
+
+	_, err = svc.Evaluate(ctx, "checkout", flags.EvalContext{
+		Attrs: map[string]any{"plan": "pro", "seats": float64(10), "beta": true},
+	})
+	require.NoError(t, err)
+
+	spans := recorder.Ended()[created:]
+	require.Len(t, spans, 3, "rules after the one that serves are not checked")
+	assert.Equal(t, "flags.MatchRule", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), flags.AttrMatched.Bool(true))
+}
+
+func TestService_Evaluate_UnsampledRules(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()),
+		sdktrace.WithSpanProcessor(recorder))
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithTracerProvider(provider))
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, flagstest.SampleFlag("checkout"))
+	require.NoError(t, err)
+
+	result, err := svc.Evaluate(ctx, "checkout", flags.EvalContext{
+		Attrs: map[string]any{"plan": "pro", "seats": float64(10), "beta": true},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "beta", result.RuleID)
+	assert.Empty(t, recorder.Ended())
+}
+
+// spanTree returns the names of the ended spans by name of their parent.
+func spanTree(spans []sdktrace.ReadOnlySpan) map[string][]string {
+	names := map[trace.SpanID]string{}
+	for _, span := range spans {
+		names[span.SpanContext().SpanID()] = span.Name()
+	}
+
+	tree := map[string][]string{}
+
+	for _, span := range spans {
+		parent := names[span.Parent().SpanID()]
+		tree[parent] = append(tree[parent], span.Name())
+	}
+
+	return tree
+}
+
+func TestService_Write_Spans(t *testing.T) {
+	t.Parallel()
+
+	svc, recorder := newTracedService(t)
+	ctx := context.Background()
+	flag := flagstest.SampleFlag("checkout")
+	flag.Protected = false
+
+	_, err := svc.Create(ctx, flag)
+	require.NoError(t, err)
+
+	next := flag
+	next.Description = "Traced"
+
+	_, err = svc.Update(ctx, next)
+	require.NoError(t, err)
+	require.NoError(t, svc.Delete(ctx, "checkout"))
+
+	assert.Equal(t, map[string][]string{
+		"":              {"flags.Create", "flags.Replace", "flags.Remove"},
+		"flags.Create":  {"flags.Commit"},
+		"flags.Replace": {"flags.Commit"},
+		"flags.Remove":  {"history.delete", "flags.Commit"},
+		"flags.Commit":  {"history.append", "audit.append", "history.append", "audit.append", "audit.append"},
+	}, spanTree(recorder.Ended()))
+
+	for _, span := range recorder.Ended() {
+		if span.Name() == "flags.Replace" {
+			assert.Contains(t, span.Attributes(), flags.AttrAction.String(string(flags.AuditUpdate)))
+		}
+	}
+}
+
+func TestService_Store_Spans(t *testing.T) {
+	t.Parallel()
+
+	svc, recorder := newTracedService(t, flags.WithApprovalPolicy(flags.ApprovalPolicy{ProtectAll: true}))
+	ctx := as("alice")
+
+	_, err := svc.Create(ctx, flagstest.SampleFlag("checkout"))
+	change := pending(t, err)
+
+	_, err = svc.ChangeRequests(ctx, flags.ChangeFilter{})
+	require.NoError(t, err)
+
+	_, err = svc.Approve(as("bob"), change.ID, "")
+	require.NoError(t, err)
+
+	_, err = svc.ChangeRequest(ctx, "missing")
+	require.ErrorIs(t, err, flags.ErrChangeNotFound)
+
+	_, err = svc.AuditLog(ctx, flags.AuditFilter{})
+	require.NoError(t, err)
+
+	_, err = svc.Versions(ctx, "checkout")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	_, err = svc.Version(ctx, "checkout", 1)
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	names := make([]string, 0, len(recorder.Ended()))
+
+	for _, span := range recorder.Ended() {
+		names = append(names, span.Name())
+		assert.Equal(t, codes.Unset, span.Status().Code, span.Name())
+	}
+
+	assert.Equal(t, []string{
+		"changes.create", "changes.list", "changes.get", "changes.update", "changes.get",
+		"audit.list", "history.list", "history.get",
+	}, filterStoreSpans(names))
+}
+
+// filterStoreSpans drops the service's own spans.
+func filterStoreSpans(names []string) []string {
+	return slices.DeleteFunc(names, func(name string) bool { return strings.HasPrefix(name, "flags.") })
+}
+
+func TestService_Commit_SpanError(t *testing.T) {
+	t.Parallel()
+
+	svc, recorder := newTracedService(t, flags.WithAuditStore(failingAuditStore{}))
+
+	_, err := svc.Create(context.Background(), flagstest.SampleFlag("checkout"))
+	require.Error(t, err)
+
+	status := map[string]codes.Code{}
+	for _, span := range recorder.Ended() {
+		status[span.Name()] = span.Status().Code
+	}
+
+	assert.Equal(t, map[string]codes.Code{
+		"history.append": codes.Unset,
+		"audit.append":   codes.Error,
+		"flags.Commit":   codes.Error,
+		"flags.Create":   codes.Error,
+	}, status)
+}
+
+func TestService_Evaluate_SpanNotFound(t *testing.T) {
+	t.Parallel()
+
+	svc, recorder := newTracedService(t)
+
+	_, err := svc.Evaluate(context.Background(), "missing", flags.EvalContext{})
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code, "a missing flag is an answer, not a failure")
+	assert.Empty(t, spans[0].Events())
+}
+
+func TestService_SimulateAndRunTests_SpanErrors(t *testing.T) {
+	t.Parallel()
+
+	svc, recorder := newTracedService(t, flags.WithAuthorizer(grant{}))
+	ctx := context.Background()
+
+	_, err := svc.Simulate(ctx, flagstest.SampleFlag("checkout"), nil)
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	_, err = svc.RunTests(ctx, "checkout")
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	for i, name := range []string{"flags.Simulate", "flags.RunTests"} {
+		assert.Equal(t, name, spans[i].Name())
+		assert.Equal(t, codes.Error, spans[i].Status().Code)
+		assert.Contains(t, spans[i].Attributes(), flags.AttrFlagKey.String("checkout"))
+	}
+}
@@ -0,0 +1,275 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"hash/maphash"
+	"slices"
+	"sync"
+	"time"
+)
+
+// UsageRetention is how long usage is kept; older buckets are pruned when
+// usage is flushed.
+const UsageRetention = 90 * 24 * time.Hour
+
+// DefaultUsageWindow is how far back Usage counts when no start is given.
+const DefaultUsageWindow = 7 * 24 * time.Hour
+
+// usageShards spreads the flags over locks so that concurrent evaluations of
+// different flags rarely wait for each other.
+const usageShards = 32
+
+// usageTracker counts evaluations in memory until they are flushed to the
+// service's UsageStore.
+type usageTracker struct {
+	seed   maphash.Seed
+	shards [usageShards]usageShard
+}
+
+type usageShard struct {
+	mu      sync.Mutex
+	pending map[usageSlot]*UsageBucket
+}
+
+type usageSlot struct {
+	key  FlagKey
+	hour int64
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{seed: maphash.MakeSeed()}
+}
+
+func (t *usageTracker) shard(key FlagKey) *usageShard {
+	return &t.shards[maphash.String(t.seed, string(key))%usageShards]
+}
+
+func (t *usageTracker) record(result EvalResult, now time.Time) {
+	hour := now.UTC().Truncate(time.Hour)
+	slot := usageSlot{key: result.FlagKey, hour: hour.Unix()}
+	shard := t.shard(result.FlagKey)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	bucket, ok := shard.pending[slot]
+	if !ok {
+		if shard.pending == nil {
+			shard.pending = map[usageSlot]*UsageBucket{}
+		}
+
+		bucket = newUsageBucket(result.FlagKey, hour)
+		shard.pending[slot] = bucket
+	}
+
+	bucket.count(result.Value, result.Reason, 1, now)
+}
+
+// take removes and returns every pending bucket.
+func (t *usageTracker) take() []UsageBucket {
+	var taken []UsageBucket
+
+	for i := range t.shards {
+		shard := &t.shards[i]
+
+		shard.mu.Lock()
+		pending := shard.pending
+		shard.pending = nil
+		shard.mu.Unlock()
+
+		for _, bucket := range pending {
+			taken = append(taken, *bucket)
+		}
+	}
+
+	return taken
+}
+
+// restore puts back buckets that could not be flushed.
+func (t *usageTracker) restore(buckets []UsageBucket) {
+	for _, bucket := range buckets {
+		shard := t.shard(bucket.FlagKey)
+		slot := usageSlot{key: bucket.FlagKey, hour: bucket.Hour.Unix()}
+
+		shard.mu.Lock()
+
+		if shard.pending == nil {
+			shard.pending = map[usageSlot]*UsageBucket{}
+		}
+
+		merged := bucket.clone()
+		if pending, ok := shard.pending[slot]; ok {
+			merged = pending.Merge(bucket)
+		}
+
+		shard.pending[slot] = &merged
+
+		shard.mu.Unlock()
+	}
+}
+
+// pending returns copies of the pending buckets of key.
+func (t *usageTracker) pending(key FlagKey) []UsageBucket {
+	shard := t.shard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	var buckets []UsageBucket
+
+	for slot, bucket := range shard.pending {
+		if slot.key == key {
+			buckets = append(buckets, bucket.clone())
+		}
+	}
+
+	return buckets
+}
+
+// all returns copies of every pending bucket.
+func (t *usageTracker) all() []UsageBucket {
+	var buckets []UsageBucket
+
+	for i := range t.shards {
+		shard := &t.shards[i]
+
+		shard.mu.Lock()
+
+		for _, bucket := range shard.pending {
+			buckets = append(buckets, bucket.clone())
+		}
+
+		shard.mu.Unlock()
+	}
+
+	return buckets
+}
+
+// drop discards the pending buckets of key.
+func (t *usageTracker) drop(key FlagKey) {
+	shard := t.shard(key)
+
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	for slot := range shard.pending {
+		if slot.key == key {
+			delete(shard.pending, slot)
+		}
+	}
+}
+
+// FlagUsage summarizes the evaluations of a flag. LastEvaluated covers all
+// retained usage; the counts and Hours cover the hours from Since on.
+type FlagUsage struct {
+	FlagKey       FlagKey
+	Since         time.Time
+	LastEvaluated time.Time
+	Evaluations   int64
+	Reasons       map[EvalReason]int64
+	Variations    []VariationCount
+	// Hours are the hours with evaluations, oldest first.
+	Hours []UsageBucket
+}
+
+// Usage returns the usage of the flag in the hours from since on, or over
+// the last DefaultUsageWindow when since is zero, including evaluations not
+// flushed yet.
+func (s *Service) Usage(ctx context.Context, key FlagKey, since time.Time) (FlagUsage, error) {
+	if err := s.authorizer.Authorize(ctx, PermissionRead, key); err != nil {
+		return FlagUsage{}, err
+	}
+
+	if since.IsZero() {
+		since = s.clock.Now().Add(-DefaultUsageWindow)
+	}
+
+	since = since.UTC().Truncate(time.Hour)
+
+	if _, err := s.repo.Get(ctx, key); err != nil {
+		return FlagUsage{}, err
+	}
+
+	stored, err := s.usageStore.List(ctx, UsageFilter{FlagKey: key})
+	if err != nil {
+		return FlagUsage{}, err
+	}
+
+	return summarizeUsage(key, since, mergeUsage(stored, s.usage.pending(key))), nil
+}
+
+// mergeUsage sums the buckets of the same flag and hour and sorts the result.
+func mergeUsage(buckets ...[]UsageBucket) []UsageBucket {
+	merged := map[usageSlot]UsageBucket{}
+
+	for _, bucket := range slices.Concat(buckets...) {
+		slot := usageSlot{key: bucket.FlagKey, hour: bucket.Hour.Unix()}
+		if existing, ok := merged[slot]; ok {
+			bucket = existing.Merge(bucket)
+		}
+
+		merged[slot] = bucket
+	}
+
+	result := make([]UsageBucket, 0, len(merged))
+	for _, bucket := range merged {
+		result = append(result, bucket)
+	}
+
+	slices.SortFunc(result, compareUsage)
+
+	return result
+}
+
+func summarizeUsage(key FlagKey, since time.Time, buckets []UsageBucket) FlagUsage {
+	usage := FlagUsage{FlagKey: key, Since: since, Hours: []UsageBucket{}}
+	total := *newUsageBucket(key, since)
+
+	for _, bucket := range buckets {
+		if bucket.LastEvaluated.After(usage.LastEvaluated) {
+			usage.LastEvaluated = bucket.LastEvaluated
+		}
+
+		if bucket.Hour.Before(since) {
+			continue
+		}
+
+		total = total.Merge(bucket)
+		usage.Hours = append(usage.Hours, bucket)
+	}
+
+	usage.Evaluations = total.Evaluations
+	usage.Reasons = total.Reasons
+	usage.Variations = total.Variations
+
+	return usage
+}
+
+// FlushUsage adds the evaluations counted since the last flush to the usage
+// store, and prunes the usage older than UsageRetention. It returns the
+// flushed buckets. Buckets that cannot be added are kept for the next flush.
+func (s *Service) FlushUsage(ctx context.Context) ([]UsageBucket, error) {
+	pending := s.usage.take()
+
+	if len(pending) > 0 {
+		if err := s.usageStore.Add(ctx, pending); err != nil {
+			s.usage.restore(pending)
+
+			return nil, fmt.Errorf("flush usage: %w", err)
+		}
+	}
+
+	if err := s.usageStore.Prune(ctx, s.clock.Now().Add(-UsageRetention)); err != nil {
+		return pending, fmt.Errorf("prune usage: %w", err)
+	}
+
+	return pending, nil
+}
+
+// WatchUsage flushes usage every interval until ctx is done. Each flush that
+// wrote buckets or failed is passed to report. Flush once more after ctx is
+// done to keep the last evaluations.
+func (s *Service) WatchUsage(ctx context.Context, interval time.Duration, report func([]UsageBucket, error)) {
+	watch(ctx, interval, s.FlushUsage, report)
+}
@@ -1,10 +1,15 @@
 package flags
 
 import (
+	"errors"
+	"fmt"
+	"slices"
 	"strings"
 	"time"
 )
 
+var ErrInvalidFlag = errors.New("invalid flag")
+
 type FlagKey string
 
 type FlagType string
@@ -15,19 +20,41 @@ const (
 	FlagNumber FlagType = "number"
 )
 
+// ManagedByFile marks flags owned by flags files; see WithManager.
+const ManagedByFile = "file"
+
 type Flag struct {
-	Key          FlagKey
-	Type         FlagType
-	Enabled      bool // global kill switch
-	DefaultValue Value
-	Rules        []Rule // ordered: first match wins
-	UpdatedAt    time.Time
+	Key          FlagKey    `json:"key"`
+	Description  string     `json:"description,omitempty"`
+	Owner        string     `json:"owner,omitempty"` // team to ask about the flag
+	Tags         []string   `json:"tags,omitempty"`
+	Kind         FlagKind   `json:"kind,omitempty"`
+	ExpiresAt    time.Time  `json:"expiresAt,omitzero"` // when the flag is expected to be removed; zero if never
+	Type         FlagType   `json:"type"`
+	Enabled      bool       `json:"enabled"`             // global kill switch
+	Protected    bool       `json:"protected,omitempty"` // changes need approval; see ApprovalPolicy
+	DefaultValue Value      `json:"defaultValue"`
+	Rules        []Rule     `json:"rules,omitempty"` // ordered: first match wins
+	Version      int        `json:"version"`         // starts at 1, incremented on every change
+	UpdatedAt    time.Time  `json:"updatedAt"`
+	ManagedBy    string     `json:"managedBy,omitempty"` // empty when the flag is managed through the API
+	Tests        []FlagTest `json:"tests,omitempty"`     // checked before every change; see FlagTest
 }
 
 func (f Flag) Evaluate(evalCtx EvalContext) EvalResult {
+	return f.evaluate(evalCtx, f.serves)
+}
+
+// serves reports whether rule serves its value to evalCtx.
+func (f Flag) serves(rule Rule, evalCtx EvalContext) bool {
+	return rule.Matches(evalCtx) && rule.Rollout.includes(f.Key, evalCtx)
+}
+
+// evaluate is Evaluate with serves deciding which rule serves evalCtx.
+func (f Flag) evaluate(evalCtx EvalContext, serves func(Rule, EvalContext) bool) EvalResult {
 	result := EvalResult{
 		FlagKey:     f.Key,
-		EvaluatedAt: time.Now(),
+		EvaluatedAt: evalCtx.now(),
 	}
 
 	if !f.Enabled {
@@ -38,7 +65,7 @@ func (f Flag) Evaluate(evalCtx EvalContext) EvalResult {
 	}
 
 	for _, rule := range f.Rules {
-		if rule.Matches(evalCtx) {
+		if serves(rule, evalCtx) {
 			result.Value = rule.Value
 			result.Reason = ReasonRuleMatch
 			result.RuleID = rule.ID
@@ -54,9 +81,10 @@ func (f Flag) Evaluate(evalCtx EvalContext) EvalResult {
 }
 
 type Rule struct {
-	ID         string
-	Conditions []Condition // AND across conditions
-	Value      Value
+	ID         string      `json:"id"`
+	Conditions []Condition `json:"conditions"` // AND across conditions
+	Value      Value       `json:"value"`
+	Rollout    *Rollout    `json:"rollout,omitempty"` // nil serves every matching context
 }
 
 func (r Rule) Matches(evalCtx EvalContext) bool {
@@ -78,14 +106,30 @@ const (
 	OpNotIn      ConditionOp = "not_in"
 	OpExists     ConditionOp = "exists"
 	OpStartsWith ConditionOp = "starts_with"
+	OpBefore     ConditionOp = "before"  // RFC 3339 timestamp
+	OpAfter      ConditionOp = "after"   // RFC 3339 timestamp, inclusive
+	OpBetween    ConditionOp = "between" // [from, to) as RFC 3339 timestamps
+	OpWindow     ConditionOp = "window"  // recurring window; see Condition.Matches
 )
 
 type Condition struct {
-	Attr  string      // e.g. "tenant_id", "user_id", "plan", "country"
-	Op    ConditionOp // eq/in/exists/...
-	Value any         // string | float64 | bool | []any depending on Op
+	Attr  string      `json:"attr"`  // e.g. "tenant_id", "user_id", "plan", "country"
+	Op    ConditionOp `json:"op"`    // eq/in/exists/...
+	Value any         `json:"value"` // string | float64 | bool | []any depending on Op
 }
 
+// Matches reports whether evalCtx satisfies the condition. Values of the wrong
+// type never match.
+//
+// The time operators compare a timestamp attribute, usually "now", the time
+// of the evaluation. OpWindow takes a recurring window such as
+//
+//	{"days": ["sat", "sun"], "from": "09:00", "to": "17:00", "tz": "Europe/Amsterdam"}
+//
+// where every field is optional: the window covers every day, the whole day,
+// in UTC unless told otherwise. "to" may be 24:00, and a window that ends
+// before it starts runs past midnight. Days are matched against the date in
+// tz.
 func (c Condition) Matches(evalCtx EvalContext) bool {
 	attrValue := evalCtx.GetAttr(c.Attr)
 
@@ -101,15 +145,21 @@ func (c Condition) Matches(evalCtx EvalContext) bool {
 	case OpExists:
 		return attrValue != nil
 	case OpStartsWith:
-		str, ok := attrValue.(string)
-		prefix, prefixOk := c.Value.(string)
-
-		return ok && prefixOk && strings.HasPrefix(str, prefix)
+		return c.hasPrefix(attrValue)
+	case OpBefore, OpAfter, OpBetween, OpWindow:
+		return c.matchesTime(attrValue)
 	default:
 		return false
 	}
 }
 
+func (c Condition) hasPrefix(attrValue any) bool {
+	str, ok := attrValue.(string)
+	prefix, prefixOk := c.Value.(string)
+
+	return ok && prefixOk && strings.HasPrefix(str, prefix)
+}
+
 func (c Condition) containsValue(attrValue any) bool {
 	slice, ok := c.Value.([]any)
 	if !ok {
@@ -126,10 +176,10 @@ func (c Condition) containsValue(attrValue any) bool {
 }
 
 type Value struct {
-	Kind   FlagType
-	Bool   *bool
-	String *string
-	Number *float64
+	Kind   FlagType `json:"kind"`
+	Bool   *bool    `json:"bool,omitempty"`
+	String *string  `json:"string,omitempty"`
+	Number *float64 `json:"number,omitempty"`
 }
 
 func BoolValue(v bool) Value {
@@ -145,17 +195,22 @@ func NumberValue(v float64) Value {
 }
 
 type EvalContext struct {
-	TenantID string
-	UserID   string
-	Attrs    map[string]any // arbitrary attributes for rule conditions
+	TenantID string         `json:"tenantId,omitempty"`
+	UserID   string         `json:"userId,omitempty"`
+	Attrs    map[string]any `json:"attrs,omitempty"` // arbitrary attributes for rule conditions
+	Now      time.Time      `json:"now,omitzero"`    // time of the evaluation; zero means the current time
 }
 
+// GetAttr returns the attribute for conditions. user_id, tenant_id and now
+// are built in; the rest come from Attrs.
 func (e EvalContext) GetAttr(attr string) any {
 	switch attr {
 	case "user_id":
 		return e.UserID
 	case "tenant_id":
 		return e.TenantID
+	case "now":
+		return e.now()
 	default:
 		if e.Attrs != nil {
 			return e.Attrs[attr]
@@ -165,6 +220,14 @@ func (e EvalContext) GetAttr(attr string) any {
 	}
 }
 
+func (e EvalContext) now() time.Time {
+	if e.Now.IsZero() {
+		return time.Now()
+	}
+
+	return e.Now
+}
+
 type EvalReason string
 
 const (
@@ -180,3 +243,108 @@ type EvalResult struct {
 	RuleID      string
 	EvaluatedAt time.Time
 }
+
+// validate reports conditions whose value can never match, such as a time
+// window on an unknown day.
+func (f Flag) validate() error {
+	return validateRules(f.Rules)
+}
+
+func validateRules(rules []Rule) error {
+	for _, rule := range rules {
+		for i, cond := range rule.Conditions {
+			if err := cond.validateTime(); err != nil {
+				return fmt.Errorf("%w: rule %s: condition %d: %w", ErrInvalidFlag, rule.ID, i+1, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Clone returns a deep copy of f that shares no slices or pointers with it.
+func (f Flag) Clone() Flag {
+	f.DefaultValue = f.DefaultValue.clone()
+	f.Tags = slices.Clone(f.Tags)
+
+	if f.Rules != nil {
+		rules := make([]Rule, len(f.Rules))
+		for i, rule := range f.Rules {
+			rules[i] = rule.clone()
+		}
+
+		f.Rules = rules
+	}
+
+	if f.Tests != nil {
+		tests := make([]FlagTest, len(f.Tests))
+		for i, test := range f.Tests {
+			tests[i] = test.clone()
+		}
+
+		f.Tests = tests
+	}
+
+	return f
+}
+
+func (r Rule) clone() Rule {
+	r.Value = r.Value.clone()
+
+	if r.Rollout != nil {
+		rollout := *r.Rollout
+		r.Rollout = &rollout
+	}
+
+	if r.Conditions != nil {
+		conditions := make([]Condition, len(r.Conditions))
+		for i, cond := range r.Conditions {
+			cond.Value = cloneConditionValue(cond.Value)
+			conditions[i] = cond
+		}
+
+		r.Conditions = conditions
+	}
+
+	return r
+}
+
+func cloneConditionValue(value any) any {
+	switch value := value.(type) {
+	case []any:
+		values := make([]any, len(value))
+		for i, item := range value {
+			values[i] = cloneConditionValue(item)
+		}
+
+		return values
+	case map[string]any:
+		values := make(map[string]any, len(value))
+		for key, item := range value {
+			values[key] = cloneConditionValue(item)
+		}
+
+		return values
+	default:
+		return value
+	}
+}
+
+func (v Value) clone() Value {
+	if v.Bool != nil {
+		b := *v.Bool
+		v.Bool = &b
+	}
+
+	if v.String != nil {
+		s := *v.String
+		v.String = &s
+	}
+
+	if v.Number != nil {
+		n := *v.Number
+		v.Number = &n
+	}
+
+	return v
+}
@@ -2,9 +2,11 @@ package flags_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/serroba/features/internal/flags"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEvalContext_GetAttr(t *testing.T) {
@@ -38,6 +40,14 @@ func TestEvalContext_GetAttr(t *testing.T) {
 		assert.Nil(t, ctx.GetAttr("missing"))
 	})
 
+	t.Run("returns the evaluation time for now", func(t *testing.T) {
+		t.Parallel()
+
+		now := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+		assert.Equal(t, now, flags.EvalContext{Now: now}.GetAttr("now"))
+		assert.WithinDuration(t, time.Now(), flags.EvalContext{}.GetAttr("now").(time.Time), time.Minute)
+	})
+
 	t.Run("returns nil when Attrs is nil", func(t *testing.T) {
 		t.Parallel()
 
@@ -122,6 +132,109 @@ func TestCondition_Matches(t *testing.T) {
 		assert.False(t, cond.Matches(ctx("num", 123)))
 	})
 
+	t.Run("OpBefore and OpAfter", func(t *testing.T) {
+		t.Parallel()
+
+		launch := "2025-09-01T00:00:00Z"
+		before := flags.Condition{Attr: "now", Op: flags.OpBefore, Value: launch}
+		after := flags.Condition{Attr: "now", Op: flags.OpAfter, Value: launch}
+
+		at := func(ts string) flags.EvalContext {
+			now, err := time.Parse(time.RFC3339, ts)
+			require.NoError(t, err)
+
+			return flags.EvalContext{Now: now}
+		}
+
+		assert.True(t, before.Matches(at("2025-08-31T23:59:59Z")))
+		assert.False(t, after.Matches(at("2025-08-31T23:59:59Z")))
+		assert.False(t, before.Matches(at(launch)))
+		assert.True(t, after.Matches(at(launch)))
+		assert.True(t, after.Matches(at("2025-09-01T02:00:00+02:00")), "offsets are honoured")
+
+		signup := flags.Condition{Attr: "signed_up", Op: flags.OpBefore, Value: launch}
+		assert.True(t, signup.Matches(ctx("signed_up", "2025-01-01T00:00:00Z")), "attributes can be timestamps")
+		assert.False(t, signup.Matches(ctx("signed_up", "yesterday")))
+		assert.False(t, flags.Condition{Attr: "now", Op: flags.OpBefore, Value: 42}.Matches(at(launch)))
+	})
+
+	t.Run("OpBefore with more values than are kept parsed", func(t *testing.T) {
+		t.Parallel()
+
+		start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+		for round := range 2 {
+			for i := range 1500 {
+				bound := start.Add(time.Duration(i) * time.Minute)
+				cond := flags.Condition{Attr: "now", Op: flags.OpBefore, Value: bound.Format(time.RFC3339)}
+
+				require.True(t, cond.Matches(flags.EvalContext{Now: bound.Add(-time.Second)}), "round %d", round)
+				require.False(t, cond.Matches(flags.EvalContext{Now: bound}), "round %d", round)
+			}
+		}
+	})
+
+	t.Run("OpBetween", func(t *testing.T) {
+		t.Parallel()
+
+		cond := flags.Condition{Attr: "now", Op: flags.OpBetween, Value: []any{
+			"2025-11-28T00:00:00Z", "2025-12-01T00:00:00Z",
+		}}
+
+		assert.True(t, cond.Matches(flags.EvalContext{Now: time.Date(2025, 11, 28, 0, 0, 0, 0, time.UTC)}))
+		assert.True(t, cond.Matches(flags.EvalContext{Now: time.Date(2025, 11, 30, 23, 0, 0, 0, time.UTC)}))
+		assert.False(t, cond.Matches(flags.EvalContext{Now: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)}))
+		assert.False(t, cond.Matches(flags.EvalContext{Now: time.Date(2025, 11, 27, 0, 0, 0, 0, time.UTC)}))
+
+		for _, value := range []any{"2025-11-28T00:00:00Z", []any{"2025-11-28T00:00:00Z"}, []any{"a", "b"}} {
+			invalid := flags.Condition{Attr: "now", Op: flags.OpBetween, Value: value}
+			assert.False(t, invalid.Matches(flags.EvalContext{Now: time.Date(2025, 11, 29, 0, 0, 0, 0, time.UTC)}))
+		}
+	})
+
+	t.Run("OpWindow", func(t *testing.T) {
+		t.Parallel()
+
+		window := func(value map[string]any) flags.Condition {
+			return flags.Condition{Attr: "now", Op: flags.OpWindow, Value: value}
+		}
+		// 2025-06-06 is a Friday.
+		at := func(day, hour, minute int) flags.EvalContext {
+			return flags.EvalContext{Now: time.Date(2025, 6, day, hour, minute, 0, 0, time.UTC)}
+		}
+
+		office := window(map[string]any{"days": []any{"Mon", "tue", "wed", "thu", "fri"}, "from": "09:00", "to": "17:30"})
+		assert.True(t, office.Matches(at(6, 9, 0)))
+		assert.True(t, office.Matches(at(6, 17, 29)))
+		assert.False(t, office.Matches(at(6, 17, 30)))
+		assert.False(t, office.Matches(at(7, 12, 0)), "saturday")
+
+		overnight := window(map[string]any{"from": "22:00", "to": "06:00"})
+		assert.True(t, overnight.Matches(at(6, 23, 0)))
+		assert.True(t, overnight.Matches(at(7, 5, 59)))
+		assert.False(t, overnight.Matches(at(7, 6, 0)))
+
+		weekend := window(map[string]any{"days": []any{"sat", "sun"}, "to": "24:00", "tz": "America/New_York"})
+		assert.False(t, weekend.Matches(at(7, 3, 0)), "saturday 03:00 UTC is friday in New York")
+		assert.True(t, weekend.Matches(at(9, 3, 0)), "monday 03:00 UTC is sunday in New York")
+		assert.True(t, window(map[string]any{}).Matches(at(6, 12, 0)), "every field is optional")
+
+		for _, invalid := range []any{
+			"weekends",
+			map[string]any{"days": "sat"},
+			map[string]any{"days": []any{"someday"}},
+			map[string]any{"days": []any{"mon,tue"}},
+			map[string]any{"days": []any{1}},
+			map[string]any{"from": "9am"},
+			map[string]any{"from": 9},
+			map[string]any{"to": "25:00"},
+			map[string]any{"tz": "Mars/Olympus_Mons"},
+		} {
+			cond := flags.Condition{Attr: "now", Op: flags.OpWindow, Value: invalid}
+			assert.False(t, cond.Matches(at(6, 12, 0)), "%v", invalid)
+		}
+	})
+
 	t.Run("unknown operator", func(t *testing.T) {
 		t.Parallel()
 
@@ -240,8 +353,9 @@ func TestFlag_Evaluate(t *testing.T) {
 			Enabled:      true,
 			DefaultValue: flags.BoolValue(true),
 		}
-		result := flag.Evaluate(flags.EvalContext{})
+		now := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+		result := flag.Evaluate(flags.EvalContext{Now: now})
 		assert.Equal(t, flags.FlagKey("my-flag"), result.FlagKey)
-		assert.False(t, result.EvaluatedAt.IsZero())
+		assert.Equal(t, now, result.EvaluatedAt)
 	})
 }
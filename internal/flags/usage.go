@@ -0,0 +1,296 @@
+package flags
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UsageBucket counts the evaluations of one flag in the hour starting at Hour,
+// in UTC.
+type UsageBucket struct {
+	FlagKey       FlagKey              `json:"flagKey"`
+	Hour          time.Time            `json:"hour"`
+	Evaluations   int64                `json:"evaluations"`
+	Reasons       map[EvalReason]int64 `json:"reasons"`
+	Variations    []VariationCount     `json:"variations"`
+	LastEvaluated time.Time            `json:"lastEvaluated"`
+}
+
+// VariationCount is how many evaluations served Value.
+type VariationCount struct {
+	Value Value `json:"value"`
+	Count int64 `json:"count"`
+}
+
+func newUsageBucket(key FlagKey, hour time.Time) *UsageBucket {
+	return &UsageBucket{FlagKey: key, Hour: hour, Reasons: map[EvalReason]int64{}}
+}
+
+// count adds n evaluations that served value for reason, the last at at.
+func (b *UsageBucket) count(value Value, reason EvalReason, n int64, at time.Time) {
+	b.Evaluations += n
+	b.Reasons[reason] += n
+	b.addVariation(value, n)
+
+	if at.After(b.LastEvaluated) {
+		b.LastEvaluated = at
+	}
+}
+
+func (b *UsageBucket) addVariation(value Value, n int64) {
+	for i := range b.Variations {
+		if sameValue(b.Variations[i].Value, value) {
+			b.Variations[i].Count += n
+
+			return
+		}
+	}
+
+	b.Variations = append(b.Variations, VariationCount{Value: value.clone(), Count: n})
+}
+
+// Merge returns the sum of b and other, which share no maps or slices with
+// it. Stores use it to add flushed buckets to stored ones.
+func (b *UsageBucket) Merge(other UsageBucket) UsageBucket {
+	merged := b.clone()
+	merged.Evaluations += other.Evaluations
+
+	for reason, n := range other.Reasons {
+		merged.Reasons[reason] += n
+	}
+
+	for _, variation := range other.Variations {
+		merged.addVariation(variation.Value, variation.Count)
+	}
+
+	if other.LastEvaluated.After(merged.LastEvaluated) {
+		merged.LastEvaluated = other.LastEvaluated
+	}
+
+	return merged
+}
+
+func (b *UsageBucket) clone() UsageBucket {
+	clone := *b
+
+	clone.Reasons = maps.Clone(b.Reasons)
+	if clone.Reasons == nil {
+		clone.Reasons = map[EvalReason]int64{}
+	}
+
+	clone.Variations = make([]VariationCount, len(b.Variations))
+	for i, variation := range b.Variations {
+		clone.Variations[i] = VariationCount{Value: variation.Value.clone(), Count: variation.Count}
+	}
+
+	return clone
+}
+
+// sameValue reports whether a and b are the same variation. It is cheaper
+// than reflect.DeepEqual on the evaluation path.
+func sameValue(a, b Value) bool {
+	return a.Kind == b.Kind && samePointee(a.Bool, b.Bool) && samePointee(a.String, b.String) &&
+		samePointee(a.Number, b.Number)
+}
+
+func samePointee[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
+}
+
+func compareUsage(a, b UsageBucket) int {
+	return cmp.Or(strings.Compare(string(a.FlagKey), string(b.FlagKey)), a.Hour.Compare(b.Hour))
+}
+
+// UsageFilter selects the buckets of FlagKey, or of every flag when it is
+// empty, for the hours from Since on.
+type UsageFilter struct {
+	FlagKey FlagKey
+	Since   time.Time
+}
+
+func (f UsageFilter) matches(bucket UsageBucket) bool {
+	return (f.FlagKey == "" || bucket.FlagKey == f.FlagKey) && !bucket.Hour.Before(f.Since)
+}
+
+// UsageStore keeps hourly usage buckets. List returns them by flag key, then
+// by hour.
+type UsageStore interface {
+	// Add merges buckets into the stored buckets of the same flag and hour.
+	Add(ctx context.Context, buckets []UsageBucket) error
+	List(ctx context.Context, filter UsageFilter) ([]UsageBucket, error)
+	// Delete removes every bucket of the flag.
+	Delete(ctx context.Context, key FlagKey) error
+	// Prune removes the buckets of the hours before before.
+	Prune(ctx context.Context, before time.Time) error
+}
+
+// WithUsageStore replaces the default in-memory usage store.
+func WithUsageStore(store UsageStore) Option {
+	return func(s *Service) {
+		s.usageStore = store
+	}
+}
+
+type MemoryUsageStore struct {
+	mu      sync.RWMutex
+	buckets []UsageBucket
+}
+
+func NewMemoryUsageStore() *MemoryUsageStore {
+	return &MemoryUsageStore{}
+}
+
+// Add replaces merged buckets rather than changing them in place, so that a
+// FileUsageStore can restore the buckets it held before a failed write.
+func (s *MemoryUsageStore) Add(_ context.Context, buckets []UsageBucket) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, bucket := range buckets {
+		i, found := slices.BinarySearchFunc(s.buckets, bucket, compareUsage)
+		if found {
+			s.buckets[i] = s.buckets[i].Merge(bucket)
+
+			continue
+		}
+
+		s.buckets = slices.Insert(s.buckets, i, bucket.clone())
+	}
+
+	return nil
+}
+
+func (s *MemoryUsageStore) List(_ context.Context, filter UsageFilter) ([]UsageBucket, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []UsageBucket
+
+	for _, bucket := range s.buckets {
+		if filter.matches(bucket) {
+			result = append(result, bucket.clone())
+		}
+	}
+
+	return result, nil
+}
+
+func (s *MemoryUsageStore) Delete(_ context.Context, key FlagKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buckets = slices.DeleteFunc(s.buckets, func(bucket UsageBucket) bool {
+		return bucket.FlagKey == key
+	})
+
+	return nil
+}
+
+func (s *MemoryUsageStore) Prune(_ context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buckets = slices.DeleteFunc(s.buckets, func(bucket UsageBucket) bool {
+		return bucket.Hour.Before(before)
+	})
+
+	return nil
+}
+
+func (s *MemoryUsageStore) hasBefore(before time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return slices.ContainsFunc(s.buckets, func(bucket UsageBucket) bool { return bucket.Hour.Before(before) })
+}
+
+// FileUsageStore keeps usage buckets in memory and rewrites a JSON file on
+// every flush, so usage survives restarts.
+type FileUsageStore struct {
+	mu     sync.Mutex
+	path   string
+	memory *MemoryUsageStore
+	closed bool
+}
+
+// OpenFileUsageStore loads the usage buckets in path, which is created on the
+// first flush if it does not exist.
+func OpenFileUsageStore(path string) (*FileUsageStore, error) {
+	s := &FileUsageStore{path: path, memory: NewMemoryUsageStore()}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read usage: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.memory.buckets); err != nil {
+		return nil, fmt.Errorf("decode usage %s: %w", path, err)
+	}
+
+	slices.SortFunc(s.memory.buckets, compareUsage)
+
+	return s, nil
+}
+
+func (s *FileUsageStore) Add(ctx context.Context, buckets []UsageBucket) error {
+	return s.write(func() error { return s.memory.Add(ctx, buckets) })
+}
+
+func (s *FileUsageStore) List(ctx context.Context, filter UsageFilter) ([]UsageBucket, error) {
+	return s.memory.List(ctx, filter)
+}
+
+func (s *FileUsageStore) Delete(ctx context.Context, key FlagKey) error {
+	return s.write(func() error { return s.memory.Delete(ctx, key) })
+}
+
+// Prune rewrites the file only when there is something to remove, since it
+// runs after every flush.
+func (s *FileUsageStore) Prune(ctx context.Context, before time.Time) error {
+	if !s.memory.hasBefore(before) {
+		return nil
+	}
+
+	return s.write(func() error { return s.memory.Prune(ctx, before) })
+}
+
+// write applies change in memory and persists the result, undoing the change
+// when the file cannot be written.
+func (s *FileUsageStore) write(change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+
+	return rewriteJSON(&s.memory.mu, &s.memory.buckets, s.path, "usage", change)
+}
+
+// Close waits for a write in progress to finish. Later writes fail with
+// ErrStoreClosed.
+func (s *FileUsageStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+
+	return nil
+}
@@ -0,0 +1,476 @@
+package flags_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func proFlag(key flags.FlagKey) flags.Flag {
+	return flags.Flag{
+		Key: key, Type: flags.FlagBool, Enabled: true, DefaultValue: flags.BoolValue(false),
+		Rules: []flags.Rule{{
+			ID:         "pro",
+			Conditions: []flags.Condition{{Attr: "plan", Op: flags.OpEquals, Value: "pro"}},
+			Value:      flags.BoolValue(true),
+		}},
+	}
+}
+
+var proUser = flags.EvalContext{Attrs: map[string]any{"plan": "pro"}}
+
+func evaluateTimes(t *testing.T, svc *flags.Service, evalCtx flags.EvalContext, times int) {
+	t.Helper()
+
+	for range times {
+		_, err := svc.Evaluate(context.Background(), "checkout", evalCtx)
+		require.NoError(t, err)
+	}
+}
+
+func TestService_Usage(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, proFlag("checkout"))
+	require.NoError(t, err)
+
+	start := clock.Now()
+
+	evaluateTimes(t, svc, proUser, 3)
+	evaluateTimes(t, svc, flags.EvalContext{}, 1)
+
+	flushed, err := svc.FlushUsage(ctx)
+	require.NoError(t, err)
+	require.Len(t, flushed, 1)
+
+	clock.Advance(90 * time.Minute)
+	evaluateTimes(t, svc, proUser, 1)
+
+	// An evaluation as of another time still counts now.
+	_, err = svc.Evaluate(ctx, "checkout", flags.EvalContext{Now: start.Add(-48 * time.Hour)})
+	require.NoError(t, err)
+
+	usage, err := svc.Usage(ctx, "checkout", time.Time{})
+	require.NoError(t, err)
+
+	assert.Equal(t, flags.FlagKey("checkout"), usage.FlagKey)
+	assert.Equal(t, clock.Now().Add(-flags.DefaultUsageWindow).Truncate(time.Hour), usage.Since)
+	assert.Equal(t, clock.Now(), usage.LastEvaluated)
+	assert.Equal(t, int64(6), usage.Evaluations)
+	assert.Equal(t, map[flags.EvalReason]int64{flags.ReasonRuleMatch: 4, flags.ReasonDefault: 2}, usage.Reasons)
+	assert.Equal(t, []flags.VariationCount{
+		{Value: flags.BoolValue(true), Count: 4},
+		{Value: flags.BoolValue(false), Count: 2},
+	}, usage.Variations)
+
+	require.Len(t, usage.Hours, 2)
+	assert.Equal(t, start, usage.Hours[0].Hour)
+	assert.Equal(t, int64(4), usage.Hours[0].Evaluations)
+	assert.Equal(t, start, usage.Hours[0].LastEvaluated)
+	assert.Equal(t, start.Add(time.Hour), usage.Hours[1].Hour)
+	assert.Equal(t, int64(2), usage.Hours[1].Evaluations, "unflushed evaluations are counted")
+
+	recent, err := svc.Usage(ctx, "checkout", clock.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), recent.Evaluations)
+	assert.Equal(t, clock.Now(), recent.LastEvaluated)
+	require.Len(t, recent.Hours, 1)
+}
+
+func TestService_Usage_OnlyEvaluations(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(newFakeClock()))
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, proFlag("checkout"))
+	require.NoError(t, err)
+
+	_, err = svc.Simulate(ctx, proFlag("checkout"), []flags.EvalContext{proUser})
+	require.NoError(t, err)
+
+	_, err = svc.RunTests(ctx, "checkout")
+	require.NoError(t, err)
+
+	usage, err := svc.Usage(ctx, "checkout", time.Time{})
+	require.NoError(t, err)
+	assert.Zero(t, usage.Evaluations)
+	assert.True(t, usage.LastEvaluated.IsZero())
+	assert.Empty(t, usage.Variations)
+	assert.NotNil(t, usage.Hours)
+}
+
+func TestService_Usage_Errors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	_, err := flags.NewService(flags.NewMemoryRepository()).Usage(ctx, "missing", time.Time{})
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	_, err = flags.NewService(flags.NewMemoryRepository(), flags.WithAuthorizer(grant{})).
+		Usage(ctx, "checkout", time.Time{})
+	require.ErrorIs(t, err, flags.ErrForbidden)
+
+	repo := flags.NewMemoryRepository()
+	require.NoError(t, repo.Create(ctx, proFlag("checkout")))
+
+	_, err = flags.NewService(repo, flags.WithUsageStore(failingUsageStore{})).Usage(ctx, "checkout", time.Time{})
+	require.ErrorIs(t, err, errUsageStore)
+}
+
+func TestService_Usage_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(newFakeClock()))
+	ctx := context.Background()
+	keys := []flags.FlagKey{"a", "b", "c", "d"}
+
+	for _, key := range keys {
+		_, err := svc.Create(ctx, proFlag(key))
+		require.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range 8 {
+		wg.Go(func() {
+			for range 250 {
+				_, _ = svc.Evaluate(ctx, keys[i%len(keys)], proUser)
+			}
+
+			_, _ = svc.FlushUsage(ctx)
+		})
+	}
+
+	wg.Wait()
+
+	for _, key := range keys {
+		usage, err := svc.Usage(ctx, key, time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(500), usage.Evaluations, key)
+	}
+}
+
+func TestService_FlushUsage_Failure(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := &flakyUsageStore{MemoryUsageStore: flags.NewMemoryUsageStore(), fail: true}
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(newFakeClock()), flags.WithUsageStore(store))
+
+	_, err := svc.Create(ctx, proFlag("checkout"))
+	require.NoError(t, err)
+
+	evaluateTimes(t, svc, proUser, 2)
+
+	_, err = svc.FlushUsage(ctx)
+	require.ErrorIs(t, err, errUsageStore)
+
+	evaluateTimes(t, svc, proUser, 1)
+
+	store.fail = false
+	flushed, err := svc.FlushUsage(ctx)
+	require.NoError(t, err)
+	require.Len(t, flushed, 1)
+	assert.Equal(t, int64(3), flushed[0].Evaluations, "the failed flush is retried")
+
+	stored, err := store.List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, int64(3), stored[0].Evaluations)
+
+	store.failPrune = true
+	_, err = svc.FlushUsage(ctx)
+	require.ErrorIs(t, err, errUsageStore)
+}
+
+func TestService_FlushUsage_PrunesOldUsage(t *testing.T) {
+	t.Parallel()
+
+	clock := newFakeClock()
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, proFlag("checkout"))
+	require.NoError(t, err)
+
+	evaluateTimes(t, svc, proUser, 1)
+	clock.Advance(flags.UsageRetention + time.Hour)
+	evaluateTimes(t, svc, proUser, 1)
+
+	_, err = svc.FlushUsage(ctx)
+	require.NoError(t, err)
+
+	usage, err := svc.Usage(ctx, "checkout", clock.Now().Add(-2*flags.UsageRetention))
+	require.NoError(t, err)
+	require.Len(t, usage.Hours, 1)
+	assert.Equal(t, clock.Now(), usage.Hours[0].Hour)
+}
+
+func TestService_Delete_RemovesUsage(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(newFakeClock()))
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, proFlag("checkout"))
+	require.NoError(t, err)
+
+	evaluateTimes(t, svc, proUser, 1)
+
+	_, err = svc.FlushUsage(ctx)
+	require.NoError(t, err)
+
+	evaluateTimes(t, svc, proUser, 1)
+	require.NoError(t, svc.Delete(ctx, "checkout"))
+
+	_, err = svc.Create(ctx, proFlag("checkout"))
+	require.NoError(t, err)
+
+	usage, err := svc.Usage(ctx, "checkout", time.Time{})
+	require.NoError(t, err)
+	assert.Zero(t, usage.Evaluations, "a recreated flag starts without usage")
+
+	failing := flags.NewService(flags.NewMemoryRepository(), flags.WithUsageStore(failingUsageStore{}))
+
+	_, err = failing.Create(ctx, proFlag("checkout"))
+	require.NoError(t, err)
+	require.ErrorIs(t, failing.Delete(ctx, "checkout"), errUsageStore)
+}
+
+func TestService_WatchUsage(t *testing.T) {
+	t.Parallel()
+
+	svc := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(newFakeClock()))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, err := svc.Create(ctx, proFlag("checkout"))
+	require.NoError(t, err)
+
+	evaluateTimes(t, svc, proUser, 1)
+
+	var reported []flags.UsageBucket
+
+	svc.WatchUsage(ctx, time.Hour, func(flushed []flags.UsageBucket, err error) {
+		require.NoError(t, err)
+
+		reported = flushed
+
+		cancel()
+	})
+
+	require.Len(t, reported, 1)
+	assert.Equal(t, int64(1), reported[0].Evaluations)
+}
+
+func TestMemoryUsageStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	store := flags.NewMemoryUsageStore()
+	hour := time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC)
+
+	bucket := func(key flags.FlagKey, hour time.Time, value flags.Value, n int64) flags.UsageBucket {
+		return flags.UsageBucket{
+			FlagKey: key, Hour: hour, Evaluations: n, LastEvaluated: hour.Add(time.Minute),
+			Reasons:    map[flags.EvalReason]int64{flags.ReasonDefault: n},
+			Variations: []flags.VariationCount{{Value: value, Count: n}},
+		}
+	}
+
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{
+		bucket("b", hour, flags.StringValue("x"), 1),
+		bucket("a", hour.Add(time.Hour), flags.StringValue("x"), 2),
+		bucket("a", hour, flags.StringValue("x"), 3),
+	}))
+	require.NoError(t, store.Add(ctx, []flags.UsageBucket{bucket("a", hour, flags.StringValue("y"), 4)}))
+
+	all, err := store.List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, flags.FlagKey("a"), all[0].FlagKey)
+	assert.Equal(t, hour, all[0].Hour)
+	assert.Equal(t, int64(7), all[0].Evaluations)
+	assert.Equal(t, int64(7), all[0].Reasons[flags.ReasonDefault])
+	assert.Equal(t, []flags.VariationCount{
+		{Value: flags.StringValue("x"), Count: 3}, {Value: flags.StringValue("y"), Count: 4},
+	}, all[0].Variations)
+	assert.Equal(t, hour.Add(time.Hour), all[1].Hour)
+	assert.Equal(t, flags.FlagKey("b"), all[2].FlagKey)
+
+	all[0].Reasons[flags.ReasonDefault] = 100
+	all[0].Variations[0].Count = 100
+
+	since, err := store.List(ctx, flags.UsageFilter{FlagKey: "a", Since: hour.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, since, 1)
+	assert.Equal(t, int64(2), since[0].Evaluations)
+
+	first, err := store.List(ctx, flags.UsageFilter{FlagKey: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), first[0].Reasons[flags.ReasonDefault], "List returns copies")
+	assert.Equal(t, int64(3), first[0].Variations[0].Count)
+
+	require.NoError(t, store.Prune(ctx, hour.Add(time.Hour)))
+	require.NoError(t, store.Delete(ctx, "b"))
+
+	all, err = store.List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, hour.Add(time.Hour), all[0].Hour)
+}
+
+func TestMemoryUsageStore_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunUsageStoreSuite(t, func(*testing.T) flags.UsageStore {
+		return flags.NewMemoryUsageStore()
+	})
+}
+
+func TestFileUsageStore_Conformance(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunUsageStoreSuite(t, func(t *testing.T) flags.UsageStore {
+		t.Helper()
+
+		store, err := flags.OpenFileUsageStore(filepath.Join(t.TempDir(), "usage.json"))
+		require.NoError(t, err)
+
+		return store
+	})
+}
+
+func TestFileUsageStore_PersistsAcrossReopen(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "usage.json")
+	clock := newFakeClock()
+	repo := flags.NewMemoryRepository()
+	ctx := context.Background()
+
+	store, err := flags.OpenFileUsageStore(path)
+	require.NoError(t, err)
+
+	svc := flags.NewService(repo, flags.WithClock(clock), flags.WithUsageStore(store))
+
+	_, err = svc.Create(ctx, proFlag("checkout"))
+	require.NoError(t, err)
+
+	evaluateTimes(t, svc, proUser, 2)
+
+	_, err = svc.FlushUsage(ctx)
+	require.NoError(t, err)
+
+	reopened, err := flags.OpenFileUsageStore(path)
+	require.NoError(t, err)
+
+	restarted := flags.NewService(repo, flags.WithClock(clock), flags.WithUsageStore(reopened))
+
+	usage, err := restarted.Usage(ctx, "checkout", time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), usage.Evaluations)
+	assert.Equal(t, []flags.VariationCount{{Value: flags.BoolValue(true), Count: 2}}, usage.Variations)
+
+	clock.Advance(flags.UsageRetention + time.Hour)
+
+	_, err = restarted.FlushUsage(ctx)
+	require.NoError(t, err)
+
+	pruned, err := flags.OpenFileUsageStore(path)
+	require.NoError(t, err)
+
+	all, err := pruned.List(ctx, flags.UsageFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, all)
+
+	require.NoError(t, pruned.Delete(ctx, "checkout"))
+}
+
+func TestFileUsageStore_WritesFailAfterClose(t *testing.T) {
+	t.Parallel()
+
+	store, err := flags.OpenFileUsageStore(filepath.Join(t.TempDir(), "usage.json"))
+	require.NoError(t, err)
+	require.NoError(t, store.Close())
+
+	ctx := context.Background()
+	assert.ErrorIs(t, store.Add(ctx, []flags.UsageBucket{{FlagKey: "checkout"}}), flags.ErrStoreClosed)
+}
+
+func TestFileUsageStore_Errors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.json")
+	require.NoError(t, os.WriteFile(corrupt, []byte("{"), 0o600))
+
+	_, err := flags.OpenFileUsageStore(corrupt)
+	require.Error(t, err)
+
+	_, err = flags.OpenFileUsageStore(dir)
+	require.Error(t, err)
+
+	store, err := flags.OpenFileUsageStore(filepath.Join(dir, "missing", "usage.json"))
+	require.NoError(t, err)
+
+	bucket := flags.UsageBucket{FlagKey: "checkout", Hour: time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC), Evaluations: 1}
+	require.Error(t, store.Add(context.Background(), []flags.UsageBucket{bucket}))
+
+	all, err := store.List(context.Background(), flags.UsageFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, all, "a failed write is undone")
+}
+
+var errUsageStore = errors.New("usage store unavailable")
+
+// failingUsageStore fails every call.
+type failingUsageStore struct{}
+
+func (failingUsageStore) Add(context.Context, []flags.UsageBucket) error { return errUsageStore }
+
+func (failingUsageStore) List(context.Context, flags.UsageFilter) ([]flags.UsageBucket, error) {
+	return nil, errUsageStore
+}
+
+func (failingUsageStore) Delete(context.Context, flags.FlagKey) error { return errUsageStore }
+
+func (failingUsageStore) Prune(context.Context, time.Time) error { return errUsageStore }
+
+// flakyUsageStore fails Add while fail is set and Prune while failPrune is.
+type flakyUsageStore struct {
+	*flags.MemoryUsageStore
+
+	fail      bool
+	failPrune bool
+}
+
+func (s *flakyUsageStore) Add(ctx context.Context, buckets []flags.UsageBucket) error {
+	if s.fail {
+		return errUsageStore
+	}
+
+	return s.MemoryUsageStore.Add(ctx, buckets)
+}
+
+func (s *flakyUsageStore) Prune(ctx context.Context, before time.Time) error {
+	if s.failPrune {
+		return errUsageStore
+	}
+
+	return s.MemoryUsageStore.Prune(ctx, before)
+}
@@ -0,0 +1,153 @@
+// Package flagsfile loads flag definitions from YAML or JSON files and
+// reconciles a flags.Service with them, so flags can be reviewed as code.
+package flagsfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/handler"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrInvalidFile  = errors.New("invalid flags file")
+	ErrDuplicateKey = errors.New("flag declared more than once")
+)
+
+// Document is the file format. Each flag has the same shape as the body of
+// POST /flags and is validated against the same schema.
+type Document struct {
+	Flags []handler.CreateFlagBody `json:"flags"`
+}
+
+var (
+	registry       = huma.NewMapRegistry("#/components/schemas/", huma.DefaultSchemaNamer)
+	documentSchema = registry.Schema(reflect.TypeFor[Document](), true, "")
+)
+
+// Load reads the flags declared in path, which is either a single file or a
+// directory whose *.yaml, *.yml and *.json files are read in name order.
+// Loaded flags are marked as managed by files.
+func Load(path string) ([]flags.Flag, error) {
+	files, err := listFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []flags.Flag
+
+	declaredIn := make(map[flags.FlagKey]string)
+
+	for _, file := range files {
+		loaded, err := loadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, flag := range loaded {
+			if other, ok := declaredIn[flag.Key]; ok {
+				return nil, fmt.Errorf("%w: %s in %s and %s", ErrDuplicateKey, flag.Key, other, file)
+			}
+
+			declaredIn[flag.Key] = file
+			result = append(result, flag)
+		}
+	}
+
+	slices.SortFunc(result, func(a, b flags.Flag) int { return strings.Compare(string(a.Key), string(b.Key)) })
+
+	return result, nil
+}
+
+func listFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("read flags: %w", err)
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("read flags: %w", err)
+	}
+
+	var files []string
+
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+	}
+
+	return files, nil
+}
+
+func loadFile(path string) ([]flags.Flag, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read flags: %w", err)
+	}
+
+	doc, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrInvalidFile, path, err)
+	}
+
+	result := make([]flags.Flag, len(doc.Flags))
+	for i, body := range doc.Flags {
+		result[i] = handler.ToFlag(body)
+		result[i].ManagedBy = flags.ManagedByFile
+	}
+
+	return result, nil
+}
+
+// decode parses YAML (and therefore JSON), validates it against the document
+// schema and converts it to a Document.
+func decode(data []byte) (Document, error) {
+	var raw any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return Document{}, err
+	}
+
+	// Round-trip through JSON so the validator sees the same types it sees for
+	// request bodies (float64 numbers, map[string]any objects).
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return Document{}, err
+	}
+
+	var value any
+	if err := json.Unmarshal(normalized, &value); err != nil {
+		return Document{}, err
+	}
+
+	res := &huma.ValidateResult{}
+	huma.Validate(registry, documentSchema, huma.NewPathBuffer([]byte{}, 0), huma.ModeWriteToServer, value, res)
+
+	if len(res.Errors) > 0 {
+		return Document{}, errors.Join(res.Errors...)
+	}
+
+	var doc Document
+	if err := json.Unmarshal(normalized, &doc); err != nil {
+		return Document{}, err
+	}
+
+	return doc, nil
+}
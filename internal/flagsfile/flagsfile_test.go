@@ -0,0 +1,179 @@
+package flagsfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flagsfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const darkModeYAML = `
+flags:
+  - key: dark-mode
+    owner: web
+    tags: [ui]
+    kind: release
+    type: bool
+    enabled: true
+    defaultValue: {kind: bool, bool: false}
+    rules:
+      - id: beta
+        conditions:
+          - {attr: plan, op: in, value: [pro, enterprise]}
+          - {attr: seats, op: eq, value: 10}
+        value: {kind: bool, bool: true}
+`
+
+const checkoutJSON = `{
+  "flags": [
+    {
+      "key": "checkout",
+      "type": "string",
+      "enabled": false,
+      "defaultValue": {"kind": "string", "string": "v1"}
+    }
+  ]
+}`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestLoad_YAML(t *testing.T) {
+	t.Parallel()
+
+	path := writeFile(t, t.TempDir(), "flags.yaml", darkModeYAML)
+
+	loaded, err := flagsfile.Load(path)
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+
+	assert.Equal(t, flags.Flag{
+		Key:          "dark-mode",
+		Owner:        "web",
+		Tags:         []string{"ui"},
+		Kind:         flags.KindRelease,
+		Type:         flags.FlagBool,
+		Enabled:      true,
+		DefaultValue: flags.BoolValue(false),
+		Rules: []flags.Rule{{
+			ID: "beta",
+			Conditions: []flags.Condition{
+				{Attr: "plan", Op: flags.OpIn, Value: []any{"pro", "enterprise"}},
+				{Attr: "seats", Op: flags.OpEquals, Value: float64(10)},
+			},
+			Value: flags.BoolValue(true),
+		}},
+		ManagedBy: flags.ManagedByFile,
+	}, loaded[0])
+}
+
+func TestLoad_JSON(t *testing.T) {
+	t.Parallel()
+
+	path := writeFile(t, t.TempDir(), "flags.json", checkoutJSON)
+
+	loaded, err := flagsfile.Load(path)
+	require.NoError(t, err)
+	require.Len(t, loaded, 1)
+	assert.Equal(t, flags.FlagKey("checkout"), loaded[0].Key)
+	assert.Equal(t, flags.StringValue("v1"), loaded[0].DefaultValue)
+}
+
+func TestLoad_Directory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "b.yml", darkModeYAML)
+	writeFile(t, dir, "a.json", checkoutJSON)
+	writeFile(t, dir, "README.md", "not a flags file")
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested.yaml"), 0o750))
+
+	loaded, err := flagsfile.Load(dir)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, flags.FlagKey("checkout"), loaded[0].Key)
+	assert.Equal(t, flags.FlagKey("dark-mode"), loaded[1].Key)
+}
+
+func TestLoad_DuplicateKey(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "a.yaml", darkModeYAML)
+	writeFile(t, dir, "b.yaml", darkModeYAML)
+
+	_, err := flagsfile.Load(dir)
+	require.ErrorIs(t, err, flagsfile.ErrDuplicateKey)
+	assert.ErrorContains(t, err, "a.yaml and ")
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "syntax",
+			content: "flags: [",
+			want:    "yaml",
+		},
+		{
+			name:    "key pattern",
+			content: "flags:\n  - {key: Dark, type: bool, enabled: true, defaultValue: {kind: bool}}",
+			want:    "flags[0].key",
+		},
+		{
+			name:    "missing field",
+			content: "flags:\n  - {key: dark, type: bool, enabled: true}",
+			want:    "defaultValue",
+		},
+		{
+			name: "unknown operator",
+			content: "flags:\n  - {key: dark, type: bool, enabled: true, defaultValue: {kind: bool}, " +
+				"rules: [{id: r, value: {kind: bool}, conditions: [{attr: a, op: gt, value: 1}]}]}",
+			want: "flags[0].rules[0].conditions[0].op",
+		},
+		{
+			name:    "unknown property",
+			content: "flags: []\nsegments: []",
+			want:    "segments",
+		},
+		{
+			name:    "empty",
+			content: "",
+			want:    "expected object",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := writeFile(t, t.TempDir(), "flags.yaml", tt.content)
+
+			_, err := flagsfile.Load(path)
+			require.ErrorIs(t, err, flagsfile.ErrInvalidFile)
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	t.Parallel()
+
+	_, err := flagsfile.Load(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,172 @@
+package flagsfile
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+)
+
+// Actor is recorded in the audit log for changes applied from files.
+const Actor = "flags-file"
+
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+type Change struct {
+	Action Action
+	Key    flags.FlagKey
+	Diff   []flags.FieldChange
+}
+
+// Service is the subset of flags.Service the syncer writes through, so file
+// changes get the same versioning and audit trail as API changes.
+type Service interface {
+	List(ctx context.Context, filter flags.FlagFilter) ([]flags.Flag, error)
+	Create(ctx context.Context, flag flags.Flag) (flags.Flag, error)
+	Update(ctx context.Context, flag flags.Flag) (flags.Flag, error)
+	Delete(ctx context.Context, key flags.FlagKey) error
+}
+
+// Plan returns the changes, ordered by key, that make current match desired.
+// Flags declared in desired are created or updated (taking over flags that
+// were created through the API); file-managed flags missing from desired are
+// deleted. Other flags are left alone.
+func Plan(current, desired []flags.Flag) []Change {
+	existing := make(map[flags.FlagKey]flags.Flag, len(current))
+	for _, flag := range current {
+		existing[flag.Key] = flag
+	}
+
+	declared := make(map[flags.FlagKey]bool, len(desired))
+
+	var changes []Change
+
+	for _, flag := range desired {
+		declared[flag.Key] = true
+
+		prev, ok := existing[flag.Key]
+		if !ok {
+			changes = append(changes, Change{Action: ActionCreate, Key: flag.Key, Diff: flags.Diff(nil, &flag)})
+
+			continue
+		}
+
+		if diff := flags.Diff(&prev, &flag); len(diff) > 0 {
+			changes = append(changes, Change{Action: ActionUpdate, Key: flag.Key, Diff: diff})
+		}
+	}
+
+	for _, flag := range current {
+		if flag.ManagedBy == flags.ManagedByFile && !declared[flag.Key] {
+			changes = append(changes, Change{Action: ActionDelete, Key: flag.Key, Diff: flags.Diff(&flag, nil)})
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b Change) int { return strings.Compare(string(a.Key), string(b.Key)) })
+
+	return changes
+}
+
+type Syncer struct {
+	service Service
+	path    string
+	dryRun  bool
+}
+
+type SyncerOption func(*Syncer)
+
+// WithDryRun makes Sync compute changes without applying them.
+func WithDryRun(dryRun bool) SyncerOption {
+	return func(s *Syncer) {
+		s.dryRun = dryRun
+	}
+}
+
+// NewSyncer returns a Syncer reconciling service with the flags declared in
+// path; see Load.
+func NewSyncer(service Service, path string, opts ...SyncerOption) *Syncer {
+	s := &Syncer{service: service, path: path}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Sync loads the files, plans the changes and applies them in order. It stops
+// at the first change that fails and returns the changes applied before it.
+func (s *Syncer) Sync(ctx context.Context) ([]Change, error) {
+	desired, err := Load(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.service.List(ctx, flags.FlagFilter{})
+	if err != nil {
+		return nil, fmt.Errorf("list flags: %w", err)
+	}
+
+	changes := Plan(current, desired)
+	if s.dryRun {
+		return changes, nil
+	}
+
+	ctx = flags.WithActor(flags.WithManager(ctx, flags.ManagedByFile), Actor)
+
+	byKey := make(map[flags.FlagKey]flags.Flag, len(desired))
+	for _, flag := range desired {
+		byKey[flag.Key] = flag
+	}
+
+	for i, change := range changes {
+		if err := s.apply(ctx, change, byKey[change.Key]); err != nil {
+			return changes[:i], fmt.Errorf("%s %s: %w", change.Action, change.Key, err)
+		}
+	}
+
+	return changes, nil
+}
+
+// Watch calls Sync every interval until ctx is done, passing each result
+// that has changes or an error to report.
+func (s *Syncer) Watch(ctx context.Context, interval time.Duration, report func([]Change, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changes, err := s.Sync(ctx)
+			if len(changes) > 0 || err != nil {
+				report(changes, err)
+			}
+		}
+	}
+}
+
+func (s *Syncer) apply(ctx context.Context, change Change, flag flags.Flag) error {
+	var err error
+
+	switch change.Action {
+	case ActionCreate:
+		_, err = s.service.Create(ctx, flag)
+	case ActionUpdate:
+		_, err = s.service.Update(ctx, flag)
+	case ActionDelete:
+		err = s.service.Delete(ctx, change.Key)
+	}
+
+	return err
+}
@@ -0,0 +1,226 @@
+package flagsfile_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flagsfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+
+	managed := func(key flags.FlagKey, enabled bool) flags.Flag {
+		return flags.Flag{Key: key, Type: flags.FlagBool, Enabled: enabled, ManagedBy: flags.ManagedByFile}
+	}
+
+	current := []flags.Flag{
+		managed("changed", true),
+		managed("removed", true),
+		managed("same", true),
+		{Key: "api-only", Type: flags.FlagBool},
+		{Key: "taken-over", Type: flags.FlagBool, Enabled: true},
+	}
+	current[2].Version = 7 // versions and timestamps are not part of the comparison
+
+	desired := []flags.Flag{
+		managed("added", true),
+		managed("changed", false),
+		managed("same", true),
+		managed("taken-over", true),
+	}
+
+	changes := flagsfile.Plan(current, desired)
+
+	require.Len(t, changes, 4)
+	assert.Equal(t, flagsfile.Change{
+		Action: flagsfile.ActionCreate,
+		Key:    "added",
+		Diff:   flags.Diff(nil, &desired[0]),
+	}, changes[0])
+	assert.Equal(t, flagsfile.Change{
+		Action: flagsfile.ActionUpdate,
+		Key:    "changed",
+		Diff:   []flags.FieldChange{{Path: "enabled", Kind: flags.ChangeModified, Before: true, After: false}},
+	}, changes[1])
+	assert.Equal(t, flagsfile.ActionDelete, changes[2].Action)
+	assert.Equal(t, flags.FlagKey("removed"), changes[2].Key)
+	assert.Equal(t, flagsfile.Change{
+		Action: flagsfile.ActionUpdate,
+		Key:    "taken-over",
+		Diff: []flags.FieldChange{
+			{Path: "managedBy", Kind: flags.ChangeModified, Before: "", After: flags.ManagedByFile},
+		},
+	}, changes[3])
+}
+
+func TestSyncer_Sync(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "dark-mode.yaml", darkModeYAML)
+	writeFile(t, dir, "checkout.json", checkoutJSON)
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	syncer := flagsfile.NewSyncer(svc, dir)
+	ctx := context.Background()
+
+	changes, err := syncer.Sync(ctx)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	flag, err := svc.Get(ctx, "dark-mode")
+	require.NoError(t, err)
+	assert.Equal(t, flags.ManagedByFile, flag.ManagedBy)
+
+	// A second pass over unchanged files is a no-op.
+	changes, err = syncer.Sync(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// Flags owned by files cannot be changed through the API.
+	_, err = svc.Update(ctx, flag)
+	require.ErrorIs(t, err, flags.ErrFlagReadOnly)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "checkout.json")))
+	writeFile(t, dir, "dark-mode.yaml",
+		"flags:\n  - {key: dark-mode, type: bool, enabled: false, defaultValue: {kind: bool, bool: false}}")
+
+	changes, err = syncer.Sync(ctx)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	assert.Equal(t, flagsfile.ActionDelete, changes[0].Action)
+	assert.Equal(t, flagsfile.ActionUpdate, changes[1].Action)
+
+	_, err = svc.Get(ctx, "checkout")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	flag, err = svc.Get(ctx, "dark-mode")
+	require.NoError(t, err)
+	assert.False(t, flag.Enabled)
+	assert.Empty(t, flag.Rules)
+	assert.Equal(t, 2, flag.Version)
+
+	page, err := svc.AuditLog(ctx, flags.AuditFilter{Actor: flagsfile.Actor})
+	require.NoError(t, err)
+	assert.Len(t, page.Entries, 4)
+}
+
+func TestSyncer_DryRun(t *testing.T) {
+	t.Parallel()
+
+	path := writeFile(t, t.TempDir(), "flags.yaml", darkModeYAML)
+	svc := flags.NewService(flags.NewMemoryRepository())
+
+	changes, err := flagsfile.NewSyncer(svc, path, flagsfile.WithDryRun(true)).Sync(context.Background())
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, flagsfile.ActionCreate, changes[0].Action)
+
+	_, err = svc.Get(context.Background(), "dark-mode")
+	assert.ErrorIs(t, err, flags.ErrFlagNotFound)
+}
+
+func TestSyncer_InvalidFile(t *testing.T) {
+	t.Parallel()
+
+	path := writeFile(t, t.TempDir(), "flags.yaml", "flags: [{key: Bad}]")
+	svc := flags.NewService(flags.NewMemoryRepository())
+
+	_, err := flagsfile.NewSyncer(svc, path).Sync(context.Background())
+	assert.ErrorIs(t, err, flagsfile.ErrInvalidFile)
+}
+
+type failingService struct {
+	flagsfile.Service
+	listErr   error
+	createErr error
+}
+
+func (s failingService) List(ctx context.Context, filter flags.FlagFilter) ([]flags.Flag, error) {
+	if s.listErr != nil {
+		return nil, s.listErr
+	}
+
+	return s.Service.List(ctx, filter)
+}
+
+func (s failingService) Create(ctx context.Context, flag flags.Flag) (flags.Flag, error) {
+	if s.createErr != nil && flag.Key == "dark-mode" {
+		return flags.Flag{}, s.createErr
+	}
+
+	return s.Service.Create(ctx, flag)
+}
+
+func TestSyncer_ServiceErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFile(t, dir, "dark-mode.yaml", darkModeYAML)
+	writeFile(t, dir, "checkout.json", checkoutJSON)
+
+	svc := flags.NewService(flags.NewMemoryRepository())
+	unavailable := errors.New("unavailable")
+
+	_, err := flagsfile.NewSyncer(failingService{Service: svc, listErr: unavailable}, dir).Sync(context.Background())
+	require.ErrorIs(t, err, unavailable)
+
+	changes, err := flagsfile.NewSyncer(failingService{Service: svc, createErr: unavailable}, dir).
+		Sync(context.Background())
+	require.ErrorIs(t, err, unavailable)
+	require.ErrorContains(t, err, "create dark-mode")
+	require.Len(t, changes, 1, "checkout sorts first and was applied")
+	assert.Equal(t, flags.FlagKey("checkout"), changes[0].Key)
+}
+
+func TestSyncer_Watch(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	svc := flags.NewService(flags.NewMemoryRepository())
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var (
+		mu      sync.Mutex
+		reports []error
+	)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		flagsfile.NewSyncer(svc, dir).Watch(ctx, 5*time.Millisecond, func(_ []flagsfile.Change, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			reports = append(reports, err)
+		})
+	}()
+
+	writeFile(t, dir, "flags.yaml", darkModeYAML)
+
+	assert.Eventually(t, func() bool {
+		_, err := svc.Get(context.Background(), "dark-mode")
+
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.NotEmpty(t, reports)
+	assert.NoError(t, reports[len(reports)-1])
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/features/internal/flags"
+)
+
+// ChangeService is the part of FlagService that reviews and applies change
+// requests.
+type ChangeService interface {
+	ChangeRequests(ctx context.Context, filter flags.ChangeFilter) ([]flags.ChangeRequest, error)
+	ChangeRequest(ctx context.Context, id string) (flags.ChangeRequest, error)
+	Approve(ctx context.Context, id, comment string) (flags.ChangeRequest, error)
+	Reject(ctx context.Context, id, comment string) (flags.ChangeRequest, error)
+	Comment(ctx context.Context, id, text string) (flags.ChangeRequest, error)
+	Apply(ctx context.Context, id string) (flags.ChangeRequest, error)
+}
+
+func (h *Handler) ListChanges(ctx context.Context, req *ListChangesRequest) (*ListChangesResponse, error) {
+	changes, err := h.service.ChangeRequests(ctx, ToChangeFilter(req))
+	if err != nil {
+		return nil, changeError(err, "failed to list change requests")
+	}
+
+	return &ListChangesResponse{Body: ToListChangesResponseBody(changes)}, nil
+}
+
+func (h *Handler) GetChange(ctx context.Context, req *ChangeIDRequest) (*ChangeResponse, error) {
+	change, err := h.service.ChangeRequest(ctx, req.ID)
+	if err != nil {
+		return nil, changeError(err, "failed to get change request")
+	}
+
+	return &ChangeResponse{Body: ToChangeRequestBody(change)}, nil
+}
+
+func (h *Handler) ApproveChange(ctx context.Context, req *ReviewChangeRequest) (*ChangeResponse, error) {
+	change, err := h.service.Approve(ctx, req.ID, req.Body.Comment)
+	if err != nil {
+		return nil, changeError(err, "failed to approve change request")
+	}
+
+	return &ChangeResponse{Body: ToChangeRequestBody(change)}, nil
+}
+
+func (h *Handler) RejectChange(ctx context.Context, req *ReviewChangeRequest) (*ChangeResponse, error) {
+	change, err := h.service.Reject(ctx, req.ID, req.Body.Comment)
+	if err != nil {
+		return nil, changeError(err, "failed to reject change request")
+	}
+
+	return &ChangeResponse{Body: ToChangeRequestBody(change)}, nil
+}
+
+func (h *Handler) CommentChange(ctx context.Context, req *CommentChangeRequest) (*ChangeResponse, error) {
+	change, err := h.service.Comment(ctx, req.ID, req.Body.Text)
+	if err != nil {
+		return nil, changeError(err, "failed to comment on change request")
+	}
+
+	return &ChangeResponse{Body: ToChangeRequestBody(change)}, nil
+}
+
+func (h *Handler) ApplyChange(ctx context.Context, req *ChangeIDRequest) (*ChangeResponse, error) {
+	change, err := h.service.Apply(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, flags.ErrFlagExists) {
+			return nil, huma.Error409Conflict("flag already exists")
+		}
+
+		return nil, changeError(err, "failed to apply change request")
+	}
+
+	return &ChangeResponse{Body: ToChangeRequestBody(change)}, nil
+}
+
+// changeError maps the change request errors to HTTP errors and leaves the
+// rest to flagError.
+func changeError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, flags.ErrChangeNotFound):
+		return huma.Error404NotFound("change request not found")
+	case errors.Is(err, flags.ErrSelfApproval):
+		return huma.Error403Forbidden(err.Error())
+	case errors.Is(err, flags.ErrChangeClosed),
+		errors.Is(err, flags.ErrChangeNotReady),
+		errors.Is(err, flags.ErrChangeConflict):
+		return huma.Error409Conflict(err.Error())
+	default:
+		return flagError(err, fallback)
+	}
+}
+
+// pendingChangeError is the 202 Accepted response of a mutation that opened a
+// change request. It is returned as an error because it replaces the
+// operation's usual response.
+type pendingChangeError struct {
+	ChangeRequestBody
+}
+
+func (e *pendingChangeError) Error() string {
+	return "change request " + e.ID + " awaits approval"
+}
+
+func (e *pendingChangeError) GetStatus() int {
+	return http.StatusAccepted
+}
+
+func (e *pendingChangeError) GetHeaders() http.Header {
+	return http.Header{"Location": {"/changes/" + e.ID}}
+}
+
+// emergency marks ctx for an emergency change when requested.
+func emergency(ctx context.Context, requested bool) context.Context {
+	if requested {
+		return flags.WithEmergency(ctx)
+	}
+
+	return ctx
+}
@@ -0,0 +1,174 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// newChangesAPI returns an API over a real service where the X-Actor header
+// names the caller.
+func newChangesAPI(t *testing.T) humatest.TestAPI {
+	t.Helper()
+
+	_, api := humatest.New(t)
+	api.UseMiddleware(func(ctx huma.Context, next func(huma.Context)) {
+		next(huma.WithContext(ctx, flags.WithActor(ctx.Context(), ctx.Header("X-Actor"))))
+	})
+	handler.New(flags.NewService(flags.NewMemoryRepository())).Register(api)
+
+	return api
+}
+
+func decodeChange(t *testing.T, resp *httptest.ResponseRecorder) handler.ChangeRequestBody {
+	t.Helper()
+
+	var change handler.ChangeRequestBody
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &change), resp.Body.String())
+
+	return change
+}
+
+func TestChanges_Lifecycle(t *testing.T) {
+	t.Parallel()
+
+	api := newChangesAPI(t)
+	on, off := true, false
+
+	resp := api.Post("/flags", "X-Actor: alice", handler.CreateFlagBody{
+		Key: "kill-switch", Type: "bool", Enabled: true, Protected: true,
+		DefaultValue: handler.ValueBody{Kind: "bool", Bool: &off},
+	})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	resp = api.Put("/flags/kill-switch", "X-Actor: alice", handler.UpdateFlagBody{
+		Type: "bool", Enabled: false, Protected: true,
+		DefaultValue: handler.ValueBody{Kind: "bool", Bool: &off},
+	})
+	require.Equal(t, http.StatusAccepted, resp.Code, resp.Body.String())
+
+	change := decodeChange(t, resp)
+	assert.Equal(t, "/changes/"+change.ID, resp.Header().Get("Location"))
+	assert.Equal(t, "pending", change.Status)
+	assert.Equal(t, "alice", change.Requester)
+	require.Len(t, change.Diff, 1)
+	assert.Equal(t, "enabled", change.Diff[0].Path)
+
+	resp = api.Get("/changes?status=pending&flagKey=kill-switch")
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), change.ID)
+
+	resp = api.Post("/changes/"+change.ID+"/approve", "X-Actor: alice", handler.ReviewBody{})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	resp = api.Post("/changes/"+change.ID+"/apply", "X-Actor: alice", struct{}{})
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = api.Post("/changes/"+change.ID+"/comments", "X-Actor: bob", handler.CommentBody{Text: "why?"})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	resp = api.Post("/changes/"+change.ID+"/approve", "X-Actor: bob", handler.ReviewBody{Comment: "ok"})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, []string{"bob"}, decodeChange(t, resp).Approvers)
+
+	resp = api.Post("/changes/"+change.ID+"/apply", "X-Actor: alice", struct{}{})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	applied := decodeChange(t, resp)
+	assert.Equal(t, "applied", applied.Status)
+	assert.Equal(t, 2, applied.AppliedVersion)
+	assert.Len(t, applied.Comments, 2)
+
+	resp = api.Get("/changes/" + change.ID)
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "applied", decodeChange(t, resp).Status)
+
+	resp = api.Put("/flags/kill-switch?emergency=true", "X-Actor: alice", handler.UpdateFlagBody{
+		Type: "bool", Enabled: true, Protected: true,
+		DefaultValue: handler.ValueBody{Kind: "bool", Bool: &on},
+	})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	resp = api.Get("/audit?flagKey=kill-switch")
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var audit handler.ListAuditResponseBody
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &audit))
+	require.Len(t, audit.Entries, 3)
+	assert.Equal(t, change.ID, audit.Entries[1].ChangeID)
+	assert.True(t, audit.Entries[2].Emergency)
+
+	resp = api.Delete("/flags/kill-switch", "X-Actor: alice")
+	require.Equal(t, http.StatusAccepted, resp.Code, resp.Body.String())
+
+	deletion := decodeChange(t, resp)
+
+	resp = api.Post("/changes/"+deletion.ID+"/reject", "X-Actor: alice", handler.ReviewBody{Comment: "never mind"})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Equal(t, "rejected", decodeChange(t, resp).Status)
+
+	resp = api.Get("/changes/missing")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestHandler_ChangeErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[error]int{
+		flags.ErrChangeNotFound: http.StatusNotFound,
+		flags.ErrSelfApproval:   http.StatusForbidden,
+		flags.ErrForbidden:      http.StatusForbidden,
+		flags.ErrChangeClosed:   http.StatusConflict,
+		flags.ErrChangeNotReady: http.StatusConflict,
+		flags.ErrChangeConflict: http.StatusConflict,
+		errors.New("boom"):      http.StatusInternalServerError,
+	}
+
+	for cause, status := range tests {
+		ctrl := gomock.NewController(t)
+		mockService := NewMockFlagService(ctrl)
+		h := handler.New(mockService)
+		ctx := context.Background()
+
+		mockService.EXPECT().ChangeRequests(gomock.Any(), gomock.Any()).Return(nil, cause)
+		mockService.EXPECT().ChangeRequest(gomock.Any(), "id").Return(flags.ChangeRequest{}, cause)
+		mockService.EXPECT().Approve(gomock.Any(), "id", "").Return(flags.ChangeRequest{}, cause)
+		mockService.EXPECT().Reject(gomock.Any(), "id", "").Return(flags.ChangeRequest{}, cause)
+		mockService.EXPECT().Comment(gomock.Any(), "id", "hi").Return(flags.ChangeRequest{}, cause)
+		mockService.EXPECT().Apply(gomock.Any(), "id").Return(flags.ChangeRequest{}, cause)
+
+		_, errList := h.ListChanges(ctx, &handler.ListChangesRequest{})
+		_, errGet := h.GetChange(ctx, &handler.ChangeIDRequest{ID: "id"})
+		_, errApprove := h.ApproveChange(ctx, &handler.ReviewChangeRequest{ID: "id"})
+		_, errReject := h.RejectChange(ctx, &handler.ReviewChangeRequest{ID: "id"})
+		_, errComment := h.CommentChange(ctx, &handler.CommentChangeRequest{ID: "id", Body: handler.CommentBody{Text: "hi"}})
+		_, errApply := h.ApplyChange(ctx, &handler.ChangeIDRequest{ID: "id"})
+
+		for _, err := range []error{errList, errGet, errApprove, errReject, errComment, errApply} {
+			var statusErr huma.StatusError
+			require.ErrorAs(t, err, &statusErr)
+			assert.Equal(t, status, statusErr.GetStatus(), cause.Error())
+		}
+	}
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+
+	mockService.EXPECT().Apply(gomock.Any(), "id").Return(flags.ChangeRequest{}, flags.ErrFlagExists)
+
+	_, err := h.ApplyChange(context.Background(), &handler.ChangeIDRequest{ID: "id"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+}
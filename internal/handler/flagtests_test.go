@@ -0,0 +1,105 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stringValue(s string) handler.ValueBody {
+	return handler.ValueBody{Kind: "string", String: &s}
+}
+
+func tierRules() []handler.RuleBody {
+	return []handler.RuleBody{
+		{
+			ID:         "pro",
+			Conditions: []handler.ConditionBody{{Attr: "plan", Op: "eq", Value: "pro"}},
+			Value:      stringValue("pro"),
+		},
+		{
+			ID:         "beta",
+			Conditions: []handler.ConditionBody{{Attr: "beta", Op: "eq", Value: true}},
+			Value:      stringValue("beta"),
+		},
+	}
+}
+
+func TestFlagTests(t *testing.T) {
+	t.Parallel()
+
+	service := flags.NewService(flags.NewMemoryRepository())
+
+	_, api := humatest.New(t)
+	handler.New(service).Register(api)
+	handler.NewSimulationHandler(service).Register(api)
+
+	tests := []handler.FlagTestBody{{
+		Name:    "pro beta users keep pro",
+		Context: handler.EvaluateFlagBody{Attrs: map[string]any{"plan": "pro", "beta": true}},
+		Expect:  stringValue("pro"),
+		RuleID:  "pro",
+	}}
+
+	resp := api.Post("/flags", handler.CreateFlagBody{
+		Key: "tier", Type: "string", Enabled: true, DefaultValue: stringValue("free"), Rules: tierRules(), Tests: tests,
+	})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	resp = api.Get("/flags/tier")
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var flag handler.FlagBody
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &flag))
+	assert.Equal(t, tests, flag.Tests)
+
+	reordered := tierRules()
+	reordered[0], reordered[1] = reordered[1], reordered[0]
+
+	resp = api.Put("/flags/tier", handler.UpdateFlagBody{
+		Type: "string", Enabled: true, DefaultValue: stringValue("free"), Rules: reordered, Tests: tests,
+	})
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(),
+		`test \"pro beta users keep pro\": expected \"pro\" from rule pro, got \"beta\" from rule beta`)
+
+	resp = api.Post("/flags/tier/tests/run")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var run handler.RunFlagTestsResponseBody
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &run))
+	assert.Zero(t, run.Failed)
+	require.Len(t, run.Results, 1)
+	assert.True(t, run.Results[0].Passed)
+	assert.Equal(t, "pro", run.Results[0].Result.RuleID)
+
+	assert.Equal(t, http.StatusNotFound, api.Post("/flags/missing/tests/run").Code)
+}
+
+func TestFlagTests_FailureMessages(t *testing.T) {
+	t.Parallel()
+
+	service := flags.NewService(flags.NewMemoryRepository())
+
+	_, api := humatest.New(t)
+	handler.New(service).Register(api)
+
+	on, seats := true, 10.0
+
+	resp := api.Post("/flags", handler.CreateFlagBody{
+		Key: "seats", Type: "number", Enabled: true, DefaultValue: handler.ValueBody{Kind: "number", Number: &seats},
+		Tests: []handler.FlagTestBody{
+			{Name: "bool", Expect: handler.ValueBody{Kind: "bool", Bool: &on}},
+			{Name: "missing", Expect: handler.ValueBody{Kind: "string"}},
+		},
+	})
+	require.Equal(t, http.StatusUnprocessableEntity, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), `test \"bool\": expected true, got 10 (default)`)
+	assert.Contains(t, resp.Body.String(), `test \"missing\": expected null, got 10 (default)`)
+}
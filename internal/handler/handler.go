@@ -3,8 +3,11 @@ package handler
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/features/internal/auth"
 	"github.com/serroba/features/internal/flags"
 )
 
@@ -12,7 +15,31 @@ import (
 
 type FlagService interface {
 	Create(ctx context.Context, flag flags.Flag) (flags.Flag, error)
+	List(ctx context.Context, filter flags.FlagFilter) ([]flags.Flag, error)
+	Get(ctx context.Context, key flags.FlagKey) (flags.Flag, error)
+	Update(ctx context.Context, flag flags.Flag) (flags.Flag, error)
+	Delete(ctx context.Context, key flags.FlagKey) error
 	Evaluate(ctx context.Context, key flags.FlagKey, evalCtx flags.EvalContext) (flags.EvalResult, error)
+	AuditLog(ctx context.Context, filter flags.AuditFilter) (flags.AuditPage, error)
+	VersionService
+	ArchiveService
+	ChangeService
+}
+
+// VersionService is the part of FlagService that reads and restores
+// revisions.
+type VersionService interface {
+	Versions(ctx context.Context, key flags.FlagKey) ([]flags.Flag, error)
+	Version(ctx context.Context, key flags.FlagKey, version int) (flags.Flag, error)
+	DiffVersions(ctx context.Context, key flags.FlagKey, from, to int) ([]flags.FieldChange, error)
+	Rollback(ctx context.Context, key flags.FlagKey, version int) (flags.Flag, error)
+}
+
+// ArchiveService is the part of FlagService that exports and imports every
+// flag at once.
+type ArchiveService interface {
+	Export(ctx context.Context) (flags.Archive, error)
+	Import(ctx context.Context, archive flags.Archive, mode flags.ImportMode, dryRun bool) ([]flags.ImportChange, error)
 }
 
 type Handler struct {
@@ -24,13 +51,13 @@ func New(service FlagService) *Handler {
 }
 
 func (h *Handler) CreateFlag(ctx context.Context, req *CreateFlagRequest) (*CreateFlagResponse, error) {
-	flag, err := h.service.Create(ctx, ToFlag(req.Body))
+	flag, err := h.service.Create(emergency(ctx, req.Emergency), ToFlag(req.Body))
 	if err != nil {
 		if errors.Is(err, flags.ErrFlagExists) {
 			return nil, huma.Error409Conflict("flag already exists")
 		}
 
-		return nil, huma.Error500InternalServerError("failed to create flag")
+		return nil, flagError(err, "failed to create flag")
 	}
 
 	return &CreateFlagResponse{
@@ -42,6 +69,12 @@ func (h *Handler) CreateFlag(ctx context.Context, req *CreateFlagRequest) (*Crea
 }
 
 func (h *Handler) EvaluateFlag(ctx context.Context, req *EvaluateFlagRequest) (*EvaluateFlagResponse, error) {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		if err := principal.AuthorizeTenant(req.Body.TenantID); err != nil {
+			return nil, huma.Error403Forbidden(err.Error())
+		}
+	}
+
 	evalCtx := ToEvalContext(req.Body)
 
 	result, err := h.service.Evaluate(ctx, flags.FlagKey(req.Key), evalCtx)
@@ -57,3 +90,189 @@ func (h *Handler) EvaluateFlag(ctx context.Context, req *EvaluateFlagRequest) (*
 		Body: ToEvalResultBody(result),
 	}, nil
 }
+
+func (h *Handler) ListAudit(ctx context.Context, req *ListAuditRequest) (*ListAuditResponse, error) {
+	page, err := h.service.AuditLog(ctx, ToAuditFilter(req))
+	if err != nil {
+		if errors.Is(err, flags.ErrInvalidCursor) {
+			return nil, huma.Error400BadRequest("invalid cursor")
+		}
+
+		return nil, flagError(err, "failed to list audit log")
+	}
+
+	return &ListAuditResponse{
+		Body: ToListAuditResponseBody(page),
+	}, nil
+}
+
+func (h *Handler) ListFlags(ctx context.Context, req *ListFlagsRequest) (*ListFlagsResponse, error) {
+	all, err := h.service.List(ctx, ToFlagFilter(req))
+	if err != nil {
+		return nil, flagError(err, "failed to list flags")
+	}
+
+	return &ListFlagsResponse{Body: ToListFlagsResponseBody(all)}, nil
+}
+
+func (h *Handler) GetFlag(ctx context.Context, req *GetFlagRequest) (*FlagResponse, error) {
+	flag, err := h.service.Get(ctx, flags.FlagKey(req.Key))
+	if err != nil {
+		return nil, flagError(err, "failed to get flag")
+	}
+
+	return &FlagResponse{Body: ToFlagBody(flag)}, nil
+}
+
+func (h *Handler) UpdateFlag(ctx context.Context, req *UpdateFlagRequest) (*FlagResponse, error) {
+	flag, err := h.service.Update(emergency(ctx, req.Emergency), ToUpdatedFlag(req.Key, req.Body))
+	if err != nil {
+		return nil, flagError(err, "failed to update flag")
+	}
+
+	return &FlagResponse{Body: ToFlagBody(flag)}, nil
+}
+
+func (h *Handler) DeleteFlag(ctx context.Context, req *DeleteFlagRequest) (*struct{}, error) {
+	if err := h.service.Delete(emergency(ctx, req.Emergency), flags.FlagKey(req.Key)); err != nil {
+		return nil, flagError(err, "failed to delete flag")
+	}
+
+	return &struct{}{}, nil
+}
+
+func (h *Handler) ListVersions(ctx context.Context, req *ListVersionsRequest) (*ListVersionsResponse, error) {
+	revisions, err := h.service.Versions(ctx, flags.FlagKey(req.Key))
+	if err != nil {
+		return nil, flagError(err, "failed to list versions")
+	}
+
+	return &ListVersionsResponse{Body: ToListVersionsResponseBody(revisions)}, nil
+}
+
+func (h *Handler) GetVersion(ctx context.Context, req *GetVersionRequest) (*FlagResponse, error) {
+	revision, err := h.service.Version(ctx, flags.FlagKey(req.Key), req.Version)
+	if err != nil {
+		return nil, flagError(err, "failed to get version")
+	}
+
+	return &FlagResponse{Body: ToFlagBody(revision)}, nil
+}
+
+func (h *Handler) DiffVersions(ctx context.Context, req *DiffVersionsRequest) (*DiffVersionsResponse, error) {
+	changes, err := h.service.DiffVersions(ctx, flags.FlagKey(req.Key), req.From, req.To)
+	if err != nil {
+		return nil, flagError(err, "failed to diff versions")
+	}
+
+	return &DiffVersionsResponse{Body: ToDiffVersionsResponseBody(req.From, req.To, changes)}, nil
+}
+
+func (h *Handler) RollbackFlag(ctx context.Context, req *RollbackFlagRequest) (*FlagResponse, error) {
+	flag, err := h.service.Rollback(emergency(ctx, req.Emergency), flags.FlagKey(req.Key), req.To)
+	if err != nil {
+		return nil, flagError(err, "failed to roll back flag")
+	}
+
+	return &FlagResponse{Body: ToFlagBody(flag)}, nil
+}
+
+func (h *Handler) Export(ctx context.Context, _ *struct{}) (*ExportResponse, error) {
+	archive, err := h.service.Export(ctx)
+	if err != nil {
+		return nil, flagError(err, "failed to export flags")
+	}
+
+	return &ExportResponse{Body: ToArchiveBody(archive)}, nil
+}
+
+func (h *Handler) Import(ctx context.Context, req *ImportRequest) (*ImportResponse, error) {
+	changes, err := h.service.Import(ctx, ToArchive(req.Body), flags.ImportMode(req.Mode), req.DryRun)
+	if err != nil {
+		switch {
+		case errors.Is(err, flags.ErrUnsupportedArchive),
+			errors.Is(err, flags.ErrInvalidArchive),
+			errors.Is(err, flags.ErrInvalidImportMode):
+			return nil, huma.Error400BadRequest(err.Error())
+		default:
+			return nil, flagError(err, "failed to import flags; applied changes were reverted")
+		}
+	}
+
+	return &ImportResponse{Body: ToImportResponseBody(changes, req.DryRun)}, nil
+}
+
+// flagError maps the flags package sentinel errors to HTTP errors, falling
+// back to a 500 with the given message. Mutations that opened a change
+// request answer 202 with the change; invalid flags answer 422, as do those
+// that fail the flag's tests, listing the failures.
+func flagError(err error, fallback string) error {
+	var pending *flags.PendingChangeError
+	if errors.As(err, &pending) {
+		return &pendingChangeError{ToChangeRequestBody(pending.Change)}
+	}
+
+	var failed *flags.TestsFailedError
+	if errors.As(err, &failed) {
+		return huma.Error422UnprocessableEntity("flag tests failed", testFailures(failed.Failures)...)
+	}
+
+	switch {
+	case errors.Is(err, flags.ErrInvalidFlag):
+		return huma.Error422UnprocessableEntity(err.Error())
+	case errors.Is(err, flags.ErrFlagNotFound):
+		return huma.Error404NotFound("flag not found")
+	case errors.Is(err, flags.ErrRevisionNotFound):
+		return huma.Error404NotFound("flag revision not found")
+	case errors.Is(err, flags.ErrVersionConflict):
+		return huma.Error409Conflict("flag was modified concurrently")
+	case errors.Is(err, flags.ErrFlagReadOnly):
+		return huma.Error409Conflict("flag is managed outside the API and is read-only")
+	case errors.Is(err, flags.ErrForbidden):
+		return huma.Error403Forbidden(err.Error())
+	default:
+		return huma.Error500InternalServerError(fallback)
+	}
+}
+
+// testFailures describes each failed flag test as an error detail.
+func testFailures(failures []flags.TestResult) []error {
+	details := make([]error, len(failures))
+
+	for i, failure := range failures {
+		got := formatValue(failure.Result.Value)
+		if failure.Result.RuleID != "" {
+			got += " from rule " + failure.Result.RuleID
+		} else {
+			got += " (" + string(failure.Result.Reason) + ")"
+		}
+
+		want := formatValue(failure.Test.Expect)
+		if failure.Test.RuleID != "" {
+			want += " from rule " + failure.Test.RuleID
+		}
+
+		details[i] = &huma.ErrorDetail{
+			Message:  fmt.Sprintf("test %q: expected %s, got %s", failure.Test.Name, want, got),
+			Location: "tests",
+			Value:    failure.Test.Name,
+		}
+	}
+
+	return details
+}
+
+// formatValue formats a flag value for messages: true, 12.5 or a quoted
+// string.
+func formatValue(value flags.Value) string {
+	switch {
+	case value.Bool != nil:
+		return strconv.FormatBool(*value.Bool)
+	case value.Number != nil:
+		return strconv.FormatFloat(*value.Number, 'g', -1, 64)
+	case value.String != nil:
+		return strconv.Quote(*value.String)
+	default:
+		return "null"
+	}
+}
@@ -2,10 +2,16 @@ package handler_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
 	"testing"
 	"time"
 
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/serroba/features/internal/auth"
 	"github.com/serroba/features/internal/flags"
 	"github.com/serroba/features/internal/handler"
 	"github.com/stretchr/testify/assert"
@@ -134,6 +140,32 @@ func TestHandler_EvaluateFlag(t *testing.T) {
 	assert.True(t, *resp.Body.Value.Bool)
 }
 
+func TestHandler_EvaluateFlag_TenantKey(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{Scope: auth.ScopeEvaluate, Tenant: "acme"})
+
+	mockService.EXPECT().
+		Evaluate(gomock.Any(), flags.FlagKey("my-flag"), gomock.Any()).
+		Return(flags.EvalResult{FlagKey: "my-flag", Value: flags.BoolValue(true), Reason: flags.ReasonDefault}, nil)
+
+	_, err := h.EvaluateFlag(ctx, &handler.EvaluateFlagRequest{
+		Key:  "my-flag",
+		Body: handler.EvaluateFlagBody{TenantID: "acme"},
+	})
+	require.NoError(t, err)
+
+	_, err = h.EvaluateFlag(ctx, &handler.EvaluateFlagRequest{
+		Key:  "my-flag",
+		Body: handler.EvaluateFlagBody{TenantID: "globex"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "key is restricted to tenant acme")
+}
+
 func TestHandler_EvaluateFlag_NotFound(t *testing.T) {
 	t.Parallel()
 
@@ -240,3 +272,615 @@ func TestHandler_EvaluateFlag_InternalError(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to evaluate flag")
 }
+
+func TestHandler_ListAudit(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		AuditLog(gomock.Any(), flags.AuditFilter{FlagKey: "my-flag", Actor: "alice", Limit: 50}).
+		Return(flags.AuditPage{
+			Entries:    []flags.AuditEntry{{Seq: 1, Actor: "alice", Action: flags.AuditCreate, FlagKey: "my-flag"}},
+			NextCursor: "next",
+		}, nil)
+
+	resp, err := h.ListAudit(ctx, &handler.ListAuditRequest{FlagKey: "my-flag", Actor: "alice", Limit: 50})
+	require.NoError(t, err)
+
+	require.Len(t, resp.Body.Entries, 1)
+	assert.Equal(t, "create", resp.Body.Entries[0].Action)
+	assert.Equal(t, "next", resp.Body.NextCursor)
+}
+
+func TestHandler_ListAudit_InvalidCursor(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		AuditLog(gomock.Any(), gomock.Any()).
+		Return(flags.AuditPage{}, flags.ErrInvalidCursor)
+
+	_, err := h.ListAudit(ctx, &handler.ListAuditRequest{Cursor: "bogus"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid cursor")
+}
+
+func TestHandler_ListAudit_InternalError(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		AuditLog(gomock.Any(), gomock.Any()).
+		Return(flags.AuditPage{}, errors.New("disk failure"))
+
+	_, err := h.ListAudit(ctx, &handler.ListAuditRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to list audit log")
+}
+
+func TestHandler_ListFlags(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+
+	mockService.EXPECT().
+		List(gomock.Any(), flags.FlagFilter{Tag: "checkout", Owner: "payments", Kind: flags.KindRelease}).
+		Return([]flags.Flag{{Key: "a", Version: 1}, {Key: "b", Version: 2}}, nil)
+
+	resp, err := h.ListFlags(context.Background(), &handler.ListFlagsRequest{
+		Tag: "checkout", Owner: "payments", Kind: "release",
+	})
+	require.NoError(t, err)
+
+	require.Len(t, resp.Body.Flags, 2)
+	assert.Equal(t, flags.FlagKey("b"), resp.Body.Flags[1].Key)
+	assert.Equal(t, 2, resp.Body.Flags[1].Version)
+}
+
+func TestHandler_ListFlags_Filter(t *testing.T) {
+	t.Parallel()
+
+	_, api := humatest.New(t)
+	handler.New(flags.NewService(flags.NewMemoryRepository())).Register(api)
+
+	off := false
+
+	for key, metadata := range map[string]handler.MetadataBody{
+		"checkout": {Owner: "payments", Tags: []string{"web"}, Kind: "release"},
+		"refunds":  {Owner: "payments", Kind: "kill-switch", Description: "Stops refunds"},
+		"search":   {Owner: "search", Tags: []string{"web"}},
+	} {
+		body := handler.CreateFlagBody{Key: key, MetadataBody: metadata, Type: "bool"}
+		body.DefaultValue = handler.ValueBody{Kind: "bool", Bool: &off}
+
+		resp := api.Post("/flags", body)
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	}
+
+	keys := func(query string) []flags.FlagKey {
+		resp := api.Get("/flags" + query)
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+		var body handler.ListFlagsResponseBody
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+
+		result := make([]flags.FlagKey, len(body.Flags))
+		for i, flag := range body.Flags {
+			result[i] = flag.Key
+		}
+
+		return result
+	}
+
+	assert.Equal(t, []flags.FlagKey{"checkout", "refunds", "search"}, keys(""))
+	assert.Equal(t, []flags.FlagKey{"checkout", "search"}, keys("?tag=web"))
+	assert.Equal(t, []flags.FlagKey{"checkout"}, keys("?tag=web&owner=payments"))
+	assert.Equal(t, []flags.FlagKey{"refunds"}, keys("?kind=kill-switch"))
+
+	resp := api.Get("/flags/refunds")
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Contains(t, resp.Body.String(), `"description":"Stops refunds"`)
+
+	resp = api.Get("/flags?kind=temporary")
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+
+	resp = api.Post("/flags", handler.CreateFlagBody{
+		Key: "bad", MetadataBody: handler.MetadataBody{Kind: "temporary"}, Type: "bool",
+		DefaultValue: handler.ValueBody{Kind: "bool", Bool: &off},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+}
+
+func TestHandler_RejectsInvalidTimeConditions(t *testing.T) {
+	t.Parallel()
+
+	service := flags.NewService(flags.NewMemoryRepository())
+
+	_, api := humatest.New(t)
+	handler.New(service).Register(api)
+	handler.NewScheduleHandler(service).Register(api)
+
+	on := true
+	rules := func(op string, value any) []handler.RuleBody {
+		return []handler.RuleBody{{
+			ID:         "timed",
+			Conditions: []handler.ConditionBody{{Attr: "now", Op: op, Value: value}},
+			Value:      handler.ValueBody{Kind: "bool", Bool: &on},
+		}}
+	}
+
+	resp := api.Post("/flags", proPlanFlag())
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	for name, invalid := range map[string][]handler.RuleBody{
+		"timestamp": rules("before", "tomorrow"),
+		"between":   rules("between", []any{"2025-12-01T00:00:00Z", "2025-11-01T00:00:00Z"}),
+		"time zone": rules("window", map[string]any{"tz": "Mars/Olympus_Mons"}),
+		"time":      rules("window", map[string]any{"from": "9am"}),
+		"weekday":   rules("window", map[string]any{"days": []any{"someday"}}),
+	} {
+		create := proPlanFlag()
+		create.Key = "invalid"
+		create.Rules = invalid
+
+		resp = api.Post("/flags", create)
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, "create %s: %s", name, resp.Body.String())
+
+		resp = api.Put("/flags/checkout", handler.UpdateFlagBody{
+			Type: "bool", DefaultValue: handler.ValueBody{Kind: "bool", Bool: &on}, Rules: invalid,
+		})
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, "update %s: %s", name, resp.Body.String())
+
+		resp = api.Post("/flags/checkout/schedules", handler.ScheduleFlagBody{
+			Action: "set-rules", At: time.Now().Add(time.Hour), Rules: invalid,
+		})
+		assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, "schedule %s: %s", name, resp.Body.String())
+	}
+}
+
+func TestHandler_ListFlags_InternalError(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+
+	mockService.EXPECT().
+		List(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("boom"))
+
+	_, err := h.ListFlags(context.Background(), &handler.ListFlagsRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to list flags")
+}
+
+func TestHandler_GetFlag(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		Get(gomock.Any(), flags.FlagKey("my-flag")).
+		Return(flags.Flag{Key: "my-flag", Type: flags.FlagBool, Enabled: true, Version: 3}, nil)
+
+	resp, err := h.GetFlag(ctx, &handler.GetFlagRequest{Key: "my-flag"})
+	require.NoError(t, err)
+
+	assert.Equal(t, flags.FlagKey("my-flag"), resp.Body.Key)
+	assert.Equal(t, 3, resp.Body.Version)
+	assert.True(t, resp.Body.Enabled)
+}
+
+func TestHandler_GetFlag_NotFound(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		Get(gomock.Any(), flags.FlagKey("nonexistent")).
+		Return(flags.Flag{}, flags.ErrFlagNotFound)
+
+	_, err := h.GetFlag(ctx, &handler.GetFlagRequest{Key: "nonexistent"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "flag not found")
+}
+
+func TestHandler_UpdateFlag(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		Update(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, flag flags.Flag) (flags.Flag, error) {
+			assert.Equal(t, flags.FlagKey("my-flag"), flag.Key)
+			assert.Equal(t, 2, flag.Version)
+
+			flag.Version = 3
+
+			return flag, nil
+		})
+
+	req := &handler.UpdateFlagRequest{
+		Key:  "my-flag",
+		Body: handler.UpdateFlagBody{Type: "bool", Enabled: true, Version: 2},
+	}
+
+	resp, err := h.UpdateFlag(ctx, req)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, resp.Body.Version)
+}
+
+func TestHandler_UpdateFlag_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "not found", err: flags.ErrFlagNotFound, want: "flag not found"},
+		{name: "conflict", err: flags.ErrVersionConflict, want: "modified concurrently"},
+		{name: "read-only", err: flags.ErrFlagReadOnly, want: "read-only"},
+		{name: "invalid", err: fmt.Errorf("%w: rule r1: condition 1: bad tz", flags.ErrInvalidFlag), want: "bad tz"},
+		{name: "forbidden", err: fmt.Errorf("%w: key:bob lacks write", flags.ErrForbidden), want: "key:bob lacks write"},
+		{name: "internal", err: errors.New("boom"), want: "failed to update flag"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockService := NewMockFlagService(ctrl)
+			h := handler.New(mockService)
+
+			mockService.EXPECT().
+				Update(gomock.Any(), gomock.Any()).
+				Return(flags.Flag{}, tt.err)
+
+			_, err := h.UpdateFlag(context.Background(), &handler.UpdateFlagRequest{Key: "my-flag"})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestHandler_Forbidden(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+	forbidden := fmt.Errorf("%w: key:bob lacks read", flags.ErrForbidden)
+
+	mockService.EXPECT().Create(gomock.Any(), gomock.Any()).Return(flags.Flag{}, forbidden)
+	mockService.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, forbidden)
+	mockService.EXPECT().AuditLog(gomock.Any(), gomock.Any()).Return(flags.AuditPage{}, forbidden)
+	mockService.EXPECT().Export(gomock.Any()).Return(flags.Archive{}, forbidden)
+
+	_, createErr := h.CreateFlag(ctx, &handler.CreateFlagRequest{})
+	_, listErr := h.ListFlags(ctx, &handler.ListFlagsRequest{})
+	_, auditErr := h.ListAudit(ctx, &handler.ListAuditRequest{})
+	_, exportErr := h.Export(ctx, nil)
+
+	for _, err := range []error{createErr, listErr, auditErr, exportErr} {
+		var statusErr huma.StatusError
+		require.ErrorAs(t, err, &statusErr)
+		assert.Equal(t, http.StatusForbidden, statusErr.GetStatus())
+		assert.Contains(t, err.Error(), "key:bob lacks read")
+	}
+}
+
+func TestHandler_DeleteFlag(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+
+	mockService.EXPECT().
+		Delete(gomock.Any(), flags.FlagKey("my-flag")).
+		Return(nil)
+
+	_, err := h.DeleteFlag(context.Background(), &handler.DeleteFlagRequest{Key: "my-flag"})
+	require.NoError(t, err)
+}
+
+func TestHandler_DeleteFlag_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "not found", err: flags.ErrFlagNotFound, want: "flag not found"},
+		{name: "read-only", err: flags.ErrFlagReadOnly, want: "read-only"},
+		{name: "internal", err: errors.New("boom"), want: "failed to delete flag"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockService := NewMockFlagService(ctrl)
+			h := handler.New(mockService)
+
+			mockService.EXPECT().
+				Delete(gomock.Any(), flags.FlagKey("my-flag")).
+				Return(tt.err)
+
+			_, err := h.DeleteFlag(context.Background(), &handler.DeleteFlagRequest{Key: "my-flag"})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestHandler_ListVersions(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		Versions(gomock.Any(), flags.FlagKey("my-flag")).
+		Return([]flags.Flag{{Key: "my-flag", Version: 1}, {Key: "my-flag", Version: 2}}, nil)
+
+	resp, err := h.ListVersions(ctx, &handler.ListVersionsRequest{Key: "my-flag"})
+	require.NoError(t, err)
+
+	require.Len(t, resp.Body.Versions, 2)
+	assert.Equal(t, 2, resp.Body.Versions[1].Version)
+}
+
+func TestHandler_ListVersions_NotFound(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		Versions(gomock.Any(), flags.FlagKey("nonexistent")).
+		Return(nil, flags.ErrFlagNotFound)
+
+	_, err := h.ListVersions(ctx, &handler.ListVersionsRequest{Key: "nonexistent"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "flag not found")
+}
+
+func TestHandler_GetVersion(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		Version(gomock.Any(), flags.FlagKey("my-flag"), 2).
+		Return(flags.Flag{Key: "my-flag", Version: 2}, nil)
+
+	resp, err := h.GetVersion(ctx, &handler.GetVersionRequest{Key: "my-flag", Version: 2})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, resp.Body.Version)
+}
+
+func TestHandler_GetVersion_RevisionNotFound(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		Version(gomock.Any(), flags.FlagKey("my-flag"), 9).
+		Return(flags.Flag{}, flags.ErrRevisionNotFound)
+
+	_, err := h.GetVersion(ctx, &handler.GetVersionRequest{Key: "my-flag", Version: 9})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "revision not found")
+}
+
+func TestHandler_DiffVersions(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		DiffVersions(gomock.Any(), flags.FlagKey("my-flag"), 1, 2).
+		Return([]flags.FieldChange{{Path: "enabled", Kind: flags.ChangeModified, Before: true, After: false}}, nil)
+
+	resp, err := h.DiffVersions(ctx, &handler.DiffVersionsRequest{Key: "my-flag", From: 1, To: 2})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, resp.Body.From)
+	assert.Equal(t, 2, resp.Body.To)
+	assert.Equal(t, []handler.FieldChangeBody{
+		{Path: "enabled", Kind: "modified", Before: true, After: false},
+	}, resp.Body.Changes)
+}
+
+func TestHandler_DiffVersions_Error(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		DiffVersions(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("boom"))
+
+	_, err := h.DiffVersions(ctx, &handler.DiffVersionsRequest{Key: "my-flag", From: 1, To: 2})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to diff versions")
+}
+
+func TestHandler_RollbackFlag(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		Rollback(gomock.Any(), flags.FlagKey("my-flag"), 1).
+		Return(flags.Flag{Key: "my-flag", Version: 4}, nil)
+
+	resp, err := h.RollbackFlag(ctx, &handler.RollbackFlagRequest{Key: "my-flag", To: 1})
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, resp.Body.Version)
+}
+
+func TestHandler_RollbackFlag_Error(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+	ctx := context.Background()
+
+	mockService.EXPECT().
+		Rollback(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(flags.Flag{}, errors.New("boom"))
+
+	_, err := h.RollbackFlag(ctx, &handler.RollbackFlagRequest{Key: "my-flag", To: 1})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to roll back flag")
+}
+
+func TestHandler_Export(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+
+	mockService.EXPECT().
+		Export(gomock.Any()).
+		Return(flags.Archive{FormatVersion: 1, Flags: []flags.Flag{{Key: "a", Type: flags.FlagBool}}}, nil)
+
+	resp, err := h.Export(context.Background(), &struct{}{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp.Body.FormatVersion)
+	require.Len(t, resp.Body.Flags, 1)
+	assert.Equal(t, "a", resp.Body.Flags[0].Key)
+}
+
+func TestHandler_Export_InternalError(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+
+	mockService.EXPECT().
+		Export(gomock.Any()).
+		Return(flags.Archive{}, errors.New("boom"))
+
+	_, err := h.Export(context.Background(), &struct{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to export flags")
+}
+
+func TestHandler_Import(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	mockService := NewMockFlagService(ctrl)
+	h := handler.New(mockService)
+
+	mockService.EXPECT().
+		Import(gomock.Any(), gomock.Any(), flags.ImportMerge, true).
+		DoAndReturn(func(_ context.Context, archive flags.Archive, _ flags.ImportMode, _ bool) (
+			[]flags.ImportChange, error,
+		) {
+			assert.Equal(t, flags.FlagKey("a"), archive.Flags[0].Key)
+
+			return []flags.ImportChange{{Action: flags.ImportCreate, Key: "a"}}, nil
+		})
+
+	resp, err := h.Import(context.Background(), &handler.ImportRequest{
+		Mode:   "merge",
+		DryRun: true,
+		Body:   handler.ArchiveBody{FormatVersion: 1, Flags: []handler.CreateFlagBody{{Key: "a", Type: "bool"}}},
+	})
+	require.NoError(t, err)
+	assert.True(t, resp.Body.DryRun)
+	require.Len(t, resp.Body.Changes, 1)
+	assert.Equal(t, "create", resp.Body.Changes[0].Action)
+}
+
+func TestHandler_Import_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "format", err: fmt.Errorf("%w: 2", flags.ErrUnsupportedArchive), want: "format version: 2"},
+		{name: "archive", err: flags.ErrInvalidArchive, want: "invalid archive"},
+		{name: "mode", err: flags.ErrInvalidImportMode, want: "invalid import mode"},
+		{name: "conflict", err: flags.ErrVersionConflict, want: "modified concurrently"},
+		{name: "internal", err: errors.New("boom"), want: "applied changes were reverted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctrl := gomock.NewController(t)
+			mockService := NewMockFlagService(ctrl)
+			h := handler.New(mockService)
+
+			mockService.EXPECT().
+				Import(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(nil, tt.err)
+
+			_, err := h.Import(context.Background(), &handler.ImportRequest{Mode: "merge"})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
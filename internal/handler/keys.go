@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/features/internal/auth"
+	"github.com/serroba/features/internal/flags"
+)
+
+// KeyService manages API keys. *auth.Service implements it.
+type KeyService interface {
+	Create(ctx context.Context, spec auth.NewKey) (auth.Key, string, error)
+	List(ctx context.Context) ([]auth.Key, error)
+	Rotate(ctx context.Context, id string) (auth.Key, string, error)
+	Revoke(ctx context.Context, id string) (auth.Key, error)
+}
+
+type KeyHandler struct {
+	keys KeyService
+}
+
+func NewKeyHandler(keys KeyService) *KeyHandler {
+	return &KeyHandler{keys: keys}
+}
+
+func (h *KeyHandler) CreateKey(ctx context.Context, req *CreateKeyRequest) (*KeySecretResponse, error) {
+	key, token, err := h.keys.Create(ctx, ToNewKey(req.Body))
+	if err != nil {
+		return nil, keyError(err, "failed to create key")
+	}
+
+	return &KeySecretResponse{Body: KeySecretResponseBody{Key: ToKeyBody(key), Token: token}}, nil
+}
+
+func (h *KeyHandler) ListKeys(ctx context.Context, _ *struct{}) (*ListKeysResponse, error) {
+	keys, err := h.keys.List(ctx)
+	if err != nil {
+		return nil, huma.Error500InternalServerError("failed to list keys")
+	}
+
+	return &ListKeysResponse{Body: ToListKeysResponseBody(keys)}, nil
+}
+
+func (h *KeyHandler) RotateKey(ctx context.Context, req *KeyRequest) (*KeySecretResponse, error) {
+	key, token, err := h.keys.Rotate(ctx, req.ID)
+	if err != nil {
+		return nil, keyError(err, "failed to rotate key")
+	}
+
+	return &KeySecretResponse{Body: KeySecretResponseBody{Key: ToKeyBody(key), Token: token}}, nil
+}
+
+func (h *KeyHandler) RevokeKey(ctx context.Context, req *KeyRequest) (*struct{}, error) {
+	if _, err := h.keys.Revoke(ctx, req.ID); err != nil {
+		return nil, keyError(err, "failed to revoke key")
+	}
+
+	return &struct{}{}, nil
+}
+
+// keyError maps the auth package sentinel errors to HTTP errors, falling
+// back to a 500 with the given message.
+func keyError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		return huma.Error404NotFound("key not found")
+	case errors.Is(err, auth.ErrKeyRevoked):
+		return huma.Error409Conflict("key is revoked")
+	case errors.Is(err, auth.ErrNameTaken):
+		return huma.Error409Conflict(err.Error())
+	case errors.Is(err, auth.ErrInvalidScope), errors.Is(err, auth.ErrTenantScope):
+		return huma.Error400BadRequest(err.Error())
+	case errors.Is(err, flags.ErrForbidden):
+		return huma.Error403Forbidden(err.Error())
+	default:
+		return huma.Error500InternalServerError(fallback)
+	}
+}
@@ -1,19 +1,66 @@
 package handler
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/serroba/features/internal/auth"
 	"github.com/serroba/features/internal/flags"
 )
 
 func ToFlag(body CreateFlagBody) flags.Flag {
 	return flags.Flag{
 		Key:          flags.FlagKey(body.Key),
+		Description:  body.Description,
+		Owner:        body.Owner,
+		Tags:         body.Tags,
+		Kind:         flags.FlagKind(body.Kind),
+		ExpiresAt:    body.ExpiresAt,
+		Type:         flags.FlagType(body.Type),
+		Enabled:      body.Enabled,
+		Protected:    body.Protected,
+		DefaultValue: toValue(body.DefaultValue),
+		Rules:        toRules(body.Rules),
+		Tests:        toFlagTests(body.Tests),
+	}
+}
+
+func ToUpdatedFlag(key string, body UpdateFlagBody) flags.Flag {
+	return flags.Flag{
+		Key:          flags.FlagKey(key),
+		Description:  body.Description,
+		Owner:        body.Owner,
+		Tags:         body.Tags,
+		Kind:         flags.FlagKind(body.Kind),
+		ExpiresAt:    body.ExpiresAt,
 		Type:         flags.FlagType(body.Type),
 		Enabled:      body.Enabled,
+		Protected:    body.Protected,
 		DefaultValue: toValue(body.DefaultValue),
 		Rules:        toRules(body.Rules),
+		Tests:        toFlagTests(body.Tests),
+		Version:      body.Version,
 	}
 }
 
+func toFlagTests(bodies []FlagTestBody) []flags.FlagTest {
+	if len(bodies) == 0 {
+		return nil
+	}
+
+	tests := make([]flags.FlagTest, len(bodies))
+	for i, body := range bodies {
+		tests[i] = flags.FlagTest{
+			Name:    body.Name,
+			Context: ToEvalContext(body.Context),
+			Expect:  toValue(body.Expect),
+			RuleID:  body.RuleID,
+		}
+	}
+
+	return tests
+}
+
 func toRules(bodies []RuleBody) []flags.Rule {
 	if len(bodies) == 0 {
 		return nil
@@ -25,12 +72,21 @@ func toRules(bodies []RuleBody) []flags.Rule {
 			ID:         b.ID,
 			Conditions: toConditions(b.Conditions),
 			Value:      toValue(b.Value),
+			Rollout:    toRollout(b.Rollout),
 		}
 	}
 
 	return rules
 }
 
+func toRollout(body *RolloutBody) *flags.Rollout {
+	if body == nil {
+		return nil
+	}
+
+	return &flags.Rollout{Percentage: body.Percentage, BucketBy: body.BucketBy}
+}
+
 func toConditions(bodies []ConditionBody) []flags.Condition {
 	if len(bodies) == 0 {
 		return nil
@@ -62,6 +118,16 @@ func ToEvalContext(body EvaluateFlagBody) flags.EvalContext {
 		TenantID: body.TenantID,
 		UserID:   body.UserID,
 		Attrs:    body.Attrs,
+		Now:      body.At,
+	}
+}
+
+func toEvaluateFlagBody(evalCtx flags.EvalContext) EvaluateFlagBody {
+	return EvaluateFlagBody{
+		TenantID: evalCtx.TenantID,
+		UserID:   evalCtx.UserID,
+		Attrs:    evalCtx.Attrs,
+		At:       evalCtx.Now,
 	}
 }
 
@@ -83,3 +149,541 @@ func toValueBody(value flags.Value) ValueBody {
 		Number: value.Number,
 	}
 }
+
+func ToFlagBody(flag flags.Flag) FlagBody {
+	return FlagBody{
+		Key:          flag.Key,
+		MetadataBody: toMetadataBody(flag),
+		Type:         string(flag.Type),
+		Enabled:      flag.Enabled,
+		Protected:    flag.Protected,
+		DefaultValue: toValueBody(flag.DefaultValue),
+		Rules:        toRuleBodies(flag.Rules),
+		Version:      flag.Version,
+		UpdatedAt:    flag.UpdatedAt,
+		ManagedBy:    flag.ManagedBy,
+		Tests:        toFlagTestBodies(flag.Tests),
+	}
+}
+
+// ToCreateFlagBody is the inverse of ToFlag; versions, timestamps and the
+// manager are dropped.
+func ToCreateFlagBody(flag flags.Flag) CreateFlagBody {
+	return CreateFlagBody{
+		Key:          string(flag.Key),
+		MetadataBody: toMetadataBody(flag),
+		Type:         string(flag.Type),
+		Enabled:      flag.Enabled,
+		Protected:    flag.Protected,
+		DefaultValue: toValueBody(flag.DefaultValue),
+		Rules:        toRuleBodies(flag.Rules),
+		Tests:        toFlagTestBodies(flag.Tests),
+	}
+}
+
+func toFlagTestBodies(tests []flags.FlagTest) []FlagTestBody {
+	if len(tests) == 0 {
+		return nil
+	}
+
+	bodies := make([]FlagTestBody, len(tests))
+	for i, test := range tests {
+		bodies[i] = FlagTestBody{
+			Name:    test.Name,
+			Context: toEvaluateFlagBody(test.Context),
+			Expect:  toValueBody(test.Expect),
+			RuleID:  test.RuleID,
+		}
+	}
+
+	return bodies
+}
+
+func toMetadataBody(flag flags.Flag) MetadataBody {
+	return MetadataBody{
+		Description: flag.Description,
+		Owner:       flag.Owner,
+		Tags:        flag.Tags,
+		Kind:        string(flag.Kind),
+		ExpiresAt:   flag.ExpiresAt,
+	}
+}
+
+func ToFlagFilter(req *ListFlagsRequest) flags.FlagFilter {
+	return flags.FlagFilter{Tag: req.Tag, Owner: req.Owner, Kind: flags.FlagKind(req.Kind)}
+}
+
+func ToListFlagsResponseBody(all []flags.Flag) ListFlagsResponseBody {
+	bodies := make([]FlagBody, len(all))
+	for i, flag := range all {
+		bodies[i] = ToFlagBody(flag)
+	}
+
+	return ListFlagsResponseBody{Flags: bodies}
+}
+
+func ToListVersionsResponseBody(revisions []flags.Flag) ListVersionsResponseBody {
+	versions := make([]FlagBody, len(revisions))
+	for i, revision := range revisions {
+		versions[i] = ToFlagBody(revision)
+	}
+
+	return ListVersionsResponseBody{Versions: versions}
+}
+
+func ToDiffVersionsResponseBody(from, to int, changes []flags.FieldChange) DiffVersionsResponseBody {
+	return DiffVersionsResponseBody{
+		From:    from,
+		To:      to,
+		Changes: ToFieldChangeBodies(changes),
+	}
+}
+
+func toRuleBodies(rules []flags.Rule) []RuleBody {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	bodies := make([]RuleBody, len(rules))
+	for i, rule := range rules {
+		bodies[i] = toRuleBody(rule)
+	}
+
+	return bodies
+}
+
+func toRuleBody(rule flags.Rule) RuleBody {
+	conditions := make([]ConditionBody, len(rule.Conditions))
+	for i, cond := range rule.Conditions {
+		conditions[i] = ConditionBody{
+			Attr:  cond.Attr,
+			Op:    string(cond.Op),
+			Value: cond.Value,
+		}
+	}
+
+	body := RuleBody{
+		ID:         rule.ID,
+		Conditions: conditions,
+		Value:      toValueBody(rule.Value),
+	}
+
+	if rule.Rollout != nil {
+		body.Rollout = &RolloutBody{Percentage: rule.Rollout.Percentage, BucketBy: rule.Rollout.BucketBy}
+	}
+
+	return body
+}
+
+func ToAuditFilter(req *ListAuditRequest) flags.AuditFilter {
+	return flags.AuditFilter{
+		FlagKey: flags.FlagKey(req.FlagKey),
+		Actor:   req.Actor,
+		Since:   req.Since,
+		Until:   req.Until,
+		Cursor:  req.Cursor,
+		Limit:   req.Limit,
+	}
+}
+
+func ToListAuditResponseBody(page flags.AuditPage) ListAuditResponseBody {
+	entries := make([]AuditEntryBody, len(page.Entries))
+	for i, entry := range page.Entries {
+		entries[i] = toAuditEntryBody(entry)
+	}
+
+	return ListAuditResponseBody{
+		Entries:    entries,
+		NextCursor: page.NextCursor,
+	}
+}
+
+func toAuditEntryBody(entry flags.AuditEntry) AuditEntryBody {
+	return AuditEntryBody{
+		Seq:        entry.Seq,
+		Time:       entry.Time,
+		Actor:      entry.Actor,
+		Action:     string(entry.Action),
+		FlagKey:    entry.FlagKey,
+		Before:     toFlagBodyPtr(entry.Before),
+		After:      toFlagBodyPtr(entry.After),
+		Diff:       ToFieldChangeBodies(entry.Diff),
+		RequestID:  entry.RequestID,
+		ChangeID:   entry.ChangeID,
+		Emergency:  entry.Emergency,
+		ScheduleID: entry.ScheduleID,
+		RampID:     entry.RampID,
+	}
+}
+
+func toFlagBodyPtr(flag *flags.Flag) *FlagBody {
+	if flag == nil {
+		return nil
+	}
+
+	body := ToFlagBody(*flag)
+
+	return &body
+}
+
+func ToFieldChangeBodies(changes []flags.FieldChange) []FieldChangeBody {
+	bodies := make([]FieldChangeBody, len(changes))
+	for i, change := range changes {
+		bodies[i] = FieldChangeBody{
+			Path:   change.Path,
+			Kind:   string(change.Kind),
+			Before: toChangeValue(change.Before),
+			After:  toChangeValue(change.After),
+		}
+	}
+
+	return bodies
+}
+
+func toChangeValue(value any) any {
+	switch v := value.(type) {
+	case flags.Value:
+		return toValueBody(v)
+	case flags.Rule:
+		return toRuleBody(v)
+	case []flags.FlagTest:
+		return toFlagTestBodies(v)
+	case flags.FlagType:
+		return string(v)
+	case flags.FlagKind:
+		return string(v)
+	default:
+		return v
+	}
+}
+
+func ToArchiveBody(archive flags.Archive) ArchiveBody {
+	bodies := make([]CreateFlagBody, len(archive.Flags))
+	for i, flag := range archive.Flags {
+		bodies[i] = ToCreateFlagBody(flag)
+	}
+
+	return ArchiveBody{FormatVersion: archive.FormatVersion, ExportedAt: archive.ExportedAt, Flags: bodies}
+}
+
+func ToArchive(body ArchiveBody) flags.Archive {
+	all := make([]flags.Flag, len(body.Flags))
+	for i, flag := range body.Flags {
+		all[i] = ToFlag(flag)
+	}
+
+	return flags.Archive{FormatVersion: body.FormatVersion, ExportedAt: body.ExportedAt, Flags: all}
+}
+
+func ToImportResponseBody(changes []flags.ImportChange, dryRun bool) ImportResponseBody {
+	bodies := make([]ImportChangeBody, len(changes))
+	for i, change := range changes {
+		bodies[i] = ImportChangeBody{
+			Action:  string(change.Action),
+			Key:     change.Key,
+			Reason:  change.Reason,
+			Changes: ToFieldChangeBodies(change.Diff),
+		}
+	}
+
+	return ImportResponseBody{DryRun: dryRun, Changes: bodies}
+}
+
+func ToChangeFilter(req *ListChangesRequest) flags.ChangeFilter {
+	return flags.ChangeFilter{FlagKey: flags.FlagKey(req.FlagKey), Status: flags.ChangeStatus(req.Status)}
+}
+
+func ToChangeRequestBody(change flags.ChangeRequest) ChangeRequestBody {
+	comments := make([]ChangeCommentBody, len(change.Comments))
+	for i, comment := range change.Comments {
+		comments[i] = ChangeCommentBody{Author: comment.Author, Text: comment.Text, Time: comment.Time}
+	}
+
+	approvers := change.Approvers
+	if approvers == nil {
+		approvers = []string{}
+	}
+
+	return ChangeRequestBody{
+		ID:                change.ID,
+		FlagKey:           change.FlagKey,
+		Action:            string(change.Action),
+		Status:            string(change.Status),
+		Base:              toFlagBodyPtr(change.Base),
+		Proposed:          toFlagBodyPtr(change.Proposed),
+		Diff:              ToFieldChangeBodies(change.Diff),
+		Requester:         change.Requester,
+		RequiredApprovals: change.RequiredApprovals,
+		Approvers:         approvers,
+		Comments:          comments,
+		CreatedAt:         change.CreatedAt,
+		UpdatedAt:         change.UpdatedAt,
+		ClosedBy:          change.ClosedBy,
+		ClosedAt:          change.ClosedAt,
+		AppliedVersion:    change.AppliedVersion,
+	}
+}
+
+func ToListChangesResponseBody(changes []flags.ChangeRequest) ListChangesResponseBody {
+	bodies := make([]ChangeRequestBody, len(changes))
+	for i, change := range changes {
+		bodies[i] = ToChangeRequestBody(change)
+	}
+
+	return ListChangesResponseBody{Changes: bodies}
+}
+
+func ToScheduledChange(req *ScheduleFlagRequest) flags.ScheduledChange {
+	return flags.ScheduledChange{
+		FlagKey:    flags.FlagKey(req.Key),
+		Action:     flags.ScheduleAction(req.Body.Action),
+		Rules:      toRules(req.Body.Rules),
+		RuleID:     req.Body.RuleID,
+		Percentage: req.Body.Percentage,
+		At:         req.Body.At,
+	}
+}
+
+func ToScheduleFilter(req *ListSchedulesRequest) flags.ScheduleFilter {
+	return flags.ScheduleFilter{FlagKey: flags.FlagKey(req.FlagKey), Status: flags.ScheduleStatus(req.Status)}
+}
+
+func ToScheduleBody(change flags.ScheduledChange) ScheduleBody {
+	return ScheduleBody{
+		ID:             change.ID,
+		FlagKey:        change.FlagKey,
+		Action:         string(change.Action),
+		Rules:          toRuleBodies(change.Rules),
+		RuleID:         change.RuleID,
+		Percentage:     change.Percentage,
+		At:             change.At,
+		CreatedBy:      change.CreatedBy,
+		CreatedAt:      change.CreatedAt,
+		Status:         string(change.Status),
+		RanAt:          change.RanAt,
+		AppliedVersion: change.AppliedVersion,
+		Error:          change.Error,
+		CanceledBy:     change.CanceledBy,
+		CanceledAt:     change.CanceledAt,
+	}
+}
+
+func ToListSchedulesResponseBody(changes []flags.ScheduledChange) ListSchedulesResponseBody {
+	bodies := make([]ScheduleBody, len(changes))
+	for i, change := range changes {
+		bodies[i] = ToScheduleBody(change)
+	}
+
+	return ListSchedulesResponseBody{Schedules: bodies}
+}
+
+func ToNewKey(body CreateKeyBody) auth.NewKey {
+	return auth.NewKey{
+		Name:        body.Name,
+		Scope:       auth.Scope(body.Scope),
+		Environment: body.Environment,
+		Tenant:      body.Tenant,
+	}
+}
+
+// ToKeyBody omits the key's hash.
+func ToKeyBody(key auth.Key) KeyBody {
+	return KeyBody{
+		ID:          key.ID,
+		Name:        key.Name,
+		Scope:       string(key.Scope),
+		Environment: key.Environment,
+		Tenant:      key.Tenant,
+		CreatedAt:   key.CreatedAt,
+		RotatedAt:   key.RotatedAt,
+		RevokedAt:   key.RevokedAt,
+	}
+}
+
+func ToListKeysResponseBody(keys []auth.Key) ListKeysResponseBody {
+	bodies := make([]KeyBody, len(keys))
+	for i, key := range keys {
+		bodies[i] = ToKeyBody(key)
+	}
+
+	return ListKeysResponseBody{Keys: bodies}
+}
+
+// ToRamp maps a start ramp request, rejecting dwells that are not durations.
+func ToRamp(req *StartRampRequest) (flags.Ramp, error) {
+	steps := make([]flags.RampStep, len(req.Body.Steps))
+
+	for i, step := range req.Body.Steps {
+		steps[i].Percentage = step.Percentage
+
+		if step.Dwell == "" {
+			continue
+		}
+
+		dwell, err := time.ParseDuration(step.Dwell)
+		if err != nil {
+			return flags.Ramp{}, fmt.Errorf("step %d: %w", i+1, err)
+		}
+
+		steps[i].Dwell = dwell
+	}
+
+	return flags.Ramp{FlagKey: flags.FlagKey(req.Key), RuleID: req.Body.RuleID, Steps: steps}, nil
+}
+
+func ToRampFilter(req *ListRampsRequest) flags.RampFilter {
+	return flags.RampFilter{FlagKey: flags.FlagKey(req.FlagKey), Status: flags.RampStatus(req.Status)}
+}
+
+func ToRampBody(ramp flags.Ramp) RampBody {
+	steps := make([]RampStepBody, len(ramp.Steps))
+	for i, step := range ramp.Steps {
+		steps[i].Percentage = step.Percentage
+		if step.Dwell > 0 {
+			steps[i].Dwell = step.Dwell.String()
+		}
+	}
+
+	return RampBody{
+		ID:            ramp.ID,
+		FlagKey:       ramp.FlagKey,
+		RuleID:        ramp.RuleID,
+		Steps:         steps,
+		Step:          ramp.Step,
+		Percentage:    ramp.Percentage(),
+		Status:        string(ramp.Status),
+		CreatedBy:     ramp.CreatedBy,
+		CreatedAt:     ramp.CreatedAt,
+		StepStartedAt: ramp.StepStartedAt,
+		NextStepAt:    ramp.NextStepAt(),
+		PausedAt:      ramp.PausedAt,
+		UpdatedBy:     ramp.UpdatedBy,
+		UpdatedAt:     ramp.UpdatedAt,
+		Error:         ramp.Error,
+	}
+}
+
+func ToListRampsResponseBody(ramps []flags.Ramp) ListRampsResponseBody {
+	bodies := make([]RampBody, len(ramps))
+	for i, ramp := range ramps {
+		bodies[i] = ToRampBody(ramp)
+	}
+
+	return ListRampsResponseBody{Ramps: bodies}
+}
+
+func ToEvalContexts(bodies []EvaluateFlagBody) []flags.EvalContext {
+	contexts := make([]flags.EvalContext, len(bodies))
+	for i, body := range bodies {
+		contexts[i] = ToEvalContext(body)
+	}
+
+	return contexts
+}
+
+func ToSimulateFlagResponseBody(simulations []flags.Simulation) SimulateFlagResponseBody {
+	body := SimulateFlagResponseBody{Results: make([]SimulationBody, len(simulations))}
+
+	for i, simulation := range simulations {
+		body.Results[i] = SimulationBody{
+			Context:  toEvaluateFlagBody(simulation.Context),
+			Current:  ToEvalResultBody(simulation.Current),
+			Proposed: ToEvalResultBody(simulation.Proposed),
+			Changed:  simulation.Changed,
+		}
+
+		if simulation.Changed {
+			body.Changed++
+		}
+	}
+
+	return body
+}
+
+func ToRunFlagTestsResponseBody(results []flags.TestResult) RunFlagTestsResponseBody {
+	body := RunFlagTestsResponseBody{Results: make([]FlagTestResultBody, len(results))}
+
+	for i, result := range results {
+		body.Results[i] = FlagTestResultBody{
+			Name:   result.Test.Name,
+			Passed: result.Passed,
+			Expect: toValueBody(result.Test.Expect),
+			RuleID: result.Test.RuleID,
+			Result: ToEvalResultBody(result.Result),
+		}
+
+		if !result.Passed {
+			body.Failed++
+		}
+	}
+
+	return body
+}
+
+func ToFlagUsageBody(usage flags.FlagUsage) FlagUsageBody {
+	body := FlagUsageBody{
+		Key:           string(usage.FlagKey),
+		Since:         usage.Since,
+		LastEvaluated: usage.LastEvaluated,
+		Evaluations:   usage.Evaluations,
+		Reasons:       toReasonCounts(usage.Reasons),
+		Variations:    toVariationCountBodies(usage.Variations, usage.Evaluations),
+		Hours:         make([]UsageHourBody, len(usage.Hours)),
+	}
+
+	for i, hour := range usage.Hours {
+		body.Hours[i] = UsageHourBody{
+			Hour:        hour.Hour,
+			Evaluations: hour.Evaluations,
+			Reasons:     toReasonCounts(hour.Reasons),
+			Variations:  toVariationCountBodies(hour.Variations, hour.Evaluations),
+		}
+	}
+
+	return body
+}
+
+func toReasonCounts(reasons map[flags.EvalReason]int64) map[string]int64 {
+	counts := make(map[string]int64, len(reasons))
+	for reason, count := range reasons {
+		counts[string(reason)] = count
+	}
+
+	return counts
+}
+
+func toVariationCountBodies(variations []flags.VariationCount, total int64) []VariationCountBody {
+	bodies := make([]VariationCountBody, len(variations))
+
+	for i, variation := range variations {
+		bodies[i] = VariationCountBody{Value: toValueBody(variation.Value), Count: variation.Count}
+		if total > 0 {
+			bodies[i].Share = float64(variation.Count) / float64(total)
+		}
+	}
+
+	return bodies
+}
+
+func ToStaleReportBody(report flags.StaleReport) StaleReportBody {
+	body := StaleReportBody{Since: report.Since, Flags: make([]StaleFlagBody, len(report.Flags))}
+
+	for i, stale := range report.Flags {
+		body.Flags[i] = StaleFlagBody{
+			Key:           string(stale.Flag.Key),
+			Kind:          string(stale.Kind),
+			Owner:         stale.Flag.Owner,
+			ExpiresAt:     stale.Flag.ExpiresAt,
+			UpdatedAt:     stale.Flag.UpdatedAt,
+			LastEvaluated: stale.LastEvaluated,
+			Evaluations:   stale.Evaluations,
+		}
+
+		if stale.Kind == flags.StaleSingleValue {
+			value := toValueBody(stale.Value)
+			body.Flags[i].Value = &value
+		}
+	}
+
+	return body
+}
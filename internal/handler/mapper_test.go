@@ -4,9 +4,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/serroba/features/internal/auth"
 	"github.com/serroba/features/internal/flags"
 	"github.com/serroba/features/internal/handler"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestToFlag(t *testing.T) {
@@ -49,6 +51,50 @@ func TestToFlag(t *testing.T) {
 	assert.Equal(t, flags.OpEquals, flag.Rules[0].Conditions[0].Op)
 }
 
+func TestToFlag_Rollout(t *testing.T) {
+	t.Parallel()
+
+	on := true
+	flag := handler.ToFlag(handler.CreateFlagBody{
+		Key:  "checkout",
+		Type: "bool",
+		Rules: []handler.RuleBody{{
+			ID:      "everyone",
+			Value:   handler.ValueBody{Kind: "bool", Bool: &on},
+			Rollout: &handler.RolloutBody{Percentage: 12.5, BucketBy: "tenant_id"},
+		}},
+	})
+
+	require.Len(t, flag.Rules, 1)
+	assert.Equal(t, &flags.Rollout{Percentage: 12.5, BucketBy: "tenant_id"}, flag.Rules[0].Rollout)
+
+	body := handler.ToFlagBody(flag)
+	assert.Equal(t, &handler.RolloutBody{Percentage: 12.5, BucketBy: "tenant_id"}, body.Rules[0].Rollout)
+}
+
+func TestToRamp(t *testing.T) {
+	t.Parallel()
+
+	ramp, err := handler.ToRamp(&handler.StartRampRequest{
+		Key: "checkout",
+		Body: handler.StartRampBody{
+			RuleID: "everyone",
+			Steps:  []handler.RampStepBody{{Percentage: 5, Dwell: "1h30m"}, {Percentage: 100}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, flags.Ramp{
+		FlagKey: "checkout",
+		RuleID:  "everyone",
+		Steps:   []flags.RampStep{{Percentage: 5, Dwell: 90 * time.Minute}, {Percentage: 100}},
+	}, ramp)
+
+	_, err = handler.ToRamp(&handler.StartRampRequest{
+		Body: handler.StartRampBody{Steps: []handler.RampStepBody{{Percentage: 5, Dwell: "soon"}}},
+	})
+	require.ErrorContains(t, err, "step 1")
+}
+
 func TestToFlag_EmptyRules(t *testing.T) {
 	t.Parallel()
 
@@ -74,6 +120,7 @@ func TestToEvalContext(t *testing.T) {
 			"plan":    "premium",
 			"country": "US",
 		},
+		At: time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC),
 	}
 
 	ctx := handler.ToEvalContext(body)
@@ -82,6 +129,7 @@ func TestToEvalContext(t *testing.T) {
 	assert.Equal(t, "user-456", ctx.UserID)
 	assert.Equal(t, "premium", ctx.Attrs["plan"])
 	assert.Equal(t, "US", ctx.Attrs["country"])
+	assert.Equal(t, body.At, ctx.Now)
 }
 
 func TestToEvalResultBody(t *testing.T) {
@@ -139,3 +187,276 @@ func TestToFlag_EmptyConditions(t *testing.T) {
 	assert.Len(t, flag.Rules, 1)
 	assert.Nil(t, flag.Rules[0].Conditions)
 }
+
+func TestToFlagBody(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	flag := flags.Flag{
+		Key:          "test-flag",
+		Type:         flags.FlagBool,
+		Enabled:      true,
+		DefaultValue: flags.BoolValue(false),
+		Rules: []flags.Rule{
+			{
+				ID:         "rule-1",
+				Conditions: []flags.Condition{{Attr: "plan", Op: flags.OpIn, Value: []any{"pro"}}},
+				Value:      flags.BoolValue(true),
+			},
+		},
+		UpdatedAt: now,
+		ManagedBy: flags.ManagedByFile,
+		Owner:     "payments",
+		Tags:      []string{"checkout"},
+		Kind:      flags.KindRelease,
+		ExpiresAt: now.Add(time.Hour),
+	}
+
+	body := handler.ToFlagBody(flag)
+
+	assert.Equal(t, flags.FlagKey("test-flag"), body.Key)
+	assert.Equal(t, "bool", body.Type)
+	assert.True(t, body.Enabled)
+	assert.False(t, *body.DefaultValue.Bool)
+	assert.Equal(t, now, body.UpdatedAt)
+	assert.Equal(t, "file", body.ManagedBy)
+	assert.Equal(t, "payments", body.Owner)
+	assert.Equal(t, []string{"checkout"}, body.Tags)
+	assert.Equal(t, "release", body.Kind)
+	assert.Equal(t, now.Add(time.Hour), body.ExpiresAt)
+	assert.Len(t, body.Rules, 1)
+	assert.Equal(t, "rule-1", body.Rules[0].ID)
+	assert.Equal(t, handler.ConditionBody{Attr: "plan", Op: "in", Value: []any{"pro"}}, body.Rules[0].Conditions[0])
+	assert.True(t, *body.Rules[0].Value.Bool)
+	assert.Nil(t, handler.ToFlagBody(flags.Flag{}).Rules)
+}
+
+func TestToAuditFilter(t *testing.T) {
+	t.Parallel()
+
+	since := time.Now()
+	req := &handler.ListAuditRequest{
+		FlagKey: "test-flag",
+		Actor:   "alice",
+		Since:   since,
+		Cursor:  "abc",
+		Limit:   10,
+	}
+
+	filter := handler.ToAuditFilter(req)
+
+	assert.Equal(t, flags.AuditFilter{
+		FlagKey: "test-flag",
+		Actor:   "alice",
+		Since:   since,
+		Cursor:  "abc",
+		Limit:   10,
+	}, filter)
+}
+
+func TestToListAuditResponseBody(t *testing.T) {
+	t.Parallel()
+
+	before := flags.Flag{Key: "test-flag", Type: flags.FlagBool, DefaultValue: flags.BoolValue(false)}
+	after := before
+	after.Enabled = true
+	after.DefaultValue = flags.BoolValue(true)
+	after.Rules = []flags.Rule{{ID: "rule-1", Value: flags.BoolValue(true)}}
+
+	page := flags.AuditPage{
+		Entries: []flags.AuditEntry{
+			{
+				Seq:       7,
+				Actor:     "alice",
+				Action:    flags.AuditCreate,
+				FlagKey:   "test-flag",
+				Before:    &before,
+				After:     &after,
+				Diff:      flags.Diff(&before, &after),
+				RequestID: "req-1",
+			},
+			{Seq: 8, FlagKey: "test-flag", Diff: []flags.FieldChange{{Path: "type", After: flags.FlagString}}},
+		},
+		NextCursor: "next",
+	}
+
+	body := handler.ToListAuditResponseBody(page)
+
+	require.Len(t, body.Entries, 2)
+	assert.Equal(t, "next", body.NextCursor)
+
+	entry := body.Entries[0]
+	assert.Equal(t, uint64(7), entry.Seq)
+	assert.Equal(t, "create", entry.Action)
+	assert.Equal(t, "req-1", entry.RequestID)
+	require.NotNil(t, entry.Before)
+	require.NotNil(t, entry.After)
+	assert.True(t, entry.After.Enabled)
+	assert.Equal(t, []handler.FieldChangeBody{
+		{Path: "enabled", Kind: "modified", Before: false, After: true},
+		{
+			Path:   "defaultValue",
+			Kind:   "modified",
+			Before: handler.ValueBody{Kind: "bool", Bool: before.DefaultValue.Bool},
+			After:  handler.ValueBody{Kind: "bool", Bool: after.DefaultValue.Bool},
+		},
+		{
+			Path: "rules[rule-1]",
+			Kind: "added",
+			After: handler.RuleBody{
+				ID:         "rule-1",
+				Conditions: []handler.ConditionBody{},
+				Value:      handler.ValueBody{Kind: "bool", Bool: after.Rules[0].Value.Bool},
+			},
+		},
+	}, entry.Diff)
+
+	assert.Nil(t, body.Entries[1].Before)
+	assert.Equal(t, "string", body.Entries[1].Diff[0].After)
+}
+
+func TestToUpdatedFlag(t *testing.T) {
+	t.Parallel()
+
+	boolVal := false
+	body := handler.UpdateFlagBody{
+		Type:         "bool",
+		Enabled:      true,
+		DefaultValue: handler.ValueBody{Kind: "bool", Bool: &boolVal},
+		Version:      4,
+		MetadataBody: handler.MetadataBody{Owner: "payments", Kind: "kill-switch"},
+	}
+
+	flag := handler.ToUpdatedFlag("test-flag", body)
+
+	assert.Equal(t, flags.FlagKey("test-flag"), flag.Key)
+	assert.Equal(t, flags.FlagBool, flag.Type)
+	assert.True(t, flag.Enabled)
+	assert.False(t, *flag.DefaultValue.Bool)
+	assert.Equal(t, 4, flag.Version)
+	assert.Equal(t, "payments", flag.Owner)
+	assert.Equal(t, flags.KindKillSwitch, flag.Kind)
+	assert.Nil(t, flag.Rules)
+}
+
+func TestToCreateFlagBody(t *testing.T) {
+	t.Parallel()
+
+	flag := flags.Flag{
+		Key:          "test-flag",
+		Description:  "New checkout",
+		Owner:        "payments",
+		Tags:         []string{"checkout", "q3"},
+		Kind:         flags.KindRelease,
+		ExpiresAt:    time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC),
+		Type:         flags.FlagBool,
+		Enabled:      true,
+		DefaultValue: flags.BoolValue(false),
+		Rules: []flags.Rule{{
+			ID:         "rule-1",
+			Conditions: []flags.Condition{{Attr: "plan", Op: flags.OpIn, Value: []any{"pro"}}},
+			Value:      flags.BoolValue(true),
+		}},
+	}
+
+	managed := flag
+	managed.Version = 3
+	managed.ManagedBy = flags.ManagedByFile
+
+	body := handler.ToCreateFlagBody(managed)
+
+	assert.Equal(t, "test-flag", body.Key)
+	assert.Equal(t, flag, handler.ToFlag(body))
+}
+
+func TestToListFlagsResponseBody(t *testing.T) {
+	t.Parallel()
+
+	body := handler.ToListFlagsResponseBody([]flags.Flag{{Key: "a"}, {Key: "b", Enabled: true}})
+
+	require.Len(t, body.Flags, 2)
+	assert.Equal(t, flags.FlagKey("a"), body.Flags[0].Key)
+	assert.True(t, body.Flags[1].Enabled)
+	assert.Empty(t, handler.ToListFlagsResponseBody(nil).Flags)
+}
+
+func TestToListVersionsResponseBody(t *testing.T) {
+	t.Parallel()
+
+	body := handler.ToListVersionsResponseBody([]flags.Flag{
+		{Key: "test-flag", Version: 1},
+		{Key: "test-flag", Version: 2, Enabled: true},
+	})
+
+	require.Len(t, body.Versions, 2)
+	assert.Equal(t, 1, body.Versions[0].Version)
+	assert.True(t, body.Versions[1].Enabled)
+}
+
+func TestToArchive(t *testing.T) {
+	t.Parallel()
+
+	exportedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	archive := flags.Archive{
+		FormatVersion: flags.ArchiveFormatVersion,
+		ExportedAt:    exportedAt,
+		Flags:         []flags.Flag{{Key: "a", Type: flags.FlagBool, Enabled: true, DefaultValue: flags.BoolValue(true)}},
+	}
+
+	body := handler.ToArchiveBody(archive)
+	assert.Equal(t, 1, body.FormatVersion)
+	assert.Equal(t, exportedAt, body.ExportedAt)
+	assert.Equal(t, archive, handler.ToArchive(body))
+}
+
+func TestToImportResponseBody(t *testing.T) {
+	t.Parallel()
+
+	body := handler.ToImportResponseBody([]flags.ImportChange{
+		{Action: flags.ImportUpdate, Key: "a", Diff: []flags.FieldChange{
+			{Path: "enabled", Kind: flags.ChangeModified, Before: false, After: true},
+		}},
+		{Action: flags.ImportSkip, Key: "b", Reason: "flag exists"},
+	}, true)
+
+	assert.True(t, body.DryRun)
+	require.Len(t, body.Changes, 2)
+	assert.Equal(t, handler.ImportChangeBody{
+		Action:  "update",
+		Key:     "a",
+		Changes: []handler.FieldChangeBody{{Path: "enabled", Kind: "modified", Before: false, After: true}},
+	}, body.Changes[0])
+	assert.Equal(t, "flag exists", body.Changes[1].Reason)
+}
+
+func TestToNewKey(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		auth.NewKey{Name: "backend", Scope: auth.ScopeEvaluate, Environment: "prod", Tenant: "acme"},
+		handler.ToNewKey(handler.CreateKeyBody{Name: "backend", Scope: "evaluate", Environment: "prod", Tenant: "acme"}))
+}
+
+func TestToListKeysResponseBody(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	body := handler.ToListKeysResponseBody([]auth.Key{{
+		ID:        "k1",
+		Name:      "backend",
+		Scope:     auth.ScopeRead,
+		Hash:      "secret-hash",
+		CreatedAt: createdAt,
+		RevokedAt: createdAt.Add(time.Hour),
+	}})
+
+	require.Len(t, body.Keys, 1)
+	assert.Equal(t, handler.KeyBody{
+		ID:        "k1",
+		Name:      "backend",
+		Scope:     "read",
+		CreatedAt: createdAt,
+		RevokedAt: createdAt.Add(time.Hour),
+	}, body.Keys[0])
+	assert.Empty(t, handler.ToListKeysResponseBody(nil).Keys)
+}
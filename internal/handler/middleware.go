@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/serroba/features/internal/auth"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/metrics"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestContext copies the chi request ID into the context consumed by
+// flags.Service so audit entries can be correlated with access logs. It must
+// be mounted after middleware.RequestID.
+func RequestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if requestID := middleware.GetReqID(ctx); requestID != "" {
+			ctx = flags.WithRequestID(ctx, requestID)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Instrument returns a huma middleware that records every operation's status
+// and latency. Installed with UseMiddleware before Authenticate, it also
+// counts the requests that fail authentication.
+func Instrument(requests *metrics.HTTP) func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		start := time.Now()
+
+		next(ctx)
+
+		requests.Observe(ctx.Operation().OperationID, ctx.Method(), ctx.Status(), time.Since(start))
+	}
+}
+
+// TracerName names the tracer of the HTTP spans.
+const TracerName = "github.com/serroba/features/internal/handler"
+
+// Trace returns a huma middleware that wraps every operation in a server span,
+// continuing the trace in the request's W3C traceparent header when there is
+// one. Only server errors mark the span as failed. Installed with
+// UseMiddleware first, it also covers authentication.
+func Trace(provider trace.TracerProvider) func(huma.Context, func(huma.Context)) {
+	tracer := provider.Tracer(TracerName)
+	propagator := propagation.TraceContext{}
+
+	return func(ctx huma.Context, next func(huma.Context)) {
+		op := ctx.Operation()
+
+		spanCtx, span := tracer.Start(
+			propagator.Extract(ctx.Context(), headerCarrier{ctx}),
+			op.Method+" "+op.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(op.Method), semconv.HTTPRoute(op.Path)),
+		)
+		defer span.End()
+
+		if key := ctx.Param("key"); key != "" {
+			span.SetAttributes(flags.AttrFlagKey.String(key))
+		}
+
+		next(huma.WithContext(ctx, spanCtx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(ctx.Status()))
+
+		if ctx.Status() >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(ctx.Status()))
+		}
+	}
+}
+
+// headerCarrier reads propagation headers from a huma request.
+type headerCarrier struct {
+	ctx huma.Context
+}
+
+func (c headerCarrier) Get(key string) string {
+	return c.ctx.Header(key)
+}
+
+func (headerCarrier) Set(string, string) {}
+
+func (c headerCarrier) Keys() []string {
+	var keys []string
+
+	c.ctx.EachHeader(func(name, _ string) {
+		keys = append(keys, name)
+	})
+
+	return keys
+}
+
+// Security scheme names declared by SecuritySchemes. Operations accept an API
+// key in either.
+const (
+	APIKeyScheme = "apiKey"
+	BearerScheme = "bearer"
+)
+
+// SecuritySchemes are the OpenAPI security schemes for API keys, for
+// huma.Config.Components.
+func SecuritySchemes() map[string]*huma.SecurityScheme {
+	return map[string]*huma.SecurityScheme{
+		APIKeyScheme: {
+			Type:        "apiKey",
+			Description: "API key with the evaluate, read or admin scope",
+			Name:        apiKeyHeader,
+			In:          "header",
+		},
+		BearerScheme: {
+			Type:        "http",
+			Description: "API key, or a JWT from the identity provider",
+			Scheme:      "bearer",
+		},
+	}
+}
+
+const apiKeyHeader = "X-API-Key" //nolint:gosec // a header name, not a credential
+
+// requires is the security requirement of an operation that needs scope.
+func requires(scope auth.Scope) []map[string][]string {
+	return []map[string][]string{
+		{APIKeyScheme: {string(scope)}},
+		{BearerScheme: {string(scope)}},
+	}
+}
+
+// Authenticate returns a huma middleware that authenticates the bearer token
+// of every operation with a security requirement and checks the principal
+// against the scope it requires and the server's environment. The principal
+// is stored in the context and recorded as the actor. It must be installed
+// with UseMiddleware before Register.
+func Authenticate(
+	api huma.API, authenticator auth.Authenticator, environment string,
+) func(huma.Context, func(huma.Context)) {
+	return func(ctx huma.Context, next func(huma.Context)) {
+		scope, ok := requiredScope(ctx.Operation())
+		if !ok {
+			next(ctx)
+
+			return
+		}
+
+		principal, err := authenticator.Authenticate(ctx.Context(), requestToken(ctx))
+		if err != nil {
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				ctx.SetHeader("WWW-Authenticate", "Bearer")
+				_ = huma.WriteErr(api, ctx, http.StatusUnauthorized, auth.ErrUnauthenticated.Error())
+			} else {
+				_ = huma.WriteErr(api, ctx, http.StatusInternalServerError, "failed to authenticate")
+			}
+
+			return
+		}
+
+		if err := principal.Authorize(scope, environment); err != nil {
+			_ = huma.WriteErr(api, ctx, http.StatusForbidden, err.Error())
+
+			return
+		}
+
+		next(huma.WithContext(ctx, auth.WithPrincipal(flags.WithActor(ctx.Context(), principal.Name), principal)))
+	}
+}
+
+func requiredScope(op *huma.Operation) (auth.Scope, bool) {
+	for _, requirement := range op.Security {
+		for _, scopes := range requirement {
+			if len(scopes) > 0 {
+				return auth.Scope(scopes[0]), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func requestToken(ctx huma.Context) string {
+	if token := ctx.Header(apiKeyHeader); token != "" {
+		return token
+	}
+
+	token, _ := strings.CutPrefix(ctx.Header("Authorization"), "Bearer ")
+
+	return token
+}
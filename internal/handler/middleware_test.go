@@ -0,0 +1,143 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/serroba/features/internal/auth"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/handler"
+	"github.com/serroba/features/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRequestContext(t *testing.T) {
+	t.Parallel()
+
+	var requestID string
+
+	next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		requestID = flags.RequestIDFromContext(r.Context())
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/audit", nil)
+	req.Header.Set(middleware.RequestIDHeader, "req-42")
+
+	middleware.RequestID(handler.RequestContext(next)).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "req-42", requestID)
+}
+
+func TestRequestContext_NoRequestID(t *testing.T) {
+	t.Parallel()
+
+	requestID := "unset"
+
+	next := http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		requestID = flags.RequestIDFromContext(r.Context())
+	})
+
+	req := httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/audit", nil)
+
+	handler.RequestContext(next).ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Empty(t, requestID)
+}
+
+func TestInstrument(t *testing.T) {
+	t.Parallel()
+
+	config := huma.DefaultConfig("Feature Flags API", "1.0.0")
+	config.Components.SecuritySchemes = handler.SecuritySchemes()
+
+	_, api := humatest.New(t, config)
+	registry := metrics.NewRegistry()
+	keys := auth.NewService(auth.NewMemoryStore(), auth.WithBootstrapKey("root"))
+
+	api.UseMiddleware(handler.Instrument(metrics.NewHTTP(registry)))
+	api.UseMiddleware(handler.Authenticate(api, keys, "prod"))
+	handler.New(flags.NewService(flags.NewMemoryRepository())).Register(api)
+
+	require.Equal(t, http.StatusOK, api.Get("/flags", rootKey).Code)
+	require.Equal(t, http.StatusNotFound, api.Get("/flags/missing", rootKey).Code)
+	require.Equal(t, http.StatusUnauthorized, api.Get("/flags").Code)
+
+	var out strings.Builder
+	require.NoError(t, registry.Write(context.Background(), &out))
+
+	assert.Contains(t, out.String(), `features_http_requests_total{operation="list-flags",method="GET",code="200"} 1`)
+	assert.Contains(t, out.String(), `features_http_requests_total{operation="list-flags",method="GET",code="401"} 1`)
+	assert.Contains(t, out.String(), `features_http_requests_total{operation="get-flag",method="GET",code="404"} 1`)
+	assert.Contains(t, out.String(), `features_http_request_duration_seconds_count{operation="list-flags"} 2`)
+}
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	mockService := NewMockFlagService(gomock.NewController(t))
+
+	_, api := humatest.New(t)
+	api.UseMiddleware(handler.Trace(provider))
+	handler.New(mockService).Register(api)
+
+	var served trace.SpanContext
+
+	mockService.EXPECT().Evaluate(gomock.Any(), flags.FlagKey("checkout"), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ flags.FlagKey, _ flags.EvalContext) (flags.EvalResult, error) {
+			served = trace.SpanContextFromContext(ctx)
+
+			return flags.EvalResult{}, errors.New("storage unavailable")
+		})
+
+	resp := api.Post("/flags/checkout/evaluate",
+		"traceparent: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", map[string]any{})
+	require.Equal(t, http.StatusInternalServerError, resp.Code)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+
+	span := spans[0]
+	assert.Equal(t, "POST /flags/{key}/evaluate", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, span.SpanContext(), served, "the operation runs inside the span")
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.Contains(t, span.Attributes(), attribute.String("flag.key", "checkout"))
+	assert.Contains(t, span.Attributes(), attribute.Int("http.response.status_code", http.StatusInternalServerError))
+}
+
+func TestTrace_ClientError(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	_, api := humatest.New(t)
+	api.UseMiddleware(handler.Trace(provider))
+	handler.New(flags.NewService(flags.NewMemoryRepository())).Register(api)
+
+	require.Equal(t, http.StatusNotFound, api.Get("/flags/missing").Code)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.False(t, spans[0].Parent().IsValid(), "a request without traceparent starts a trace")
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+	assert.Contains(t, spans[0].Attributes(), attribute.Int("http.response.status_code", http.StatusNotFound))
+}
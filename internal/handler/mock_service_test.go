@@ -41,6 +41,96 @@ func (m *MockFlagService) EXPECT() *MockFlagServiceMockRecorder {
 	return m.recorder
 }
 
+// Apply mocks base method.
+func (m *MockFlagService) Apply(ctx context.Context, id string) (flags.ChangeRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", ctx, id)
+	ret0, _ := ret[0].(flags.ChangeRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Apply indicates an expected call of Apply.
+func (mr *MockFlagServiceMockRecorder) Apply(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockFlagService)(nil).Apply), ctx, id)
+}
+
+// Approve mocks base method.
+func (m *MockFlagService) Approve(ctx context.Context, id, comment string) (flags.ChangeRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Approve", ctx, id, comment)
+	ret0, _ := ret[0].(flags.ChangeRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Approve indicates an expected call of Approve.
+func (mr *MockFlagServiceMockRecorder) Approve(ctx, id, comment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Approve", reflect.TypeOf((*MockFlagService)(nil).Approve), ctx, id, comment)
+}
+
+// AuditLog mocks base method.
+func (m *MockFlagService) AuditLog(ctx context.Context, filter flags.AuditFilter) (flags.AuditPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuditLog", ctx, filter)
+	ret0, _ := ret[0].(flags.AuditPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AuditLog indicates an expected call of AuditLog.
+func (mr *MockFlagServiceMockRecorder) AuditLog(ctx, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuditLog", reflect.TypeOf((*MockFlagService)(nil).AuditLog), ctx, filter)
+}
+
+// ChangeRequest mocks base method.
+func (m *MockFlagService) ChangeRequest(ctx context.Context, id string) (flags.ChangeRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeRequest", ctx, id)
+	ret0, _ := ret[0].(flags.ChangeRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangeRequest indicates an expected call of ChangeRequest.
+func (mr *MockFlagServiceMockRecorder) ChangeRequest(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeRequest", reflect.TypeOf((*MockFlagService)(nil).ChangeRequest), ctx, id)
+}
+
+// ChangeRequests mocks base method.
+func (m *MockFlagService) ChangeRequests(ctx context.Context, filter flags.ChangeFilter) ([]flags.ChangeRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangeRequests", ctx, filter)
+	ret0, _ := ret[0].([]flags.ChangeRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangeRequests indicates an expected call of ChangeRequests.
+func (mr *MockFlagServiceMockRecorder) ChangeRequests(ctx, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangeRequests", reflect.TypeOf((*MockFlagService)(nil).ChangeRequests), ctx, filter)
+}
+
+// Comment mocks base method.
+func (m *MockFlagService) Comment(ctx context.Context, id, text string) (flags.ChangeRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Comment", ctx, id, text)
+	ret0, _ := ret[0].(flags.ChangeRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Comment indicates an expected call of Comment.
+func (mr *MockFlagServiceMockRecorder) Comment(ctx, id, text any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Comment", reflect.TypeOf((*MockFlagService)(nil).Comment), ctx, id, text)
+}
+
 // Create mocks base method.
 func (m *MockFlagService) Create(ctx context.Context, flag flags.Flag) (flags.Flag, error) {
 	m.ctrl.T.Helper()
@@ -56,6 +146,35 @@ func (mr *MockFlagServiceMockRecorder) Create(ctx, flag any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockFlagService)(nil).Create), ctx, flag)
 }
 
+// Delete mocks base method.
+func (m *MockFlagService) Delete(ctx context.Context, key flags.FlagKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockFlagServiceMockRecorder) Delete(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockFlagService)(nil).Delete), ctx, key)
+}
+
+// DiffVersions mocks base method.
+func (m *MockFlagService) DiffVersions(ctx context.Context, key flags.FlagKey, from, to int) ([]flags.FieldChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DiffVersions", ctx, key, from, to)
+	ret0, _ := ret[0].([]flags.FieldChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DiffVersions indicates an expected call of DiffVersions.
+func (mr *MockFlagServiceMockRecorder) DiffVersions(ctx, key, from, to any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiffVersions", reflect.TypeOf((*MockFlagService)(nil).DiffVersions), ctx, key, from, to)
+}
+
 // Evaluate mocks base method.
 func (m *MockFlagService) Evaluate(ctx context.Context, key flags.FlagKey, evalCtx flags.EvalContext) (flags.EvalResult, error) {
 	m.ctrl.T.Helper()
@@ -70,3 +189,138 @@ func (mr *MockFlagServiceMockRecorder) Evaluate(ctx, key, evalCtx any) *gomock.C
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Evaluate", reflect.TypeOf((*MockFlagService)(nil).Evaluate), ctx, key, evalCtx)
 }
+
+// Export mocks base method.
+func (m *MockFlagService) Export(ctx context.Context) (flags.Archive, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", ctx)
+	ret0, _ := ret[0].(flags.Archive)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockFlagServiceMockRecorder) Export(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockFlagService)(nil).Export), ctx)
+}
+
+// Get mocks base method.
+func (m *MockFlagService) Get(ctx context.Context, key flags.FlagKey) (flags.Flag, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, key)
+	ret0, _ := ret[0].(flags.Flag)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockFlagServiceMockRecorder) Get(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockFlagService)(nil).Get), ctx, key)
+}
+
+// Import mocks base method.
+func (m *MockFlagService) Import(ctx context.Context, archive flags.Archive, mode flags.ImportMode, dryRun bool) ([]flags.ImportChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", ctx, archive, mode, dryRun)
+	ret0, _ := ret[0].([]flags.ImportChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Import indicates an expected call of Import.
+func (mr *MockFlagServiceMockRecorder) Import(ctx, archive, mode, dryRun any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockFlagService)(nil).Import), ctx, archive, mode, dryRun)
+}
+
+// List mocks base method.
+func (m *MockFlagService) List(ctx context.Context, filter flags.FlagFilter) ([]flags.Flag, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", ctx, filter)
+	ret0, _ := ret[0].([]flags.Flag)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockFlagServiceMockRecorder) List(ctx, filter any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockFlagService)(nil).List), ctx, filter)
+}
+
+// Reject mocks base method.
+func (m *MockFlagService) Reject(ctx context.Context, id, comment string) (flags.ChangeRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reject", ctx, id, comment)
+	ret0, _ := ret[0].(flags.ChangeRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reject indicates an expected call of Reject.
+func (mr *MockFlagServiceMockRecorder) Reject(ctx, id, comment any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reject", reflect.TypeOf((*MockFlagService)(nil).Reject), ctx, id, comment)
+}
+
+// Rollback mocks base method.
+func (m *MockFlagService) Rollback(ctx context.Context, key flags.FlagKey, version int) (flags.Flag, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rollback", ctx, key, version)
+	ret0, _ := ret[0].(flags.Flag)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Rollback indicates an expected call of Rollback.
+func (mr *MockFlagServiceMockRecorder) Rollback(ctx, key, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rollback", reflect.TypeOf((*MockFlagService)(nil).Rollback), ctx, key, version)
+}
+
+// Update mocks base method.
+func (m *MockFlagService) Update(ctx context.Context, flag flags.Flag) (flags.Flag, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", ctx, flag)
+	ret0, _ := ret[0].(flags.Flag)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Update indicates an expected call of Update.
+func (mr *MockFlagServiceMockRecorder) Update(ctx, flag any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockFlagService)(nil).Update), ctx, flag)
+}
+
+// Version mocks base method.
+func (m *MockFlagService) Version(ctx context.Context, key flags.FlagKey, version int) (flags.Flag, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Version", ctx, key, version)
+	ret0, _ := ret[0].(flags.Flag)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Version indicates an expected call of Version.
+func (mr *MockFlagServiceMockRecorder) Version(ctx, key, version any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Version", reflect.TypeOf((*MockFlagService)(nil).Version), ctx, key, version)
+}
+
+// Versions mocks base method.
+func (m *MockFlagService) Versions(ctx context.Context, key flags.FlagKey) ([]flags.Flag, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Versions", ctx, key)
+	ret0, _ := ret[0].([]flags.Flag)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Versions indicates an expected call of Versions.
+func (mr *MockFlagServiceMockRecorder) Versions(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Versions", reflect.TypeOf((*MockFlagService)(nil).Versions), ctx, key)
+}
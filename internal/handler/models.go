@@ -9,29 +9,57 @@ import (
 // Request/Response models for Create Flag
 
 type CreateFlagRequest struct {
-	Body CreateFlagBody
+	Emergency bool `doc:"Skip approval; audited" query:"emergency"`
+	Body      CreateFlagBody
 }
 
 type CreateFlagBody struct {
-	Key          string     `json:"key"                maxLength:"128" minLength:"1" pattern:"^[a-z][a-z0-9-]*$"`
-	Type         string     `enum:"bool,string,number" json:"type"`
-	Enabled      bool       `json:"enabled"`
-	DefaultValue ValueBody  `json:"defaultValue"`
-	Rules        []RuleBody `json:"rules,omitempty"`
+	Key string `json:"key" maxLength:"128" minLength:"1" pattern:"^[a-z][a-z0-9-]*$"`
+	MetadataBody
+	Type         string         `enum:"bool,string,number"  json:"type"`
+	Enabled      bool           `json:"enabled"`
+	Protected    bool           `json:"protected,omitempty"`
+	DefaultValue ValueBody      `json:"defaultValue"`
+	Rules        []RuleBody     `json:"rules,omitempty"`
+	Tests        []FlagTestBody `json:"tests,omitempty"     maxItems:"100"`
+}
+
+// MetadataBody describes a flag to the people working with it.
+type MetadataBody struct {
+	Description string    `json:"description,omitempty"                         maxLength:"1024"`
+	Owner       string    `doc:"Team to ask about the flag"                     json:"owner,omitempty"    maxLength:"128"`
+	Tags        []string  `json:"tags,omitempty"                                maxItems:"20"`
+	Kind        string    `enum:"release,experiment,ops,permission,kill-switch" json:"kind,omitempty"`
+	ExpiresAt   time.Time `doc:"When the flag is expected to be removed"        json:"expiresAt,omitzero"`
 }
 
 type RuleBody struct {
-	ID         string          `json:"id"         maxLength:"64" minLength:"1"`
-	Conditions []ConditionBody `json:"conditions" minItems:"1"`
+	ID         string          `json:"id"                maxLength:"64" minLength:"1"`
+	Conditions []ConditionBody `json:"conditions"        minItems:"1"`
 	Value      ValueBody       `json:"value"`
+	Rollout    *RolloutBody    `json:"rollout,omitempty"`
+}
+
+type RolloutBody struct {
+	Percentage float64 `json:"percentage"                                     maximum:"100"             minimum:"0"`
+	BucketBy   string  `doc:"Attribute hashed into buckets (default user_id)" json:"bucketBy,omitempty" maxLength:"64"`
 }
 
 type ConditionBody struct {
-	Attr  string `json:"attr"                                maxLength:"64" minLength:"1"`
-	Op    string `enum:"eq,neq,in,not_in,exists,starts_with" json:"op"`
+	Attr  string `json:"attr"                                                            maxLength:"64" minLength:"1"`
+	Op    string `enum:"eq,neq,in,not_in,exists,starts_with,before,after,between,window" json:"op"`
 	Value any    `json:"value"`
 }
 
+// FlagTestBody pins the value a flag returns for a context; changes that
+// break it are rejected.
+type FlagTestBody struct {
+	Name    string           `json:"name"                          maxLength:"128"         minLength:"1"`
+	Context EvaluateFlagBody `json:"context"`
+	Expect  ValueBody        `json:"expect"`
+	RuleID  string           `doc:"Rule that must serve the value" json:"ruleId,omitempty" maxLength:"64"`
+}
+
 type ValueBody struct {
 	Kind   string   `enum:"bool,string,number" json:"kind"`
 	Bool   *bool    `json:"bool,omitempty"`
@@ -56,9 +84,10 @@ type EvaluateFlagRequest struct {
 }
 
 type EvaluateFlagBody struct {
-	TenantID string         `json:"tenantId,omitempty" maxLength:"128"`
-	UserID   string         `json:"userId,omitempty"   maxLength:"128"`
+	TenantID string         `json:"tenantId,omitempty"      maxLength:"128"`
+	UserID   string         `json:"userId,omitempty"        maxLength:"128"`
 	Attrs    map[string]any `json:"attrs,omitempty"`
+	At       time.Time      `doc:"Evaluate as of this time" json:"at,omitzero"`
 }
 
 type EvaluateFlagResponse struct {
@@ -72,3 +101,517 @@ type EvalResultBody struct {
 	RuleID      string        `json:"ruleId,omitempty"`
 	EvaluatedAt time.Time     `json:"evaluatedAt"`
 }
+
+// Shared flag representation for read endpoints
+
+type FlagBody struct {
+	Key flags.FlagKey `json:"key"`
+	MetadataBody
+	Type         string         `json:"type"`
+	Enabled      bool           `json:"enabled"`
+	Protected    bool           `json:"protected,omitempty"`
+	DefaultValue ValueBody      `json:"defaultValue"`
+	Rules        []RuleBody     `json:"rules,omitempty"`
+	Version      int            `json:"version"`
+	UpdatedAt    time.Time      `json:"updatedAt"`
+	ManagedBy    string         `doc:"Set when the flag is managed outside the API (read-only)" json:"managedBy,omitempty"`
+	Tests        []FlagTestBody `json:"tests,omitempty"`
+}
+
+type FlagResponse struct {
+	Body FlagBody
+}
+
+type ListFlagsRequest struct {
+	Tag   string `doc:"Only flags with this tag"                       query:"tag"`
+	Owner string `doc:"Only flags owned by this team"                  query:"owner"`
+	Kind  string `enum:"release,experiment,ops,permission,kill-switch" query:"kind"`
+}
+
+type ListFlagsResponse struct {
+	Body ListFlagsResponseBody
+}
+
+type ListFlagsResponseBody struct {
+	Flags []FlagBody `json:"flags"`
+}
+
+// Request/Response models for Get, Update and Delete Flag
+
+type GetFlagRequest struct {
+	Key string `maxLength:"128" minLength:"1" path:"key" pattern:"^[a-z][a-z0-9-]*$"`
+}
+
+type UpdateFlagRequest struct {
+	Key       string `maxLength:"128"              minLength:"1"     path:"key" pattern:"^[a-z][a-z0-9-]*$"`
+	Emergency bool   `doc:"Skip approval; audited" query:"emergency"`
+	Body      UpdateFlagBody
+}
+
+type UpdateFlagBody struct {
+	MetadataBody
+	Type         string         `enum:"bool,string,number"                     json:"type"`
+	Enabled      bool           `json:"enabled"`
+	Protected    bool           `json:"protected,omitempty"`
+	DefaultValue ValueBody      `json:"defaultValue"`
+	Rules        []RuleBody     `json:"rules,omitempty"`
+	Tests        []FlagTestBody `json:"tests,omitempty"                        maxItems:"100"`
+	Version      int            `doc:"Expected current version (409 if stale)" json:"version,omitempty" minimum:"0"`
+}
+
+type DeleteFlagRequest struct {
+	Key       string `maxLength:"128"              minLength:"1"     path:"key" pattern:"^[a-z][a-z0-9-]*$"`
+	Emergency bool   `doc:"Skip approval; audited" query:"emergency"`
+}
+
+// Request/Response models for Flag Versions
+
+type ListVersionsRequest struct {
+	Key string `maxLength:"128" minLength:"1" path:"key" pattern:"^[a-z][a-z0-9-]*$"`
+}
+
+type ListVersionsResponse struct {
+	Body ListVersionsResponseBody
+}
+
+type ListVersionsResponseBody struct {
+	Versions []FlagBody `json:"versions"`
+}
+
+type GetVersionRequest struct {
+	Key     string `maxLength:"128" minLength:"1"  path:"key" pattern:"^[a-z][a-z0-9-]*$"`
+	Version int    `minimum:"1"     path:"version"`
+}
+
+type DiffVersionsRequest struct {
+	Key  string `maxLength:"128" minLength:"1" path:"key"      pattern:"^[a-z][a-z0-9-]*$"`
+	From int    `minimum:"1"     query:"from"  required:"true"`
+	To   int    `minimum:"1"     query:"to"    required:"true"`
+}
+
+type DiffVersionsResponse struct {
+	Body DiffVersionsResponseBody
+}
+
+type DiffVersionsResponseBody struct {
+	From    int               `json:"from"`
+	To      int               `json:"to"`
+	Changes []FieldChangeBody `json:"changes"`
+}
+
+type RollbackFlagRequest struct {
+	Key       string `maxLength:"128"              minLength:"1"     path:"key"      pattern:"^[a-z][a-z0-9-]*$"`
+	To        int    `minimum:"1"                  query:"to"        required:"true"`
+	Emergency bool   `doc:"Skip approval; audited" query:"emergency"`
+}
+
+// Request/Response models for Audit Log
+
+type ListAuditRequest struct {
+	FlagKey string    `maxLength:"128"                        query:"flagKey"`
+	Actor   string    `maxLength:"128"                        query:"actor"`
+	Since   time.Time `doc:"Inclusive lower bound (RFC 3339)" query:"since"`
+	Until   time.Time `doc:"Exclusive upper bound (RFC 3339)" query:"until"`
+	Cursor  string    `maxLength:"64"                         query:"cursor"`
+	Limit   int       `default:"50"                           maximum:"500"   minimum:"1" query:"limit"`
+}
+
+type ListAuditResponse struct {
+	Body ListAuditResponseBody
+}
+
+type ListAuditResponseBody struct {
+	Entries    []AuditEntryBody `json:"entries"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+type AuditEntryBody struct {
+	Seq        uint64            `json:"seq"`
+	Time       time.Time         `json:"time"`
+	Actor      string            `json:"actor"`
+	Action     string            `json:"action"`
+	FlagKey    flags.FlagKey     `json:"flagKey"`
+	Before     *FlagBody         `json:"before,omitempty"`
+	After      *FlagBody         `json:"after,omitempty"`
+	Diff       []FieldChangeBody `json:"diff"`
+	RequestID  string            `json:"requestId,omitempty"`
+	ChangeID   string            `doc:"Change request the entry applied" json:"changeId,omitempty"`
+	Emergency  bool              `json:"emergency,omitempty"`
+	ScheduleID string            `doc:"Scheduled change the entry ran"   json:"scheduleId,omitempty"`
+	RampID     string            `doc:"Rollout ramp the entry stepped"   json:"rampId,omitempty"`
+}
+
+type FieldChangeBody struct {
+	Path   string `json:"path"`
+	Kind   string `enum:"added,removed,modified" json:"kind"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// Request/Response models for Export and Import
+
+type ArchiveBody struct {
+	FormatVersion int              `doc:"Archive format version" json:"formatVersion" minimum:"1"`
+	ExportedAt    time.Time        `json:"exportedAt,omitzero"`
+	Flags         []CreateFlagBody `json:"flags"`
+}
+
+type ExportResponse struct {
+	Body ArchiveBody
+}
+
+type ImportRequest struct {
+	Mode   string `default:"create-only"                          enum:"create-only,merge,overwrite" query:"mode"`
+	DryRun bool   `doc:"Report the changes without applying them" query:"dryRun"`
+	Body   ArchiveBody
+}
+
+type ImportResponse struct {
+	Body ImportResponseBody
+}
+
+type ImportResponseBody struct {
+	DryRun  bool               `json:"dryRun"`
+	Changes []ImportChangeBody `json:"changes"`
+}
+
+type ImportChangeBody struct {
+	Action  string            `enum:"create,update,delete,skip" json:"action"`
+	Key     flags.FlagKey     `json:"key"`
+	Reason  string            `json:"reason,omitempty"`
+	Changes []FieldChangeBody `json:"changes"`
+}
+
+// Request/Response models for Change Requests
+
+type ListChangesRequest struct {
+	FlagKey string `maxLength:"128"                          query:"flagKey"`
+	Status  string `enum:"pending,approved,rejected,applied" query:"status"`
+}
+
+type ListChangesResponse struct {
+	Body ListChangesResponseBody
+}
+
+type ListChangesResponseBody struct {
+	Changes []ChangeRequestBody `json:"changes"`
+}
+
+type ChangeIDRequest struct {
+	ID string `maxLength:"64" minLength:"1" path:"id"`
+}
+
+type ReviewChangeRequest struct {
+	ID   string `maxLength:"64" minLength:"1" path:"id"`
+	Body ReviewBody
+}
+
+type ReviewBody struct {
+	Comment string `json:"comment,omitempty" maxLength:"2000"`
+}
+
+type CommentChangeRequest struct {
+	ID   string `maxLength:"64" minLength:"1" path:"id"`
+	Body CommentBody
+}
+
+type CommentBody struct {
+	Text string `json:"text" maxLength:"2000" minLength:"1"`
+}
+
+type ChangeResponse struct {
+	Body ChangeRequestBody
+}
+
+type ChangeRequestBody struct {
+	ID                string              `json:"id"`
+	FlagKey           flags.FlagKey       `json:"flagKey"`
+	Action            string              `enum:"create,update,rollback,delete"         json:"action"`
+	Status            string              `enum:"pending,approved,rejected,applied"     json:"status"`
+	Base              *FlagBody           `doc:"The flag when the change was requested" json:"base,omitempty"`
+	Proposed          *FlagBody           `json:"proposed,omitempty"`
+	Diff              []FieldChangeBody   `json:"diff"`
+	Requester         string              `json:"requester"`
+	RequiredApprovals int                 `json:"requiredApprovals"`
+	Approvers         []string            `json:"approvers"`
+	Comments          []ChangeCommentBody `json:"comments"`
+	CreatedAt         time.Time           `json:"createdAt"`
+	UpdatedAt         time.Time           `json:"updatedAt"`
+	ClosedBy          string              `doc:"Who rejected or applied the change"     json:"closedBy,omitempty"`
+	ClosedAt          time.Time           `json:"closedAt,omitzero"`
+	AppliedVersion    int                 `json:"appliedVersion,omitempty"`
+}
+
+type ChangeCommentBody struct {
+	Author string    `json:"author"`
+	Text   string    `json:"text"`
+	Time   time.Time `json:"time"`
+}
+
+// Request/Response models for Scheduled Changes
+
+type ScheduleFlagRequest struct {
+	Key  string `maxLength:"128" minLength:"1" path:"key" pattern:"^[a-z][a-z0-9-]*$"`
+	Body ScheduleFlagBody
+}
+
+type ScheduleFlagBody struct {
+	Action     string     `enum:"enable,disable,set-rules,set-rollout" json:"action"`
+	At         time.Time  `doc:"When to make the change"               json:"at"`
+	Rules      []RuleBody `doc:"Replacement rules for set-rules"       json:"rules,omitempty"`
+	RuleID     string     `json:"ruleId,omitempty"                     maxLength:"128"`
+	Percentage float64    `json:"percentage,omitempty"                 maximum:"100"          minimum:"0"`
+}
+
+type ListSchedulesRequest struct {
+	FlagKey string `maxLength:"128"                        query:"flagKey"`
+	Status  string `enum:"pending,applied,failed,canceled" query:"status"`
+}
+
+type ListSchedulesResponse struct {
+	Body ListSchedulesResponseBody
+}
+
+type ListSchedulesResponseBody struct {
+	Schedules []ScheduleBody `json:"schedules"`
+}
+
+type ScheduleIDRequest struct {
+	ID string `maxLength:"64" minLength:"1" path:"id"`
+}
+
+type ScheduleResponse struct {
+	Body ScheduleBody
+}
+
+type ScheduleBody struct {
+	ID             string        `json:"id"`
+	FlagKey        flags.FlagKey `json:"flagKey"`
+	Action         string        `enum:"enable,disable,set-rules,set-rollout"      json:"action"`
+	Rules          []RuleBody    `json:"rules,omitempty"`
+	RuleID         string        `json:"ruleId,omitempty"`
+	Percentage     float64       `json:"percentage,omitempty"`
+	At             time.Time     `json:"at"`
+	CreatedBy      string        `json:"createdBy"`
+	CreatedAt      time.Time     `json:"createdAt"`
+	Status         string        `enum:"pending,applied,failed,canceled"           json:"status"`
+	RanAt          time.Time     `doc:"Later than at when the schedule was missed" json:"ranAt,omitzero"`
+	AppliedVersion int           `json:"appliedVersion,omitempty"`
+	Error          string        `doc:"Why the change failed"                      json:"error,omitempty"`
+	CanceledBy     string        `json:"canceledBy,omitempty"`
+	CanceledAt     time.Time     `json:"canceledAt,omitzero"`
+}
+
+// Request/Response models for API Keys
+
+type CreateKeyRequest struct {
+	Body CreateKeyBody
+}
+
+type CreateKeyBody struct {
+	Name        string `json:"name"                                 maxLength:"128"              minLength:"1"`
+	Scope       string `enum:"evaluate,read,admin"                  json:"scope"`
+	Environment string `doc:"Restrict the key to this environment"  json:"environment,omitempty" maxLength:"64"`
+	Tenant      string `doc:"Restrict evaluate keys to this tenant" json:"tenant,omitempty"      maxLength:"128"`
+}
+
+type KeyRequest struct {
+	ID string `maxLength:"64" minLength:"1" path:"id"`
+}
+
+type KeySecretResponse struct {
+	Body KeySecretResponseBody
+}
+
+type KeySecretResponseBody struct {
+	Key   KeyBody `json:"key"`
+	Token string  `doc:"Shown only once; store it now" json:"token"`
+}
+
+type ListKeysResponse struct {
+	Body ListKeysResponseBody
+}
+
+type ListKeysResponseBody struct {
+	Keys []KeyBody `json:"keys"`
+}
+
+type KeyBody struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Scope       string    `enum:"evaluate,read,admin"   json:"scope"`
+	Environment string    `json:"environment,omitempty"`
+	Tenant      string    `json:"tenant,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	RotatedAt   time.Time `json:"rotatedAt,omitzero"`
+	RevokedAt   time.Time `json:"revokedAt,omitzero"`
+}
+
+// Request/Response models for Rollout Ramps
+
+type StartRampRequest struct {
+	Key  string `maxLength:"128" minLength:"1" path:"key" pattern:"^[a-z][a-z0-9-]*$"`
+	Body StartRampBody
+}
+
+type StartRampBody struct {
+	RuleID string         `json:"ruleId" maxLength:"128" minLength:"1"`
+	Steps  []RampStepBody `json:"steps"  maxItems:"100"  minItems:"1"`
+}
+
+type RampStepBody struct {
+	Percentage float64 `exclusiveMinimum:"0"                           json:"percentage"      maximum:"100"`
+	Dwell      string  `doc:"How long to hold the step, such as 1h30m" json:"dwell,omitempty" maxLength:"32"`
+}
+
+type ListRampsRequest struct {
+	FlagKey string `maxLength:"128"                                query:"flagKey"`
+	Status  string `enum:"running,paused,completed,aborted,failed" query:"status"`
+}
+
+type ListRampsResponse struct {
+	Body ListRampsResponseBody
+}
+
+type ListRampsResponseBody struct {
+	Ramps []RampBody `json:"ramps"`
+}
+
+type RampIDRequest struct {
+	ID string `maxLength:"64" minLength:"1" path:"id"`
+}
+
+type RampResponse struct {
+	Body RampBody
+}
+
+type RampBody struct {
+	ID            string         `json:"id"`
+	FlagKey       flags.FlagKey  `json:"flagKey"`
+	RuleID        string         `json:"ruleId"`
+	Steps         []RampStepBody `json:"steps"`
+	Step          int            `doc:"Index of the step in effect"              json:"step"`
+	Percentage    float64        `json:"percentage"`
+	Status        string         `enum:"running,paused,completed,aborted,failed" json:"status"`
+	CreatedBy     string         `json:"createdBy"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	StepStartedAt time.Time      `json:"stepStartedAt"`
+	NextStepAt    time.Time      `json:"nextStepAt,omitzero"`
+	PausedAt      time.Time      `json:"pausedAt,omitzero"`
+	UpdatedBy     string         `json:"updatedBy,omitempty"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	Error         string         `doc:"Why the ramp failed"                      json:"error,omitempty"`
+}
+
+// Request/Response models for Simulate Flag
+
+type SimulateFlagRequest struct {
+	Key  string `maxLength:"128" minLength:"1" path:"key" pattern:"^[a-z][a-z0-9-]*$"`
+	Body SimulateFlagBody
+}
+
+type SimulateFlagBody struct {
+	Flag     CreateFlagBody     `doc:"Proposed definition; not stored" json:"flag"`
+	Contexts []EvaluateFlagBody `json:"contexts"                       maxItems:"1000" minItems:"1"`
+}
+
+type SimulateFlagResponse struct {
+	Body SimulateFlagResponseBody
+}
+
+type SimulateFlagResponseBody struct {
+	Results []SimulationBody `json:"results"`
+	Changed int              `doc:"Number of contexts whose value changes" json:"changed"`
+}
+
+type SimulationBody struct {
+	Context  EvaluateFlagBody `json:"context"`
+	Current  EvalResultBody   `json:"current"`
+	Proposed EvalResultBody   `json:"proposed"`
+	Changed  bool             `json:"changed"`
+}
+
+// Request/Response models for Run Flag Tests
+
+type RunFlagTestsRequest struct {
+	Key string `maxLength:"128" minLength:"1" path:"key" pattern:"^[a-z][a-z0-9-]*$"`
+}
+
+type RunFlagTestsResponse struct {
+	Body RunFlagTestsResponseBody
+}
+
+type RunFlagTestsResponseBody struct {
+	Results []FlagTestResultBody `json:"results"`
+	Failed  int                  `json:"failed"`
+}
+
+type FlagTestResultBody struct {
+	Name   string         `json:"name"`
+	Passed bool           `json:"passed"`
+	Expect ValueBody      `json:"expect"`
+	RuleID string         `json:"ruleId,omitempty"`
+	Result EvalResultBody `json:"result"`
+}
+
+// Request/Response models for Get Flag Usage
+
+type GetFlagUsageRequest struct {
+	Key   string    `maxLength:"128"                                 minLength:"1" path:"key" pattern:"^[a-z][a-z0-9-]*$"`
+	Since time.Time `doc:"First hour (RFC 3339); default 7 days ago" query:"since"`
+}
+
+type GetFlagUsageResponse struct {
+	Body FlagUsageBody
+}
+
+type FlagUsageBody struct {
+	Key           string               `json:"key"`
+	Since         time.Time            `doc:"Start of the first hour counted"              json:"since"`
+	LastEvaluated time.Time            `doc:"Latest evaluation in 90 days; absent if none" json:"lastEvaluated,omitzero"`
+	Evaluations   int64                `json:"evaluations"`
+	Reasons       map[string]int64     `doc:"Evaluations by reason"                        json:"reasons"`
+	Variations    []VariationCountBody `doc:"Evaluations by value served"                  json:"variations"`
+	Hours         []UsageHourBody      `doc:"Hours with evaluations, oldest first"         json:"hours"`
+}
+
+type VariationCountBody struct {
+	Value ValueBody `json:"value"`
+	Count int64     `json:"count"`
+	Share float64   `doc:"Share of the evaluations, from 0 to 1" json:"share"`
+}
+
+type UsageHourBody struct {
+	Hour        time.Time            `json:"hour"`
+	Evaluations int64                `json:"evaluations"`
+	Reasons     map[string]int64     `json:"reasons"`
+	Variations  []VariationCountBody `json:"variations"`
+}
+
+// Request/Response models for Get Stale Report
+
+type GetStaleReportRequest struct {
+	ListFlagsRequest
+
+	Days int `default:"30" doc:"Days without changes or varied evaluations" maximum:"90" minimum:"1" query:"days"`
+}
+
+type GetStaleReportResponse struct {
+	Body StaleReportBody
+}
+
+type StaleReportBody struct {
+	Since time.Time       `doc:"Start of the period checked" json:"since"`
+	Flags []StaleFlagBody `json:"flags"`
+}
+
+type StaleFlagBody struct {
+	Key           string     `json:"key"`
+	Kind          string     `enum:"expired,unused,single_value"                  json:"kind"`
+	Owner         string     `json:"owner,omitempty"`
+	ExpiresAt     time.Time  `json:"expiresAt,omitzero"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+	LastEvaluated time.Time  `doc:"Latest evaluation in 90 days; absent if none"  json:"lastEvaluated,omitzero"`
+	Evaluations   int64      `doc:"Evaluations in the period"                     json:"evaluations"`
+	Value         *ValueBody `doc:"The only value served, for single_value flags" json:"value,omitempty"`
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/features/internal/flags"
+)
+
+// RampService manages rollout ramps. *flags.Service implements it.
+type RampService interface {
+	StartRamp(ctx context.Context, ramp flags.Ramp) (flags.Ramp, error)
+	Ramps(ctx context.Context, filter flags.RampFilter) ([]flags.Ramp, error)
+	PauseRamp(ctx context.Context, id string) (flags.Ramp, error)
+	ResumeRamp(ctx context.Context, id string) (flags.Ramp, error)
+	AbortRamp(ctx context.Context, id string) (flags.Ramp, error)
+}
+
+type RampHandler struct {
+	ramps RampService
+}
+
+func NewRampHandler(ramps RampService) *RampHandler {
+	return &RampHandler{ramps: ramps}
+}
+
+func (h *RampHandler) StartRamp(ctx context.Context, req *StartRampRequest) (*RampResponse, error) {
+	ramp, err := ToRamp(req)
+	if err != nil {
+		return nil, huma.Error422UnprocessableEntity(err.Error())
+	}
+
+	ramp, err = h.ramps.StartRamp(ctx, ramp)
+	if err != nil {
+		return nil, rampError(err, "failed to start rollout ramp")
+	}
+
+	return &RampResponse{Body: ToRampBody(ramp)}, nil
+}
+
+func (h *RampHandler) ListRamps(ctx context.Context, req *ListRampsRequest) (*ListRampsResponse, error) {
+	ramps, err := h.ramps.Ramps(ctx, ToRampFilter(req))
+	if err != nil {
+		return nil, rampError(err, "failed to list rollout ramps")
+	}
+
+	return &ListRampsResponse{Body: ToListRampsResponseBody(ramps)}, nil
+}
+
+func (h *RampHandler) PauseRamp(ctx context.Context, req *RampIDRequest) (*RampResponse, error) {
+	return h.control(ctx, req.ID, h.ramps.PauseRamp, "failed to pause rollout ramp")
+}
+
+func (h *RampHandler) ResumeRamp(ctx context.Context, req *RampIDRequest) (*RampResponse, error) {
+	return h.control(ctx, req.ID, h.ramps.ResumeRamp, "failed to resume rollout ramp")
+}
+
+func (h *RampHandler) AbortRamp(ctx context.Context, req *RampIDRequest) (*RampResponse, error) {
+	return h.control(ctx, req.ID, h.ramps.AbortRamp, "failed to abort rollout ramp")
+}
+
+func (h *RampHandler) control(ctx context.Context, id string,
+	action func(context.Context, string) (flags.Ramp, error), fallback string,
+) (*RampResponse, error) {
+	ramp, err := action(ctx, id)
+	if err != nil {
+		return nil, rampError(err, fallback)
+	}
+
+	return &RampResponse{Body: ToRampBody(ramp)}, nil
+}
+
+// rampError maps the rollout ramp errors to HTTP errors and leaves the rest
+// to flagError.
+func rampError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, flags.ErrRampNotFound):
+		return huma.Error404NotFound("rollout ramp not found")
+	case errors.Is(err, flags.ErrInvalidRamp), errors.Is(err, flags.ErrRuleNotFound):
+		return huma.Error422UnprocessableEntity(err.Error())
+	case errors.Is(err, flags.ErrRampActive), errors.Is(err, flags.ErrRampState),
+		errors.Is(err, flags.ErrApprovalRequired):
+		return huma.Error409Conflict(err.Error())
+	default:
+		return flagError(err, fallback)
+	}
+}
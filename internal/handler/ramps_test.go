@@ -0,0 +1,128 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRampHandler_Lifecycle(t *testing.T) {
+	t.Parallel()
+
+	service := flags.NewService(flags.NewMemoryRepository())
+	_, err := service.Create(context.Background(), flags.Flag{
+		Key: "checkout", Type: flags.FlagBool, Enabled: true, DefaultValue: flags.BoolValue(false),
+		Rules: []flags.Rule{{ID: "everyone", Value: flags.BoolValue(true)}},
+	})
+	require.NoError(t, err)
+
+	_, api := humatest.New(t)
+	handler.NewRampHandler(service).Register(api)
+
+	resp := api.Post("/flags/checkout/ramps", handler.StartRampBody{
+		RuleID: "everyone",
+		Steps:  []handler.RampStepBody{{Percentage: 1, Dwell: "1h"}, {Percentage: 50, Dwell: "1h"}, {Percentage: 100}},
+	})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var ramp handler.RampBody
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &ramp))
+	assert.Equal(t, "running", ramp.Status)
+	assert.InDelta(t, 1, ramp.Percentage, 0)
+	assert.Equal(t, "1h0m0s", ramp.Steps[0].Dwell)
+	assert.Empty(t, ramp.Steps[2].Dwell)
+	assert.False(t, ramp.NextStepAt.IsZero())
+
+	flag, err := service.Get(context.Background(), "checkout")
+	require.NoError(t, err)
+	assert.InDelta(t, 1, flag.Rules[0].Rollout.Percentage, 0)
+
+	resp = api.Get("/ramps?flagKey=checkout&status=running")
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var list handler.ListRampsResponseBody
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &list))
+	require.Len(t, list.Ramps, 1)
+	assert.Equal(t, ramp.ID, list.Ramps[0].ID)
+
+	resp = api.Post("/flags/checkout/ramps", handler.StartRampBody{
+		RuleID: "everyone", Steps: []handler.RampStepBody{{Percentage: 100}},
+	})
+	assert.Equal(t, http.StatusConflict, resp.Code, "one active ramp per rule")
+
+	resp = api.Post("/ramps/" + ramp.ID + "/pause")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), `"status":"paused"`)
+
+	resp = api.Post("/ramps/" + ramp.ID + "/pause")
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = api.Post("/ramps/" + ramp.ID + "/resume")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), `"status":"running"`)
+
+	resp = api.Post("/ramps/" + ramp.ID + "/abort")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), `"status":"aborted"`)
+
+	resp = api.Post("/ramps/missing/abort")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = api.Post("/flags/checkout/ramps", handler.StartRampBody{
+		RuleID: "everyone", Steps: []handler.RampStepBody{{Percentage: 5, Dwell: "soon"}, {Percentage: 100}},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+
+	resp = api.Post("/flags/checkout/ramps", handler.StartRampBody{
+		RuleID: "everyone", Steps: []handler.RampStepBody{{Percentage: 50, Dwell: "1h"}, {Percentage: 10}},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+
+	resp = api.Post("/flags/checkout/ramps", handler.StartRampBody{
+		RuleID: "missing", Steps: []handler.RampStepBody{{Percentage: 100}},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+
+	resp = api.Post("/flags/missing/ramps", handler.StartRampBody{
+		RuleID: "everyone", Steps: []handler.RampStepBody{{Percentage: 100}},
+	})
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestRampHandler_Forbidden(t *testing.T) {
+	t.Parallel()
+
+	repo, ramps := flags.NewMemoryRepository(), flags.NewMemoryRampStore()
+	seed := flags.NewService(repo, flags.WithRampStore(ramps))
+	_, err := seed.Create(context.Background(), flags.Flag{
+		Key: "checkout", Type: flags.FlagBool, DefaultValue: flags.BoolValue(false),
+		Rules: []flags.Rule{{ID: "everyone", Value: flags.BoolValue(true)}},
+	})
+	require.NoError(t, err)
+
+	ramp, err := seed.StartRamp(context.Background(), flags.Ramp{
+		FlagKey: "checkout", RuleID: "everyone", Steps: []flags.RampStep{{Percentage: 100}},
+	})
+	require.NoError(t, err)
+
+	service := flags.NewService(repo, flags.WithRampStore(ramps), flags.WithAuthorizer(denyAll{}))
+
+	_, api := humatest.New(t)
+	handler.NewRampHandler(service).Register(api)
+
+	assert.Equal(t, http.StatusForbidden, api.Get("/ramps").Code)
+	assert.Equal(t, http.StatusForbidden, api.Post("/flags/checkout/ramps", handler.StartRampBody{
+		RuleID: "everyone", Steps: []handler.RampStepBody{{Percentage: 100}},
+	}).Code)
+
+	for _, action := range []string{"pause", "resume", "abort"} {
+		assert.Equal(t, http.StatusForbidden, api.Post("/ramps/"+ramp.ID+"/"+action).Code, action)
+	}
+}
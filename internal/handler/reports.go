@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+)
+
+// ReportService reports on the flags as a whole. *flags.Service implements it.
+type ReportService interface {
+	StaleFlags(ctx context.Context, age time.Duration, filter flags.FlagFilter) (flags.StaleReport, error)
+}
+
+type ReportHandler struct {
+	reports ReportService
+}
+
+func NewReportHandler(reports ReportService) *ReportHandler {
+	return &ReportHandler{reports: reports}
+}
+
+func (h *ReportHandler) GetStaleReport(
+	ctx context.Context, req *GetStaleReportRequest,
+) (*GetStaleReportResponse, error) {
+	age := time.Duration(req.Days) * 24 * time.Hour
+
+	report, err := h.reports.StaleFlags(ctx, age, ToFlagFilter(&req.ListFlagsRequest))
+	if err != nil {
+		return nil, flagError(err, "failed to report stale flags")
+	}
+
+	return &GetStaleReportResponse{Body: ToStaleReportBody(report)}, nil
+}
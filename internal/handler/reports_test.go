@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/serroba/features/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportHandler_GetStaleReport(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	clock := flagstest.NewClock(time.Date(2025, 6, 2, 8, 0, 0, 0, time.UTC))
+	service := flags.NewService(flags.NewMemoryRepository(), flags.WithClock(clock))
+
+	for _, key := range []string{"checkout", "unused"} {
+		flag := handler.ToFlag(proPlanFlag("pro"))
+		flag.Key = flags.FlagKey(key)
+		flag.Owner = "payments"
+
+		_, err := service.Create(ctx, flag)
+		require.NoError(t, err)
+	}
+
+	clock.Advance(10 * 24 * time.Hour)
+
+	_, err := service.Evaluate(ctx, "checkout", flags.EvalContext{Attrs: map[string]any{"plan": "pro"}})
+	require.NoError(t, err)
+
+	_, api := humatest.New(t)
+	handler.NewReportHandler(service).Register(api)
+
+	resp := api.Get("/reports/stale?days=5")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var body handler.StaleReportBody
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, clock.Now().Add(-5*24*time.Hour), body.Since)
+	require.Len(t, body.Flags, 2)
+
+	assert.Equal(t, "checkout", body.Flags[0].Key)
+	assert.Equal(t, "single_value", body.Flags[0].Kind)
+	assert.Equal(t, int64(1), body.Flags[0].Evaluations)
+	require.NotNil(t, body.Flags[0].Value)
+	assert.True(t, *body.Flags[0].Value.Bool)
+
+	assert.Equal(t, "unused", body.Flags[1].Key)
+	assert.Equal(t, "unused", body.Flags[1].Kind)
+	assert.Nil(t, body.Flags[1].Value)
+	assert.True(t, body.Flags[1].LastEvaluated.IsZero())
+
+	assert.Equal(t, "payments", body.Flags[1].Owner)
+
+	resp = api.Get("/reports/stale")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Empty(t, body.Flags, "the flags changed within the default 30 days")
+
+	expired := handler.ToFlag(proPlanFlag("pro"))
+	expired.Key = "tmp-fix-2"
+	expired.Owner = "search"
+	expired.ExpiresAt = clock.Now()
+
+	_, err = service.Create(ctx, expired)
+	require.NoError(t, err)
+
+	resp = api.Get("/reports/stale?owner=search")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	require.Len(t, body.Flags, 1)
+	assert.Equal(t, "tmp-fix-2", body.Flags[0].Key)
+	assert.Equal(t, "expired", body.Flags[0].Kind)
+	assert.Equal(t, "search", body.Flags[0].Owner)
+	assert.Equal(t, clock.Now(), body.Flags[0].ExpiresAt)
+
+	resp = api.Get("/reports/stale?days=91")
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+}
+
+func TestReportHandler_Forbidden(t *testing.T) {
+	t.Parallel()
+
+	service := flags.NewService(flags.NewMemoryRepository(), flags.WithAuthorizer(denyAll{}))
+
+	_, api := humatest.New(t)
+	handler.NewReportHandler(service).Register(api)
+
+	resp := api.Get("/reports/stale")
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+}
@@ -2,24 +2,400 @@ package handler
 
 import (
 	"net/http"
+	"reflect"
 
 	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/features/internal/auth"
 )
 
+// OpenAPI tags grouping the operations.
+const (
+	tagFlags     = "Flags"
+	tagVersions  = "Versions"
+	tagKeys      = "Keys"
+	tagChanges   = "Changes"
+	tagSchedules = "Schedules"
+	tagRamps     = "Ramps"
+)
+
+// flagPath is the path of a single flag.
+const flagPath = "/flags/{key}"
+
 func (h *Handler) Register(api huma.API) {
+	h.registerFlags(api)
+	h.registerVersions(api)
+	h.registerAudit(api)
+	h.registerArchive(api)
+	h.registerChanges(api)
+}
+
+// pendingResponse documents the 202 Accepted of mutations that open a change
+// request instead of changing a protected flag.
+func pendingResponse(api huma.API) map[string]*huma.Response {
+	schema := api.OpenAPI().Components.Schemas.Schema(reflect.TypeFor[ChangeRequestBody](), true, "")
+
+	return map[string]*huma.Response{
+		"202": {
+			Description: "The flag is protected; a change request awaits approval",
+			Content:     map[string]*huma.MediaType{"application/json": {Schema: schema}},
+		},
+	}
+}
+
+func (h *Handler) registerFlags(api huma.API) {
 	huma.Register(api, huma.Operation{
 		OperationID: "create-flag",
 		Method:      http.MethodPost,
 		Path:        "/flags",
 		Summary:     "Create a new feature flag",
-		Tags:        []string{"Flags"},
+		Tags:        []string{tagFlags},
+		Responses:   pendingResponse(api),
+		Security:    requires(auth.ScopeAdmin),
 	}, h.CreateFlag)
 
+	huma.Register(api, huma.Operation{
+		OperationID: "list-flags",
+		Method:      http.MethodGet,
+		Path:        "/flags",
+		Summary:     "List feature flags",
+		Description: "Filters by tag, owner and kind combine; a flag must match all of them.",
+		Tags:        []string{tagFlags},
+		Security:    requires(auth.ScopeRead),
+	}, h.ListFlags)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-flag",
+		Method:      http.MethodGet,
+		Path:        flagPath,
+		Summary:     "Get a feature flag",
+		Tags:        []string{tagFlags},
+		Security:    requires(auth.ScopeRead),
+	}, h.GetFlag)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "update-flag",
+		Method:      http.MethodPut,
+		Path:        flagPath,
+		Summary:     "Update a feature flag",
+		Tags:        []string{tagFlags},
+		Responses:   pendingResponse(api),
+		Security:    requires(auth.ScopeAdmin),
+	}, h.UpdateFlag)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "delete-flag",
+		Method:      http.MethodDelete,
+		Path:        flagPath,
+		Summary:     "Delete a feature flag and its revisions",
+		Tags:        []string{tagFlags},
+		Responses:   pendingResponse(api),
+		Security:    requires(auth.ScopeAdmin),
+	}, h.DeleteFlag)
+
 	huma.Register(api, huma.Operation{
 		OperationID: "evaluate-flag",
 		Method:      http.MethodPost,
 		Path:        "/flags/{key}/evaluate",
 		Summary:     "Evaluate a feature flag",
-		Tags:        []string{"Flags"},
+		Tags:        []string{tagFlags},
+		Security:    requires(auth.ScopeEvaluate),
 	}, h.EvaluateFlag)
 }
+
+func (h *Handler) registerVersions(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "list-flag-versions",
+		Method:      http.MethodGet,
+		Path:        "/flags/{key}/versions",
+		Summary:     "List every revision of a flag",
+		Tags:        []string{tagVersions},
+		Security:    requires(auth.ScopeRead),
+	}, h.ListVersions)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-flag-version",
+		Method:      http.MethodGet,
+		Path:        "/flags/{key}/versions/{version}",
+		Summary:     "Get a single revision of a flag",
+		Tags:        []string{tagVersions},
+		Security:    requires(auth.ScopeRead),
+	}, h.GetVersion)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "diff-flag-versions",
+		Method:      http.MethodGet,
+		Path:        "/flags/{key}/diff",
+		Summary:     "Diff two revisions of a flag",
+		Tags:        []string{tagVersions},
+		Security:    requires(auth.ScopeRead),
+	}, h.DiffVersions)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "rollback-flag",
+		Method:      http.MethodPost,
+		Path:        "/flags/{key}/rollback",
+		Summary:     "Restore a previous revision as a new revision",
+		Tags:        []string{tagVersions},
+		Responses:   pendingResponse(api),
+		Security:    requires(auth.ScopeAdmin),
+	}, h.RollbackFlag)
+}
+
+func (h *Handler) registerAudit(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "list-audit",
+		Method:      http.MethodGet,
+		Path:        "/audit",
+		Summary:     "List audit log entries",
+		Tags:        []string{"Audit"},
+		Security:    requires(auth.ScopeRead),
+	}, h.ListAudit)
+}
+
+func (h *Handler) registerArchive(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "export-flags",
+		Method:      http.MethodGet,
+		Path:        "/export",
+		Summary:     "Export every flag as a versioned archive",
+		Tags:        []string{"Archive"},
+		Security:    requires(auth.ScopeRead),
+	}, h.Export)
+
+	huma.Register(api, huma.Operation{
+		OperationID:  "import-flags",
+		Method:       http.MethodPost,
+		Path:         "/import",
+		Summary:      "Import an archive, all or nothing",
+		Tags:         []string{"Archive"},
+		MaxBodyBytes: 64 << 20,
+		Security:     requires(auth.ScopeAdmin),
+	}, h.Import)
+}
+
+func (h *Handler) registerChanges(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "list-changes",
+		Method:      http.MethodGet,
+		Path:        "/changes",
+		Summary:     "List change requests, oldest first",
+		Tags:        []string{tagChanges},
+		Security:    requires(auth.ScopeRead),
+	}, h.ListChanges)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "get-change",
+		Method:      http.MethodGet,
+		Path:        "/changes/{id}",
+		Summary:     "Get a change request",
+		Tags:        []string{tagChanges},
+		Security:    requires(auth.ScopeRead),
+	}, h.GetChange)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "approve-change",
+		Method:      http.MethodPost,
+		Path:        "/changes/{id}/approve",
+		Summary:     "Approve a change request",
+		Description: "Requesters cannot approve their own changes.",
+		Tags:        []string{tagChanges},
+		Security:    requires(auth.ScopeAdmin),
+	}, h.ApproveChange)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "reject-change",
+		Method:      http.MethodPost,
+		Path:        "/changes/{id}/reject",
+		Summary:     "Reject or withdraw a change request",
+		Tags:        []string{tagChanges},
+		Security:    requires(auth.ScopeAdmin),
+	}, h.RejectChange)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "comment-change",
+		Method:      http.MethodPost,
+		Path:        "/changes/{id}/comments",
+		Summary:     "Comment on a change request",
+		Tags:        []string{tagChanges},
+		Security:    requires(auth.ScopeAdmin),
+	}, h.CommentChange)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "apply-change",
+		Method:      http.MethodPost,
+		Path:        "/changes/{id}/apply",
+		Summary:     "Apply an approved change request",
+		Description: "The change is rebased on the current flag; 409 if the fields it changes were changed since.",
+		Tags:        []string{tagChanges},
+		Security:    requires(auth.ScopeAdmin),
+	}, h.ApplyChange)
+}
+
+func (h *KeyHandler) Register(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "create-key",
+		Method:      http.MethodPost,
+		Path:        "/keys",
+		Summary:     "Create an API key",
+		Description: "The response holds the key's token, which is not stored and cannot be shown again.",
+		Tags:        []string{tagKeys},
+		Security:    requires(auth.ScopeAdmin),
+	}, h.CreateKey)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-keys",
+		Method:      http.MethodGet,
+		Path:        "/keys",
+		Summary:     "List API keys, revoked ones included",
+		Tags:        []string{tagKeys},
+		Security:    requires(auth.ScopeAdmin),
+	}, h.ListKeys)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "rotate-key",
+		Method:      http.MethodPost,
+		Path:        "/keys/{id}/rotate",
+		Summary:     "Replace an API key's secret",
+		Tags:        []string{tagKeys},
+		Security:    requires(auth.ScopeAdmin),
+	}, h.RotateKey)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "revoke-key",
+		Method:      http.MethodDelete,
+		Path:        "/keys/{id}",
+		Summary:     "Revoke an API key",
+		Tags:        []string{tagKeys},
+		Security:    requires(auth.ScopeAdmin),
+	}, h.RevokeKey)
+}
+
+func (h *ScheduleHandler) Register(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "schedule-flag",
+		Method:      http.MethodPost,
+		Path:        "/flags/{key}/schedules",
+		Summary:     "Schedule a change to a flag",
+		Description: "The change is made at the given time by the server's scheduler and recorded in the " +
+			"audit log with the caller as the actor.",
+		Tags:     []string{tagSchedules},
+		Security: requires(auth.ScopeAdmin),
+	}, h.ScheduleFlag)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-schedules",
+		Method:      http.MethodGet,
+		Path:        "/schedules",
+		Summary:     "List scheduled changes in the order they run",
+		Tags:        []string{tagSchedules},
+		Security:    requires(auth.ScopeRead),
+	}, h.ListSchedules)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "cancel-schedule",
+		Method:      http.MethodDelete,
+		Path:        "/schedules/{id}",
+		Summary:     "Cancel a pending scheduled change",
+		Tags:        []string{tagSchedules},
+		Security:    requires(auth.ScopeAdmin),
+	}, h.CancelSchedule)
+}
+
+func (h *RampHandler) Register(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "start-ramp",
+		Method:      http.MethodPost,
+		Path:        "/flags/{key}/ramps",
+		Summary:     "Roll a rule out in steps",
+		Description: "The rule is rolled out to the first step at once. The server's scheduler moves it to each " +
+			"next step once the dwell has passed, recording every step in the flag's history.",
+		Tags:     []string{tagRamps},
+		Security: requires(auth.ScopeAdmin),
+	}, h.StartRamp)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "list-ramps",
+		Method:      http.MethodGet,
+		Path:        "/ramps",
+		Summary:     "List rollout ramps, oldest first",
+		Tags:        []string{tagRamps},
+		Security:    requires(auth.ScopeRead),
+	}, h.ListRamps)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "pause-ramp",
+		Method:      http.MethodPost,
+		Path:        "/ramps/{id}/pause",
+		Summary:     "Hold a rollout ramp at its current step",
+		Tags:        []string{tagRamps},
+		Security:    requires(auth.ScopeAdmin),
+	}, h.PauseRamp)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "resume-ramp",
+		Method:      http.MethodPost,
+		Path:        "/ramps/{id}/resume",
+		Summary:     "Resume a paused rollout ramp",
+		Tags:        []string{tagRamps},
+		Security:    requires(auth.ScopeAdmin),
+	}, h.ResumeRamp)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "abort-ramp",
+		Method:      http.MethodPost,
+		Path:        "/ramps/{id}/abort",
+		Summary:     "Stop a rollout ramp and roll its rule back to 0%",
+		Tags:        []string{tagRamps},
+		Security:    requires(auth.ScopeAdmin),
+	}, h.AbortRamp)
+}
+
+func (h *SimulationHandler) Register(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "simulate-flag",
+		Method:      http.MethodPost,
+		Path:        "/flags/{key}/simulate",
+		Summary:     "Compare a proposed flag with the stored one",
+		Description: "Each context is evaluated against the stored flag and against the proposed definition, " +
+			"exactly as evaluate-flag would. Nothing is stored.",
+		Tags:     []string{tagFlags},
+		Security: requires(auth.ScopeRead),
+	}, h.SimulateFlag)
+
+	huma.Register(api, huma.Operation{
+		OperationID: "run-flag-tests",
+		Method:      http.MethodPost,
+		Path:        "/flags/{key}/tests/run",
+		Summary:     "Run a flag's tests",
+		Description: "The same tests run before every change to the flag; a change that fails any of them is " +
+			"rejected with 422.",
+		Tags:     []string{tagFlags},
+		Security: requires(auth.ScopeRead),
+	}, h.RunFlagTests)
+}
+
+func (h *UsageHandler) Register(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-flag-usage",
+		Method:      http.MethodGet,
+		Path:        "/flags/{key}/usage",
+		Summary:     "Get how a flag has been evaluated",
+		Description: "Evaluations are counted per hour, by reason and by value served, and kept for 90 days. " +
+			"Counts include evaluations not yet flushed to storage.",
+		Tags:     []string{tagFlags},
+		Security: requires(auth.ScopeRead),
+	}, h.GetFlagUsage)
+}
+
+func (h *ReportHandler) Register(api huma.API) {
+	huma.Register(api, huma.Operation{
+		OperationID: "get-stale-report",
+		Method:      http.MethodGet,
+		Path:        "/reports/stale",
+		Summary:     "List flags that are likely safe to remove",
+		Description: "Lists the flags past their expected removal date, and the flags unchanged for the given " +
+			"number of days that were not evaluated in that time or served one value to every evaluation.",
+		Tags:     []string{"Reports"},
+		Security: requires(auth.ScopeRead),
+	}, h.GetStaleReport)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/features/internal/flags"
+)
+
+// ScheduleService manages scheduled changes. *flags.Service implements it.
+type ScheduleService interface {
+	Schedule(ctx context.Context, change flags.ScheduledChange) (flags.ScheduledChange, error)
+	ScheduledChanges(ctx context.Context, filter flags.ScheduleFilter) ([]flags.ScheduledChange, error)
+	CancelSchedule(ctx context.Context, id string) (flags.ScheduledChange, error)
+}
+
+type ScheduleHandler struct {
+	schedules ScheduleService
+}
+
+func NewScheduleHandler(schedules ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{schedules: schedules}
+}
+
+func (h *ScheduleHandler) ScheduleFlag(ctx context.Context, req *ScheduleFlagRequest) (*ScheduleResponse, error) {
+	change, err := h.schedules.Schedule(ctx, ToScheduledChange(req))
+	if err != nil {
+		return nil, scheduleError(err, "failed to schedule change")
+	}
+
+	return &ScheduleResponse{Body: ToScheduleBody(change)}, nil
+}
+
+func (h *ScheduleHandler) ListSchedules(
+	ctx context.Context, req *ListSchedulesRequest,
+) (*ListSchedulesResponse, error) {
+	changes, err := h.schedules.ScheduledChanges(ctx, ToScheduleFilter(req))
+	if err != nil {
+		return nil, scheduleError(err, "failed to list scheduled changes")
+	}
+
+	return &ListSchedulesResponse{Body: ToListSchedulesResponseBody(changes)}, nil
+}
+
+func (h *ScheduleHandler) CancelSchedule(ctx context.Context, req *ScheduleIDRequest) (*ScheduleResponse, error) {
+	change, err := h.schedules.CancelSchedule(ctx, req.ID)
+	if err != nil {
+		return nil, scheduleError(err, "failed to cancel scheduled change")
+	}
+
+	return &ScheduleResponse{Body: ToScheduleBody(change)}, nil
+}
+
+// scheduleError maps the scheduled change errors to HTTP errors and leaves
+// the rest to flagError.
+func scheduleError(err error, fallback string) error {
+	switch {
+	case errors.Is(err, flags.ErrScheduleNotFound):
+		return huma.Error404NotFound("scheduled change not found")
+	case errors.Is(err, flags.ErrInvalidSchedule):
+		return huma.Error422UnprocessableEntity(err.Error())
+	case errors.Is(err, flags.ErrScheduleClosed):
+		return huma.Error409Conflict(err.Error())
+	default:
+		return flagError(err, fallback)
+	}
+}
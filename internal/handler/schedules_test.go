@@ -0,0 +1,105 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleHandler_Lifecycle(t *testing.T) {
+	t.Parallel()
+
+	service := flags.NewService(flags.NewMemoryRepository())
+	_, err := service.Create(context.Background(), flags.Flag{
+		Key: "promo", Type: flags.FlagBool, DefaultValue: flags.BoolValue(false),
+	})
+	require.NoError(t, err)
+
+	_, api := humatest.New(t)
+	handler.NewScheduleHandler(service).Register(api)
+
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	on := true
+
+	resp := api.Post("/flags/promo/schedules", handler.ScheduleFlagBody{
+		Action: "set-rules",
+		At:     at,
+		Rules: []handler.RuleBody{{
+			ID:         "pro",
+			Conditions: []handler.ConditionBody{{Attr: "plan", Op: "eq", Value: "pro"}},
+			Value:      handler.ValueBody{Kind: "bool", Bool: &on},
+		}},
+	})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var scheduled handler.ScheduleBody
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &scheduled))
+	assert.Equal(t, "pending", scheduled.Status)
+	assert.True(t, at.Equal(scheduled.At))
+	require.Len(t, scheduled.Rules, 1)
+	assert.Equal(t, "pro", scheduled.Rules[0].ID)
+
+	resp = api.Get("/schedules?flagKey=promo&status=pending")
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var list handler.ListSchedulesResponseBody
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &list))
+	require.Len(t, list.Schedules, 1)
+	assert.Equal(t, scheduled.ID, list.Schedules[0].ID)
+
+	resp = api.Delete("/schedules/" + scheduled.ID)
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	assert.Contains(t, resp.Body.String(), `"status":"canceled"`)
+
+	resp = api.Delete("/schedules/" + scheduled.ID)
+	assert.Equal(t, http.StatusConflict, resp.Code)
+
+	resp = api.Delete("/schedules/missing")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = api.Post("/flags/missing/schedules", handler.ScheduleFlagBody{Action: "enable", At: at})
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = api.Post("/flags/promo/schedules", handler.ScheduleFlagBody{Action: "enable", At: time.Now().Add(-time.Hour)})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+}
+
+func TestScheduleHandler_Forbidden(t *testing.T) {
+	t.Parallel()
+
+	repo, schedules := flags.NewMemoryRepository(), flags.NewMemoryScheduleStore()
+	seed := flags.NewService(repo, flags.WithScheduleStore(schedules))
+	_, err := seed.Create(context.Background(), flags.Flag{
+		Key: "promo", Type: flags.FlagBool, DefaultValue: flags.BoolValue(false),
+	})
+	require.NoError(t, err)
+
+	change, err := seed.Schedule(context.Background(), flags.ScheduledChange{
+		FlagKey: "promo", Action: flags.ScheduleEnable, At: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	service := flags.NewService(repo, flags.WithScheduleStore(schedules), flags.WithAuthorizer(denyAll{}))
+
+	_, api := humatest.New(t)
+	handler.NewScheduleHandler(service).Register(api)
+
+	assert.Equal(t, http.StatusForbidden, api.Get("/schedules").Code)
+	assert.Equal(t, http.StatusForbidden,
+		api.Post("/flags/promo/schedules", handler.ScheduleFlagBody{Action: "enable", At: time.Now()}).Code)
+	assert.Equal(t, http.StatusForbidden, api.Delete("/schedules/"+change.ID).Code)
+}
+
+type denyAll struct{}
+
+func (denyAll) Authorize(context.Context, flags.Permission, flags.FlagKey) error {
+	return flags.ErrForbidden
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/danielgtaylor/huma/v2"
+	"github.com/serroba/features/internal/flags"
+)
+
+// SimulationService evaluates proposed flags and runs flag tests.
+// *flags.Service implements it.
+type SimulationService interface {
+	Simulate(ctx context.Context, proposed flags.Flag, contexts []flags.EvalContext) ([]flags.Simulation, error)
+	RunTests(ctx context.Context, key flags.FlagKey) ([]flags.TestResult, error)
+}
+
+type SimulationHandler struct {
+	simulations SimulationService
+}
+
+func NewSimulationHandler(simulations SimulationService) *SimulationHandler {
+	return &SimulationHandler{simulations: simulations}
+}
+
+func (h *SimulationHandler) SimulateFlag(
+	ctx context.Context, req *SimulateFlagRequest,
+) (*SimulateFlagResponse, error) {
+	if req.Body.Flag.Key != req.Key {
+		return nil, huma.Error422UnprocessableEntity("flag key must match the path")
+	}
+
+	simulations, err := h.simulations.Simulate(ctx, ToFlag(req.Body.Flag), ToEvalContexts(req.Body.Contexts))
+	if err != nil {
+		return nil, flagError(err, "failed to simulate flag")
+	}
+
+	return &SimulateFlagResponse{Body: ToSimulateFlagResponseBody(simulations)}, nil
+}
+
+func (h *SimulationHandler) RunFlagTests(
+	ctx context.Context, req *RunFlagTestsRequest,
+) (*RunFlagTestsResponse, error) {
+	results, err := h.simulations.RunTests(ctx, flags.FlagKey(req.Key))
+	if err != nil {
+		return nil, flagError(err, "failed to run flag tests")
+	}
+
+	return &RunFlagTestsResponse{Body: ToRunFlagTestsResponseBody(results)}, nil
+}
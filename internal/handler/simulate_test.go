@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func proPlanFlag(plans ...any) handler.CreateFlagBody {
+	off, on := false, true
+
+	return handler.CreateFlagBody{
+		Key:          "checkout",
+		Type:         "bool",
+		Enabled:      true,
+		DefaultValue: handler.ValueBody{Kind: "bool", Bool: &off},
+		Rules: []handler.RuleBody{{
+			ID:         "paid",
+			Conditions: []handler.ConditionBody{{Attr: "plan", Op: "in", Value: plans}},
+			Value:      handler.ValueBody{Kind: "bool", Bool: &on},
+		}},
+	}
+}
+
+func TestSimulationHandler_SimulateFlag(t *testing.T) {
+	t.Parallel()
+
+	service := flags.NewService(flags.NewMemoryRepository())
+	_, err := service.Create(context.Background(), handler.ToFlag(proPlanFlag("pro")))
+	require.NoError(t, err)
+
+	_, api := humatest.New(t)
+	handler.NewSimulationHandler(service).Register(api)
+
+	resp := api.Post("/flags/checkout/simulate", handler.SimulateFlagBody{
+		Flag: proPlanFlag("pro", "team"),
+		Contexts: []handler.EvaluateFlagBody{
+			{UserID: "a", Attrs: map[string]any{"plan": "pro"}},
+			{UserID: "b", Attrs: map[string]any{"plan": "team"}},
+		},
+	})
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var body handler.SimulateFlagResponseBody
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	require.Len(t, body.Results, 2)
+	assert.Equal(t, 1, body.Changed)
+
+	assert.False(t, body.Results[0].Changed)
+	assert.Equal(t, "a", body.Results[0].Context.UserID)
+
+	changed := body.Results[1]
+	assert.True(t, changed.Changed)
+	assert.Equal(t, "default", changed.Current.Reason)
+	assert.False(t, *changed.Current.Value.Bool)
+	assert.Equal(t, "rule_match", changed.Proposed.Reason)
+	assert.Equal(t, "paid", changed.Proposed.RuleID)
+	assert.True(t, *changed.Proposed.Value.Bool)
+	assert.False(t, changed.Context.At.IsZero())
+
+	resp = api.Post("/flags/other/simulate", handler.SimulateFlagBody{
+		Flag: proPlanFlag("pro"), Contexts: []handler.EvaluateFlagBody{{}},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+
+	missing := proPlanFlag("pro")
+	missing.Key = "missing"
+
+	resp = api.Post("/flags/missing/simulate", handler.SimulateFlagBody{
+		Flag: missing, Contexts: []handler.EvaluateFlagBody{{}},
+	})
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+
+	resp = api.Post("/flags/checkout/simulate", handler.SimulateFlagBody{
+		Flag: proPlanFlag("pro"), Contexts: []handler.EvaluateFlagBody{},
+	})
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.Code, "at least one context is required")
+}
+
+func TestSimulationHandler_Forbidden(t *testing.T) {
+	t.Parallel()
+
+	service := flags.NewService(flags.NewMemoryRepository(), flags.WithAuthorizer(denyAll{}))
+
+	_, api := humatest.New(t)
+	handler.NewSimulationHandler(service).Register(api)
+
+	resp := api.Post("/flags/checkout/simulate", handler.SimulateFlagBody{
+		Flag: proPlanFlag("pro"), Contexts: []handler.EvaluateFlagBody{{}},
+	})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+}
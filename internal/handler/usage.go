@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+)
+
+// UsageService reports how flags are evaluated. *flags.Service implements it.
+type UsageService interface {
+	Usage(ctx context.Context, key flags.FlagKey, since time.Time) (flags.FlagUsage, error)
+}
+
+type UsageHandler struct {
+	usage UsageService
+}
+
+func NewUsageHandler(usage UsageService) *UsageHandler {
+	return &UsageHandler{usage: usage}
+}
+
+func (h *UsageHandler) GetFlagUsage(ctx context.Context, req *GetFlagUsageRequest) (*GetFlagUsageResponse, error) {
+	usage, err := h.usage.Usage(ctx, flags.FlagKey(req.Key), req.Since)
+	if err != nil {
+		return nil, flagError(err, "failed to get flag usage")
+	}
+
+	return &GetFlagUsageResponse{Body: ToFlagUsageBody(usage)}, nil
+}
@@ -0,0 +1,77 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/danielgtaylor/huma/v2/humatest"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageHandler_GetFlagUsage(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	service := flags.NewService(flags.NewMemoryRepository())
+	_, err := service.Create(ctx, handler.ToFlag(proPlanFlag("pro")))
+	require.NoError(t, err)
+
+	for _, plan := range []string{"pro", "free", "free", "free"} {
+		_, err := service.Evaluate(ctx, "checkout", flags.EvalContext{Attrs: map[string]any{"plan": plan}})
+		require.NoError(t, err)
+	}
+
+	_, api := humatest.New(t)
+	handler.NewUsageHandler(service).Register(api)
+
+	resp := api.Get("/flags/checkout/usage")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+
+	var body handler.FlagUsageBody
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Equal(t, "checkout", body.Key)
+	assert.Equal(t, int64(4), body.Evaluations)
+	assert.Equal(t, map[string]int64{"rule_match": 1, "default": 3}, body.Reasons)
+	assert.False(t, body.LastEvaluated.IsZero())
+	require.Len(t, body.Hours, 1)
+	assert.Equal(t, int64(4), body.Hours[0].Evaluations)
+
+	require.Len(t, body.Variations, 2)
+
+	for _, variation := range body.Variations {
+		if *variation.Value.Bool {
+			assert.Equal(t, int64(1), variation.Count)
+			assert.InDelta(t, 0.25, variation.Share, 1e-9)
+		} else {
+			assert.Equal(t, int64(3), variation.Count)
+			assert.InDelta(t, 0.75, variation.Share, 1e-9)
+		}
+	}
+
+	resp = api.Get("/flags/checkout/usage?since=2999-01-01T00:00:00Z")
+	require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+	assert.Zero(t, body.Evaluations)
+	assert.Empty(t, body.Hours)
+	assert.False(t, body.LastEvaluated.IsZero(), "last evaluation covers all retained usage")
+
+	resp = api.Get("/flags/missing/usage")
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+}
+
+func TestUsageHandler_Forbidden(t *testing.T) {
+	t.Parallel()
+
+	service := flags.NewService(flags.NewMemoryRepository(), flags.WithAuthorizer(denyAll{}))
+
+	_, api := humatest.New(t)
+	handler.NewUsageHandler(service).Register(api)
+
+	resp := api.Get("/flags/checkout/usage")
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+}
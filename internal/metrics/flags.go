@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/serroba/features/internal/flags"
+)
+
+// OtherFlag is the flag label of evaluations of flags outside the allow-list.
+const OtherFlag = "other"
+
+// Evaluations counts evaluations by flag, reason and rule. It implements
+// flags.EvaluationRecorder.
+type Evaluations struct {
+	total   *Counter
+	allowed map[flags.FlagKey]bool
+}
+
+// NewEvaluations registers the evaluation counter. Only the flags in allowed
+// get their own series; the others are counted together under OtherFlag
+// without their rule, which keeps the number of series bounded.
+func NewEvaluations(registry *Registry, allowed []flags.FlagKey) *Evaluations {
+	e := &Evaluations{
+		total: registry.Counter("features_evaluations_total", "Flag evaluations served.",
+			"flag", "reason", "rule"),
+		allowed: make(map[flags.FlagKey]bool, len(allowed)),
+	}
+
+	for _, key := range allowed {
+		e.allowed[key] = true
+	}
+
+	return e
+}
+
+func (e *Evaluations) RecordEvaluation(_ context.Context, result flags.EvalResult) {
+	if !e.allowed[result.FlagKey] {
+		e.total.Inc(OtherFlag, string(result.Reason), "")
+
+		return
+	}
+
+	e.total.Inc(string(result.FlagKey), string(result.Reason), result.RuleID)
+}
+
+// Repository records the latency and failures of every call to the
+// repository it wraps.
+type Repository struct {
+	repo     flags.Repository
+	duration *Histogram
+	errors   *Counter
+}
+
+// NewRepository wraps repo and registers its metrics. Missing flags, taken
+// keys and version conflicts are answers rather than failures, so they are
+// not counted as errors.
+func NewRepository(registry *Registry, repo flags.Repository) *Repository {
+	return &Repository{
+		repo: repo,
+		duration: registry.Histogram("features_repository_operation_duration_seconds",
+			"Latency of flag repository operations.", DefaultBuckets, "operation"),
+		errors: registry.Counter("features_repository_errors_total",
+			"Flag repository operations that failed.", "operation"),
+	}
+}
+
+func (r *Repository) Get(ctx context.Context, key flags.FlagKey) (flags.Flag, error) {
+	defer r.observe("get", time.Now())
+
+	flag, err := r.repo.Get(ctx, key)
+
+	return flag, r.count("get", err)
+}
+
+func (r *Repository) List(ctx context.Context) ([]flags.Flag, error) {
+	defer r.observe("list", time.Now())
+
+	all, err := r.repo.List(ctx)
+
+	return all, r.count("list", err)
+}
+
+func (r *Repository) Create(ctx context.Context, flag flags.Flag) error {
+	defer r.observe("create", time.Now())
+
+	return r.count("create", r.repo.Create(ctx, flag))
+}
+
+func (r *Repository) Update(ctx context.Context, flag flags.Flag) error {
+	defer r.observe("update", time.Now())
+
+	return r.count("update", r.repo.Update(ctx, flag))
+}
+
+func (r *Repository) Delete(ctx context.Context, key flags.FlagKey) error {
+	defer r.observe("delete", time.Now())
+
+	return r.count("delete", r.repo.Delete(ctx, key))
+}
+
+func (r *Repository) observe(operation string, start time.Time) {
+	r.duration.Observe(time.Since(start).Seconds(), operation)
+}
+
+func (r *Repository) count(operation string, err error) error {
+	if err != nil && !errors.Is(err, flags.ErrFlagNotFound) && !errors.Is(err, flags.ErrFlagExists) &&
+		!errors.Is(err, flags.ErrVersionConflict) {
+		r.errors.Inc(operation)
+	}
+
+	return err
+}
+
+// RegisterFlagCount registers a gauge of the number of flags in repo, read
+// on every scrape.
+func RegisterFlagCount(registry *Registry, repo flags.Repository) {
+	registry.GaugeFunc("features_flags", "Flags stored.", func(ctx context.Context) (float64, error) {
+		all, err := repo.List(ctx)
+
+		return float64(len(all)), err
+	})
+}
@@ -0,0 +1,121 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/serroba/features/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluations(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	svc := flags.NewService(flags.NewMemoryRepository(),
+		flags.WithEvaluationRecorder(metrics.NewEvaluations(registry, []flags.FlagKey{"checkout"})))
+	ctx := context.Background()
+
+	for _, key := range []flags.FlagKey{"checkout", "banner"} {
+		_, err := svc.Create(ctx, flags.Flag{
+			Key: key, Type: flags.FlagBool, Enabled: true, DefaultValue: flags.BoolValue(false),
+			Rules: []flags.Rule{{
+				ID:         "pro",
+				Conditions: []flags.Condition{{Attr: "plan", Op: flags.OpEquals, Value: "pro"}},
+				Value:      flags.BoolValue(true),
+			}},
+		})
+		require.NoError(t, err)
+	}
+
+	for _, evalCtx := range []flags.EvalContext{{Attrs: map[string]any{"plan": "pro"}}, {}} {
+		for _, key := range []flags.FlagKey{"checkout", "banner"} {
+			_, err := svc.Evaluate(ctx, key, evalCtx)
+			require.NoError(t, err)
+		}
+	}
+
+	_, err := svc.Evaluate(ctx, "missing", flags.EvalContext{})
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	out := scrape(t, registry)
+	assert.Contains(t, out, `features_evaluations_total{flag="checkout",reason="rule_match",rule="pro"} 1`)
+	assert.Contains(t, out, `features_evaluations_total{flag="checkout",reason="default",rule=""} 1`)
+	assert.Contains(t, out, `features_evaluations_total{flag="other",reason="rule_match",rule=""} 1`)
+	assert.Contains(t, out, `features_evaluations_total{flag="other",reason="default",rule=""} 1`)
+	assert.NotContains(t, out, "banner")
+	assert.NotContains(t, out, "missing")
+}
+
+func TestRepository(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunRepositorySuite(t, func(*testing.T) flags.Repository {
+		return metrics.NewRepository(metrics.NewRegistry(), flags.NewMemoryRepository())
+	})
+}
+
+// brokenRepository fails every call.
+type brokenRepository struct {
+	flags.Repository
+}
+
+var errBroken = errors.New("disk full")
+
+func (brokenRepository) Get(context.Context, flags.FlagKey) (flags.Flag, error) {
+	return flags.Flag{}, errBroken
+}
+
+func (brokenRepository) List(context.Context) ([]flags.Flag, error) { return nil, errBroken }
+
+func TestRepository_Metrics(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	repo := metrics.NewRepository(registry, flags.NewMemoryRepository())
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, flagstest.SampleFlag("sample")))
+	require.ErrorIs(t, repo.Create(ctx, flagstest.SampleFlag("sample")), flags.ErrFlagExists)
+
+	_, err := repo.Get(ctx, "missing")
+	require.ErrorIs(t, err, flags.ErrFlagNotFound)
+
+	out := scrape(t, registry)
+	assert.Contains(t, out, `features_repository_operation_duration_seconds_count{operation="create"} 2`)
+	assert.Contains(t, out, `features_repository_operation_duration_seconds_count{operation="get"} 1`)
+	assert.NotContains(t, out, `features_repository_errors_total{`, "missing and taken keys are not failures")
+
+	brokenRegistry := metrics.NewRegistry()
+	broken := metrics.NewRepository(brokenRegistry, brokenRepository{})
+
+	_, err = broken.Get(ctx, "sample")
+	require.ErrorIs(t, err, errBroken)
+
+	_, err = broken.List(ctx)
+	require.ErrorIs(t, err, errBroken)
+
+	out = scrape(t, brokenRegistry)
+	assert.Contains(t, out, `features_repository_errors_total{operation="get"} 1`)
+	assert.Contains(t, out, `features_repository_errors_total{operation="list"} 1`)
+}
+
+func TestRegisterFlagCount(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	repo := flags.NewMemoryRepository()
+	metrics.RegisterFlagCount(registry, repo)
+
+	require.NoError(t, repo.Create(context.Background(), flagstest.SampleFlag("a")))
+	require.NoError(t, repo.Create(context.Background(), flagstest.SampleFlag("b")))
+	assert.Contains(t, scrape(t, registry), "features_flags 2\n")
+
+	broken := metrics.NewRegistry()
+	metrics.RegisterFlagCount(broken, brokenRepository{})
+	assert.NotContains(t, scrape(t, broken), "\nfeatures_flags ")
+}
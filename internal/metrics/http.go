@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+)
+
+// HTTP counts requests and their latency by API operation.
+type HTTP struct {
+	requests *Counter
+	duration *Histogram
+}
+
+func NewHTTP(registry *Registry) *HTTP {
+	return &HTTP{
+		requests: registry.Counter("features_http_requests_total", "HTTP requests by operation and status.",
+			"operation", "method", "code"),
+		duration: registry.Histogram("features_http_request_duration_seconds",
+			"Latency of HTTP requests by operation.", DefaultBuckets, "operation"),
+	}
+}
+
+// Observe records a request to the operation with the given ID.
+func (h *HTTP) Observe(operation, method string, status int, duration time.Duration) {
+	h.requests.Inc(operation, method, strconv.Itoa(status))
+	h.duration.Observe(duration.Seconds(), operation)
+}
@@ -0,0 +1,282 @@
+// Package metrics collects the server's metrics and serves them in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// labelSeparator joins label values into series keys; it cannot appear in
+// valid UTF-8.
+const labelSeparator = "\xff"
+
+// Registry holds metrics in the order they were registered. It is safe for
+// concurrent use and serves the metrics as an http.Handler.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(ctx context.Context, w *bufio.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes every metric in the text exposition format.
+func (r *Registry) Write(ctx context.Context, w io.Writer) error {
+	r.mu.Lock()
+	all := slices.Clone(r.metrics)
+	r.mu.Unlock()
+
+	buf := bufio.NewWriter(w)
+	for _, m := range all {
+		m.write(ctx, buf)
+	}
+
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("write metrics: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = r.Write(req.Context(), w)
+}
+
+// Counter is a monotonically increasing count per combination of label
+// values.
+type Counter struct {
+	desc
+
+	mu     sync.Mutex
+	series map[string]float64
+}
+
+// Counter registers a counter with the given label names.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name: name, help: help, kind: "counter", labels: labels}, series: map[string]float64{}}
+	r.register(c)
+
+	return c
+}
+
+// Inc adds one to the series with the given label values, which must match
+// the counter's labels in number and order.
+func (c *Counter) Inc(values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.series[c.key(values)]++
+}
+
+func (c *Counter) write(_ context.Context, w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w)
+
+	for _, key := range sortedKeys(c.series) {
+		c.sample(w, "", c.values(key), "", c.series[key])
+	}
+}
+
+// DefaultBuckets are the upper bounds, in seconds, of latency histograms.
+var DefaultBuckets = []float64{0.0005, 0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// Histogram counts observations in cumulative buckets per combination of
+// label values.
+type Histogram struct {
+	desc
+
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	count  uint64
+}
+
+// Histogram registers a histogram with the given bucket upper bounds, which
+// must be sorted, and label names.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		series:  map[string]*histogramSeries{},
+	}
+	r.register(h)
+
+	return h
+}
+
+// Observe records value in the series with the given label values.
+func (h *Histogram) Observe(value float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := h.key(values)
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+
+	i, _ := slices.BinarySearch(h.buckets, value)
+	s.counts[i]++
+	s.sum += value
+	s.count++
+}
+
+func (h *Histogram) write(_ context.Context, w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w)
+
+	for _, key := range sortedKeys(h.series) {
+		s, values := h.series[key], h.values(key)
+
+		var cumulative uint64
+
+		for i, count := range s.counts {
+			cumulative += count
+
+			bound := math.Inf(1)
+			if i < len(h.buckets) {
+				bound = h.buckets[i]
+			}
+
+			h.sample(w, "_bucket", values, formatFloat(bound), float64(cumulative))
+		}
+
+		h.sample(w, "_sum", values, "", s.sum)
+		h.sample(w, "_count", values, "", float64(s.count))
+	}
+}
+
+// GaugeFunc is a gauge whose value is read when the metrics are written.
+type GaugeFunc struct {
+	desc
+
+	value func(ctx context.Context) (float64, error)
+}
+
+// GaugeFunc registers a gauge read from value on every scrape. The sample is
+// left out when value fails.
+func (r *Registry) GaugeFunc(name, help string, value func(ctx context.Context) (float64, error)) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name: name, help: help, kind: "gauge"}, value: value}
+	r.register(g)
+
+	return g
+}
+
+func (g *GaugeFunc) write(ctx context.Context, w *bufio.Writer) {
+	g.header(w)
+
+	if value, err := g.value(ctx); err == nil {
+		g.sample(w, "", nil, "", value)
+	}
+}
+
+// desc is what every metric has: a name, help text, a type and label names.
+type desc struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+}
+
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+
+	return strings.Join(values, labelSeparator)
+}
+
+func (d desc) header(w *bufio.Writer) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, helpEscaper.Replace(d.help), d.name, d.kind)
+}
+
+// sample writes one line. le is the upper bound of a histogram bucket and
+// empty for other samples.
+func (d desc) sample(w *bufio.Writer, suffix string, values []string, le string, value float64) {
+	pairs := make([]string, 0, len(d.labels)+1)
+	for i, name := range d.labels {
+		pairs = append(pairs, name+`="`+labelEscaper.Replace(values[i])+`"`)
+	}
+
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+
+	_, _ = w.WriteString(d.name + suffix)
+
+	if len(pairs) > 0 {
+		_, _ = w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+
+	_, _ = w.WriteString(" " + formatFloat(value) + "\n")
+}
+
+// values is the inverse of key.
+func (d desc) values(key string) []string {
+	if len(d.labels) == 0 {
+		return nil
+	}
+
+	return strings.Split(key, labelSeparator)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	}
+}
+
+func sortedKeys[V any](series map[string]V) []string {
+	keys := make([]string, 0, len(series))
+	for key := range series {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
@@ -0,0 +1,110 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/serroba/features/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, registry *metrics.Registry) string {
+	t.Helper()
+
+	var out strings.Builder
+	require.NoError(t, registry.Write(context.Background(), &out))
+
+	return out.String()
+}
+
+func TestRegistry_Counter(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	requests := registry.Counter("requests_total", "Requests by path.\nEscaped \\ help.", "path", "code")
+
+	requests.Inc("/b", "200")
+	requests.Inc("/a", "500")
+	requests.Inc("/a", "500")
+	requests.Inc(`/"quoted"`+"\n", "200")
+
+	assert.Equal(t, `# HELP requests_total Requests by path.\nEscaped \\ help.
+# TYPE requests_total counter
+requests_total{path="/\"quoted\"\n",code="200"} 1
+requests_total{path="/a",code="500"} 2
+requests_total{path="/b",code="200"} 1
+`, scrape(t, registry))
+
+	assert.Panics(t, func() { requests.Inc("/a") }, "every label needs a value")
+}
+
+func TestRegistry_Histogram(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	latency := registry.Histogram("latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+
+	latency.Observe(0.05, "get")
+	latency.Observe(0.1, "get")
+	latency.Observe(0.5, "get")
+	latency.Observe(2, "get")
+
+	assert.Equal(t, `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="get",le="0.1"} 2
+latency_seconds_bucket{op="get",le="1"} 3
+latency_seconds_bucket{op="get",le="+Inf"} 4
+latency_seconds_sum{op="get"} 2.65
+latency_seconds_count{op="get"} 4
+`, scrape(t, registry))
+}
+
+func TestRegistry_GaugeFunc(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	registry.GaugeFunc("things", "Things.", func(context.Context) (float64, error) { return 3, nil })
+	registry.GaugeFunc("broken", "Broken.", func(context.Context) (float64, error) {
+		return 0, errors.New("unavailable")
+	})
+	registry.Counter("empty_total", "Nothing yet.")
+
+	assert.Equal(t, `# HELP things Things.
+# TYPE things gauge
+things 3
+# HELP broken Broken.
+# TYPE broken gauge
+# HELP empty_total Nothing yet.
+# TYPE empty_total counter
+`, scrape(t, registry))
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	registry.Counter("hits_total", "Hits.").Inc()
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequestWithContext(t.Context(), http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "hits_total 1\n")
+}
+
+func TestHTTP_Observe(t *testing.T) {
+	t.Parallel()
+
+	registry := metrics.NewRegistry()
+	metrics.NewHTTP(registry).Observe("get-flag", http.MethodGet, http.StatusNotFound, 0)
+
+	out := scrape(t, registry)
+	assert.Contains(t, out, `features_http_requests_total{operation="get-flag",method="GET",code="404"} 1`)
+	assert.Contains(t, out, `features_http_request_duration_seconds_count{operation="get-flag"} 1`)
+}
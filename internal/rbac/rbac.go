@@ -0,0 +1,221 @@
+// Package rbac authorizes flags.Service operations with roles bound to
+// principals, optionally per environment and per project.
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/serroba/features/internal/auth"
+	"github.com/serroba/features/internal/flags"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleEditor   Role = "editor"
+	RoleApprover Role = "approver"
+	RoleAdmin    Role = "admin"
+)
+
+var permissions = map[Role][]flags.Permission{
+	RoleViewer:   {flags.PermissionRead},
+	RoleEditor:   {flags.PermissionRead, flags.PermissionWrite},
+	RoleApprover: {flags.PermissionRead, flags.PermissionApprove},
+	RoleAdmin:    {flags.PermissionRead, flags.PermissionWrite, flags.PermissionApprove, flags.PermissionAdmin},
+}
+
+// Everyone is the principal that matches every caller.
+const Everyone = "*"
+
+// Binding grants Role to Principal in Environment, or in every environment
+// when Environment is empty, on the flags of Project, or on every flag when
+// Project is empty. Principals are actors as recorded in the audit log, for
+// example "key:deploy-bot".
+type Binding struct {
+	Principal   string `yaml:"principal"`
+	Role        Role   `yaml:"role"`
+	Environment string `yaml:"environment,omitempty"`
+	Project     string `yaml:"project,omitempty"`
+}
+
+// Project names the flags whose keys match any of Flags, which are patterns
+// in the syntax of path.Match such as "checkout-*".
+type Project struct {
+	Name  string   `yaml:"name"`
+	Flags []string `yaml:"flags"`
+}
+
+func (p Project) contains(key flags.FlagKey) bool {
+	return slices.ContainsFunc(p.Flags, func(pattern string) bool {
+		matched, _ := path.Match(pattern, string(key))
+
+		return matched
+	})
+}
+
+// Config is the content of a role bindings file.
+type Config struct {
+	Projects []Project `yaml:"projects"`
+	Bindings []Binding `yaml:"bindings"`
+}
+
+// Policy is a flags.Authorizer for the server running in one environment.
+// It denies anything no binding grants.
+type Policy struct {
+	environment string
+	bindings    []Binding
+	projects    map[string]Project
+}
+
+// NewPolicy returns the policy of environment. Bindings may only name the
+// projects given.
+func NewPolicy(environment string, bindings []Binding, projects ...Project) (*Policy, error) {
+	byName, err := indexProjects(projects)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, binding := range bindings {
+		if binding.Principal == "" {
+			return nil, fmt.Errorf("%w: binding %d has no principal", ErrInvalidPolicy, i)
+		}
+
+		if _, ok := permissions[binding.Role]; !ok {
+			return nil, fmt.Errorf("%w: binding %d has unknown role %q", ErrInvalidPolicy, i, binding.Role)
+		}
+
+		if _, ok := byName[binding.Project]; binding.Project != "" && !ok {
+			return nil, fmt.Errorf("%w: binding %d has unknown project %q", ErrInvalidPolicy, i, binding.Project)
+		}
+	}
+
+	return &Policy{environment: environment, bindings: slices.Clone(bindings), projects: byName}, nil
+}
+
+func indexProjects(projects []Project) (map[string]Project, error) {
+	byName := make(map[string]Project, len(projects))
+
+	for i, project := range projects {
+		if project.Name == "" {
+			return nil, fmt.Errorf("%w: project %d has no name", ErrInvalidPolicy, i)
+		}
+
+		if _, ok := byName[project.Name]; ok {
+			return nil, fmt.Errorf("%w: project %q is defined twice", ErrInvalidPolicy, project.Name)
+		}
+
+		for _, pattern := range project.Flags {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%w: project %q: pattern %q: %w", ErrInvalidPolicy, project.Name, pattern, err)
+			}
+		}
+
+		byName[project.Name] = Project{Name: project.Name, Flags: slices.Clone(project.Flags)}
+	}
+
+	return byName, nil
+}
+
+// LoadConfig reads a YAML or JSON file with a top-level "bindings" list and
+// an optional "projects" list.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read policy: %w", err)
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("%w: %s: %w", ErrInvalidPolicy, path, err)
+	}
+
+	return config, nil
+}
+
+// Roles returns the roles principal holds on the flag key in the policy's
+// environment. With an empty key, which stands for the flags as a whole,
+// only bindings without a project count.
+func (p *Policy) Roles(principal string, key flags.FlagKey) []Role {
+	var roles []Role
+
+	for _, binding := range p.bindings {
+		if binding.Principal != principal && binding.Principal != Everyone {
+			continue
+		}
+
+		if binding.Environment != "" && binding.Environment != p.environment {
+			continue
+		}
+
+		if binding.Project != "" && (key == "" || !p.projects[binding.Project].contains(key)) {
+			continue
+		}
+
+		if !slices.Contains(roles, binding.Role) {
+			roles = append(roles, binding.Role)
+		}
+	}
+
+	return roles
+}
+
+// claimRoles returns the roles the authenticated principal's token claims in
+// the policy's environment. A claimed role is either a role name or
+// "environment:role"; anything else is ignored.
+func (p *Policy) claimRoles(ctx context.Context) []Role {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	var roles []Role
+
+	for _, claim := range principal.Roles {
+		environment, name, scoped := strings.Cut(claim, ":")
+		if !scoped {
+			name = environment
+		} else if environment != p.environment {
+			continue
+		}
+
+		if _, ok := permissions[Role(name)]; ok {
+			roles = append(roles, Role(name))
+		}
+	}
+
+	return roles
+}
+
+// Authorize implements flags.Authorizer. The caller holds the roles bound to
+// its actor on key and those claimed by its identity provider token, which
+// apply to every flag.
+func (p *Policy) Authorize(ctx context.Context, permission flags.Permission, key flags.FlagKey) error {
+	actor := flags.ActorFromContext(ctx)
+
+	for _, role := range slices.Concat(p.Roles(actor, key), p.claimRoles(ctx)) {
+		if slices.Contains(permissions[role], permission) {
+			return nil
+		}
+	}
+
+	denied := fmt.Sprintf("%s lacks the %s permission", actor, permission)
+	if key != "" {
+		denied += " on " + string(key)
+	}
+
+	if p.environment != "" {
+		denied += " in " + p.environment
+	}
+
+	return fmt.Errorf("%w: %s", flags.ErrForbidden, denied)
+}
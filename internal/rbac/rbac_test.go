@@ -0,0 +1,237 @@
+package rbac_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/serroba/features/internal/auth"
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/rbac"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Authorize(t *testing.T) {
+	t.Parallel()
+
+	bindings := []rbac.Binding{
+		{Principal: rbac.Everyone, Role: rbac.RoleViewer},
+		{Principal: "key:alice", Role: rbac.RoleEditor},
+		{Principal: "key:bob", Role: rbac.RoleEditor, Environment: "staging"},
+		{Principal: "key:carol", Role: rbac.RoleApprover, Environment: "prod"},
+		{Principal: "key:dave", Role: rbac.RoleAdmin, Environment: "prod"},
+	}
+
+	prod, err := rbac.NewPolicy("prod", bindings)
+	require.NoError(t, err)
+
+	staging, err := rbac.NewPolicy("staging", bindings)
+	require.NoError(t, err)
+
+	tests := []struct {
+		policy     *rbac.Policy
+		actor      string
+		permission flags.Permission
+		allowed    bool
+	}{
+		{policy: prod, actor: "key:anyone", permission: flags.PermissionRead, allowed: true},
+		{policy: prod, actor: "key:anyone", permission: flags.PermissionWrite},
+		{policy: prod, actor: "key:alice", permission: flags.PermissionWrite, allowed: true},
+		{policy: prod, actor: "key:alice", permission: flags.PermissionApprove},
+		{policy: prod, actor: "key:bob", permission: flags.PermissionWrite},
+		{policy: staging, actor: "key:bob", permission: flags.PermissionWrite, allowed: true},
+		{policy: prod, actor: "key:carol", permission: flags.PermissionApprove, allowed: true},
+		{policy: prod, actor: "key:carol", permission: flags.PermissionWrite},
+		{policy: staging, actor: "key:carol", permission: flags.PermissionApprove},
+		{policy: prod, actor: "key:dave", permission: flags.PermissionWrite, allowed: true},
+		{policy: prod, actor: "key:dave", permission: flags.PermissionApprove, allowed: true},
+		{policy: staging, actor: "key:dave", permission: flags.PermissionWrite},
+		{policy: prod, actor: "key:dave", permission: flags.PermissionAdmin, allowed: true},
+		{policy: prod, actor: "key:alice", permission: flags.PermissionAdmin},
+	}
+
+	for _, tt := range tests {
+		ctx := flags.WithActor(context.Background(), tt.actor)
+		err := tt.policy.Authorize(ctx, tt.permission, "checkout")
+
+		if tt.allowed {
+			require.NoError(t, err, "%s %s", tt.actor, tt.permission)
+		} else {
+			require.ErrorIs(t, err, flags.ErrForbidden, "%s %s", tt.actor, tt.permission)
+		}
+	}
+}
+
+func TestPolicy_ClaimedRoles(t *testing.T) {
+	t.Parallel()
+
+	prod, err := rbac.NewPolicy("prod", nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		roles      []string
+		permission flags.Permission
+		allowed    bool
+	}{
+		{roles: []string{"editor"}, permission: flags.PermissionWrite, allowed: true},
+		{roles: []string{"prod:approver"}, permission: flags.PermissionApprove, allowed: true},
+		{roles: []string{"staging:admin"}, permission: flags.PermissionRead},
+		{roles: []string{"owner", "prod:root"}, permission: flags.PermissionRead},
+		{roles: []string{"viewer"}, permission: flags.PermissionWrite},
+		{roles: nil, permission: flags.PermissionRead},
+	}
+
+	for _, tt := range tests {
+		ctx := flags.WithActor(context.Background(), "user:alice")
+		ctx = auth.WithPrincipal(ctx, auth.Principal{Name: "user:alice", Scope: auth.ScopeAdmin, Roles: tt.roles})
+		err := prod.Authorize(ctx, tt.permission, "")
+
+		if tt.allowed {
+			require.NoError(t, err, "%v %s", tt.roles, tt.permission)
+		} else {
+			require.ErrorIs(t, err, flags.ErrForbidden, "%v %s", tt.roles, tt.permission)
+		}
+	}
+}
+
+func TestPolicy_DenyMessage(t *testing.T) {
+	t.Parallel()
+
+	prod, err := rbac.NewPolicy("prod", nil)
+	require.NoError(t, err)
+
+	err = prod.Authorize(flags.WithActor(context.Background(), "key:bob"), flags.PermissionWrite, "")
+	require.EqualError(t, err, "forbidden: key:bob lacks the write permission in prod")
+
+	err = prod.Authorize(flags.WithActor(context.Background(), "key:bob"), flags.PermissionWrite, "checkout")
+	require.EqualError(t, err, "forbidden: key:bob lacks the write permission on checkout in prod")
+
+	anywhere, err := rbac.NewPolicy("", nil)
+	require.NoError(t, err)
+
+	err = anywhere.Authorize(context.Background(), flags.PermissionRead, "")
+	assert.EqualError(t, err, "forbidden: anonymous lacks the read permission")
+}
+
+func TestPolicy_Roles(t *testing.T) {
+	t.Parallel()
+
+	policy, err := rbac.NewPolicy("prod", []rbac.Binding{
+		{Principal: rbac.Everyone, Role: rbac.RoleViewer},
+		{Principal: "key:alice", Role: rbac.RoleViewer, Environment: "prod"},
+		{Principal: "key:alice", Role: rbac.RoleApprover},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []rbac.Role{rbac.RoleViewer, rbac.RoleApprover}, policy.Roles("key:alice", "checkout"))
+	assert.Equal(t, []rbac.Role{rbac.RoleViewer}, policy.Roles("key:bob", "checkout"))
+}
+
+func TestPolicy_Projects(t *testing.T) {
+	t.Parallel()
+
+	policy, err := rbac.NewPolicy("prod", []rbac.Binding{
+		{Principal: rbac.Everyone, Role: rbac.RoleViewer},
+		{Principal: "key:alice", Role: rbac.RoleEditor, Project: "checkout"},
+		{Principal: "key:bob", Role: rbac.RoleEditor, Project: "search", Environment: "staging"},
+	}, rbac.Project{Name: "checkout", Flags: []string{"checkout", "checkout-*"}},
+		rbac.Project{Name: "search", Flags: []string{"search-*"}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		actor   string
+		key     flags.FlagKey
+		allowed bool
+	}{
+		{actor: "key:alice", key: "checkout", allowed: true},
+		{actor: "key:alice", key: "checkout-v2", allowed: true},
+		{actor: "key:alice", key: "search-ranking"},
+		{actor: "key:alice", key: ""},
+		{actor: "key:bob", key: "search-ranking"},
+	}
+
+	for _, tt := range tests {
+		err := policy.Authorize(flags.WithActor(context.Background(), tt.actor), flags.PermissionWrite, tt.key)
+
+		if tt.allowed {
+			require.NoError(t, err, "%s %s", tt.actor, tt.key)
+		} else {
+			require.ErrorIs(t, err, flags.ErrForbidden, "%s %s", tt.actor, tt.key)
+		}
+	}
+
+	assert.Equal(t, []rbac.Role{rbac.RoleViewer}, policy.Roles("key:alice", ""))
+	assert.Equal(t, []rbac.Role{rbac.RoleViewer, rbac.RoleEditor}, policy.Roles("key:alice", "checkout"))
+}
+
+func TestNewPolicy_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := rbac.NewPolicy("prod", []rbac.Binding{{Role: rbac.RoleViewer}})
+	require.ErrorIs(t, err, rbac.ErrInvalidPolicy)
+
+	_, err = rbac.NewPolicy("prod", []rbac.Binding{{Principal: "key:alice", Role: "owner"}})
+	require.ErrorIs(t, err, rbac.ErrInvalidPolicy)
+	require.ErrorContains(t, err, `unknown role "owner"`)
+
+	_, err = rbac.NewPolicy("prod", []rbac.Binding{{Principal: "key:alice", Role: rbac.RoleEditor, Project: "search"}})
+	require.ErrorIs(t, err, rbac.ErrInvalidPolicy)
+	require.ErrorContains(t, err, `unknown project "search"`)
+
+	_, err = rbac.NewPolicy("prod", nil, rbac.Project{Flags: []string{"search-*"}})
+	require.ErrorIs(t, err, rbac.ErrInvalidPolicy)
+
+	_, err = rbac.NewPolicy("prod", nil, rbac.Project{Name: "search"}, rbac.Project{Name: "search"})
+	require.ErrorIs(t, err, rbac.ErrInvalidPolicy)
+	require.ErrorContains(t, err, "defined twice")
+
+	_, err = rbac.NewPolicy("prod", nil, rbac.Project{Name: "search", Flags: []string{"search-["}})
+	require.ErrorIs(t, err, rbac.ErrInvalidPolicy)
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "policy.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+projects:
+  - name: checkout
+    flags: ["checkout-*"]
+bindings:
+  - principal: "*"
+    role: viewer
+  - principal: key:alice
+    role: editor
+    environment: prod
+    project: checkout
+`), 0o600))
+
+	config, err := rbac.LoadConfig(yamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, rbac.Config{
+		Projects: []rbac.Project{{Name: "checkout", Flags: []string{"checkout-*"}}},
+		Bindings: []rbac.Binding{
+			{Principal: rbac.Everyone, Role: rbac.RoleViewer},
+			{Principal: "key:alice", Role: rbac.RoleEditor, Environment: "prod", Project: "checkout"},
+		},
+	}, config)
+
+	jsonPath := filepath.Join(dir, "policy.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte(`{"bindings": [{"principal": "key:bob", "role": "admin"}]}`), 0o600))
+
+	config, err = rbac.LoadConfig(jsonPath)
+	require.NoError(t, err)
+	assert.Equal(t, rbac.Config{Bindings: []rbac.Binding{{Principal: "key:bob", Role: rbac.RoleAdmin}}}, config)
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("bindings: {"), 0o600))
+
+	_, err = rbac.LoadConfig(invalid)
+	require.ErrorIs(t, err, rbac.ErrInvalidPolicy)
+
+	_, err = rbac.LoadConfig(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, err, "read policy")
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/serroba/features/internal/flags"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName names the tracer of the repository spans.
+const TracerName = "github.com/serroba/features/internal/tracing"
+
+// Repository starts a span for every call to the repository it wraps.
+type Repository struct {
+	repo   flags.Repository
+	tracer trace.Tracer
+}
+
+// NewRepository wraps repo. Spans of failed calls are marked as errors, as
+// flags.SpanError decides.
+func NewRepository(provider trace.TracerProvider, repo flags.Repository) *Repository {
+	return &Repository{repo: repo, tracer: provider.Tracer(TracerName)}
+}
+
+func (r *Repository) Get(ctx context.Context, key flags.FlagKey) (flags.Flag, error) {
+	ctx, span := r.tracer.Start(ctx, "repository.get", trace.WithAttributes(flags.AttrFlagKey.String(string(key))))
+	defer span.End()
+
+	flag, err := r.repo.Get(ctx, key)
+
+	return flag, flags.SpanError(span, err)
+}
+
+func (r *Repository) List(ctx context.Context) ([]flags.Flag, error) {
+	ctx, span := r.tracer.Start(ctx, "repository.list")
+	defer span.End()
+
+	all, err := r.repo.List(ctx)
+	if err == nil {
+		span.SetAttributes(attribute.Int("flag.count", len(all)))
+	}
+
+	return all, flags.SpanError(span, err)
+}
+
+func (r *Repository) Create(ctx context.Context, flag flags.Flag) error {
+	ctx, span := r.tracer.Start(ctx, "repository.create", trace.WithAttributes(flags.AttrFlagKey.String(string(flag.Key))))
+	defer span.End()
+
+	return flags.SpanError(span, r.repo.Create(ctx, flag))
+}
+
+func (r *Repository) Update(ctx context.Context, flag flags.Flag) error {
+	ctx, span := r.tracer.Start(ctx, "repository.update", trace.WithAttributes(flags.AttrFlagKey.String(string(flag.Key))))
+	defer span.End()
+
+	return flags.SpanError(span, r.repo.Update(ctx, flag))
+}
+
+func (r *Repository) Delete(ctx context.Context, key flags.FlagKey) error {
+	ctx, span := r.tracer.Start(ctx, "repository.delete", trace.WithAttributes(flags.AttrFlagKey.String(string(key))))
+	defer span.End()
+
+	return flags.SpanError(span, r.repo.Delete(ctx, key))
+}
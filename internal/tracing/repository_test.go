@@ -0,0 +1,80 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/serroba/features/internal/flags"
+	"github.com/serroba/features/internal/flags/flagstest"
+	"github.com/serroba/features/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRepository(t *testing.T) {
+	t.Parallel()
+
+	flagstest.RunRepositorySuite(t, func(*testing.T) flags.Repository {
+		return tracing.NewRepository(sdktrace.NewTracerProvider(), flags.NewMemoryRepository())
+	})
+}
+
+// brokenRepository fails every call.
+type brokenRepository struct {
+	flags.Repository
+}
+
+var errBroken = errors.New("disk full")
+
+func (brokenRepository) Get(context.Context, flags.FlagKey) (flags.Flag, error) {
+	return flags.Flag{}, errBroken
+}
+
+func TestRepository_Spans(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	repo := tracing.NewRepository(provider, flags.NewMemoryRepository())
+	svc := flags.NewService(repo, flags.WithTracerProvider(provider))
+	ctx := context.Background()
+
+	require.NoError(t, repo.Create(ctx, flagstest.SampleFlag("checkout")))
+
+	_, err := svc.Evaluate(ctx, "checkout", flags.EvalContext{})
+	require.NoError(t, err)
+
+	_, err = repo.List(ctx)
+	require.NoError(t, err)
+
+	_, err = tracing.NewRepository(provider, brokenRepository{}).Get(ctx, "checkout")
+	require.ErrorIs(t, err, errBroken)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 8)
+
+	names := make([]string, len(spans))
+	for i, span := range spans {
+		names[i] = span.Name()
+	}
+
+	assert.Equal(t, []string{
+		"repository.create", "repository.get", "flags.MatchRule", "flags.MatchRule", "flags.EvaluateRules",
+		"flags.Evaluate", "repository.list", "repository.get",
+	}, names)
+
+	get, evaluate := spans[1], spans[5]
+	assert.Equal(t, evaluate.SpanContext().SpanID(), get.Parent().SpanID(), "storage time is part of the evaluation")
+	assert.Contains(t, get.Attributes(), attribute.String("flag.key", "checkout"))
+	assert.Equal(t, codes.Unset, get.Status().Code)
+	assert.Contains(t, spans[6].Attributes(), attribute.Int("flag.count", 1))
+
+	failed := spans[7]
+	assert.Equal(t, codes.Error, failed.Status().Code)
+	assert.Equal(t, "disk full", failed.Status().Description)
+}
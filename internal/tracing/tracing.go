@@ -0,0 +1,79 @@
+// Package tracing configures OpenTelemetry tracing and traces calls to the
+// flag repository.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+)
+
+// Exporters accepted by NewProvider.
+const (
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+var ErrUnknownExporter = errors.New("unknown trace exporter")
+
+// Config describes where spans go. Sampling follows the standard
+// OTEL_TRACES_SAMPLER environment variables and records every trace by
+// default.
+type Config struct {
+	// Exporter is ExporterStdout or ExporterOTLP. The OTLP exporter sends
+	// spans over HTTP to the endpoint in the standard OTEL_EXPORTER_OTLP_*
+	// environment variables, localhost:4318 by default.
+	Exporter string
+	// Stdout receives the spans of the stdout exporter.
+	Stdout io.Writer
+	// ServiceName is reported as service.name on every span.
+	ServiceName string
+}
+
+// NewProvider returns a tracer provider that batches spans to the configured
+// exporter. Shut it down to flush the spans still buffered.
+func NewProvider(ctx context.Context, config Config) (*sdktrace.TracerProvider, error) {
+	exporter, err := newExporter(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(config.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("describe service: %w", err)
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	), nil
+}
+
+func newExporter(ctx context.Context, config Config) (sdktrace.SpanExporter, error) {
+	switch config.Exporter {
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(config.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("create stdout exporter: %w", err)
+		}
+
+		return exporter, nil
+	case ExporterOTLP:
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("create otlp exporter: %w", err)
+		}
+
+		return exporter, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExporter, config.Exporter)
+	}
+}
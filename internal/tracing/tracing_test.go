@@ -0,0 +1,46 @@
+package tracing_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/serroba/features/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProvider_Stdout(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+
+	provider, err := tracing.NewProvider(context.Background(), tracing.Config{
+		Exporter: tracing.ExporterStdout, Stdout: &out, ServiceName: "features",
+	})
+	require.NoError(t, err)
+
+	_, span := provider.Tracer("test").Start(context.Background(), "flags.Evaluate")
+	span.End()
+
+	require.NoError(t, provider.Shutdown(context.Background()))
+	assert.Contains(t, out.String(), `"Name":"flags.Evaluate"`)
+	assert.Contains(t, out.String(), `"Value":"features"`)
+}
+
+func TestNewProvider_OTLP(t *testing.T) {
+	t.Parallel()
+
+	provider, err := tracing.NewProvider(context.Background(), tracing.Config{
+		Exporter: tracing.ExporterOTLP, ServiceName: "features",
+	})
+	require.NoError(t, err)
+	require.NoError(t, provider.Shutdown(context.Background()))
+}
+
+func TestNewProvider_UnknownExporter(t *testing.T) {
+	t.Parallel()
+
+	_, err := tracing.NewProvider(context.Background(), tracing.Config{Exporter: "jaeger"})
+	require.ErrorIs(t, err, tracing.ErrUnknownExporter)
+}